@@ -0,0 +1,215 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/jphenow/sp/internal/daemon"
+	"github.com/jphenow/sp/internal/store"
+)
+
+var (
+	budgetTag    string
+	budgetSprite string
+	budgetWarn   int
+)
+
+// budgetCmd manages monthly running-hour budgets, which cap the keepalive
+// holds sp starts.
+var budgetCmd = &cobra.Command{
+	Use:   "budget",
+	Short: "Show and set monthly running-hour budgets",
+	Long: `Budgets cap how many hours sprites may run each calendar month, counted from
+the running time the daemon records (see sp usage). A budget can cover every
+sprite, the sprites with a tag, or one sprite.
+
+  sp budget                          # every budget and how much is used
+  sp budget set 200                  # all sprites together
+  sp budget set 40 --tag work --warn 75
+  sp budget set 10 --sprite gh-acme--api
+  sp budget rm --tag work
+
+Once a budget passes its warning threshold (80% unless --warn says
+otherwise), starting a hold prints a warning. Holds from sp keepalive, sp rc
+and the hold sp connect takes are shortened to fit the smallest remaining
+budget, and refused once one is exhausted. Running sprites are not stopped.`,
+	Args: cobra.NoArgs,
+	RunE: runBudgetList,
+}
+
+var budgetSetCmd = &cobra.Command{
+	Use:   "set <hours>",
+	Short: "Set a budget (global unless --tag or --sprite)",
+	Args:  cobra.ExactArgs(1),
+	RunE:  runBudgetSet,
+}
+
+var budgetRmCmd = &cobra.Command{
+	Use:   "rm",
+	Short: "Remove a budget (global unless --tag or --sprite)",
+	Args:  cobra.NoArgs,
+	RunE:  runBudgetRm,
+}
+
+func init() {
+	for _, c := range []*cobra.Command{budgetSetCmd, budgetRmCmd} {
+		c.Flags().StringVar(&budgetTag, "tag", "", "budget for the sprites with this tag")
+		c.Flags().StringVar(&budgetSprite, "sprite", "", "budget for this sprite (by name)")
+		c.MarkFlagsMutuallyExclusive("tag", "sprite")
+	}
+	budgetSetCmd.Flags().IntVar(&budgetWarn, "warn", store.DefaultBudgetWarnPercent, "warn once this percentage of the budget is used")
+
+	budgetCmd.AddCommand(budgetSetCmd, budgetRmCmd)
+	rootCmd.AddCommand(budgetCmd)
+}
+
+// budgetScope returns the scope and target chosen by --tag and --sprite.
+func budgetScope() (string, string) {
+	switch {
+	case budgetTag != "":
+		return store.BudgetTag, budgetTag
+	case budgetSprite != "":
+		return store.BudgetSprite, budgetSprite
+	default:
+		return store.BudgetGlobal, ""
+	}
+}
+
+func runBudgetList(cmd *cobra.Command, args []string) error {
+	dc, err := daemon.Connect()
+	if err != nil {
+		return fmt.Errorf("connecting to daemon: %w", err)
+	}
+	defer dc.Close()
+
+	statuses, err := dc.ListBudgets("")
+	if err != nil {
+		return fmt.Errorf("listing budgets: %w", err)
+	}
+	if len(statuses) == 0 {
+		fmt.Println("No budgets set. Add one with: sp budget set <hours>")
+		return nil
+	}
+
+	fmt.Printf("Budgets for %s:\n", time.Now().Format("January 2006"))
+	fmt.Printf("  %-32s  %8s  %8s  %6s  %s\n", "BUDGET", "USED", "HOURS", "USED%", "STATE")
+	for _, s := range statuses {
+		state := "ok"
+		if s.Exhausted() {
+			state = "exhausted"
+		} else if s.Warning() {
+			state = fmt.Sprintf("warning (≥%d%%)", s.Budget.WarnPercent)
+		}
+		fmt.Printf("  %-32s  %8.1f  %8g  %5.0f%%  %s\n",
+			s.Budget.Label(), s.Used.Hours(), s.Budget.Hours, s.Percent(), state)
+	}
+	return nil
+}
+
+func runBudgetSet(cmd *cobra.Command, args []string) error {
+	hours, err := strconv.ParseFloat(args[0], 64)
+	if err != nil || hours <= 0 {
+		return fmt.Errorf("invalid hours %q: want a positive number", args[0])
+	}
+	if budgetWarn <= 0 || budgetWarn > 100 {
+		return fmt.Errorf("invalid --warn %d: want a percentage between 1 and 100", budgetWarn)
+	}
+
+	dc, err := daemon.Connect()
+	if err != nil {
+		return fmt.Errorf("connecting to daemon: %w", err)
+	}
+	defer dc.Close()
+
+	scope, target := budgetScope()
+	b := &store.Budget{Scope: scope, Target: target, Hours: hours, WarnPercent: budgetWarn}
+	if err := dc.SetBudget(b); err != nil {
+		return fmt.Errorf("setting budget: %w", err)
+	}
+	fmt.Printf("Set %s budget to %gh a month (warning at %d%%).\n", b.Label(), hours, budgetWarn)
+	return nil
+}
+
+func runBudgetRm(cmd *cobra.Command, args []string) error {
+	dc, err := daemon.Connect()
+	if err != nil {
+		return fmt.Errorf("connecting to daemon: %w", err)
+	}
+	defer dc.Close()
+
+	scope, target := budgetScope()
+	b := &store.Budget{Scope: scope, Target: target}
+	if err := dc.DeleteBudget(scope, target); err != nil {
+		return fmt.Errorf("removing %s budget: %w", b.Label(), err)
+	}
+	fmt.Printf("Removed %s budget.\n", b.Label())
+	return nil
+}
+
+// budgetedHold fits a hold of dur (0 = no deadline) on a sprite into the
+// budgets that apply to it, printing any warnings. It returns the duration
+// to hold for, or an error if a budget is exhausted. If the budgets can't be
+// read at all the hold goes ahead as asked, with a warning saying so.
+func budgetedHold(spriteName string, dur time.Duration) (time.Duration, error) {
+	statuses, err := budgetStatuses(spriteName)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: budgets not checked, so the hold isn't capped: %v\n", err)
+		return dur, nil
+	}
+	held, warnings, err := applyBudgets(statuses, dur)
+	for _, w := range warnings {
+		fmt.Fprintf(os.Stderr, "Warning: %s\n", w)
+	}
+	return held, err
+}
+
+// budgetStatuses returns the budgets that apply to a sprite, from the
+// daemon or, if it can't be reached, straight from the database.
+func budgetStatuses(spriteName string) ([]*store.BudgetStatus, error) {
+	if dc, err := daemon.Connect(); err == nil {
+		defer dc.Close()
+		if statuses, err := dc.ListBudgets(spriteName); err == nil {
+			return statuses, nil
+		}
+	}
+	db, err := store.Open()
+	if err != nil {
+		return nil, fmt.Errorf("opening database: %w", err)
+	}
+	defer db.Close()
+	return db.BudgetStatuses(spriteName, time.Now())
+}
+
+// applyBudgets works out how long a hold of dur (0 = no deadline) may last
+// under the given budgets, and what to warn about. Less than a minute left
+// counts as exhausted, since a zero-length hold would mean no deadline.
+func applyBudgets(statuses []*store.BudgetStatus, dur time.Duration) (time.Duration, []string, error) {
+	var warnings []string
+	var tightest *store.BudgetStatus
+	for _, s := range statuses {
+		if s.Remaining() < time.Minute {
+			return 0, nil, fmt.Errorf("%s budget exhausted: %.1fh of %gh used this month (see sp budget)",
+				s.Budget.Label(), s.Used.Hours(), s.Budget.Hours)
+		}
+		if s.Warning() {
+			warnings = append(warnings, fmt.Sprintf("%s budget is %.0f%% used (%.1fh of %gh this month)",
+				s.Budget.Label(), s.Percent(), s.Used.Hours(), s.Budget.Hours))
+		}
+		if tightest == nil || s.Remaining() < tightest.Remaining() {
+			tightest = s
+		}
+	}
+
+	if tightest != nil {
+		left := tightest.Remaining().Truncate(time.Minute)
+		if dur == 0 || dur > left {
+			warnings = append(warnings, fmt.Sprintf("holding for %s, all that's left of the %s budget", left, tightest.Budget.Label()))
+			dur = left
+		}
+	}
+	return dur, warnings, nil
+}
@@ -0,0 +1,52 @@
+package cmd
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jphenow/sp/internal/store"
+)
+
+func TestApplyBudgets(t *testing.T) {
+	status := func(scope string, hours float64, used time.Duration) *store.BudgetStatus {
+		return &store.BudgetStatus{
+			Budget: &store.Budget{Scope: scope, Target: "work", Hours: hours, WarnPercent: 80},
+			Used:   used,
+		}
+	}
+
+	// No budgets: the hold goes ahead as asked.
+	if held, warnings, err := applyBudgets(nil, time.Hour); held != time.Hour || warnings != nil || err != nil {
+		t.Errorf("no budgets = %v, %v, %v", held, warnings, err)
+	}
+
+	// Plenty left: unchanged, no warnings.
+	plenty := []*store.BudgetStatus{status(store.BudgetGlobal, 100, 10*time.Hour)}
+	if held, warnings, err := applyBudgets(plenty, 8*time.Hour); held != 8*time.Hour || len(warnings) != 0 || err != nil {
+		t.Errorf("plenty = %v, %v, %v", held, warnings, err)
+	}
+
+	// The tightest budget shortens the hold, and a budget past its threshold warns.
+	tight := append(plenty, status(store.BudgetTag, 10, 8*time.Hour+30*time.Second))
+	held, warnings, err := applyBudgets(tight, 8*time.Hour)
+	if err != nil || held != time.Hour+59*time.Minute {
+		t.Errorf("tight = %v, %v; want 1h59m", held, err)
+	}
+	if len(warnings) != 2 || !strings.Contains(warnings[0], "tag work budget is 80% used") {
+		t.Errorf("warnings = %q", warnings)
+	}
+
+	// A hold with no deadline gets one.
+	if held, _, _ := applyBudgets(tight, 0); held != time.Hour+59*time.Minute {
+		t.Errorf("open-ended hold = %v, want 1h59m", held)
+	}
+
+	// Exhausted, or nearly: refused.
+	for _, used := range []time.Duration{10 * time.Hour, 12 * time.Hour, 10*time.Hour - 30*time.Second} {
+		spent := append(plenty, status(store.BudgetSprite, 10, used))
+		if _, _, err := applyBudgets(spent, time.Hour); err == nil || !strings.Contains(err.Error(), "sprite work budget exhausted") {
+			t.Errorf("used %v: err = %v, want exhausted", used, err)
+		}
+	}
+}
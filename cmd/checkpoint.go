@@ -0,0 +1,269 @@
+package cmd
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/jphenow/sp/internal/daemon"
+	"github.com/jphenow/sp/internal/sprite"
+	"github.com/jphenow/sp/internal/store"
+)
+
+var (
+	checkpointLabel string
+	checkpointYes   bool
+)
+
+// checkpointCmd groups snapshot/rollback of a sprite's filesystem, for
+// trying something risky (a big refactor, an agent run) with a way back.
+var checkpointCmd = &cobra.Command{
+	Use:   "checkpoint",
+	Short: "Snapshot a sprite and roll it back later",
+	Long: `Checkpoints snapshot a sprite's whole filesystem so it can be rolled back.
+
+  sp checkpoint create . --label before-refactor
+  sp checkpoint list .
+  sp checkpoint restore before-refactor .   # by label or checkpoint ID (v3)
+
+Targets resolve like sp connect, including variants (sp checkpoint create
+owner/repo blue). Labels are kept in sp's local database.
+
+Restoring pauses the daemon's file sync for the sprite first and restarts it
+with a fresh Mutagen session afterwards, so the rolled-back remote isn't
+overwritten by (or merged into) the local tree mid-restore. Local changes
+made since the checkpoint surface as sync conflicts rather than being lost.`,
+}
+
+var checkpointCreateCmd = &cobra.Command{
+	Use:   "create [target] [variant]",
+	Short: "Checkpoint a sprite",
+	Args:  cobra.RangeArgs(0, 2),
+	RunE:  runCheckpointCreate,
+}
+
+var checkpointListCmd = &cobra.Command{
+	Use:     "list [target] [variant]",
+	Aliases: []string{"ls"},
+	Short:   "List a sprite's checkpoints",
+	Args:    cobra.RangeArgs(0, 2),
+	RunE:    runCheckpointList,
+}
+
+var checkpointRestoreCmd = &cobra.Command{
+	Use:   "restore <id-or-label> [target] [variant]",
+	Short: "Roll a sprite back to a checkpoint",
+	Args:  cobra.RangeArgs(1, 3),
+	RunE:  runCheckpointRestore,
+}
+
+func init() {
+	checkpointCreateCmd.Flags().StringVarP(&checkpointLabel, "label", "l", "", "label to find the checkpoint by later")
+	checkpointRestoreCmd.Flags().BoolVar(&checkpointYes, "yes", false, "skip the confirmation prompt")
+
+	checkpointCmd.AddCommand(checkpointCreateCmd, checkpointListCmd, checkpointRestoreCmd)
+	rootCmd.AddCommand(checkpointCmd)
+}
+
+// resolveCheckpointTarget resolves the target args and checks the sprite
+// exists.
+func resolveCheckpointTarget(args []string) (string, *sprite.Client, error) {
+	resolved, err := resolveTarget(args)
+	if err != nil {
+		return "", nil, fmt.Errorf("resolving target: %w", err)
+	}
+	client := sprite.NewClient(resolved.Org)
+	exists, err := client.Exists(resolved.SpriteName)
+	if err != nil {
+		return "", nil, fmt.Errorf("checking sprite: %w", err)
+	}
+	if !exists {
+		return "", nil, fmt.Errorf("sprite %q does not exist", resolved.SpriteName)
+	}
+	return resolved.SpriteName, client, nil
+}
+
+// runCheckpointCreate checkpoints the sprite and records the label.
+func runCheckpointCreate(cmd *cobra.Command, args []string) error {
+	name, client, err := resolveCheckpointTarget(args)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Checkpointing sprite %s...\n", name)
+	cp, err := client.CreateCheckpoint(name, checkpointLabel)
+	if err != nil {
+		return err
+	}
+
+	// Labels live in the local store, which only knows tracked sprites; an
+	// untracked sprite still gets its checkpoint, just not the label lookup.
+	dc, err := daemon.Connect()
+	if err == nil {
+		defer dc.Close()
+		err = dc.AddCheckpoint(&store.Checkpoint{
+			SpriteName:   name,
+			CheckpointID: cp.ID,
+			Label:        checkpointLabel,
+			CreatedAt:    cp.CreateTime,
+		})
+	}
+	if err != nil && checkpointLabel != "" {
+		fmt.Fprintf(os.Stderr, "Warning: label not recorded (is the sprite tracked? try sp import): %v\n", err)
+	}
+
+	if checkpointLabel != "" {
+		fmt.Printf("Created checkpoint %s (%s).\n", cp.ID, checkpointLabel)
+	} else {
+		fmt.Printf("Created checkpoint %s.\n", cp.ID)
+	}
+	return nil
+}
+
+// runCheckpointList prints the sprite's checkpoints with their local labels.
+func runCheckpointList(cmd *cobra.Command, args []string) error {
+	name, client, err := resolveCheckpointTarget(args)
+	if err != nil {
+		return err
+	}
+	checkpoints, err := client.ListCheckpoints(name)
+	if err != nil {
+		return err
+	}
+	if len(checkpoints) == 0 {
+		fmt.Printf("No checkpoints for sprite %s.\n", name)
+		return nil
+	}
+	labels := checkpointLabels(name)
+
+	fmt.Printf("%-8s %-20s %-24s %s\n", "ID", "CREATED", "LABEL", "COMMENT")
+	fmt.Println(strings.Repeat("-", 80))
+	for _, cp := range checkpoints {
+		label := labels[cp.ID]
+		if label == "" {
+			label = "-"
+		}
+		fmt.Printf("%-8s %-20s %-24s %s\n", cp.ID, cp.CreateTime.Local().Format("2006-01-02 15:04"), label, cp.Comment)
+	}
+	return nil
+}
+
+// checkpointLabels returns checkpoint ID → label from the local store.
+// Best-effort: an unreachable daemon just means no labels.
+func checkpointLabels(spriteName string) map[string]string {
+	labels := make(map[string]string)
+	dc, err := daemon.Connect()
+	if err != nil {
+		return labels
+	}
+	defer dc.Close()
+	recorded, err := dc.ListCheckpoints(spriteName)
+	if err != nil {
+		return labels
+	}
+	for _, c := range recorded {
+		labels[c.CheckpointID] = c.Label
+	}
+	return labels
+}
+
+// runCheckpointRestore resolves the ID or label, confirms, and restores with
+// the daemon's sync paused around it.
+func runCheckpointRestore(cmd *cobra.Command, args []string) error {
+	ref := args[0]
+	name, client, err := resolveCheckpointTarget(args[1:])
+	if err != nil {
+		return err
+	}
+
+	checkpoints, err := client.ListCheckpoints(name)
+	if err != nil {
+		return err
+	}
+	var target *sprite.Checkpoint
+	for i := range checkpoints {
+		if checkpoints[i].ID == ref {
+			target = &checkpoints[i]
+			break
+		}
+	}
+
+	dc, err := daemon.Connect()
+	if err != nil {
+		return fmt.Errorf("connecting to daemon: %w", err)
+	}
+	defer dc.Close()
+
+	if target == nil {
+		// A reused label picks the most recent checkpoint with it.
+		labelled, err := dc.FindCheckpoint(name, ref)
+		if err != nil {
+			return fmt.Errorf("looking up checkpoint labels: %w", err)
+		}
+		for i := range checkpoints {
+			if labelled != nil && checkpoints[i].ID == labelled.CheckpointID {
+				target = &checkpoints[i]
+				break
+			}
+		}
+	}
+	if target == nil {
+		return fmt.Errorf("no checkpoint %q on sprite %s (see sp checkpoint list)", ref, name)
+	}
+
+	if !checkpointYes && isTerminal() {
+		fmt.Printf("Restore sprite %s to %s from %s? Everything written since is lost. [y/N] ",
+			name, target.ID, target.CreateTime.Local().Format(time.DateTime))
+		reader := bufio.NewReader(os.Stdin)
+		line, _ := reader.ReadString('\n')
+		if !strings.EqualFold(strings.TrimSpace(line), "y") {
+			fmt.Println("Aborted.")
+			return nil
+		}
+	}
+
+	s, err := dc.GetSprite(name)
+	if err != nil {
+		return fmt.Errorf("looking up sprite: %w", err)
+	}
+	// Sync stays paused in the daemon until resumed, so resume it on every
+	// way out, Ctrl-C included; an interrupted restore would otherwise leave
+	// the sprite without sync or automatic recovery.
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	if s != nil {
+		fmt.Println("Pausing file sync...")
+		// Deferred first: a pause that errors may still have been stored.
+		defer func() {
+			if err := dc.ResumeSync(name); err != nil {
+				fmt.Fprintf(os.Stderr, "Warning: resuming sync failed (run sp resync): %v\n", err)
+			} else {
+				fmt.Println("File sync restarting in the background.")
+			}
+		}()
+		if err := dc.PauseSync(name); err != nil {
+			return fmt.Errorf("pausing sync: %w", err)
+		}
+	}
+
+	fmt.Printf("Restoring sprite %s to %s...\n", name, target.ID)
+	restored := make(chan error, 1)
+	go func() { restored <- client.RestoreCheckpoint(name, target.ID) }()
+	select {
+	case err := <-restored:
+		if err != nil {
+			return err
+		}
+	case <-ctx.Done():
+		return fmt.Errorf("interrupted; sprite %s may be partly restored (see sp checkpoint list)", name)
+	}
+	fmt.Printf("Restored sprite %s to %s.\n", name, target.ID)
+	return nil
+}
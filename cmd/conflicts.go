@@ -0,0 +1,247 @@
+package cmd
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/jphenow/sp/internal/daemon"
+	spSync "github.com/jphenow/sp/internal/sync"
+)
+
+var (
+	conflictsList bool
+	conflictsKeep string
+)
+
+// conflictsCmd lists a sprite's sync conflicts and resolves them file by file.
+var conflictsCmd = &cobra.Command{
+	Use:   "conflicts [target]",
+	Short: "List and resolve a sprite's sync conflicts",
+	Long: `Lists the files two-way sync is holding back because they changed both
+locally and on the sprite, with what each side has now, then goes through
+them one at a time: it shows a diff of the two copies (fetching the
+sprite's over the daemon's SSH proxy) and asks which to keep.
+
+  l  keep the local file, copying it to the sprite
+  r  keep the sprite's file, copying it over the local one
+  m  merge: edit both versions, between conflict markers, in $EDITOR,
+     and keep the result on both sides
+  s  skip the file for now
+  q  stop
+
+Keeping a side that has no file deletes it from the other. Each choice
+flushes the sync session, so the file syncs normally again afterwards.
+
+  sp conflicts .                # resolve interactively
+  sp conflicts . --list         # only list them
+  sp conflicts . --keep local   # keep every local file, without asking
+
+Target defaults to "." (current directory).`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: runConflicts,
+}
+
+func runConflicts(cmd *cobra.Command, args []string) error {
+	keep := spSync.Resolution(conflictsKeep)
+	if keep != "" && keep != spSync.KeepLocal && keep != spSync.KeepRemote {
+		return fmt.Errorf("unknown --keep %q (want local or remote)", conflictsKeep)
+	}
+
+	resolved, err := resolveTarget(args)
+	if err != nil {
+		return fmt.Errorf("resolving target: %w", err)
+	}
+	dc, err := daemon.Connect()
+	if err != nil {
+		return fmt.Errorf("connecting to daemon: %w", err)
+	}
+	defer dc.Close()
+
+	name := resolved.SpriteName
+	conflicts, err := dc.ListConflicts(name)
+	if err != nil {
+		return fmt.Errorf("listing conflicts: %w", err)
+	}
+	if len(conflicts) == 0 {
+		fmt.Printf("No sync conflicts for %s.\n", name)
+		return nil
+	}
+
+	fmt.Printf("Conflicts (%d):\n", len(conflicts))
+	fmt.Printf("  %-40s %-32s %s\n", "PATH", "LOCAL", "REMOTE")
+	fmt.Println("  " + strings.Repeat("-", 100))
+	for _, c := range conflicts {
+		fmt.Printf("  %-40s %-32s %s\n", c.Path, c.Local.Summary(c.LocalChange), c.Remote.Summary(c.RemoteChange))
+	}
+
+	switch {
+	case keep != "":
+		var failed []string
+		for _, c := range conflicts {
+			if err := dc.ResolveConflict(name, c.Path, keep, nil); err != nil {
+				fmt.Fprintf(os.Stderr, "  %s: %v\n", c.Path, err)
+				failed = append(failed, c.Path)
+				continue
+			}
+			fmt.Printf("  kept the %s %s\n", keep, c.Path)
+		}
+		if len(failed) > 0 {
+			return fmt.Errorf("%d conflict(s) not resolved: %s", len(failed), strings.Join(failed, ", "))
+		}
+		return nil
+	case conflictsList:
+		return nil
+	case !isTerminal():
+		fmt.Println("\nRun sp conflicts in a terminal to resolve them, or pass --keep local|remote.")
+		return nil
+	}
+
+	reader := bufio.NewReader(os.Stdin)
+	resolvedCount := 0
+	for i, c := range conflicts {
+		fmt.Printf("\n[%d/%d] %s\n", i+1, len(conflicts), c.Path)
+		done, quit, err := resolveConflictInteractively(dc, reader, name, c)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "  %s: %v\n", c.Path, err)
+		}
+		if done {
+			resolvedCount++
+		}
+		if quit {
+			break
+		}
+	}
+	fmt.Printf("\nResolved %d of %d conflict(s).\n", resolvedCount, len(conflicts))
+	return nil
+}
+
+// resolveConflictInteractively shows one conflict's diff and applies the
+// user's choice. It reports whether the conflict was resolved and whether
+// the user asked to stop.
+func resolveConflictInteractively(dc *daemon.Client, reader *bufio.Reader, name string, c spSync.ConflictDetail) (done, quit bool, err error) {
+	contents, readErr := dc.ReadConflict(name, c.Path)
+	canMerge := false
+	switch {
+	case readErr != nil:
+		fmt.Printf("  Can't compare: %v\n", readErr)
+	case spSync.IsBinary(contents.LocalData) || spSync.IsBinary(contents.RemoteData):
+		fmt.Printf("  Binary files differ (local %s, remote %s)\n",
+			contents.Local.Summary(c.LocalChange), contents.Remote.Summary(c.RemoteChange))
+	case contents.Local.Kind != "file" && contents.Local.Exists(), contents.Remote.Kind != "file" && contents.Remote.Exists():
+		fmt.Printf("  Local: %s\n  Remote: %s\n",
+			contents.Local.Summary(c.LocalChange), contents.Remote.Summary(c.RemoteChange))
+	default:
+		fmt.Print(conflictDiff(contents))
+		canMerge = contents.Local.Exists() && contents.Remote.Exists()
+	}
+
+	prompt := "Keep [l]ocal, [r]emote, [m]erge, [s]kip or [q]uit? "
+	if !canMerge {
+		prompt = "Keep [l]ocal, [r]emote, [s]kip or [q]uit? "
+	}
+	for {
+		fmt.Print(prompt)
+		line, readErr := reader.ReadString('\n')
+		answer := strings.ToLower(strings.TrimSpace(line))
+		if readErr != nil && answer == "" {
+			return false, true, nil // EOF
+		}
+
+		var keep spSync.Resolution
+		var merged []byte
+		switch answer {
+		case "l", "local":
+			keep = spSync.KeepLocal
+		case "r", "remote":
+			keep = spSync.KeepRemote
+		case "m", "merge":
+			if !canMerge {
+				continue
+			}
+			merged, err = editMerge(c.Path, contents)
+			if err != nil {
+				return false, false, err
+			}
+			if bytes.Contains(merged, []byte("<<<<<<< ")) {
+				fmt.Print("  The file still has conflict markers. Keep it anyway? [y/N] ")
+				line, _ := reader.ReadString('\n')
+				if !strings.EqualFold(strings.TrimSpace(line), "y") {
+					continue
+				}
+			}
+			keep = spSync.KeepMerged
+		case "s", "skip":
+			return false, false, nil
+		case "q", "quit":
+			return false, true, nil
+		default:
+			continue
+		}
+		if err := dc.ResolveConflict(name, c.Path, keep, merged); err != nil {
+			return false, false, err
+		}
+		fmt.Printf("  Kept the %s %s.\n", keep, c.Path)
+		return true, false, nil
+	}
+}
+
+// conflictDiff is the diff from the local to the remote copy of a file.
+// A side without the file diffs as /dev/null, as git shows it.
+func conflictDiff(c *spSync.ConflictContents) string {
+	localName, remoteName := "local/"+c.Path, "remote/"+c.Path
+	if !c.Local.Exists() {
+		localName = "/dev/null"
+	}
+	if !c.Remote.Exists() {
+		remoteName = "/dev/null"
+	}
+	diff := spSync.UnifiedDiff(localName, remoteName, c.LocalData, c.RemoteData)
+	if diff == "" {
+		return "  The two copies are the same.\n"
+	}
+	return diff
+}
+
+// editMerge opens both versions of a file, between conflict markers, in
+// $EDITOR and returns what the user saved.
+func editMerge(path string, c *spSync.ConflictContents) ([]byte, error) {
+	// Keep the extension so the editor highlights the file's syntax.
+	f, err := os.CreateTemp("", "sp-merge-*-"+filepath.Base(path))
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(f.Name())
+	_, err = f.Write(spSync.MergeMarkers(c.LocalData, c.RemoteData, "local", "remote"))
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	editor := os.Getenv("EDITOR")
+	if editor == "" {
+		editor = "vi"
+	}
+	editorCmd := exec.Command(editor, f.Name())
+	editorCmd.Stdin = os.Stdin
+	editorCmd.Stdout = os.Stdout
+	editorCmd.Stderr = os.Stderr
+	if err := editorCmd.Run(); err != nil {
+		return nil, fmt.Errorf("running %s: %w", editor, err)
+	}
+	return os.ReadFile(f.Name())
+}
+
+func init() {
+	conflictsCmd.Flags().BoolVar(&conflictsList, "list", false, "only list the conflicts")
+	conflictsCmd.Flags().StringVar(&conflictsKeep, "keep", "", "resolve every conflict by keeping this side: local or remote")
+	rootCmd.AddCommand(conflictsCmd)
+}
@@ -2,12 +2,17 @@ package cmd
 
 import (
 	"archive/tar"
+	"bytes"
 	"compress/gzip"
+	"context"
+	"errors"
 	"fmt"
 	"io"
 	"os"
 	"os/exec"
+	"os/signal"
 	"path/filepath"
+	"strconv"
 	"strings"
 	"time"
 
@@ -16,6 +21,7 @@ import (
 
 	"github.com/jphenow/sp/internal/daemon"
 	"github.com/jphenow/sp/internal/progress"
+	"github.com/jphenow/sp/internal/retry"
 	"github.com/jphenow/sp/internal/setup"
 	"github.com/jphenow/sp/internal/sprite"
 	"github.com/jphenow/sp/internal/store"
@@ -123,6 +129,12 @@ func runConnect(cmd *cobra.Command, args []string) error {
 
 	fmt.Printf("Connecting to sprite: %s\n", resolved.SpriteName)
 
+	// Ctrl-C during setup cancels in-flight sprite commands instead of
+	// leaving them (and the spinner) hanging. Released before the console
+	// starts so the remote shell gets interrupts as usual.
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
 	// Get Claude token
 	tp := setup.NewTokenProvider()
 	token, err := tp.GetToken()
@@ -134,8 +146,15 @@ func runConnect(cmd *cobra.Command, args []string) error {
 	client := sprite.NewClient(resolved.Org)
 
 	// Check if sprite exists, create if needed
+	// Exists only returns an error when it can't tell — never create a
+	// sprite because the API was briefly unreachable.
 	exists, err := client.Exists(resolved.SpriteName)
-	if err != nil {
+	switch {
+	case errors.Is(err, sprite.ErrUnauthorized):
+		return fmt.Errorf("checking sprite: %w (run `sprite login`)", err)
+	case errors.Is(err, sprite.ErrNetwork), errors.Is(err, sprite.ErrRateLimited):
+		return fmt.Errorf("checking sprite: %w (try again shortly)", err)
+	case err != nil:
 		return fmt.Errorf("checking sprite: %w", err)
 	}
 
@@ -151,7 +170,7 @@ func runConnect(cmd *cobra.Command, args []string) error {
 				return fmt.Errorf("creating sprite: %w", err)
 			}
 		}
-		if err := waitForSpriteReady(client, resolved.SpriteName); err != nil {
+		if err := waitForSpriteReady(ctx, client, resolved.SpriteName); err != nil {
 			return err
 		}
 		return setup.FixSpriteHomePermissions(client, resolved.SpriteName)
@@ -242,7 +261,7 @@ func runConnect(cmd *cobra.Command, args []string) error {
 		// separate parallel task to avoid the race where clone fires
 		// before the key is deployed.
 		if resolved.Repo != "" && resolved.LocalPath == "" {
-			if err := cloneRepoOnSprite(client, resolved.SpriteName, resolved.Repo, resolved.RemotePath); err != nil {
+			if err := cloneRepoOnSprite(ctx, client, resolved.SpriteName, resolved.Repo, resolved.RemotePath); err != nil {
 				return fmt.Errorf("clone repo: %w", err)
 			}
 		}
@@ -267,10 +286,34 @@ func runConnect(cmd *cobra.Command, args []string) error {
 				return fmt.Errorf("parse setup.conf: %w", err)
 			}
 			if conf != nil {
-				setup.RunSetupConf(client, resolved.SpriteName, conf)
+				// Stream command output live in verbose mode; the spinner
+				// would be garbled by it otherwise.
+				var output io.Writer
+				if verbose {
+					output = os.Stderr
+				}
+				err := setup.RunSetupConf(ctx, client, resolved.SpriteName, conf, output)
+				event := &store.Event{SpriteName: resolved.SpriteName, Kind: store.EventSetup, To: "ok"}
+				if err != nil {
+					event.To, event.Message = "failed", err.Error()
+				}
+				recordEvent(event)
+				if ctx.Err() != nil {
+					return fmt.Errorf("setup.conf: %w", err)
+				}
+				if err != nil {
+					// A failed step shouldn't cost the connection.
+					for _, line := range strings.Split(err.Error(), "\n") {
+						fmt.Fprintf(os.Stderr, "Warning: setup.conf: %s\n", line)
+					}
+				}
 			}
 			if resolved.LocalPath != "" && !noSync {
-				if err := syncInitialFiles(client, resolved.SpriteName, resolved.LocalPath, resolved.RemotePath); err != nil {
+				upload := syncInitialFiles
+				if !spSync.MutagenInstalled() {
+					upload = copyInitialFiles
+				}
+				if err := upload(client, resolved.SpriteName, resolved.LocalPath, resolved.RemotePath); err != nil {
 					return fmt.Errorf("initial sync: %w", err)
 				}
 			}
@@ -337,7 +380,11 @@ func runConnect(cmd *cobra.Command, args []string) error {
 			}()
 		}
 	} else if !noSync && resolved.LocalPath != "" && resolved.Variant == "" {
-		fmt.Println("Daemon will manage file sync.")
+		if spSync.MutagenInstalled() {
+			fmt.Println("Daemon will manage file sync.")
+		} else {
+			fmt.Println("Mutagen is not installed, so files won't sync continuously; copy changes with 'sp resync --mode'.")
+		}
 	}
 
 	if resolved.Variant != "" {
@@ -383,13 +430,18 @@ func runConnect(cmd *cobra.Command, args []string) error {
 		// Idle-exit is wrong here (it assumes idle == done, but an idle prompt
 		// usually means you stepped away), so the hold releases on session end
 		// instead — or at holdCap, so an abandoned session can't bill forever.
-		if err := launchKeepAlive(resolved.SpriteName, resolved.Org, holdCap, "", deriveTmuxSessionName()); err != nil {
+		if held, err := launchKeepAlive(resolved.SpriteName, resolved.Org, holdCap, "", deriveTmuxSessionName()); err != nil {
 			fmt.Fprintf(os.Stderr, "Warning: starting session hold: %v\n", err)
 		} else if verbose {
-			fmt.Fprintf(os.Stderr, "Holding sprite Active until this session ends (cap %s)\n", holdCap)
+			fmt.Fprintf(os.Stderr, "Holding sprite Active until this session ends (cap %s)\n", held)
 		}
 	}
 
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	stop()
+
 	// Connect to sprite shell. Pass authTokenForEnv (empty when we pushed
 	// a credentials.json) so execInSprite knows whether to inject the
 	// CLAUDE_CODE_OAUTH_TOKEN env var / tmux setenv. Injecting it
@@ -408,6 +460,14 @@ const defaultProxyPort = 9000
 // sprite exec / sprite-env don't source .bashrc, so we can't rely on PATH.
 const opencodeBin = "/home/sprite/.opencode/bin/opencode"
 
+// Names of the sprite-env services behind --web, and how long sprite-env
+// waits for them to stay up before create succeeds.
+const (
+	webServiceDirect  = "opencode"
+	webServiceProxy   = "sp-web"
+	webServiceStartup = 10 * time.Second
+)
+
 // setupWebService configures a sprite-env service for the opencode web UI with
 // auto-wake on HTTP access. There are two modes:
 //
@@ -431,14 +491,9 @@ func setupWebService(client *sprite.Client, spriteName string) error {
 	}
 
 	// Delete any existing opencode/sp-web service to avoid conflicts
-	client.Exec(sprite.ExecOptions{
-		Sprite:  spriteName,
-		Command: []string{"sprite-env", "services", "delete", "opencode"},
-	})
-	client.Exec(sprite.ExecOptions{
-		Sprite:  spriteName,
-		Command: []string{"sprite-env", "services", "delete", "sp-web"},
-	})
+	ctx := context.Background()
+	client.DeleteService(ctx, spriteName, webServiceDirect)
+	client.DeleteService(ctx, spriteName, webServiceProxy)
 
 	if webProxy {
 		return setupWebServiceProxy(client, spriteName)
@@ -456,17 +511,15 @@ func setupWebServiceDirect(client *sprite.Client, spriteName string) error {
 	// Create the service with http-port for auto-wake.
 	// --hostname 0.0.0.0 is required because the sprite proxy connects from
 	// outside localhost; without it opencode binds to 127.0.0.1 only.
-	createCmd := fmt.Sprintf(
-		"sprite-env services create opencode --cmd %s --args web,--port,%d,--hostname,0.0.0.0 --http-port %d --duration 10s",
-		opencodeBin, port, port,
-	)
-
-	out, err := client.Exec(sprite.ExecOptions{
-		Sprite:  spriteName,
-		Command: []string{"sh", "-c", createCmd},
+	err := client.CreateService(context.Background(), spriteName, sprite.ServiceOptions{
+		Name:     webServiceDirect,
+		Cmd:      opencodeBin,
+		Args:     []string{"web", "--port", strconv.Itoa(port), "--hostname", "0.0.0.0"},
+		HTTPPort: port,
+		Duration: webServiceStartup,
 	})
 	if err != nil {
-		return fmt.Errorf("creating opencode service: %w\n%s", err, string(out))
+		return fmt.Errorf("creating opencode service: %w", err)
 	}
 	fmt.Printf("  opencode service created on port %d\n", port)
 
@@ -505,22 +558,20 @@ func setupWebServiceProxy(client *sprite.Client, spriteName string) error {
 	}
 
 	// Build the service args
-	args := fmt.Sprintf("serve,--opencode-port,%d,--proxy-port,%d", oc, pp)
+	args := []string{"serve", "--opencode-port", strconv.Itoa(oc), "--proxy-port", strconv.Itoa(pp)}
 	if webDevPort > 0 {
-		args += fmt.Sprintf(",--dev-port,%d", webDevPort)
+		args = append(args, "--dev-port", strconv.Itoa(webDevPort))
 	}
 
-	createCmd := fmt.Sprintf(
-		"sprite-env services create sp-web --cmd /usr/local/bin/sp --args %s --http-port %d --duration 10s",
-		args, pp,
-	)
-
-	out, err := client.Exec(sprite.ExecOptions{
-		Sprite:  spriteName,
-		Command: []string{"sh", "-c", createCmd},
+	err := client.CreateService(context.Background(), spriteName, sprite.ServiceOptions{
+		Name:     webServiceProxy,
+		Cmd:      "/usr/local/bin/sp",
+		Args:     args,
+		HTTPPort: pp,
+		Duration: webServiceStartup,
 	})
 	if err != nil {
-		return fmt.Errorf("creating sp-web service: %w\n%s", err, string(out))
+		return fmt.Errorf("creating sp-web service: %w", err)
 	}
 
 	fmt.Printf("  sp-web proxy service created on port %d\n", pp)
@@ -549,27 +600,38 @@ func startKeepWarmSentinel(spriteName, org string, dur time.Duration) error {
 	if dur <= 0 {
 		return nil
 	}
-	return launchKeepAlive(spriteName, org, dur, deriveTmuxSessionName(), "")
+	_, err := launchKeepAlive(spriteName, org, dur, deriveTmuxSessionName(), "")
+	return err
 }
 
-// waitForSpriteReady polls until the sprite responds to commands.
-func waitForSpriteReady(client *sprite.Client, name string) error {
-	for i := 0; i < 60; i++ {
-		_, err := client.Exec(sprite.ExecOptions{
+// readyCheckTimeout bounds a single readiness probe so one hung exec can't
+// eat the whole retry.SpriteReady budget.
+const readyCheckTimeout = 15 * time.Second
+
+// waitForSpriteReady polls until the sprite responds to commands, backing
+// off per retry.SpriteReady. The spinner provides progress indication, so
+// retries are silent. A missing sprite or rejected credentials fail fast.
+func waitForSpriteReady(ctx context.Context, client *sprite.Client, name string) error {
+	p := retry.SpriteReady
+	p.Retryable = func(err error) bool { return !sprite.IsPermanent(err) }
+	err := retry.Do(ctx, p, func(ctx context.Context) error {
+		_, err := client.ExecContext(ctx, sprite.ExecOptions{
 			Sprite:  name,
 			Command: []string{"echo", "ready"},
+			Timeout: readyCheckTimeout,
 		})
-		if err == nil {
-			return nil
-		}
-		// Previously printed a `.` per retry, but the spinner now provides
-		// progress indication so the dots would interleave with the live
-		// render. Verbose mode could re-add this if useful, but for now
-		// the spinner duration counter suffices.
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("sprite did not become ready within %s: %w", p.MaxElapsed, err)
 	}
-	return fmt.Errorf("sprite did not become ready within 60 seconds")
+	return nil
 }
 
+// cloneTimeout bounds the clone script; large repos with submodules can
+// legitimately take several minutes.
+const cloneTimeout = 15 * time.Minute
+
 // cloneRepoOnSprite runs `git clone` inside the sprite for the given GitHub
 // owner/repo. Uses the SSH URL so the deployed key from SetupSpriteAuth is
 // picked up (SetupGitConfig also rewrites HTTPS GitHub URLs to SSH as a
@@ -584,7 +646,7 @@ func waitForSpriteReady(client *sprite.Client, name string) error {
 //
 // All four states are handled in a single shell script so we only pay one
 // round trip to the sprite.
-func cloneRepoOnSprite(client *sprite.Client, spriteName, ownerRepo, remoteDir string) error {
+func cloneRepoOnSprite(ctx context.Context, client *sprite.Client, spriteName, ownerRepo, remoteDir string) error {
 	sshURL := fmt.Sprintf("git@github.com:%s.git", ownerRepo)
 	parent, _ := splitRemoteDir(remoteDir)
 	q := shellQuote
@@ -629,24 +691,36 @@ git clone --recurse-submodules "$URL" "$(basename "$DIR")" || exit 3
 		env["GH_TOKEN"] = ghToken
 	}
 
-	out, err := client.Exec(sprite.ExecOptions{
+	opts := sprite.ExecOptions{
 		Sprite:  spriteName,
 		Env:     env,
 		Command: []string{"sh", "-c", script},
-	})
-	// In verbose mode, surface the script's status output ("sp: cloning…",
-	// "sp: repo already present", etc.) so users can see what happened.
-	// In spinner mode the print would interleave with the live frame, so
-	// the spinner's task name + duration carries the signal instead.
+		Timeout: cloneTimeout,
+	}
+	// In verbose mode, stream the script's status output ("sp: cloning…",
+	// "sp: repo already present", git progress) live so users can see what
+	// is happening. In spinner mode the print would interleave with the live
+	// frame, so the spinner's task name + duration carries the signal and
+	// output is only shown on failure.
+	var buf bytes.Buffer
+	var w io.Writer = &buf
 	if verbose {
-		if trimmed := strings.TrimSpace(string(out)); trimmed != "" {
-			fmt.Fprintln(os.Stderr, trimmed)
-		}
+		w = io.MultiWriter(&buf, os.Stderr)
 	}
+	code, err := client.ExecStreamTo(ctx, opts, w, w)
 	if err != nil {
-		return fmt.Errorf("clone script: %w\n%s", err, string(out))
+		return fmt.Errorf("clone script: %w\n%s", err, buf.String())
+	}
+	switch code {
+	case 0:
+		return nil
+	case 2:
+		return fmt.Errorf("%s exists on the sprite and is not a git repository; move it aside and reconnect\n%s", remoteDir, buf.String())
+	case 3:
+		return fmt.Errorf("git clone of %s failed\n%s", ownerRepo, buf.String())
+	default:
+		return fmt.Errorf("clone script: exit status %d\n%s", code, buf.String())
 	}
-	return nil
 }
 
 // splitRemoteDir splits a remote dir into its parent and last segment.
@@ -759,6 +833,38 @@ func syncInitialFiles(client *sprite.Client, name, localDir, remoteDir string) e
 	return nil
 }
 
+// copyInitialFiles uploads the local directory with the built-in copy
+// engine, for machines without Mutagen. Unlike syncInitialFiles it honours
+// the same ignore rules as ongoing sync and fails on any file it can't copy.
+// The SSH proxy it needs lives only for the duration of the copy.
+func copyInitialFiles(client *sprite.Client, name, localDir, remoteDir string) error {
+	mgr := spSync.NewManager(client, spSync.NewCopyEngine())
+
+	if err := mgr.SetupSSHServer(name); err != nil {
+		return fmt.Errorf("SSH server setup: %w", err)
+	}
+	proxy, port, err := mgr.StartProxy(name)
+	if err != nil {
+		return fmt.Errorf("starting proxy: %w", err)
+	}
+	defer func() {
+		proxy.Process.Kill()
+		proxy.Wait()
+	}()
+	if err := spSync.AddSSHConfig(name, port); err != nil {
+		return fmt.Errorf("adding SSH config: %w", err)
+	}
+	defer spSync.RemoveSSHConfig(name)
+	if err := spSync.TestSSHConnection(name, port, nil); err != nil {
+		return fmt.Errorf("SSH connection test: %w", err)
+	}
+
+	if _, err := mgr.StartSession(name, localDir, remoteDir, "one-way-replica-to-remote"); err != nil {
+		return fmt.Errorf("copying files: %w", err)
+	}
+	return nil
+}
+
 // registerWithDaemon tells the daemon about this sprite for monitoring.
 // Fetches the sprite's API info to populate ID, URL, and status.
 func registerWithDaemon(resolved *setup.ResolvedTarget, client *sprite.Client) error {
@@ -807,7 +913,7 @@ func registerWithDaemon(resolved *setup.ResolvedTarget, client *sprite.Client) e
 // child of sp, so it dies when sp exits. Only suitable for interactive console
 // sessions where sp stays running.
 func startSyncInline(client *sprite.Client, resolved *setup.ResolvedTarget) error {
-	mgr := spSync.NewManager(client)
+	mgr := spSync.NewManager(client, syncEngine)
 
 	// Setup SSH server on sprite
 	if err := mgr.SetupSSHServer(resolved.SpriteName); err != nil {
@@ -831,7 +937,7 @@ func startSyncInline(client *sprite.Client, resolved *setup.ResolvedTarget) erro
 	}
 
 	// Start Mutagen sync
-	mutagenID, err := mgr.StartMutagenSession(resolved.SpriteName, resolved.LocalPath, resolved.RemotePath, "")
+	mutagenID, err := mgr.StartSession(resolved.SpriteName, resolved.LocalPath, resolved.RemotePath, "")
 	if err != nil {
 		return fmt.Errorf("starting Mutagen: %w", err)
 	}
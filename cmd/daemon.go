@@ -1,16 +1,19 @@
 package cmd
 
 import (
+	"cmp"
 	"context"
 	"fmt"
 	"os"
 	"os/exec"
+	"strings"
 	"time"
 
 	"github.com/spf13/cobra"
 
 	"github.com/jphenow/sp/internal/daemon"
 	"github.com/jphenow/sp/internal/logging"
+	"github.com/jphenow/sp/internal/sprite"
 	"github.com/jphenow/sp/internal/store"
 )
 
@@ -20,12 +23,27 @@ var daemonCmd = &cobra.Command{
 	Short: "Manage the sp background daemon",
 }
 
+var daemonHTTPAddr string
+
 // daemonStartCmd starts the daemon in the foreground (normally auto-started).
 var daemonStartCmd = &cobra.Command{
 	Use:   "start",
 	Short: "Start the daemon (normally auto-started)",
+	Long: `Starts the daemon in the foreground. sp starts it automatically, so this is
+mostly for debugging.
+
+With --http (or SP_DAEMON_HTTP set when the daemon starts) the daemon also
+serves an HTTP/JSON API on that loopback address for editor plugins and
+scripts; see 'sp daemon token'.`,
 	RunE: func(cmd *cobra.Command, args []string) error {
-		config := daemon.DefaultConfig()
+		if daemonHTTPAddr != "" {
+			// Through the environment, so a re-exec after an upgrade keeps it.
+			os.Setenv("SP_DAEMON_HTTP", daemonHTTPAddr)
+		}
+		config, err := daemon.LoadConfig(daemon.ConfigPath())
+		if err != nil {
+			return fmt.Errorf("loading config: %w", err)
+		}
 
 		// SP_DAEMON_REEXEC=1 means gracefulRestart exec'd us. Skip the
 		// IsRunning check because we ARE the same PID and need to re-init.
@@ -54,6 +72,7 @@ var daemonStartCmd = &cobra.Command{
 		defer db.Close()
 
 		d := daemon.New(config, db)
+		d.SetNotifier(daemon.DesktopNotifier())
 		fmt.Printf("Daemon starting (socket: %s, pid: %d, log: %s)\n",
 			config.SocketPath, os.Getpid(), logging.DefaultLogPath())
 		return d.Start(context.Background())
@@ -85,6 +104,120 @@ var daemonStopCmd = &cobra.Command{
 	},
 }
 
+// daemonTokenCmd prints the HTTP API's bearer token.
+var daemonTokenCmd = &cobra.Command{
+	Use:   "token",
+	Short: "Print the bearer token for the daemon's HTTP API",
+	Long: `Prints the bearer token HTTP API clients send as "Authorization: Bearer
+<token>", generating it on first use. It is stored, readable only by you, in
+~/.config/sp/api-token; delete that file and restart the daemon to rotate it.
+
+The API is off unless the daemon starts with SP_DAEMON_HTTP set to a loopback
+address, e.g.:
+
+  export SP_DAEMON_HTTP=127.0.0.1:7375
+  sp daemon restart
+  curl -H "Authorization: Bearer $(sp daemon token)" http://127.0.0.1:7375/v1/sprites
+
+GET /v1/openapi.json describes every endpoint.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		token, err := daemon.APIToken(daemon.DefaultConfig().TokenPath)
+		if err != nil {
+			return err
+		}
+		fmt.Println(token)
+		return nil
+	},
+}
+
+// daemonConfigCmd shows the daemon's settings after config.toml and the
+// environment are applied.
+var daemonConfigCmd = &cobra.Command{
+	Use:   "config",
+	Short: "Show the daemon's polling intervals and timeouts",
+	Long: `Shows the daemon's intervals and timeouts, read from ~/.config/sp/config.toml
+and then the environment, and checks them. For example:
+
+  [daemon]
+  idle_timeout = "30m"            # "0s" never stops
+  binary_check_interval = "10s"
+
+  [health]
+  poll_interval = "60s"           # sprite status from the sprites API
+  network_interval = "15s"        # sprites API reachability
+  sync_interval = "10s"           # Mutagen session status
+  proxy_interval = "15s"          # proxy processes still alive
+  sync_reset_interval = "5m"      # force a full rescan of a stable session
+  connecting_timeout = "60s"      # rebuild sync stuck connecting this long
+  notify_interval = "5m"          # desktop notifications per sprite; "0s" for none
+
+  [sprite]
+  backend = "http"                # "cli" (default) or "http", which needs SPRITES_TOKEN
+  api_url = "https://api.sprites.dev"
+
+Each setting's environment variable, shown below, overrides the file. The
+file also configures hooks, commands run on sprite and sync events, and
+webhooks, URLs those events are posted to; see the README. The daemon
+reloads the file when it changes or on SIGHUP.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		path := daemon.ConfigPath()
+		config, err := daemon.LoadConfig(path)
+		if err != nil {
+			return err
+		}
+		if _, err := os.Stat(path); err == nil {
+			fmt.Printf("Config: %s\n\n", path)
+		} else {
+			fmt.Printf("Config: %s (not found, using defaults)\n\n", path)
+		}
+
+		fmt.Printf("%-30s %-10s %s\n", "SETTING", "VALUE", "ENV")
+		for _, s := range config.Settings() {
+			fmt.Printf("%-30s %-10s %s\n", s.Key, s.Value, s.Env)
+		}
+		backend := sprite.ConfigFromEnv()
+		fmt.Printf("%-30s %-10s %s\n", "sprite.backend", cmp.Or(backend.Backend, sprite.BackendCLI), sprite.EnvBackend)
+		fmt.Printf("%-30s %-10s %s\n", "sprite.api_url", cmp.Or(backend.APIURL, sprite.DefaultAPIURL), sprite.EnvAPIURL)
+
+		if len(config.Hooks) > 0 {
+			fmt.Println("\nHooks:")
+		}
+		for _, h := range config.Hooks {
+			on := strings.Join(h.On, ", ")
+			if h.Match != "" {
+				on += " (" + h.Match + ")"
+			}
+			if h.Run != "" {
+				fmt.Printf("  %s: run %s\n", on, h.Run)
+			}
+			if h.Remote != "" {
+				fmt.Printf("  %s: on the sprite, %s\n", on, h.Remote)
+			}
+		}
+
+		if len(config.Webhooks) > 0 {
+			fmt.Println("\nWebhooks:")
+		}
+		for _, w := range config.Webhooks {
+			on := "every event"
+			if len(w.On) > 0 {
+				on = strings.Join(w.On, ", ")
+			}
+			if w.Match != "" {
+				on += " (" + w.Match + ")"
+			}
+			signed := ""
+			if w.Secret != "" {
+				signed = ", signed"
+			}
+			fmt.Printf("  %s: post to %s%s\n", on, w.URL, signed)
+		}
+		return nil
+	},
+}
+
 // daemonStatusCmd shows daemon status.
 var daemonStatusCmd = &cobra.Command{
 	Use:   "status",
@@ -205,6 +338,8 @@ func init() {
 	daemonLogsCmd.Flags().BoolP("follow", "f", false, "follow the log output (like tail -f)")
 	daemonLogsCmd.Flags().IntP("lines", "n", 50, "number of lines to show")
 
-	daemonCmd.AddCommand(daemonStartCmd, daemonStopCmd, daemonRestartCmd, daemonStatusCmd, daemonLogsCmd)
+	daemonStartCmd.Flags().StringVar(&daemonHTTPAddr, "http", "", "also serve the HTTP API on this loopback address, e.g. 127.0.0.1:7375")
+
+	daemonCmd.AddCommand(daemonStartCmd, daemonStopCmd, daemonRestartCmd, daemonStatusCmd, daemonConfigCmd, daemonTokenCmd, daemonLogsCmd)
 	rootCmd.AddCommand(daemonCmd)
 }
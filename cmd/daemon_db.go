@@ -0,0 +1,107 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+
+	"github.com/jphenow/sp/internal/daemon"
+	"github.com/jphenow/sp/internal/store"
+)
+
+var (
+	dbMigrateStatus bool
+	dbMigrateTo     int
+)
+
+// daemonDBCmd groups commands for the daemon's SQLite database.
+var daemonDBCmd = &cobra.Command{
+	Use:   "db",
+	Short: "Manage the daemon's database",
+}
+
+// daemonDBMigrateCmd applies, reports or rolls back schema migrations.
+var daemonDBMigrateCmd = &cobra.Command{
+	Use:   "migrate",
+	Short: "Migrate the database schema",
+	Long: `Brings the database at ~/.config/sp/sp.db up to the schema this sp
+expects. The daemon does this itself when it starts, so this is mostly
+useful with its flags:
+
+  --status  list every migration and whether it has been applied, without
+            changing anything
+  --to N    migrate up or down to version N. Rolling back is for
+            downgrading sp: run it with the newer sp, then install the
+            older one before anything restarts the daemon (which would
+            migrate forward again). The daemon must not be running.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		db, err := store.OpenWithoutMigrating()
+		if err != nil {
+			return fmt.Errorf("opening database: %w", err)
+		}
+		defer db.Close()
+
+		if dbMigrateStatus {
+			return printMigrationStatus(db)
+		}
+
+		target := store.LatestSchemaVersion()
+		if cmd.Flags().Changed("to") {
+			target = dbMigrateTo
+			if daemon.IsRunning(daemon.DefaultConfig()) {
+				return fmt.Errorf("the daemon is running; stop it before migrating to a specific version")
+			}
+		}
+
+		from, err := db.SchemaVersion()
+		if err != nil {
+			return err
+		}
+		if err := db.MigrateTo(target); err != nil {
+			return fmt.Errorf("migrating: %w", err)
+		}
+		if from == target {
+			fmt.Printf("Database already at schema version %d\n", target)
+		} else {
+			fmt.Printf("Migrated database from schema version %d to %d\n", from, target)
+		}
+		return nil
+	},
+}
+
+// printMigrationStatus prints a table of the database's migrations.
+func printMigrationStatus(db *store.DB) error {
+	statuses, err := db.MigrationStatus()
+	if err != nil {
+		return err
+	}
+	version, err := db.SchemaVersion()
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Schema version %d (this sp: %d)\n\n", version, store.LatestSchemaVersion())
+	fmt.Printf("%-8s %-8s %-20s %s\n", "VERSION", "STATUS", "APPLIED", "NAME")
+	for _, s := range statuses {
+		status, applied := "pending", "-"
+		if s.Applied() {
+			status = "applied"
+			applied = s.AppliedAt.Local().Format("2006-01-02 15:04:05")
+		}
+		name := s.Name
+		if s.Unknown {
+			name += " (from a newer sp)"
+		}
+		fmt.Printf("%-8d %-8s %-20s %s\n", s.Version, status, applied, name)
+	}
+	return nil
+}
+
+func init() {
+	daemonDBMigrateCmd.Flags().BoolVar(&dbMigrateStatus, "status", false, "show applied and pending migrations without changing anything")
+	daemonDBMigrateCmd.Flags().IntVar(&dbMigrateTo, "to", 0, "migrate up or down to this schema version")
+
+	daemonDBCmd.AddCommand(daemonDBMigrateCmd)
+	daemonCmd.AddCommand(daemonDBCmd)
+}
@@ -1,14 +1,16 @@
 package cmd
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"os/exec"
-	"strings"
 
 	"github.com/spf13/cobra"
 
 	"github.com/jphenow/sp/internal/daemon"
 	"github.com/jphenow/sp/internal/store"
+	spSync "github.com/jphenow/sp/internal/sync"
 )
 
 // discoverCmd finds existing Mutagen sessions for sprites and offers to import
@@ -28,19 +30,10 @@ func init() {
 	rootCmd.AddCommand(discoverCmd)
 }
 
-// mutagenSessionInfo holds parsed info from mutagen sync list output.
-type mutagenSessionInfo struct {
-	Name       string
-	Identifier string
-	Alpha      string // local path
-	Beta       string // remote path (user@host:path)
-	Status     string
-}
-
 // runDiscover lists all sprite-* Mutagen sessions and imports untracked ones.
 func runDiscover(cmd *cobra.Command, args []string) error {
-	// List all Mutagen sessions
-	sessions, err := listAllMutagenSessions()
+	// List all sprite-* sync sessions
+	sessions, err := listSpriteSessions(context.Background(), syncEngine)
 	if err != nil {
 		return fmt.Errorf("listing Mutagen sessions: %w", err)
 	}
@@ -68,10 +61,9 @@ func runDiscover(cmd *cobra.Command, args []string) error {
 	}
 
 	// Find untracked sessions
-	var untracked []mutagenSessionInfo
+	var untracked []spSync.SessionState
 	for _, s := range sessions {
-		// Session name format: sprite-<sprite-name>
-		spriteName := strings.TrimPrefix(s.Name, "sprite-")
+		spriteName, _ := spSync.SpriteNameForSession(s.Name)
 		if !trackedNames[spriteName] {
 			untracked = append(untracked, s)
 		}
@@ -84,7 +76,7 @@ func runDiscover(cmd *cobra.Command, args []string) error {
 
 	fmt.Printf("Found %d Mutagen sessions, %d untracked:\n\n", len(sessions), len(untracked))
 	for _, s := range untracked {
-		spriteName := strings.TrimPrefix(s.Name, "sprite-")
+		spriteName, _ := spSync.SpriteNameForSession(s.Name)
 		fmt.Printf("  %-35s %s -> %s [%s]\n", spriteName, s.Alpha, s.Beta, s.Status)
 	}
 
@@ -92,7 +84,7 @@ func runDiscover(cmd *cobra.Command, args []string) error {
 
 	imported := 0
 	for _, s := range untracked {
-		spriteName := strings.TrimPrefix(s.Name, "sprite-")
+		spriteName, _ := spSync.SpriteNameForSession(s.Name)
 
 		// Try to import via daemon (fetches API info)
 		result, err := dc.ImportSprite(spriteName, s.Alpha, nil)
@@ -113,87 +105,21 @@ func runDiscover(cmd *cobra.Command, args []string) error {
 	return nil
 }
 
-// listAllMutagenSessions runs `mutagen sync list` and parses all sprite-* sessions.
-func listAllMutagenSessions() ([]mutagenSessionInfo, error) {
-	out, err := exec.Command("mutagen", "sync", "list").Output()
-	if err != nil {
-		// No sessions or mutagen not installed
+// listSpriteSessions returns the engine's sessions that belong to sprites.
+// An engine whose binary isn't installed has none.
+func listSpriteSessions(ctx context.Context, engine spSync.SyncEngine) ([]spSync.SessionState, error) {
+	all, err := engine.List(ctx)
+	if errors.Is(err, exec.ErrNotFound) {
 		return nil, nil
 	}
-
-	return parseMutagenList(string(out)), nil
-}
-
-// parseMutagenList parses the output of `mutagen sync list` (all sessions)
-// and returns info for sessions whose name starts with "sprite-".
-func parseMutagenList(output string) []mutagenSessionInfo {
-	var sessions []mutagenSessionInfo
-	var current *mutagenSessionInfo
-	inAlpha := false
-	inBeta := false
-
-	for _, line := range strings.Split(output, "\n") {
-		trimmed := strings.TrimSpace(line)
-
-		// New session block starts with a line like:
-		// Name: sprite-gh-jphenow--gameservers
-		if strings.HasPrefix(trimmed, "Name:") {
-			name := strings.TrimSpace(strings.TrimPrefix(trimmed, "Name:"))
-			if strings.HasPrefix(name, "sprite-") {
-				if current != nil {
-					sessions = append(sessions, *current)
-				}
-				current = &mutagenSessionInfo{Name: name}
-			} else {
-				// Not a sprite session — skip until next Name:
-				if current != nil {
-					sessions = append(sessions, *current)
-				}
-				current = nil
-			}
-			inAlpha = false
-			inBeta = false
-			continue
-		}
-
-		if current == nil {
-			continue
-		}
-
-		if strings.HasPrefix(trimmed, "Identifier:") {
-			current.Identifier = strings.TrimSpace(strings.TrimPrefix(trimmed, "Identifier:"))
-		}
-
-		if strings.HasPrefix(trimmed, "Status:") {
-			current.Status = strings.TrimSpace(strings.TrimPrefix(trimmed, "Status:"))
-		}
-
-		// Track which endpoint section we're in
-		if trimmed == "Alpha:" {
-			inAlpha = true
-			inBeta = false
-			continue
-		}
-		if trimmed == "Beta:" {
-			inAlpha = false
-			inBeta = true
-			continue
-		}
-
-		if strings.HasPrefix(trimmed, "URL:") {
-			url := strings.TrimSpace(strings.TrimPrefix(trimmed, "URL:"))
-			if inAlpha {
-				current.Alpha = url
-			} else if inBeta {
-				current.Beta = url
-			}
-		}
+	if err != nil {
+		return nil, err
 	}
-
-	// Don't forget the last session
-	if current != nil {
-		sessions = append(sessions, *current)
+	var sessions []spSync.SessionState
+	for _, s := range all {
+		if _, ok := spSync.SpriteNameForSession(s.Name); ok {
+			sessions = append(sessions, s)
+		}
 	}
-
-	return sessions
+	return sessions, nil
 }
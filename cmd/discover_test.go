@@ -0,0 +1,43 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os/exec"
+	"testing"
+
+	spSync "github.com/jphenow/sp/internal/sync"
+	"github.com/jphenow/sp/internal/sync/synctest"
+)
+
+func TestListSpriteSessions(t *testing.T) {
+	engine := synctest.New()
+	engine.Set(spSync.SessionState{Name: "sprite-gh-acme--api", Alpha: "/src/api"})
+	engine.Set(spSync.SessionState{Name: "dotfiles", Alpha: "/home/me/dotfiles"})
+	engine.Set(spSync.SessionState{Name: "sprite-local-notes", Alpha: "/src/notes"})
+
+	sessions, err := listSpriteSessions(context.Background(), engine)
+	if err != nil {
+		t.Fatalf("listSpriteSessions: %v", err)
+	}
+	var names []string
+	for _, s := range sessions {
+		names = append(names, s.Name)
+	}
+	if fmt.Sprint(names) != "[sprite-gh-acme--api sprite-local-notes]" {
+		t.Errorf("sessions = %q, want only the sprite sessions", names)
+	}
+}
+
+func TestListSpriteSessionsNotInstalled(t *testing.T) {
+	engine := synctest.New()
+	engine.Err = fmt.Errorf("listing mutagen sessions: %w", &exec.Error{Name: "mutagen", Err: exec.ErrNotFound})
+	if sessions, err := listSpriteSessions(context.Background(), engine); err != nil || sessions != nil {
+		t.Errorf("listSpriteSessions = %v, %v; want no sessions and no error", sessions, err)
+	}
+
+	engine.Err = fmt.Errorf("listing mutagen sessions: daemon unreachable")
+	if _, err := listSpriteSessions(context.Background(), engine); err == nil {
+		t.Error("listSpriteSessions hid an engine failure")
+	}
+}
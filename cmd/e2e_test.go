@@ -0,0 +1,164 @@
+package cmd
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/jphenow/sp/internal/daemon"
+	"github.com/jphenow/sp/internal/fakebin"
+	"github.com/jphenow/sp/internal/setup"
+	"github.com/jphenow/sp/internal/store"
+)
+
+func TestMain(m *testing.M) { fakebin.Main(m) }
+
+// startTestDaemon runs a daemon in-process at the default socket under the
+// fake HOME, so daemon.Connect finds it instead of forking the test binary,
+// and returns a client for it. Stdin is swapped for /dev/null so commands
+// never stop at a confirmation prompt.
+func startTestDaemon(t *testing.T) *daemon.Client {
+	t.Helper()
+	db, err := store.OpenPath(filepath.Join(t.TempDir(), "sp.db"))
+	if err != nil {
+		t.Fatalf("opening db: %v", err)
+	}
+	config := daemon.DefaultConfig()
+	config.IdleTimeout = 0
+	d := daemon.New(config, db)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		d.Start(ctx)
+		close(done)
+	}()
+	t.Cleanup(func() {
+		cancel()
+		<-done
+		db.Close()
+	})
+
+	var dc *daemon.Client
+	for i := 0; i < 50 && dc == nil; i++ {
+		time.Sleep(50 * time.Millisecond)
+		dc, _ = daemon.ConnectTo(config.SocketPath)
+	}
+	if dc == nil {
+		t.Fatal("daemon did not start")
+	}
+	t.Cleanup(func() { dc.Close() })
+
+	stdin, err := os.Open(os.DevNull)
+	if err != nil {
+		t.Fatal(err)
+	}
+	orig := os.Stdin
+	os.Stdin = stdin
+	t.Cleanup(func() {
+		os.Stdin = orig
+		stdin.Close()
+	})
+	return dc
+}
+
+func TestConnectWebEndToEnd(t *testing.T) {
+	if testing.Short() {
+		t.Skip("end-to-end connect test")
+	}
+	env := fakebin.New(t)
+	t.Setenv("CLAUDE_CODE_OAUTH_TOKEN", "sk-ant-oat01-fake")
+	dc := startTestDaemon(t)
+
+	dir := filepath.Join(t.TempDir(), "fake-connect-web")
+	if err := os.Mkdir(dir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	os.WriteFile(filepath.Join(dir, "main.go"), []byte("package main\n"), 0o644)
+	resolved, err := setup.ResolvePath(dir, "")
+	if err != nil {
+		t.Fatalf("resolving target: %v", err)
+	}
+
+	webMode = true
+	t.Cleanup(func() { webMode = false })
+	if err := runConnect(connectCmd, []string{dir}); err != nil {
+		t.Fatalf("runConnect: %v", err)
+	}
+
+	if env.Sprite(resolved.SpriteName) == nil {
+		t.Fatalf("sprite %s was not created", resolved.SpriteName)
+	}
+	if _, err := os.Stat(env.Path(resolved.SpriteName, resolved.RemotePath+"/main.go")); err != nil {
+		t.Errorf("initial upload missing: %v", err)
+	}
+	services := env.Services(resolved.SpriteName)
+	if len(services) != 1 || services[0].Name != webServiceDirect || services[0].HTTPPort != defaultOpencodePort {
+		t.Errorf("services = %+v, want the opencode web service", services)
+	}
+
+	// Registration hands sync to the daemon, which brings it up unaided.
+	deadline := time.Now().Add(30 * time.Second)
+	for {
+		s, err := dc.GetSprite(resolved.SpriteName)
+		if err != nil {
+			t.Fatalf("GetSprite: %v", err)
+		}
+		if s != nil && s.SyncStatus == "watching" {
+			if s.LocalPath != dir || s.URL == "" {
+				t.Errorf("registered sprite = %+v", s)
+			}
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("daemon sync never reached watching: %+v", s)
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+}
+
+func TestPruneEndToEnd(t *testing.T) {
+	if testing.Short() {
+		t.Skip("end-to-end prune test")
+	}
+	env := fakebin.New(t)
+	dc := startTestDaemon(t)
+
+	for _, s := range []*store.Sprite{
+		{Name: "proj", BaseName: "proj"},
+		{Name: "proj--stale", BaseName: "proj", Variant: "stale"},
+		{Name: "proj--keep", BaseName: "proj", Variant: "keep"},
+	} {
+		env.AddSprite(s.Name, "warm")
+		s.Status = "warm"
+		if err := dc.UpsertSprite(s); err != nil {
+			t.Fatalf("UpsertSprite: %v", err)
+		}
+	}
+	if err := dc.SetPinned("proj--keep", true); err != nil {
+		t.Fatalf("SetPinned: %v", err)
+	}
+
+	pruneYes, pruneAll = true, true
+	t.Cleanup(func() { pruneYes, pruneAll = false, false })
+	if err := runPrune(pruneCmd, nil); err != nil {
+		t.Fatalf("runPrune: %v", err)
+	}
+
+	if env.Sprite("proj--stale") != nil {
+		t.Error("unpinned variant was not destroyed")
+	}
+	if s, _ := dc.GetSprite("proj--stale"); s != nil {
+		t.Error("unpinned variant still tracked")
+	}
+	for _, name := range []string{"proj", "proj--keep"} {
+		if env.Sprite(name) == nil {
+			t.Errorf("%s was destroyed", name)
+		}
+	}
+	if !env.Called("sprite", "destroy", "--force", "proj--stale") {
+		t.Errorf("calls = %q, want a forced destroy of proj--stale", env.Calls())
+	}
+}
@@ -0,0 +1,115 @@
+package cmd
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/jphenow/sp/internal/daemon"
+	"github.com/jphenow/sp/internal/store"
+)
+
+var (
+	historySince time.Duration
+	historyAll   bool
+	historyKinds []string
+)
+
+// historyCmd prints the recorded event history of a sprite.
+var historyCmd = &cobra.Command{
+	Use:   "history [target] [variant]",
+	Short: "Show a sprite's status, sync and setup history",
+	Long: `Shows what happened to a sprite over time, as recorded by the daemon:
+status changes (running/warm/cold), sync status changes and errors, SSH
+proxy deaths, setup.conf runs, keepalive holds, hook runs and resolved
+sync conflicts.
+
+  sp history .                 # the current directory's sprite, last 24h
+  sp history . --since 72h
+  sp history --all --kind sync # sync changes across every sprite
+
+Events are kept for 30 days. Target defaults to "." (current directory).`,
+	Args: cobra.RangeArgs(0, 2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		opts := store.EventListOptions{Kinds: historyKinds}
+		if historySince > 0 {
+			opts.Since = time.Now().Add(-historySince)
+		}
+		if !historyAll {
+			resolved, err := resolveTarget(args)
+			if err != nil {
+				return fmt.Errorf("resolving target: %w", err)
+			}
+			opts.SpriteName = resolved.SpriteName
+		}
+
+		dc, err := daemon.Connect()
+		if err != nil {
+			return fmt.Errorf("connecting to daemon: %w", err)
+		}
+		defer dc.Close()
+
+		events, err := dc.ListEvents(opts)
+		if err != nil {
+			return fmt.Errorf("listing events: %w", err)
+		}
+		if len(events) == 0 {
+			fmt.Println(noEventsMessage(opts.SpriteName, historySince))
+			return nil
+		}
+		printEvents(events, historyAll)
+		return nil
+	},
+}
+
+// noEventsMessage says that nothing was found for a history query. A since
+// of 0 (or less) means there was no lower bound.
+func noEventsMessage(spriteName string, since time.Duration) string {
+	what := "No events"
+	if spriteName != "" {
+		what += " for " + spriteName
+	}
+	if since <= 0 {
+		return what + " recorded."
+	}
+	return fmt.Sprintf("%s in the last %s.", what, since)
+}
+
+// printEvents prints events as a table, with a sprite column when they can
+// come from more than one sprite.
+func printEvents(events []*store.Event, withSprite bool) {
+	if withSprite {
+		fmt.Printf("%-19s  %-30s  %-9s  %-22s  %s\n", "TIME", "SPRITE", "KIND", "CHANGE", "DETAILS")
+	} else {
+		fmt.Printf("%-19s  %-9s  %-22s  %s\n", "TIME", "KIND", "CHANGE", "DETAILS")
+	}
+	for _, e := range events {
+		when := e.CreatedAt.Local().Format("2006-01-02 15:04:05")
+		message := strings.ReplaceAll(e.Message, "\n", " ")
+		if withSprite {
+			fmt.Printf("%-19s  %-30s  %-9s  %-22s  %s\n", when, e.SpriteName, e.Kind, e.Change(), message)
+		} else {
+			fmt.Printf("%-19s  %-9s  %-22s  %s\n", when, e.Kind, e.Change(), message)
+		}
+	}
+}
+
+// recordEvent adds an event to a sprite's history through the daemon.
+// Best-effort: history is never worth failing a command over.
+func recordEvent(e *store.Event) {
+	dc, err := daemon.Connect()
+	if err != nil {
+		return
+	}
+	defer dc.Close()
+	dc.RecordEvent(e)
+}
+
+func init() {
+	historyCmd.Flags().DurationVar(&historySince, "since", 24*time.Hour, "how far back to look (0 for everything kept)")
+	historyCmd.Flags().BoolVar(&historyAll, "all", false, "show events for every sprite")
+	historyCmd.Flags().StringSliceVar(&historyKinds, "kind", nil, "only these kinds: status, sync, proxy, setup, keepalive, hook, conflict")
+	rootCmd.AddCommand(historyCmd)
+}
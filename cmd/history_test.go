@@ -0,0 +1,24 @@
+package cmd
+
+import (
+	"testing"
+	"time"
+)
+
+func TestNoEventsMessage(t *testing.T) {
+	tests := []struct {
+		sprite string
+		since  time.Duration
+		want   string
+	}{
+		{"alpha", 24 * time.Hour, "No events for alpha in the last 24h0m0s."},
+		{"", time.Hour, "No events in the last 1h0m0s."},
+		{"alpha", 0, "No events for alpha recorded."},
+		{"", 0, "No events recorded."},
+	}
+	for _, tt := range tests {
+		if got := noEventsMessage(tt.sprite, tt.since); got != tt.want {
+			t.Errorf("noEventsMessage(%q, %s) = %q, want %q", tt.sprite, tt.since, got, tt.want)
+		}
+	}
+}
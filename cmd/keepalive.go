@@ -10,6 +10,7 @@ import (
 	"github.com/spf13/cobra"
 
 	"github.com/jphenow/sp/internal/sprite"
+	"github.com/jphenow/sp/internal/store"
 )
 
 // keepAliveTaskName is the Tasks API task name sp uses to hold a sprite Active.
@@ -90,10 +91,11 @@ func runKeepalive(cmd *cobra.Command, args []string) error {
 
 	// idleSession "" → no idle-exit: an explicit keepalive holds for the full
 	// window regardless of terminal activity (that's the point of the command).
-	if err := launchKeepAlive(resolved.SpriteName, resolved.Org, keepAliveFor, "", ""); err != nil {
+	held, err := launchKeepAlive(resolved.SpriteName, resolved.Org, keepAliveFor, "", "")
+	if err != nil {
 		return fmt.Errorf("starting keepalive: %w", err)
 	}
-	fmt.Printf("Holding sprite %q Active for %s (self-releases after).\n", resolved.SpriteName, keepAliveFor)
+	fmt.Printf("Holding sprite %q Active for %s (self-releases after).\n", resolved.SpriteName, held)
 	fmt.Printf("Release early: sp keepalive %s --stop\n", strings.Join(args, " "))
 	return nil
 }
@@ -170,8 +172,14 @@ fi
 // outlives the local sp process (same detach pattern as the keep-warm sentinel:
 // Setpgid + released so a terminal SIGHUP doesn't reach it). dur of 0 means no
 // deadline; idleSession enables idle-exit and watchSession enables session-tied
-// release (see heartbeatScript).
-func launchKeepAlive(spriteName, org string, dur time.Duration, idleSession, watchSession string) error {
+// release (see heartbeatScript). The hold is fitted into the sprite's budgets
+// first (see budgetedHold); it returns how long the hold actually lasts.
+func launchKeepAlive(spriteName, org string, dur time.Duration, idleSession, watchSession string) (time.Duration, error) {
+	dur, err := budgetedHold(spriteName, dur)
+	if err != nil {
+		return 0, err
+	}
+
 	var deadline int64
 	if dur > 0 {
 		deadline = time.Now().Add(dur).Unix()
@@ -188,9 +196,25 @@ func launchKeepAlive(spriteName, org string, dur time.Duration, idleSession, wat
 	c.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
 	c.Stdin, c.Stdout, c.Stderr = nil, nil, nil
 	if err := c.Start(); err != nil {
-		return fmt.Errorf("starting keepalive sentinel: %w", err)
+		return 0, fmt.Errorf("starting keepalive sentinel: %w", err)
+	}
+	if err := c.Process.Release(); err != nil {
+		return 0, err
+	}
+	recordEvent(&store.Event{SpriteName: spriteName, Kind: store.EventKeepalive, To: "held", Message: keepAliveDescription(dur, watchSession)})
+	return dur, nil
+}
+
+// keepAliveDescription says how long a hold lasts, for its history event.
+func keepAliveDescription(dur time.Duration, watchSession string) string {
+	var parts []string
+	if dur > 0 {
+		parts = append(parts, "for "+dur.String())
+	}
+	if watchSession != "" {
+		parts = append(parts, "until session "+watchSession+" ends")
 	}
-	return c.Process.Release()
+	return strings.Join(parts, ", ")
 }
 
 // stopKeepAlive releases the hold: clearing the generation file makes any
@@ -207,4 +231,5 @@ func stopKeepAlive(client *sprite.Client, spriteName, org string) {
 		Org:     org,
 		Command: []string{"sh", "-c", script},
 	})
+	recordEvent(&store.Event{SpriteName: spriteName, Kind: store.EventKeepalive, To: "released"})
 }
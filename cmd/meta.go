@@ -0,0 +1,217 @@
+package cmd
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/jphenow/sp/internal/daemon"
+	"github.com/jphenow/sp/internal/store"
+)
+
+var noteClear bool
+
+// metaCmd shows and edits key/value annotations on a sprite: which ticket a
+// variant is for, who owns it, why it exists.
+var metaCmd = &cobra.Command{
+	Use:   "meta <sprite-or-variant>",
+	Short: "Show a sprite's notes and key/value metadata",
+	Long: `Annotations record what tags can't: why a sprite exists, which ticket it's
+for, who owns it. They show in sp status <name>, the TUI detail view and
+sp prune's candidate list.
+
+  sp meta gh-acme--api--spike                    # show
+  sp meta set acme/api:spike ticket=ENG-123 owner=sam
+  sp meta rm acme/api:spike owner
+  sp note acme/api:spike "keep until the demo on friday"
+
+The target is a sprite name or owner/repo:variant shorthand.`,
+	Args: cobra.ExactArgs(1),
+	RunE: runMetaShow,
+}
+
+var metaSetCmd = &cobra.Command{
+	Use:   "set <sprite-or-variant> <key=value>...",
+	Short: "Set metadata on a sprite",
+	Args:  cobra.MinimumNArgs(2),
+	RunE:  runMetaSet,
+}
+
+var metaRmCmd = &cobra.Command{
+	Use:   "rm <sprite-or-variant> <key>...",
+	Short: "Remove metadata from a sprite",
+	Args:  cobra.MinimumNArgs(2),
+	RunE:  runMetaRm,
+}
+
+// noteCmd is shorthand for the "note" metadata key.
+var noteCmd = &cobra.Command{
+	Use:   "note <sprite-or-variant> [text]",
+	Short: "Show or set a free-form note on a sprite",
+	Long: `Sets a sprite's note, replacing any earlier one. Without text, prints the
+note; --clear removes it. The note is the "note" key of sp meta.`,
+	Args: cobra.MinimumNArgs(1),
+	RunE: runNote,
+}
+
+func init() {
+	noteCmd.Flags().BoolVar(&noteClear, "clear", false, "remove the note")
+
+	metaCmd.AddCommand(metaSetCmd, metaRmCmd)
+	rootCmd.AddCommand(metaCmd, noteCmd)
+}
+
+// resolveTrackedSprite resolves a sprite name or owner/repo:variant
+// shorthand and checks that the daemon tracks it.
+func resolveTrackedSprite(dc *daemon.Client, input string) (string, error) {
+	name, err := resolveSpriteName(dc, input)
+	if err != nil {
+		return "", err
+	}
+	s, err := dc.GetSprite(name)
+	if err != nil {
+		return "", fmt.Errorf("looking up sprite: %w", err)
+	}
+	if s == nil {
+		return "", fmt.Errorf("sprite %q not found", name)
+	}
+	return name, nil
+}
+
+func runMetaShow(cmd *cobra.Command, args []string) error {
+	dc, err := daemon.Connect()
+	if err != nil {
+		return fmt.Errorf("connecting to daemon: %w", err)
+	}
+	defer dc.Close()
+
+	name, err := resolveTrackedSprite(dc, args[0])
+	if err != nil {
+		return err
+	}
+	meta, err := dc.GetMeta(name)
+	if err != nil {
+		return fmt.Errorf("getting metadata: %w", err)
+	}
+	if len(meta) == 0 {
+		fmt.Printf("No metadata on %s. Add some with: sp meta set %s key=value\n", name, args[0])
+		return nil
+	}
+	printMeta(meta, "")
+	return nil
+}
+
+func runMetaSet(cmd *cobra.Command, args []string) error {
+	pairs, err := parseMetaPairs(args[1:])
+	if err != nil {
+		return err
+	}
+
+	dc, err := daemon.Connect()
+	if err != nil {
+		return fmt.Errorf("connecting to daemon: %w", err)
+	}
+	defer dc.Close()
+
+	name, err := resolveTrackedSprite(dc, args[0])
+	if err != nil {
+		return err
+	}
+	for _, kv := range pairs {
+		if err := dc.SetMeta(name, kv[0], kv[1]); err != nil {
+			return fmt.Errorf("setting %s: %w", kv[0], err)
+		}
+		fmt.Printf("Set %s=%s on %s\n", kv[0], kv[1], name)
+	}
+	return nil
+}
+
+func runMetaRm(cmd *cobra.Command, args []string) error {
+	dc, err := daemon.Connect()
+	if err != nil {
+		return fmt.Errorf("connecting to daemon: %w", err)
+	}
+	defer dc.Close()
+
+	name, err := resolveTrackedSprite(dc, args[0])
+	if err != nil {
+		return err
+	}
+	for _, key := range args[1:] {
+		if err := dc.DeleteMeta(name, key); err != nil {
+			return fmt.Errorf("removing %s: %w", key, err)
+		}
+		fmt.Printf("Removed %s from %s\n", key, name)
+	}
+	return nil
+}
+
+func runNote(cmd *cobra.Command, args []string) error {
+	text := strings.TrimSpace(strings.Join(args[1:], " "))
+	if noteClear && text != "" {
+		return fmt.Errorf("--clear takes no note text")
+	}
+
+	dc, err := daemon.Connect()
+	if err != nil {
+		return fmt.Errorf("connecting to daemon: %w", err)
+	}
+	defer dc.Close()
+
+	name, err := resolveTrackedSprite(dc, args[0])
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case noteClear:
+		if err := dc.DeleteMeta(name, store.MetaNote); err != nil {
+			return fmt.Errorf("clearing note: %w", err)
+		}
+		fmt.Printf("Cleared the note on %s\n", name)
+	case text != "":
+		if err := dc.SetMeta(name, store.MetaNote, text); err != nil {
+			return fmt.Errorf("setting note: %w", err)
+		}
+		fmt.Printf("Noted on %s\n", name)
+	default:
+		meta, err := dc.GetMeta(name)
+		if err != nil {
+			return fmt.Errorf("getting note: %w", err)
+		}
+		for _, m := range meta {
+			if m.Key == store.MetaNote {
+				fmt.Println(m.Value)
+				return nil
+			}
+		}
+		fmt.Printf("No note on %s.\n", name)
+	}
+	return nil
+}
+
+// parseMetaPairs parses key=value arguments. Keys can't be empty or contain
+// whitespace; values can be anything, including empty.
+func parseMetaPairs(args []string) ([][2]string, error) {
+	var pairs [][2]string
+	for _, arg := range args {
+		key, value, ok := strings.Cut(arg, "=")
+		if !ok || key == "" || strings.ContainsAny(key, " \t\n") {
+			return nil, fmt.Errorf("invalid metadata %q: want key=value", arg)
+		}
+		pairs = append(pairs, [2]string{key, value})
+	}
+	return pairs, nil
+}
+
+// printMeta prints annotations one per line with their values aligned.
+func printMeta(meta []*store.Meta, indent string) {
+	width := 0
+	for _, m := range meta {
+		width = max(width, len(m.Key))
+	}
+	for _, m := range meta {
+		fmt.Printf("%s%-*s  %s\n", indent, width+1, m.Key+":", m.Value)
+	}
+}
@@ -0,0 +1,23 @@
+package cmd
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseMetaPairs(t *testing.T) {
+	got, err := parseMetaPairs([]string{"ticket=ENG-1", "url=https://x?a=b", "reviewer="})
+	if err != nil {
+		t.Fatalf("parseMetaPairs: %v", err)
+	}
+	want := [][2]string{{"ticket", "ENG-1"}, {"url", "https://x?a=b"}, {"reviewer", ""}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("parseMetaPairs = %v, want %v", got, want)
+	}
+
+	for _, bad := range []string{"owner", "=sam", "the owner=sam"} {
+		if _, err := parseMetaPairs([]string{bad}); err == nil {
+			t.Errorf("parseMetaPairs(%q) succeeded", bad)
+		}
+	}
+}
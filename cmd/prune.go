@@ -8,6 +8,7 @@ import (
 	"time"
 
 	"github.com/spf13/cobra"
+	"golang.org/x/sys/unix"
 
 	"github.com/jphenow/sp/internal/daemon"
 	"github.com/jphenow/sp/internal/sprite"
@@ -38,7 +39,8 @@ Flags:
   --all         ignore the age threshold; consider every unpinned variant
 
 Pinned variants are always excluded regardless of age. Use "sp pin" to
-protect an experiment you want to keep.`,
+protect an experiment you want to keep. Notes and metadata (see sp meta)
+are listed under each candidate.`,
 	Args: cobra.NoArgs,
 	RunE: runPrune,
 }
@@ -78,10 +80,20 @@ func runPrune(cmd *cobra.Command, args []string) error {
 	fmt.Printf("Candidates (%d):\n", len(sprites))
 	fmt.Printf("  %-40s %-16s %s\n", "NAME", "VARIANT", "AGE")
 	fmt.Println("  " + strings.Repeat("-", 80))
+	// Annotations are listed under each candidate: a note or owner is often
+	// the only sign that someone still needs a sprite.
 	now := time.Now()
+	annotated := 0
 	for _, s := range sprites {
 		age := now.Sub(s.UpdatedAt).Round(time.Minute)
 		fmt.Printf("  %-40s %-16s %s\n", s.Name, s.Variant, age)
+		if meta, err := dc.GetMeta(s.Name); err == nil && len(meta) > 0 {
+			printMeta(meta, "      ")
+			annotated++
+		}
+	}
+	if annotated > 0 {
+		fmt.Printf("\n%d candidate(s) have notes or metadata — check them, and sp pin anything still needed.\n", annotated)
 	}
 
 	if !pruneYes {
@@ -124,14 +136,12 @@ func runPrune(cmd *cobra.Command, args []string) error {
 	return nil
 }
 
-// isTerminal reports whether stdin is connected to a terminal. A minimal
-// stand-in so prune can skip interactive confirmation in scripts.
+// isTerminal reports whether stdin is connected to a terminal, so prune can
+// skip interactive confirmation in scripts. Asks for the window size rather
+// than checking for a character device, which /dev/null also is.
 func isTerminal() bool {
-	fi, err := os.Stdin.Stat()
-	if err != nil {
-		return false
-	}
-	return (fi.Mode() & os.ModeCharDevice) != 0
+	_, err := unix.IoctlGetWinsize(int(os.Stdin.Fd()), unix.TIOCGWINSZ)
+	return err == nil
 }
 
 func init() {
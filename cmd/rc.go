@@ -1,6 +1,7 @@
 package cmd
 
 import (
+	"context"
 	"fmt"
 	"strings"
 	"time"
@@ -72,21 +73,17 @@ func runRC(cmd *cobra.Command, args []string) error {
 		return fmt.Errorf("sprite %q does not exist — run 'sp %s' first", resolved.SpriteName, strings.Join(args, " "))
 	}
 
+	ctx := context.Background()
 	if rcStop {
-		client.Exec(sprite.ExecOptions{
-			Sprite:  resolved.SpriteName,
-			Command: []string{"sprite-env", "services", "delete", rcServiceName},
-		})
+		client.DeleteService(ctx, resolved.SpriteName, rcServiceName)
 		stopKeepAlive(client, resolved.SpriteName, resolved.Org)
 		fmt.Printf("Stopped Remote Control service and released the hold on sprite %q.\n", resolved.SpriteName)
 		return nil
 	}
 
-	// Recreate the service idempotently.
-	client.Exec(sprite.ExecOptions{
-		Sprite:  resolved.SpriteName,
-		Command: []string{"sprite-env", "services", "delete", rcServiceName},
-	})
+	// Recreate the service idempotently; the delete fails harmlessly when
+	// there is nothing to replace.
+	client.DeleteService(ctx, resolved.SpriteName, rcServiceName)
 
 	// The service runs a wrapper (not claude directly) so it can resume the last
 	// session with --continue but fall back to a fresh one when none exists yet
@@ -105,23 +102,23 @@ func runRC(cmd *cobra.Command, args []string) error {
 		return fmt.Errorf("writing Remote Control wrapper: %w", err)
 	}
 
-	createCmd := fmt.Sprintf(
-		"sprite-env services create %s --cmd %s --dir %s --duration 3s",
-		rcServiceName, wrapper, resolved.RemotePath,
-	)
-	if out, err := client.Exec(sprite.ExecOptions{
-		Sprite:  resolved.SpriteName,
-		Command: []string{"sh", "-c", createCmd},
+	if err := client.CreateService(ctx, resolved.SpriteName, sprite.ServiceOptions{
+		Name:     rcServiceName,
+		Cmd:      wrapper,
+		Dir:      resolved.RemotePath,
+		Duration: 3 * time.Second,
 	}); err != nil {
-		return fmt.Errorf("creating Remote Control service: %w\n%s", err, string(out))
+		return fmt.Errorf("creating Remote Control service: %w", err)
 	}
 
 	// Hold the sprite Active so Remote Control stays connected and reachable.
-	if err := launchKeepAlive(resolved.SpriteName, resolved.Org, rcFor, "", ""); err != nil {
+	held, err := launchKeepAlive(resolved.SpriteName, resolved.Org, rcFor, "", "")
+	if err != nil {
 		fmt.Printf("Warning: Remote Control service started but keepalive failed: %v\n", err)
+		fmt.Printf("\nRemote Control running on sprite %q (not held Active; it pauses when idle).\n", resolved.SpriteName)
+	} else {
+		fmt.Printf("\nRemote Control running on sprite %q (held Active for %s).\n", resolved.SpriteName, held)
 	}
-
-	fmt.Printf("\nRemote Control running on sprite %q (held Active for %s).\n", resolved.SpriteName, rcFor)
 	fmt.Println("Open the Claude app → Code tab and find the session by the sprite name.")
 	fmt.Printf("Stop: sp rc %s --stop\n", strings.Join(args, " "))
 	return nil
@@ -2,6 +2,7 @@ package cmd
 
 import (
 	"fmt"
+	"slices"
 
 	"github.com/spf13/cobra"
 
@@ -9,6 +10,17 @@ import (
 	"github.com/jphenow/sp/internal/sprite"
 )
 
+// resyncMode is a one-way sync mode for a one-shot copy (--mode).
+var resyncMode string
+
+// resyncModes are the modes --mode accepts.
+var resyncModes = []daemon.SyncMode{
+	daemon.SyncModeOneWayReplicaToRemote,
+	daemon.SyncModeOneWayReplicaToLocal,
+	daemon.SyncModeOneWaySafeToRemote,
+	daemon.SyncModeOneWaySafeToLocal,
+}
+
 // resyncCmd tears down and restarts sync for a sprite.
 var resyncCmd = &cobra.Command{
 	Use:   "resync [target]",
@@ -17,6 +29,18 @@ var resyncCmd = &cobra.Command{
 This flushes pending changes, kills the proxy, removes SSH config,
 and re-establishes the full sync pipeline.
 
+With --mode, first makes a one-shot copy in one direction to force one
+side to match the other:
+
+  one-way-replica-to-remote  make the sprite an exact copy of local
+  one-way-replica-to-local   make local an exact copy of the sprite
+  one-way-safe-to-remote     copy new and changed files to the sprite,
+                             skipping files that differ on both sides
+  one-way-safe-to-local      the same, from the sprite to local
+
+The copy works without Mutagen, using sp's built-in engine; ongoing
+two-way sync is only restored when Mutagen is installed.
+
 Target defaults to "." (current directory).`,
 	Args: cobra.MaximumNArgs(1),
 	RunE: func(cmd *cobra.Command, args []string) error {
@@ -52,6 +76,19 @@ Target defaults to "." (current directory).`,
 			return fmt.Errorf("no local path configured for %s — use 'sp import --path' first", resolved.SpriteName)
 		}
 
+		if resyncMode != "" {
+			mode := daemon.SyncMode(resyncMode)
+			if !slices.Contains(resyncModes, mode) {
+				return fmt.Errorf("unknown --mode %q (want one of %v)", resyncMode, resyncModes)
+			}
+			fmt.Printf("Resyncing %s with mode %s...\n", resolved.SpriteName, mode)
+			if err := dc.ResyncWithMode(resolved.SpriteName, mode); err != nil {
+				return fmt.Errorf("resync failed: %w", err)
+			}
+			fmt.Println("Resync running in background (see 'sp status').")
+			return nil
+		}
+
 		fmt.Printf("Resetting sync for %s...\n", resolved.SpriteName)
 
 		// Resync via daemon: flush + stop + start
@@ -91,6 +128,7 @@ Target defaults to "." (current directory).`,
 }
 
 func init() {
+	resyncCmd.Flags().StringVar(&resyncMode, "mode", "", "make a one-shot one-way copy first (one-way-{replica,safe}-to-{remote,local})")
 	rootCmd.AddCommand(resyncCmd)
 	rootCmd.AddCommand(sessionsCmd)
 }
@@ -5,10 +5,19 @@ import (
 	"strings"
 
 	"github.com/spf13/cobra"
+
+	"github.com/jphenow/sp/internal/daemon"
+	"github.com/jphenow/sp/internal/sprite"
+	spSync "github.com/jphenow/sp/internal/sync"
 )
 
 var (
 	verbose bool
+
+	// syncEngine runs sync sessions for commands that inspect or create them
+	// directly instead of through the daemon: Mutagen when it's installed,
+	// the built-in copy engine otherwise.
+	syncEngine spSync.SyncEngine = spSync.DefaultEngine()
 )
 
 // rootCmd is the base command for sp.
@@ -27,11 +36,21 @@ Quick start:
 	// treat them as connect targets (sp . / sp owner/repo)
 	Args:               cobra.ArbitraryArgs,
 	DisableFlagParsing: false,
+	PersistentPreRun:   loadSpriteConfig,
 	RunE:               runDefault,
 	SilenceUsage:       true,
 	SilenceErrors:      true,
 }
 
+// loadSpriteConfig picks up the sprite backend from config.toml before any
+// command creates a sprite client. A broken file is left to the daemon
+// commands to report; they refuse to start with one.
+func loadSpriteConfig(cmd *cobra.Command, args []string) {
+	if config, err := daemon.LoadConfig(daemon.ConfigPath()); err == nil {
+		sprite.SetConfig(config.Sprite)
+	}
+}
+
 // Execute runs the root command. Called from main.go.
 func Execute() error {
 	return rootCmd.Execute()
@@ -0,0 +1,234 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/jphenow/sp/internal/setup"
+	"github.com/jphenow/sp/internal/sprite"
+)
+
+var (
+	svcCmd      string
+	svcArgs     []string
+	svcDir      string
+	svcHTTPPort int
+	svcDuration time.Duration
+	svcLines    int
+	svcFollow   bool
+)
+
+// servicesCmd manages sprite-env services — the supervised processes behind
+// `sp --web`, `sp rc` and any dev servers you keep running on a sprite.
+// Bare `sp services [target]` lists them.
+var servicesCmd = &cobra.Command{
+	Use:   "services [target] [variant]",
+	Short: "Manage sprite-env services (dev servers, opencode, Remote Control)",
+	Long: `Manage the sprite-env services running on a sprite. Services are supervised
+processes that restart on boot and after a cold wake; one with --http-port also
+receives the sprite URL's traffic and wakes the sprite on request.
+
+  sp services .                              # list services on this dir's sprite
+  sp services add web . --cmd /usr/bin/npm --args run,dev --http-port 3000
+  sp services logs web . -f
+  sp services restart web owner/repo
+  sp services rm web .
+
+The target is resolved like sp connect: a path, owner/repo (with optional
+variant), or a bare sprite name.`,
+	Args: cobra.RangeArgs(0, 2),
+	RunE: runServicesList,
+}
+
+var servicesLsCmd = &cobra.Command{
+	Use:   "ls [target] [variant]",
+	Short: "List services on a sprite",
+	Args:  cobra.RangeArgs(0, 2),
+	RunE:  runServicesList,
+}
+
+var servicesAddCmd = &cobra.Command{
+	Use:   "add <name> [target] [variant]",
+	Short: "Create and start a service on a sprite",
+	Args:  cobra.RangeArgs(1, 3),
+	RunE:  runServicesAdd,
+}
+
+var servicesRmCmd = &cobra.Command{
+	Use:   "rm <name> [target] [variant]",
+	Short: "Stop and remove a service from a sprite",
+	Args:  cobra.RangeArgs(1, 3),
+	RunE:  runServicesRm,
+}
+
+var servicesLogsCmd = &cobra.Command{
+	Use:   "logs <name> [target] [variant]",
+	Short: "Show a service's log output",
+	Args:  cobra.RangeArgs(1, 3),
+	RunE:  runServicesLogs,
+}
+
+var servicesRestartCmd = &cobra.Command{
+	Use:   "restart <name> [target] [variant]",
+	Short: "Restart a service with its current definition",
+	Args:  cobra.RangeArgs(1, 3),
+	RunE:  runServicesRestart,
+}
+
+func init() {
+	servicesAddCmd.Flags().StringVar(&svcCmd, "cmd", "", "absolute path of the program to run (services don't source login profiles)")
+	servicesAddCmd.Flags().StringSliceVar(&svcArgs, "args", nil, "comma-separated arguments for the program")
+	servicesAddCmd.Flags().StringVar(&svcDir, "dir", "", "working directory (default: the target's remote path)")
+	servicesAddCmd.Flags().IntVar(&svcHTTPPort, "http-port", 0, "route the sprite URL to this port and wake the sprite on HTTP requests")
+	servicesAddCmd.Flags().DurationVar(&svcDuration, "duration", 10*time.Second, "how long the process must stay up for the create to succeed")
+	servicesAddCmd.MarkFlagRequired("cmd")
+
+	servicesRestartCmd.Flags().DurationVar(&svcDuration, "duration", 10*time.Second, "how long the process must stay up for the restart to succeed")
+
+	servicesLogsCmd.Flags().IntVarP(&svcLines, "lines", "n", 100, "number of trailing lines to show (0 for all)")
+	servicesLogsCmd.Flags().BoolVarP(&svcFollow, "follow", "f", false, "keep streaming new output until interrupted")
+
+	servicesCmd.AddCommand(servicesLsCmd, servicesAddCmd, servicesRmCmd, servicesLogsCmd, servicesRestartCmd)
+	rootCmd.AddCommand(servicesCmd)
+}
+
+// resolveServiceTarget resolves the target args and checks the sprite
+// exists, so every subcommand fails the same way for a missing sprite.
+func resolveServiceTarget(args []string) (*setup.ResolvedTarget, *sprite.Client, error) {
+	resolved, err := resolveTarget(args)
+	if err != nil {
+		return nil, nil, fmt.Errorf("resolving target: %w", err)
+	}
+	client := sprite.NewClient(resolved.Org)
+	exists, err := client.Exists(resolved.SpriteName)
+	if err != nil {
+		return nil, nil, fmt.Errorf("checking sprite: %w", err)
+	}
+	if !exists {
+		return nil, nil, fmt.Errorf("sprite %q does not exist", resolved.SpriteName)
+	}
+	return resolved, client, nil
+}
+
+// runServicesList prints a table of the sprite's services.
+func runServicesList(cmd *cobra.Command, args []string) error {
+	resolved, client, err := resolveServiceTarget(args)
+	if err != nil {
+		return err
+	}
+	services, err := client.ListServices(context.Background(), resolved.SpriteName)
+	if err != nil {
+		return err
+	}
+	if len(services) == 0 {
+		fmt.Printf("No services on sprite %s.\n", resolved.SpriteName)
+		return nil
+	}
+
+	fmt.Printf("%-16s %-10s %-8s %-6s %s\n", "NAME", "STATUS", "PID", "PORT", "COMMAND")
+	fmt.Println(strings.Repeat("-", 80))
+	for _, s := range services {
+		status, pid := "-", "-"
+		if s.State != nil {
+			if s.State.Status != "" {
+				status = s.State.Status
+			}
+			if s.State.PID > 0 {
+				pid = fmt.Sprint(s.State.PID)
+			}
+		}
+		port := "-"
+		if s.HTTPPort > 0 {
+			port = fmt.Sprint(s.HTTPPort)
+		}
+		command := strings.TrimSpace(s.Cmd + " " + strings.Join(s.Args, " "))
+		fmt.Printf("%-16s %-10s %-8s %-6s %s\n", s.Name, status, pid, port, command)
+		if s.State != nil && s.State.Error != "" {
+			fmt.Printf("%-16s error: %s\n", "", s.State.Error)
+		}
+	}
+	return nil
+}
+
+// runServicesAdd creates a service. Like `sp rc` and `--web`, an existing
+// service with the same name is replaced.
+func runServicesAdd(cmd *cobra.Command, args []string) error {
+	name := args[0]
+	resolved, client, err := resolveServiceTarget(args[1:])
+	if err != nil {
+		return err
+	}
+	dir := svcDir
+	if dir == "" {
+		dir = resolved.RemotePath
+	}
+
+	ctx := context.Background()
+	client.DeleteService(ctx, resolved.SpriteName, name)
+	if err := client.CreateService(ctx, resolved.SpriteName, sprite.ServiceOptions{
+		Name:     name,
+		Cmd:      svcCmd,
+		Args:     svcArgs,
+		Dir:      dir,
+		HTTPPort: svcHTTPPort,
+		Duration: svcDuration,
+	}); err != nil {
+		return err
+	}
+	fmt.Printf("Service %s running on sprite %s.\n", name, resolved.SpriteName)
+	if svcHTTPPort > 0 {
+		if url, err := client.GetURL(resolved.SpriteName); err == nil && url != "" {
+			fmt.Printf("  URL: %s\n", url)
+		}
+	}
+	return nil
+}
+
+// runServicesRm deletes a service.
+func runServicesRm(cmd *cobra.Command, args []string) error {
+	name := args[0]
+	resolved, client, err := resolveServiceTarget(args[1:])
+	if err != nil {
+		return err
+	}
+	if err := client.DeleteService(context.Background(), resolved.SpriteName, name); err != nil {
+		return err
+	}
+	fmt.Printf("Removed service %s from sprite %s.\n", name, resolved.SpriteName)
+	return nil
+}
+
+// runServicesLogs prints a service's log, following it until Ctrl-C with -f.
+func runServicesLogs(cmd *cobra.Command, args []string) error {
+	name := args[0]
+	resolved, client, err := resolveServiceTarget(args[1:])
+	if err != nil {
+		return err
+	}
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	return client.ServiceLogs(ctx, resolved.SpriteName, name, sprite.ServiceLogOptions{
+		Lines:  svcLines,
+		Follow: svcFollow,
+	}, os.Stdout)
+}
+
+// runServicesRestart recreates a service from its current definition.
+func runServicesRestart(cmd *cobra.Command, args []string) error {
+	name := args[0]
+	resolved, client, err := resolveServiceTarget(args[1:])
+	if err != nil {
+		return err
+	}
+	if err := client.RestartService(context.Background(), resolved.SpriteName, name, svcDuration); err != nil {
+		return err
+	}
+	fmt.Printf("Restarted service %s on sprite %s.\n", name, resolved.SpriteName)
+	return nil
+}
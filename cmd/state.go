@@ -0,0 +1,335 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/jphenow/sp/internal/daemon"
+	"github.com/jphenow/sp/internal/sprite"
+	"github.com/jphenow/sp/internal/store"
+)
+
+// stateFormatVersion is written into exports and checked on import, so a
+// future format change can be detected rather than misread.
+const stateFormatVersion = 1
+
+var (
+	stateRemaps []string
+	stateDryRun bool
+)
+
+// stateFile is the portable form of sp's tracked sprites. Local paths under
+// the home directory are written as "~/...", so they carry over between
+// machines with different user names.
+type stateFile struct {
+	Version    int            `json:"version"`
+	ExportedAt time.Time      `json:"exported_at"`
+	Sprites    []*stateSprite `json:"sprites"`
+}
+
+// stateSprite is one tracked sprite in a stateFile. Runtime state (status,
+// sync status) is left out; the daemon works it out again after import.
+type stateSprite struct {
+	Name       string            `json:"name"`
+	LocalPath  string            `json:"local_path,omitempty"`
+	RemotePath string            `json:"remote_path,omitempty"`
+	Repo       string            `json:"repo,omitempty"`
+	Org        string            `json:"org,omitempty"`
+	SpriteID   string            `json:"sprite_id,omitempty"`
+	URL        string            `json:"url,omitempty"`
+	Variant    string            `json:"variant,omitempty"`
+	BaseName   string            `json:"base_name,omitempty"`
+	Pinned     bool              `json:"pinned,omitempty"`
+	Tags       []string          `json:"tags,omitempty"`
+	Meta       map[string]string `json:"meta,omitempty"`
+}
+
+// stateCmd groups exporting and importing sp's tracked sprites.
+var stateCmd = &cobra.Command{
+	Use:   "state",
+	Short: "Export and import tracked sprites, for moving to another machine",
+	Long: `Moves the sprites sp tracks — with their tags, metadata, variants, pinned
+flags and local directories — between machines.
+
+  sp state export > sp.json                       # on the old machine
+  sp state import sp.json                         # on the new one
+  sp state import sp.json --remap-path ~/src=~/code
+  sp state import sp.json --dry-run
+
+Local paths under your home directory are exported relative to it. On import
+they can be moved with --remap-path OLD=NEW (repeatable; the longest
+matching prefix wins). Sprites that no longer exist are skipped, and a local
+path that doesn't exist on this machine is dropped — run 'sp .' in the
+checkout to link it again.`,
+}
+
+var stateExportCmd = &cobra.Command{
+	Use:   "export",
+	Short: "Write tracked sprites as JSON to stdout",
+	Args:  cobra.NoArgs,
+	RunE:  runStateExport,
+}
+
+var stateImportCmd = &cobra.Command{
+	Use:   "import <file>",
+	Short: "Track the sprites from an export (- for stdin)",
+	Args:  cobra.ExactArgs(1),
+	RunE:  runStateImport,
+}
+
+func init() {
+	stateImportCmd.Flags().StringArrayVar(&stateRemaps, "remap-path", nil, "move local paths under OLD to NEW (OLD=NEW, repeatable)")
+	stateImportCmd.Flags().BoolVar(&stateDryRun, "dry-run", false, "show what would be imported without changing anything")
+
+	stateCmd.AddCommand(stateExportCmd, stateImportCmd)
+	rootCmd.AddCommand(stateCmd)
+}
+
+func runStateExport(cmd *cobra.Command, args []string) error {
+	dc, err := daemon.Connect()
+	if err != nil {
+		return fmt.Errorf("connecting to daemon: %w", err)
+	}
+	defer dc.Close()
+
+	home, _ := os.UserHomeDir()
+	state, err := exportState(dc, home)
+	if err != nil {
+		return err
+	}
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	return enc.Encode(state)
+}
+
+// exportState collects every tracked sprite and its tags from the daemon.
+func exportState(dc *daemon.Client, home string) (*stateFile, error) {
+	sprites, err := dc.ListSprites(store.ListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("listing sprites: %w", err)
+	}
+
+	state := &stateFile{Version: stateFormatVersion, ExportedAt: time.Now(), Sprites: []*stateSprite{}}
+	for _, s := range sprites {
+		tags, err := dc.GetTags(s.Name)
+		if err != nil {
+			return nil, fmt.Errorf("getting tags for %s: %w", s.Name, err)
+		}
+		meta, err := dc.GetMeta(s.Name)
+		if err != nil {
+			return nil, fmt.Errorf("getting metadata for %s: %w", s.Name, err)
+		}
+		ss := &stateSprite{
+			Name:       s.Name,
+			LocalPath:  portablePath(s.LocalPath, home),
+			RemotePath: s.RemotePath,
+			Repo:       s.Repo,
+			Org:        s.Org,
+			SpriteID:   s.SpriteID,
+			URL:        s.URL,
+			Variant:    s.Variant,
+			BaseName:   s.BaseName,
+			Pinned:     s.Pinned,
+			Tags:       tags,
+		}
+		for _, m := range meta {
+			if ss.Meta == nil {
+				ss.Meta = make(map[string]string)
+			}
+			ss.Meta[m.Key] = m.Value
+		}
+		state.Sprites = append(state.Sprites, ss)
+	}
+	return state, nil
+}
+
+func runStateImport(cmd *cobra.Command, args []string) error {
+	var (
+		data []byte
+		err  error
+	)
+	if args[0] == "-" {
+		data, err = io.ReadAll(os.Stdin)
+	} else {
+		data, err = os.ReadFile(args[0])
+	}
+	if err != nil {
+		return fmt.Errorf("reading %s: %w", args[0], err)
+	}
+	var state stateFile
+	if err := json.Unmarshal(data, &state); err != nil {
+		return fmt.Errorf("parsing %s: %w", args[0], err)
+	}
+	if state.Version != stateFormatVersion {
+		return fmt.Errorf("%s is state format version %d; this sp reads version %d", args[0], state.Version, stateFormatVersion)
+	}
+
+	home, _ := os.UserHomeDir()
+	remaps, err := parsePathRemaps(stateRemaps, home)
+	if err != nil {
+		return err
+	}
+
+	// Only bring over sprites that still exist, checking each org once.
+	existing := make(map[string]map[string]bool)
+	for _, s := range state.Sprites {
+		if _, ok := existing[s.Org]; ok {
+			continue
+		}
+		infos, err := sprite.NewClient(s.Org).List()
+		if err != nil {
+			return fmt.Errorf("listing sprites in org %q: %w", s.Org, err)
+		}
+		names := make(map[string]bool)
+		for _, info := range infos {
+			names[info.Name] = true
+		}
+		existing[s.Org] = names
+	}
+
+	var dc *daemon.Client
+	if !stateDryRun {
+		dc, err = daemon.Connect()
+		if err != nil {
+			return fmt.Errorf("connecting to daemon: %w", err)
+		}
+		defer dc.Close()
+	}
+
+	var imported, skipped int
+	for _, s := range state.Sprites {
+		if !existing[s.Org][s.Name] {
+			fmt.Printf("  skip    %s (no longer exists)\n", s.Name)
+			skipped++
+			continue
+		}
+
+		localPath := resolveStatePath(s.LocalPath, remaps, home)
+		note := localPath
+		if localPath != "" {
+			if info, err := os.Stat(localPath); err != nil || !info.IsDir() {
+				note = localPath + " (missing, not linked)"
+				localPath = ""
+			}
+		}
+		fmt.Printf("  import  %-40s %s\n", s.Name, note)
+		imported++
+		if stateDryRun {
+			continue
+		}
+
+		err := dc.UpsertSprite(&store.Sprite{
+			Name:       s.Name,
+			LocalPath:  localPath,
+			RemotePath: s.RemotePath,
+			Repo:       s.Repo,
+			Org:        s.Org,
+			SpriteID:   s.SpriteID,
+			URL:        s.URL,
+			Variant:    s.Variant,
+			BaseName:   s.BaseName,
+		})
+		if err != nil {
+			return fmt.Errorf("importing %s: %w", s.Name, err)
+		}
+		if s.Pinned {
+			if err := dc.SetPinned(s.Name, true); err != nil {
+				return fmt.Errorf("pinning %s: %w", s.Name, err)
+			}
+		}
+		for _, tag := range s.Tags {
+			if err := dc.TagSprite(s.Name, tag); err != nil {
+				return fmt.Errorf("tagging %s: %w", s.Name, err)
+			}
+		}
+		for key, value := range s.Meta {
+			if err := dc.SetMeta(s.Name, key, value); err != nil {
+				return fmt.Errorf("annotating %s: %w", s.Name, err)
+			}
+		}
+	}
+
+	verb := "Imported"
+	if stateDryRun {
+		verb = "Would import"
+	}
+	fmt.Printf("%s %d sprite(s), skipped %d.\n", verb, imported, skipped)
+	return nil
+}
+
+// pathRemap moves local paths under From to To.
+type pathRemap struct {
+	From, To string
+}
+
+// parsePathRemaps parses OLD=NEW flags, expanding a leading "~" on either
+// side (the shell doesn't, after the "=").
+func parsePathRemaps(flags []string, home string) ([]pathRemap, error) {
+	var remaps []pathRemap
+	for _, f := range flags {
+		from, to, ok := strings.Cut(f, "=")
+		if !ok || from == "" || to == "" {
+			return nil, fmt.Errorf("invalid --remap-path %q: want OLD=NEW", f)
+		}
+		remaps = append(remaps, pathRemap{
+			From: filepath.Clean(expandHome(from, home)),
+			To:   filepath.Clean(expandHome(to, home)),
+		})
+	}
+	return remaps, nil
+}
+
+// portablePath writes a path under home as "~/...".
+func portablePath(path, home string) string {
+	if home == "" || path == "" {
+		return path
+	}
+	if path == home {
+		return "~"
+	}
+	if rel, ok := strings.CutPrefix(path, home+string(filepath.Separator)); ok {
+		return "~/" + filepath.ToSlash(rel)
+	}
+	return path
+}
+
+// expandHome turns a leading "~" into the home directory.
+func expandHome(path, home string) string {
+	if path == "~" {
+		return home
+	}
+	if rest, ok := strings.CutPrefix(path, "~/"); ok {
+		return filepath.Join(home, filepath.FromSlash(rest))
+	}
+	return path
+}
+
+// resolveStatePath turns an exported local path into one on this machine:
+// "~" becomes this home directory, then the longest matching remap applies.
+func resolveStatePath(path string, remaps []pathRemap, home string) string {
+	if path == "" {
+		return ""
+	}
+	path = filepath.Clean(expandHome(path, home))
+
+	best := -1
+	for i, r := range remaps {
+		if path != r.From && !strings.HasPrefix(path, r.From+string(filepath.Separator)) {
+			continue
+		}
+		if best < 0 || len(r.From) > len(remaps[best].From) {
+			best = i
+		}
+	}
+	if best < 0 {
+		return path
+	}
+	return remaps[best].To + strings.TrimPrefix(path, remaps[best].From)
+}
@@ -0,0 +1,110 @@
+package cmd
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/jphenow/sp/internal/fakebin"
+	"github.com/jphenow/sp/internal/store"
+)
+
+func TestResolveStatePath(t *testing.T) {
+	home := "/home/new"
+	remaps, err := parsePathRemaps([]string{"~/src=~/code", "~/src/work=/work", "/opt/old=/opt/new"}, home)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for in, want := range map[string]string{
+		"~/src/api":      "/home/new/code/api",
+		"~/src/work/api": "/work/api",
+		"~/src":          "/home/new/code",
+		"~/srcfoo/api":   "/home/new/srcfoo/api",
+		"/opt/old/x":     "/opt/new/x",
+		"/elsewhere/x":   "/elsewhere/x",
+		"~":              "/home/new",
+		"":               "",
+	} {
+		if got := resolveStatePath(in, remaps, home); got != want {
+			t.Errorf("resolveStatePath(%q) = %q, want %q", in, got, want)
+		}
+	}
+
+	if got := portablePath("/home/old/src/api", "/home/old"); got != "~/src/api" {
+		t.Errorf("portablePath = %q, want ~/src/api", got)
+	}
+	if got := portablePath("/home/older/api", "/home/old"); got != "/home/older/api" {
+		t.Errorf("portablePath outside home = %q", got)
+	}
+	if _, err := parsePathRemaps([]string{"~/src"}, home); err == nil {
+		t.Error("remap without = accepted")
+	}
+}
+
+func TestStateExportImport(t *testing.T) {
+	if testing.Short() {
+		t.Skip("end-to-end state test")
+	}
+	env := fakebin.New(t)
+	env.AddSprite("gh-acme--api", "cold")
+	env.AddSprite("gh-acme--api--spike", "cold")
+	dc := startTestDaemon(t)
+
+	oldDir := filepath.Join(env.Home, "src", "api")
+	newDir := filepath.Join(env.Home, "code", "api")
+	for _, dir := range []string{oldDir, newDir} {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	org := env.Sprite("gh-acme--api").Organization
+	for _, s := range []*store.Sprite{
+		{Name: "gh-acme--api", LocalPath: oldDir, RemotePath: "/home/sprite/api", Repo: "acme/api", Org: org},
+		{Name: "gh-acme--api--spike", LocalPath: filepath.Join(env.Home, "src", "gone"), Org: org, Variant: "spike", BaseName: "gh-acme--api"},
+		{Name: "deleted-sprite", Org: org},
+	} {
+		if err := dc.UpsertSprite(s); err != nil {
+			t.Fatal(err)
+		}
+	}
+	dc.SetPinned("gh-acme--api--spike", true)
+	dc.TagSprite("gh-acme--api", "work")
+	dc.SetMeta("gh-acme--api", "owner", "sam")
+
+	state, err := exportState(dc, env.Home)
+	if err != nil {
+		t.Fatalf("exportState: %v", err)
+	}
+	data, _ := json.Marshal(state)
+	file := filepath.Join(t.TempDir(), "sp.json")
+	os.WriteFile(file, data, 0o644)
+
+	// Import into an empty database, moving ~/src to ~/code.
+	for _, s := range state.Sprites {
+		dc.DeleteSprite(s.Name)
+	}
+	stateRemaps = []string{"~/src=~/code"}
+	t.Cleanup(func() { stateRemaps = nil })
+	if err := runStateImport(stateImportCmd, []string{file}); err != nil {
+		t.Fatalf("import: %v", err)
+	}
+
+	api, _ := dc.GetSprite("gh-acme--api")
+	if api == nil || api.LocalPath != newDir || api.Repo != "acme/api" || api.RemotePath != "/home/sprite/api" {
+		t.Errorf("api = %+v, want it linked to %s", api, newDir)
+	}
+	if tags, _ := dc.GetTags("gh-acme--api"); len(tags) != 1 || tags[0] != "work" {
+		t.Errorf("api tags = %v, want [work]", tags)
+	}
+	if meta, _ := dc.GetMeta("gh-acme--api"); len(meta) != 1 || meta[0].Key != "owner" || meta[0].Value != "sam" {
+		t.Errorf("api metadata = %+v, want owner=sam", meta)
+	}
+	spike, _ := dc.GetSprite("gh-acme--api--spike")
+	if spike == nil || !spike.Pinned || spike.Variant != "spike" || spike.BaseName != "gh-acme--api" || spike.LocalPath != "" {
+		t.Errorf("spike = %+v, want a pinned variant without its missing local path", spike)
+	}
+	if gone, _ := dc.GetSprite("deleted-sprite"); gone != nil {
+		t.Errorf("imported a sprite that no longer exists: %+v", gone)
+	}
+}
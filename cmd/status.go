@@ -1,6 +1,7 @@
 package cmd
 
 import (
+	"context"
 	"fmt"
 	"strings"
 
@@ -8,12 +9,12 @@ import (
 
 	"github.com/jphenow/sp/internal/daemon"
 	"github.com/jphenow/sp/internal/store"
-	spSync "github.com/jphenow/sp/internal/sync"
 )
 
 var (
 	statusOnlyVariants bool
 	statusVariantsOf   string
+	statusQuery        string
 )
 
 // statusCmd shows the status of sprites.
@@ -28,7 +29,11 @@ With a sprite name, shows detailed status including sync information.
 Filtering flags:
   --variants               show only variant sprites (ones spawned via "sp . foo")
   --variants-of <base>     show only variants whose base matches <base>, e.g.
-                           "sp status --variants-of gh-fly--flyctl"`,
+                           "sp status --variants-of gh-fly--flyctl"
+  --query <query>          show only sprites matching a query, e.g.
+                           "team:infra AND NOT archived", "status:running",
+                           "tag:a,b" (both tags), "variant:*", "age>7d".
+                           A bare word matches a name, path or tag.`,
 	Args: cobra.MaximumNArgs(1),
 	RunE: func(cmd *cobra.Command, args []string) error {
 		dc, err := daemon.Connect()
@@ -43,6 +48,7 @@ Filtering flags:
 		return showAllStatus(dc, store.ListOptions{
 			OnlyVariants: statusOnlyVariants || statusVariantsOf != "",
 			VariantsOf:   statusVariantsOf,
+			Query:        statusQuery,
 		})
 	},
 }
@@ -66,7 +72,7 @@ func showSpriteStatus(dc *daemon.Client, name string) error {
 	fmt.Printf("  Repo:        %s\n", s.Repo)
 
 	// Check live Mutagen status
-	mutagenState, err := spSync.GetMutagenStatus(name)
+	mutagenState, err := syncEngine.Status(context.Background(), name)
 	if err == nil {
 		fmt.Printf("\nMutagen Session:\n")
 		fmt.Printf("  ID:             %s\n", mutagenState.MutagenID)
@@ -84,6 +90,12 @@ func showSpriteStatus(dc *daemon.Client, name string) error {
 		fmt.Printf("\nTags: %s\n", strings.Join(tags, ", "))
 	}
 
+	meta, err := dc.GetMeta(name)
+	if err == nil && len(meta) > 0 {
+		fmt.Printf("\nMetadata:\n")
+		printMeta(meta, "  ")
+	}
+
 	return nil
 }
 
@@ -99,6 +111,10 @@ func showAllStatus(dc *daemon.Client, opts store.ListOptions) error {
 			fmt.Println("No variant sprites match.")
 			return nil
 		}
+		if opts.Query != "" {
+			fmt.Println("No sprites match the query.")
+			return nil
+		}
 		fmt.Println("No tracked sprites. Use 'sp import' to add existing sprites.")
 		return nil
 	}
@@ -156,5 +172,6 @@ func trimPath(p string, max int) string {
 func init() {
 	statusCmd.Flags().BoolVar(&statusOnlyVariants, "variants", false, "only show variant sprites")
 	statusCmd.Flags().StringVar(&statusVariantsOf, "variants-of", "", "only show variants of the given base sprite name")
+	statusCmd.Flags().StringVarP(&statusQuery, "query", "q", "", "only show sprites matching a query (see --help)")
 	rootCmd.AddCommand(statusCmd)
 }
@@ -9,12 +9,14 @@ import (
 	"github.com/spf13/cobra"
 
 	"github.com/jphenow/sp/internal/daemon"
+	"github.com/jphenow/sp/internal/store"
 	"github.com/jphenow/sp/internal/tui"
 )
 
 var (
 	filterTags   string
 	filterPrefix string
+	filterQuery  string
 )
 
 // tuiCmd opens the TUI dashboard for monitoring all sprites.
@@ -24,8 +26,10 @@ var tuiCmd = &cobra.Command{
 	Long: `Opens an interactive terminal UI showing all your sprite environments,
 their status (running/warm/cold), and sync state.
 
-Use --filter to show only sprites with specific tags, or --prefix to filter
-by local directory path.
+Use --filter to show only sprites with specific tags, --prefix to filter
+by local directory path, or --query for anything else (the same syntax as
+'sp status --query', e.g. "team:infra AND NOT archived"). The dashboard's
+[f] filter takes a query too.
 
 Multiple TUI instances can run simultaneously with different filters.`,
 	RunE: func(cmd *cobra.Command, args []string) error {
@@ -44,6 +48,12 @@ Multiple TUI instances can run simultaneously with different filters.`,
 		if filterPrefix != "" {
 			opts.PathPrefix = filterPrefix
 		}
+		if filterQuery != "" {
+			if _, err := store.ParseQuery(filterQuery); err != nil {
+				return err
+			}
+			opts.Query = filterQuery
+		}
 
 		// Create and run TUI
 		model := tui.NewModel(dc, opts)
@@ -71,5 +81,6 @@ Multiple TUI instances can run simultaneously with different filters.`,
 func init() {
 	tuiCmd.Flags().StringVar(&filterTags, "filter", "", "filter by tags (comma-separated)")
 	tuiCmd.Flags().StringVar(&filterPrefix, "prefix", "", "filter by local path prefix")
+	tuiCmd.Flags().StringVarP(&filterQuery, "query", "q", "", "filter by a query, e.g. \"status:running AND NOT archived\"")
 	rootCmd.AddCommand(tuiCmd)
 }
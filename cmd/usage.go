@@ -0,0 +1,255 @@
+package cmd
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/jphenow/sp/internal/daemon"
+	"github.com/jphenow/sp/internal/store"
+)
+
+var (
+	usageSince  = days(30 * 24 * time.Hour)
+	usageBy     string
+	usageRate   float64
+	usageFormat string
+)
+
+// usageCmd reports how long sprites spent running, from the intervals the
+// daemon records while polling sprite status.
+var usageCmd = &cobra.Command{
+	Use:   "usage",
+	Short: "Show how long sprites ran and what it cost",
+	Long: `Totals the time sprites spent running, as recorded by the daemon's status
+polling, with an estimated cost at an hourly rate.
+
+  sp usage                          # every sprite, last 30 days
+  sp usage --since 7d --by tag
+  sp usage --by variant --rate 0.50
+  sp usage --format csv > usage.csv
+
+--by groups by sprite (default), variant name ("(base)" for sprites that
+aren't variants), or tag. A sprite with several tags counts toward each.
+
+The rate is in dollars per running hour; it defaults to $SP_HOURLY_RATE and
+the cost column is left out when no rate is set. Only time the daemon was
+able to observe is counted, so treat the totals as an estimate.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if !cmd.Flags().Changed("rate") {
+			if env := os.Getenv("SP_HOURLY_RATE"); env != "" {
+				rate, err := strconv.ParseFloat(env, 64)
+				if err != nil {
+					return fmt.Errorf("parsing SP_HOURLY_RATE: %w", err)
+				}
+				usageRate = rate
+			}
+		}
+
+		dc, err := daemon.Connect()
+		if err != nil {
+			return fmt.Errorf("connecting to daemon: %w", err)
+		}
+		defer dc.Close()
+
+		to := time.Now()
+		from := to.Add(-time.Duration(usageSince))
+		intervals, err := dc.ListUsage(from)
+		if err != nil {
+			return fmt.Errorf("listing usage: %w", err)
+		}
+
+		var groupOf func(*store.UsageInterval) []string
+		switch usageBy {
+		case "sprite":
+			groupOf = func(u *store.UsageInterval) []string { return []string{u.SpriteName} }
+		case "variant":
+			groupOf = func(u *store.UsageInterval) []string {
+				if u.Variant == "" {
+					return []string{"(base)"}
+				}
+				return []string{u.Variant}
+			}
+		case "tag":
+			tags := make(map[string][]string)
+			for _, u := range intervals {
+				if _, ok := tags[u.SpriteName]; !ok {
+					tags[u.SpriteName], _ = dc.GetTags(u.SpriteName)
+				}
+			}
+			groupOf = func(u *store.UsageInterval) []string {
+				if len(tags[u.SpriteName]) == 0 {
+					return []string{"(untagged)"}
+				}
+				return tags[u.SpriteName]
+			}
+		default:
+			return fmt.Errorf("unknown --by %q (want sprite, variant or tag)", usageBy)
+		}
+
+		rows := summarizeUsage(intervals, from, to, groupOf)
+		switch usageFormat {
+		case "table":
+			printUsageTable(rows, usageRate)
+			return nil
+		case "csv":
+			return writeUsageCSV(rows, usageRate)
+		case "json":
+			return writeUsageJSON(rows, from, to, usageRate)
+		default:
+			return fmt.Errorf("unknown --format %q (want table, csv or json)", usageFormat)
+		}
+	},
+}
+
+// usageRow is the running time of one group of sprites.
+type usageRow struct {
+	Group   string        `json:"group"`
+	Running time.Duration `json:"-"`
+	Hours   float64       `json:"hours"`
+	Cost    float64       `json:"cost,omitempty"`
+}
+
+// summarizeUsage totals the part of each interval between from and to by
+// group, busiest first.
+func summarizeUsage(intervals []*store.UsageInterval, from, to time.Time, groupOf func(*store.UsageInterval) []string) []*usageRow {
+	byGroup := make(map[string]*usageRow)
+	for _, u := range intervals {
+		d := u.Overlap(from, to)
+		if d == 0 {
+			continue
+		}
+		for _, g := range groupOf(u) {
+			row, ok := byGroup[g]
+			if !ok {
+				row = &usageRow{Group: g}
+				byGroup[g] = row
+			}
+			row.Running += d
+		}
+	}
+
+	rows := make([]*usageRow, 0, len(byGroup))
+	for _, row := range byGroup {
+		row.Hours = row.Running.Hours()
+		rows = append(rows, row)
+	}
+	sort.Slice(rows, func(i, j int) bool {
+		if rows[i].Running != rows[j].Running {
+			return rows[i].Running > rows[j].Running
+		}
+		return rows[i].Group < rows[j].Group
+	})
+	return rows
+}
+
+// printUsageTable prints usage rows with a total, and costs if rate is set.
+func printUsageTable(rows []*usageRow, rate float64) {
+	if len(rows) == 0 {
+		fmt.Printf("No running time recorded in the last %s.\n", usageSince)
+		return
+	}
+	var total float64
+	fmt.Printf("%-40s  %9s", strings.ToUpper(usageBy), "HOURS")
+	if rate > 0 {
+		fmt.Printf("  %10s", "COST")
+	}
+	fmt.Println()
+	for _, row := range rows {
+		total += row.Hours
+		fmt.Printf("%-40s  %9.2f", row.Group, row.Hours)
+		if rate > 0 {
+			fmt.Printf("  %10s", fmt.Sprintf("$%.2f", row.Hours*rate))
+		}
+		fmt.Println()
+	}
+	if usageBy == "tag" {
+		return // sprites with several tags are counted more than once
+	}
+	fmt.Printf("%-40s  %9.2f", "TOTAL", total)
+	if rate > 0 {
+		fmt.Printf("  %10s", fmt.Sprintf("$%.2f", total*rate))
+	}
+	fmt.Println()
+}
+
+// writeUsageCSV writes usage rows as CSV to stdout.
+func writeUsageCSV(rows []*usageRow, rate float64) error {
+	w := csv.NewWriter(os.Stdout)
+	header := []string{usageBy, "hours"}
+	if rate > 0 {
+		header = append(header, "cost")
+	}
+	w.Write(header)
+	for _, row := range rows {
+		record := []string{row.Group, strconv.FormatFloat(row.Hours, 'f', 2, 64)}
+		if rate > 0 {
+			record = append(record, strconv.FormatFloat(row.Hours*rate, 'f', 2, 64))
+		}
+		w.Write(record)
+	}
+	w.Flush()
+	return w.Error()
+}
+
+// writeUsageJSON writes usage rows and the period they cover as JSON to stdout.
+func writeUsageJSON(rows []*usageRow, from, to time.Time, rate float64) error {
+	for _, row := range rows {
+		row.Cost = row.Hours * rate
+	}
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	return enc.Encode(struct {
+		From  time.Time   `json:"from"`
+		To    time.Time   `json:"to"`
+		By    string      `json:"by"`
+		Rate  float64     `json:"rate,omitempty"`
+		Usage []*usageRow `json:"usage"`
+	}{from, to, usageBy, rate, rows})
+}
+
+// days is a duration flag that also accepts a whole number of days, e.g. "30d".
+type days time.Duration
+
+func (d *days) Set(s string) error {
+	if n, ok := strings.CutSuffix(s, "d"); ok {
+		count, err := strconv.Atoi(n)
+		if err != nil {
+			return fmt.Errorf("invalid number of days %q", s)
+		}
+		*d = days(time.Duration(count) * 24 * time.Hour)
+		return nil
+	}
+	v, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	*d = days(v)
+	return nil
+}
+
+func (d days) String() string {
+	v := time.Duration(d)
+	if v > 0 && v%(24*time.Hour) == 0 {
+		return fmt.Sprintf("%dd", v/(24*time.Hour))
+	}
+	return v.String()
+}
+
+func (d *days) Type() string { return "duration" }
+
+func init() {
+	usageCmd.Flags().Var(&usageSince, "since", "how far back to count (e.g. 30d, 12h)")
+	usageCmd.Flags().StringVar(&usageBy, "by", "sprite", "group by sprite, variant or tag")
+	usageCmd.Flags().Float64Var(&usageRate, "rate", 0, "dollars per running hour for the cost estimate (default $SP_HOURLY_RATE)")
+	usageCmd.Flags().StringVar(&usageFormat, "format", "table", "output format: table, csv or json")
+	rootCmd.AddCommand(usageCmd)
+}
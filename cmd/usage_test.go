@@ -0,0 +1,64 @@
+package cmd
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/jphenow/sp/internal/store"
+)
+
+func TestSummarizeUsage(t *testing.T) {
+	to := time.Date(2026, 3, 31, 0, 0, 0, 0, time.UTC)
+	from := to.Add(-48 * time.Hour)
+	at := func(h int) time.Time { return from.Add(time.Duration(h) * time.Hour) }
+
+	intervals := []*store.UsageInterval{
+		{SpriteName: "api", StartedAt: at(-5), EndedAt: at(2)}, // 2h inside the window
+		{SpriteName: "api--spike", Variant: "spike", StartedAt: at(10), EndedAt: at(13)},
+		{SpriteName: "api", StartedAt: at(20), EndedAt: at(21)},
+		{SpriteName: "web", StartedAt: at(45)}, // still running
+		{SpriteName: "old", StartedAt: at(-10), EndedAt: at(-2)},
+	}
+	bySprite := func(u *store.UsageInterval) []string { return []string{u.SpriteName} }
+
+	rows := summarizeUsage(intervals, from, to, bySprite)
+	var got []string
+	for _, r := range rows {
+		got = append(got, fmt.Sprintf("%s=%v", r.Group, r.Running))
+	}
+	if want := "[api=3h0m0s api--spike=3h0m0s web=3h0m0s]"; fmt.Sprint(got) != want {
+		t.Errorf("by sprite = %v, want %v", got, want)
+	}
+
+	byTag := func(u *store.UsageInterval) []string {
+		if u.SpriteName == "api" {
+			return []string{"work", "billing"}
+		}
+		return []string{"(untagged)"}
+	}
+	rows = summarizeUsage(intervals, from, to, byTag)
+	if len(rows) != 3 || rows[0].Group != "(untagged)" || rows[0].Hours != 6 || rows[1].Hours != 3 {
+		t.Errorf("by tag = %+v", rows)
+	}
+}
+
+func TestDaysFlag(t *testing.T) {
+	for in, want := range map[string]time.Duration{
+		"30d": 30 * 24 * time.Hour,
+		"12h": 12 * time.Hour,
+		"1d":  24 * time.Hour,
+	} {
+		var d days
+		if err := d.Set(in); err != nil || time.Duration(d) != want {
+			t.Errorf("Set(%q) = %v, %v; want %v", in, time.Duration(d), err, want)
+		}
+	}
+	var d days
+	if err := d.Set("xd"); err == nil {
+		t.Error("Set(xd) succeeded")
+	}
+	if s := days(30 * 24 * time.Hour).String(); s != "30d" {
+		t.Errorf("String() = %q, want 30d", s)
+	}
+}
@@ -4,81 +4,189 @@ import (
 	"encoding/json"
 	"fmt"
 	"net"
+	"os"
 	"sync"
+	"time"
 
 	"github.com/jphenow/sp/internal/store"
+	spSync "github.com/jphenow/sp/internal/sync"
 )
 
 // Client connects to the sp daemon over Unix socket to issue requests.
-// All RPC calls are serialized via mu since json.Encoder/Decoder are not
-// safe for concurrent use.
+// It is safe for concurrent use: each call carries an ID, and a reader
+// goroutine hands each reply to the call waiting for it, so any number of
+// calls can be in flight on the one connection alongside a subscription.
 type Client struct {
-	conn    net.Conn
+	conn net.Conn
+
+	sendMu  sync.Mutex // serializes writes to encoder
 	encoder *json.Encoder
-	decoder *json.Decoder
+
 	mu      sync.Mutex
+	nextID  uint64
+	pending map[uint64]chan Response // calls awaiting a reply, by request ID
+	events  chan StateUpdate         // set by Subscribe
+	err     error                    // why the connection ended, once it has
 }
 
 // Connect establishes a connection to the daemon, starting it if needed.
+// A daemon left over from before an upgrade re-execs itself once it
+// notices the new binary, so Connect waits for that rather than failing.
 func Connect() (*Client, error) {
 	socketPath, err := EnsureRunning()
 	if err != nil {
 		return nil, fmt.Errorf("ensuring daemon is running: %w", err)
 	}
-	return ConnectTo(socketPath)
+	c, err := ConnectTo(socketPath)
+	if !daemonOutdated(err) {
+		return c, err
+	}
+	config, cerr := LoadConfig(ConfigPath())
+	if cerr != nil {
+		config = DefaultConfig()
+	}
+	for deadline := time.Now().Add(2 * config.BinaryCheckInterval); err != nil && time.Now().Before(deadline); {
+		time.Sleep(500 * time.Millisecond)
+		c, err = ConnectTo(socketPath)
+	}
+	return c, err
 }
 
-// ConnectTo connects to a daemon at the given Unix socket path.
+// ConnectTo connects to a daemon at the given Unix socket path and performs
+// the protocol handshake, returning a *ProtocolError if the daemon speaks a
+// different protocol version.
 func ConnectTo(socketPath string) (*Client, error) {
 	conn, err := net.Dial("unix", socketPath)
 	if err != nil {
 		return nil, fmt.Errorf("connecting to daemon at %s: %w", socketPath, err)
 	}
-	return &Client{
+	c := &Client{
 		conn:    conn,
 		encoder: json.NewEncoder(conn),
-		decoder: json.NewDecoder(conn),
-	}, nil
+		pending: make(map[uint64]chan Response),
+	}
+	go c.readLoop(json.NewDecoder(conn))
+	if err := c.handshake(); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return c, nil
 }
 
-// Close closes the connection to the daemon.
+// Close closes the connection to the daemon. Calls still in flight fail.
 func (c *Client) Close() error {
 	return c.conn.Close()
 }
 
-// call sends a request to the daemon and returns the raw response.
-// Serialized via mutex since the encoder/decoder are not safe for concurrent use.
-func (c *Client) call(method string, params interface{}) (json.RawMessage, error) {
+// readLoop routes everything the daemon sends: replies to the call with
+// the matching ID, and pushed state updates to the Subscribe channel. It
+// runs until the connection ends, then fails any calls still waiting.
+func (c *Client) readLoop(decoder *json.Decoder) {
+	for {
+		var resp Response
+		if err := decoder.Decode(&resp); err != nil {
+			c.fail(fmt.Errorf("reading response: %w", err))
+			return
+		}
+
+		switch {
+		case resp.Event != nil:
+			c.mu.Lock()
+			if c.events != nil {
+				select {
+				case c.events <- *resp.Event:
+				default:
+					// Drop if the subscriber is slow, as the daemon does.
+				}
+			}
+			c.mu.Unlock()
+		case resp.ID == 0:
+			// A reply to no request: the daemon is refusing the connection.
+			c.fail(fmt.Errorf("daemon error: %s", resp.Error))
+			return
+		default:
+			c.mu.Lock()
+			ch := c.pending[resp.ID]
+			delete(c.pending, resp.ID)
+			c.mu.Unlock()
+			if ch != nil {
+				ch <- resp
+			}
+		}
+	}
+}
+
+// fail records why the connection ended and wakes every waiting call and
+// subscriber.
+func (c *Client) fail(err error) {
 	c.mu.Lock()
 	defer c.mu.Unlock()
+	if c.err == nil {
+		c.err = err
+	}
+	for id, ch := range c.pending {
+		close(ch)
+		delete(c.pending, id)
+	}
+	if c.events != nil {
+		close(c.events)
+		c.events = nil
+	}
+	c.conn.Close()
+}
 
+// roundTrip sends a request and waits for its reply, whatever it says.
+func (c *Client) roundTrip(method string, params interface{}) (Response, error) {
 	var rawParams json.RawMessage
 	if params != nil {
 		var err error
 		rawParams, err = json.Marshal(params)
 		if err != nil {
-			return nil, fmt.Errorf("marshaling params: %w", err)
+			return Response{}, fmt.Errorf("marshaling params: %w", err)
 		}
 	}
 
-	req := Request{
-		Method: method,
-		Params: rawParams,
+	c.mu.Lock()
+	if c.err != nil {
+		err := c.err
+		c.mu.Unlock()
+		return Response{}, err
 	}
-
-	if err := c.encoder.Encode(req); err != nil {
-		return nil, fmt.Errorf("sending request: %w", err)
+	c.nextID++
+	id := c.nextID
+	ch := make(chan Response, 1)
+	c.pending[id] = ch
+	c.mu.Unlock()
+
+	c.sendMu.Lock()
+	err := c.encoder.Encode(Request{ID: id, Method: method, Params: rawParams})
+	c.sendMu.Unlock()
+	if err != nil {
+		c.mu.Lock()
+		delete(c.pending, id)
+		c.mu.Unlock()
+		return Response{}, fmt.Errorf("sending request: %w", err)
 	}
 
-	var resp Response
-	if err := c.decoder.Decode(&resp); err != nil {
-		return nil, fmt.Errorf("reading response: %w", err)
+	resp, ok := <-ch
+	if !ok {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		return Response{}, c.err
 	}
+	return resp, nil
+}
 
+// call sends a request to the daemon and returns the raw result, or the
+// daemon's error.
+func (c *Client) call(method string, params interface{}) (json.RawMessage, error) {
+	resp, err := c.roundTrip(method, params)
+	if err != nil {
+		return nil, err
+	}
 	if resp.Error != "" {
 		return nil, fmt.Errorf("daemon error: %s", resp.Error)
 	}
-
 	return resp.Result, nil
 }
 
@@ -180,6 +288,32 @@ func (c *Client) GetTags(name string) ([]string, error) {
 	return tags, nil
 }
 
+// SetMeta sets a key/value annotation on a sprite, replacing any existing
+// value for the key.
+func (c *Client) SetMeta(name, key, value string) error {
+	_, err := c.call("set_meta", map[string]string{"name": name, "key": key, "value": value})
+	return err
+}
+
+// DeleteMeta removes an annotation from a sprite.
+func (c *Client) DeleteMeta(name, key string) error {
+	_, err := c.call("delete_meta", map[string]string{"name": name, "key": key})
+	return err
+}
+
+// GetMeta returns a sprite's annotations, ordered by key.
+func (c *Client) GetMeta(name string) ([]*store.Meta, error) {
+	result, err := c.call("get_meta", map[string]string{"name": name})
+	if err != nil {
+		return nil, err
+	}
+	var meta []*store.Meta
+	if err := json.Unmarshal(result, &meta); err != nil {
+		return nil, fmt.Errorf("decoding metadata: %w", err)
+	}
+	return meta, nil
+}
+
 // ImportSprite imports an existing sprite into the database with optional local path and tags.
 func (c *Client) ImportSprite(name, localPath string, tags []string) (*store.Sprite, error) {
 	result, err := c.call("import", map[string]interface{}{
@@ -211,7 +345,9 @@ func (c *Client) Resync(name, localPath, remotePath, org string) error {
 
 // ResyncWithMode tears down the current sync, performs a one-shot sync in the
 // given mode, then restores the default two-way-safe session. Use this to force
-// one side to match the other when files have drifted out of sync.
+// one side to match the other when files have drifted out of sync. Without
+// Mutagen the copy is made by the built-in engine and sync stays stopped
+// afterwards.
 func (c *Client) ResyncWithMode(name string, mode SyncMode) error {
 	_, err := c.call("resync_with_mode", map[string]interface{}{
 		"name":      name,
@@ -220,6 +356,146 @@ func (c *Client) ResyncWithMode(name string, mode SyncMode) error {
 	return err
 }
 
+// PauseSync tears down sync for a sprite and keeps it from restarting until
+// ResumeSync. It returns once sync has stopped.
+func (c *Client) PauseSync(name string) error {
+	_, err := c.call("pause_sync", map[string]string{"name": name})
+	return err
+}
+
+// ResumeSync lifts a PauseSync and restarts sync with a fresh Mutagen
+// session in the background.
+func (c *Client) ResumeSync(name string) error {
+	_, err := c.call("resume_sync", map[string]string{"name": name})
+	return err
+}
+
+// ListConflicts returns a synced sprite's conflicts, with what the local
+// and remote sides each have at them.
+func (c *Client) ListConflicts(name string) ([]spSync.ConflictDetail, error) {
+	result, err := c.call("list_conflicts", map[string]string{"name": name})
+	if err != nil {
+		return nil, err
+	}
+	var conflicts []spSync.ConflictDetail
+	if err := json.Unmarshal(result, &conflicts); err != nil {
+		return nil, fmt.Errorf("decoding conflicts: %w", err)
+	}
+	return conflicts, nil
+}
+
+// ReadConflict returns the local and remote contents of a conflicting file.
+func (c *Client) ReadConflict(name, path string) (*spSync.ConflictContents, error) {
+	result, err := c.call("read_conflict", map[string]string{"name": name, "path": path})
+	if err != nil {
+		return nil, err
+	}
+	var contents spSync.ConflictContents
+	if err := json.Unmarshal(result, &contents); err != nil {
+		return nil, fmt.Errorf("decoding conflict: %w", err)
+	}
+	return &contents, nil
+}
+
+// ResolveConflict keeps one version of a conflicting file on both sides
+// and flushes the sync session. content is the merged file when keep is
+// spSync.KeepMerged.
+func (c *Client) ResolveConflict(name, path string, keep spSync.Resolution, content []byte) error {
+	_, err := c.call("resolve_conflict", map[string]any{"name": name, "path": path, "keep": keep, "content": content})
+	return err
+}
+
+// AddCheckpoint records a checkpoint taken on a tracked sprite.
+func (c *Client) AddCheckpoint(cp *store.Checkpoint) error {
+	_, err := c.call("add_checkpoint", cp)
+	return err
+}
+
+// ListCheckpoints returns the checkpoints recorded for a sprite, newest first.
+func (c *Client) ListCheckpoints(name string) ([]*store.Checkpoint, error) {
+	result, err := c.call("list_checkpoints", map[string]string{"name": name})
+	if err != nil {
+		return nil, err
+	}
+	var checkpoints []*store.Checkpoint
+	if err := json.Unmarshal(result, &checkpoints); err != nil {
+		return nil, fmt.Errorf("decoding checkpoints: %w", err)
+	}
+	return checkpoints, nil
+}
+
+// FindCheckpoint returns a sprite's newest recorded checkpoint with the
+// given label, or nil if there is none.
+func (c *Client) FindCheckpoint(name, label string) (*store.Checkpoint, error) {
+	result, err := c.call("find_checkpoint", map[string]string{"name": name, "label": label})
+	if err != nil {
+		return nil, err
+	}
+	var checkpoint *store.Checkpoint
+	if err := json.Unmarshal(result, &checkpoint); err != nil {
+		return nil, fmt.Errorf("decoding checkpoint: %w", err)
+	}
+	return checkpoint, nil
+}
+
+// ListEvents returns history events matching opts, oldest first.
+func (c *Client) ListEvents(opts store.EventListOptions) ([]*store.Event, error) {
+	result, err := c.call("list_events", opts)
+	if err != nil {
+		return nil, err
+	}
+	var events []*store.Event
+	if err := json.Unmarshal(result, &events); err != nil {
+		return nil, fmt.Errorf("decoding events: %w", err)
+	}
+	return events, nil
+}
+
+// ListBudgets returns the budgets that apply to a sprite, or every budget if
+// name is empty, with how much of each has been used this month.
+func (c *Client) ListBudgets(name string) ([]*store.BudgetStatus, error) {
+	result, err := c.call("list_budgets", map[string]string{"name": name})
+	if err != nil {
+		return nil, err
+	}
+	var statuses []*store.BudgetStatus
+	if err := json.Unmarshal(result, &statuses); err != nil {
+		return nil, fmt.Errorf("decoding budgets: %w", err)
+	}
+	return statuses, nil
+}
+
+// SetBudget creates or replaces a budget.
+func (c *Client) SetBudget(b *store.Budget) error {
+	_, err := c.call("set_budget", b)
+	return err
+}
+
+// DeleteBudget removes a budget.
+func (c *Client) DeleteBudget(scope, target string) error {
+	_, err := c.call("delete_budget", map[string]string{"scope": scope, "target": target})
+	return err
+}
+
+// ListUsage returns the sprites' running intervals since the given time.
+func (c *Client) ListUsage(since time.Time) ([]*store.UsageInterval, error) {
+	result, err := c.call("list_usage", map[string]time.Time{"since": since})
+	if err != nil {
+		return nil, err
+	}
+	var usage []*store.UsageInterval
+	if err := json.Unmarshal(result, &usage); err != nil {
+		return nil, fmt.Errorf("decoding usage: %w", err)
+	}
+	return usage, nil
+}
+
+// RecordEvent appends an event to a sprite's history.
+func (c *Client) RecordEvent(e *store.Event) error {
+	_, err := c.call("record_event", e)
+	return err
+}
+
 // RunSetup re-runs setup.conf (files and commands) against a sprite.
 // This pushes auth tokens, dotfiles, and re-runs conditional commands
 // without tearing down sync or reconnecting.
@@ -236,9 +512,15 @@ func (c *Client) RunSetup(name string) (string, error) {
 }
 
 // Restart asks the daemon to gracefully restart (re-exec the new binary).
-// The daemon will respond, then asynchronously shut down and exec.
+// The daemon will respond, then asynchronously shut down and exec. If
+// SP_DAEMON_HTTP is set here, the new daemon uses it, so exporting it and
+// restarting turns the HTTP API on (or off, when set empty).
 func (c *Client) Restart() error {
-	_, err := c.call("restart", nil)
+	var params any
+	if addr, ok := os.LookupEnv("SP_DAEMON_HTTP"); ok {
+		params = map[string]string{"http_addr": addr}
+	}
+	_, err := c.call("restart", params)
 	return err
 }
 
@@ -292,25 +574,25 @@ func (c *Client) StopSync(name string) error {
 }
 
 // Subscribe registers for real-time state updates from the daemon.
-// Returns a channel that receives updates. The channel is closed when
-// the connection ends.
+// Returns a channel that receives updates; calls on the client keep working
+// alongside it. The channel is closed when the connection ends. Updates are
+// dropped if the channel's buffer is full.
 func (c *Client) Subscribe() (<-chan StateUpdate, error) {
-	_, err := c.call("subscribe", nil)
-	if err != nil {
+	// Set up the channel first: updates can arrive before the reply.
+	c.mu.Lock()
+	if c.err != nil {
+		err := c.err
+		c.mu.Unlock()
 		return nil, err
 	}
+	if c.events == nil {
+		c.events = make(chan StateUpdate, 100)
+	}
+	ch := c.events
+	c.mu.Unlock()
 
-	ch := make(chan StateUpdate, 100)
-	go func() {
-		defer close(ch)
-		for {
-			var update StateUpdate
-			if err := c.decoder.Decode(&update); err != nil {
-				return
-			}
-			ch <- update
-		}
-	}()
-
+	if _, err := c.call("subscribe", nil); err != nil {
+		return nil, err
+	}
 	return ch, nil
 }
@@ -0,0 +1,495 @@
+package daemon
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/jphenow/sp/internal/sprite"
+)
+
+// config.toml tunes the daemon's polling without a rebuild: fewer API calls
+// on a metered connection, or slower health checks with many sprites. It
+// also configures hooks, webhooks and the sprite backend. It takes a small
+// subset of TOML — [daemon] and [health] tables of key = "duration" pairs,
+// and a [sprite] table and [[hooks]] and [[webhooks]] tables of strings —
+// for example:
+//
+//	[health]
+//	poll_interval = "5m"
+//	sync_reset_interval = "30m"
+//
+//	[sprite]
+//	backend = "http"
+//
+//	[[hooks]]
+//	on = ["sync_status:conflicts"]
+//	run = "notify-send 'sync conflicts'"
+//
+// Each [daemon] and [health] key can be overridden by an environment
+// variable, SP_<TABLE>_<KEY> (SP_HEALTH_POLL_INTERVAL=5m); the [sprite]
+// keys by SP_SPRITE_BACKEND and SPRITES_API_URL. The daemon reloads the
+// file on SIGHUP and when it changes.
+
+// configCheckInterval is how often the daemon checks config.toml for changes.
+const configCheckInterval = 5 * time.Second
+
+// configKey is one setting in config.toml.
+type configKey struct {
+	table, name string
+	field       func(*Config) *time.Duration
+	zeroOK      bool // 0 turns the behaviour off
+}
+
+// configKeys are the settings config.toml accepts, all durations.
+var configKeys = []configKey{
+	{"daemon", "idle_timeout", func(c *Config) *time.Duration { return &c.IdleTimeout }, true},
+	{"daemon", "binary_check_interval", func(c *Config) *time.Duration { return &c.BinaryCheckInterval }, false},
+	{"health", "poll_interval", func(c *Config) *time.Duration { return &c.HealthPollInterval }, false},
+	{"health", "network_interval", func(c *Config) *time.Duration { return &c.NetworkCheckInterval }, false},
+	{"health", "sync_interval", func(c *Config) *time.Duration { return &c.SyncCheckInterval }, false},
+	{"health", "proxy_interval", func(c *Config) *time.Duration { return &c.ProxyCheckInterval }, false},
+	{"health", "sync_reset_interval", func(c *Config) *time.Duration { return &c.SyncResetInterval }, false},
+	{"health", "connecting_timeout", func(c *Config) *time.Duration { return &c.ConnectingRecoveryTimeout }, false},
+	{"health", "notify_interval", func(c *Config) *time.Duration { return &c.NotifyInterval }, true},
+}
+
+// env is the environment variable that overrides the key.
+func (k configKey) env() string {
+	return "SP_" + strings.ToUpper(k.table+"_"+k.name)
+}
+
+// ConfigSetting is a config.toml setting and its value.
+type ConfigSetting struct {
+	Key   string // table.name, e.g. health.poll_interval
+	Env   string // the environment variable that overrides it
+	Value time.Duration
+}
+
+// Settings returns the config.toml settings and their values in c.
+func (c Config) Settings() []ConfigSetting {
+	settings := make([]ConfigSetting, len(configKeys))
+	for i, k := range configKeys {
+		settings[i] = ConfigSetting{Key: k.table + "." + k.name, Env: k.env(), Value: *k.field(&c)}
+	}
+	return settings
+}
+
+// ConfigPath returns the default path of the daemon's config file.
+func ConfigPath() string {
+	return filepath.Join(filepath.Dir(DefaultConfig().SocketPath), "config.toml")
+}
+
+// LoadConfig returns DefaultConfig with the settings from the config file at
+// path, then the environment, applied over it. A missing file is fine; an
+// unknown key or an invalid value is an error.
+func LoadConfig(path string) (Config, error) {
+	config := DefaultConfig()
+	config.ConfigFile = path
+	f, err := os.Open(path)
+	if err != nil && !os.IsNotExist(err) {
+		return Config{}, fmt.Errorf("opening %s: %w", path, err)
+	}
+	if err == nil {
+		defer f.Close()
+		if err := parseConfig(f, &config); err != nil {
+			return Config{}, fmt.Errorf("%s: %w", path, err)
+		}
+	}
+
+	for _, k := range configKeys {
+		v, ok := os.LookupEnv(k.env())
+		if !ok {
+			continue
+		}
+		d, err := time.ParseDuration(strings.TrimSpace(v))
+		if err != nil {
+			return Config{}, fmt.Errorf("%s: invalid duration %q", k.env(), v)
+		}
+		*k.field(&config) = d
+	}
+
+	if err := config.Validate(); err != nil {
+		return Config{}, err
+	}
+	return config, nil
+}
+
+// parseConfig reads config.toml settings from r into config.
+func parseConfig(r io.Reader, config *Config) error {
+	table := ""
+	scanner := bufio.NewScanner(r)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+
+		// Skip empty lines and comments
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		// Table headers; [[hooks]] and [[webhooks]] start another entry
+		if strings.HasPrefix(line, "[") {
+			header, _, _ := strings.Cut(line, "#")
+			header = strings.TrimSpace(header)
+			if !strings.HasSuffix(header, "]") {
+				return fmt.Errorf("line %d: invalid table header %q", n, line)
+			}
+			if strings.HasPrefix(header, "[[") {
+				switch table = strings.TrimSpace(strings.Trim(header, "[]")); table {
+				case "hooks":
+					config.Hooks = append(config.Hooks, Hook{})
+				case "webhooks":
+					config.Webhooks = append(config.Webhooks, Webhook{})
+				default:
+					return fmt.Errorf("line %d: unknown table %s", n, header)
+				}
+				continue
+			}
+			table = strings.TrimSpace(strings.Trim(header, "[]"))
+			if table != "daemon" && table != "health" && table != "sprite" {
+				return fmt.Errorf("line %d: unknown table [%s]", n, table)
+			}
+			continue
+		}
+
+		name, value, ok := strings.Cut(line, "=")
+		if !ok {
+			return fmt.Errorf("line %d: want key = value, got %q", n, line)
+		}
+		name, value = strings.TrimSpace(name), strings.TrimSpace(value)
+
+		if table == "hooks" {
+			if err := setHookKey(&config.Hooks[len(config.Hooks)-1], name, value); err != nil {
+				return fmt.Errorf("line %d: %w", n, err)
+			}
+			continue
+		}
+		if table == "webhooks" {
+			if err := setWebhookKey(&config.Webhooks[len(config.Webhooks)-1], name, value); err != nil {
+				return fmt.Errorf("line %d: %w", n, err)
+			}
+			continue
+		}
+		if table == "sprite" {
+			if err := setSpriteKey(&config.Sprite, name, value); err != nil {
+				return fmt.Errorf("line %d: %w", n, err)
+			}
+			continue
+		}
+
+		var key *configKey
+		for i := range configKeys {
+			if configKeys[i].table == table && configKeys[i].name == name {
+				key = &configKeys[i]
+			}
+		}
+		if key == nil {
+			if table == "" {
+				return fmt.Errorf("line %d: %s must be under a [daemon] or [health] table", n, name)
+			}
+			return fmt.Errorf("line %d: unknown setting %s in [%s]", n, name, table)
+		}
+		d, err := parseTOMLDuration(value)
+		if err != nil {
+			return fmt.Errorf("line %d: %s: %w", n, name, err)
+		}
+		*key.field(config) = d
+	}
+	return scanner.Err()
+}
+
+// setHookKey sets one key of a [[hooks]] table.
+func setHookKey(h *Hook, name, value string) error {
+	var err error
+	switch name {
+	case "on":
+		h.On, err = parseTOMLStrings(value)
+	case "run":
+		h.Run, err = parseTOMLString(value)
+	case "remote":
+		h.Remote, err = parseTOMLString(value)
+	case "match":
+		h.Match, err = parseTOMLString(value)
+	case "timeout":
+		h.Timeout, err = parseTOMLDuration(value)
+	default:
+		return fmt.Errorf("unknown setting %s in [[hooks]]", name)
+	}
+	if err != nil {
+		return fmt.Errorf("%s: %w", name, err)
+	}
+	return nil
+}
+
+// setWebhookKey sets one key of a [[webhooks]] table.
+func setWebhookKey(w *Webhook, name, value string) error {
+	var err error
+	switch name {
+	case "url":
+		w.URL, err = parseTOMLString(value)
+	case "on":
+		w.On, err = parseTOMLStrings(value)
+	case "match":
+		w.Match, err = parseTOMLString(value)
+	case "secret":
+		w.Secret, err = parseTOMLString(value)
+	case "timeout":
+		w.Timeout, err = parseTOMLDuration(value)
+	default:
+		return fmt.Errorf("unknown setting %s in [[webhooks]]", name)
+	}
+	if err != nil {
+		return fmt.Errorf("%s: %w", name, err)
+	}
+	return nil
+}
+
+// setSpriteKey sets one key of the [sprite] table.
+func setSpriteKey(c *sprite.Config, name, value string) error {
+	var err error
+	switch name {
+	case "backend":
+		c.Backend, err = parseTOMLString(value)
+	case "api_url":
+		c.APIURL, err = parseTOMLString(value)
+	default:
+		return fmt.Errorf("unknown setting %s in [sprite]", name)
+	}
+	if err != nil {
+		return fmt.Errorf("%s: %w", name, err)
+	}
+	return nil
+}
+
+// parseTOMLDuration parses a quoted Go duration such as "30s".
+func parseTOMLDuration(value string) (time.Duration, error) {
+	if value == "" || (value[0] != '"' && value[0] != '\'') {
+		return 0, fmt.Errorf("want a quoted duration like \"30s\", got %s", value)
+	}
+	s, err := parseTOMLString(value)
+	if err != nil {
+		return 0, err
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		return 0, fmt.Errorf("invalid duration %q (want e.g. \"30s\" or \"5m\")", s)
+	}
+	return d, nil
+}
+
+// parseTOMLString parses a quoted TOML string value, allowing a trailing
+// comment.
+func parseTOMLString(value string) (string, error) {
+	if value == "" || (value[0] != '"' && value[0] != '\'') {
+		return "", fmt.Errorf("want a quoted string, got %s", value)
+	}
+	end := -1
+	for i := 1; i < len(value); i++ {
+		if value[0] == '"' && value[i] == '\\' {
+			i++ // an escape; basic strings may hold \"
+		} else if value[i] == value[0] {
+			end = i - 1
+			break
+		}
+	}
+	if end < 0 {
+		return "", fmt.Errorf("unterminated string %s", value)
+	}
+	if rest := strings.TrimSpace(value[end+2:]); rest != "" && !strings.HasPrefix(rest, "#") {
+		return "", fmt.Errorf("unexpected %q after the value", rest)
+	}
+	if value[0] == '\'' {
+		return value[1 : end+1], nil
+	}
+	s, err := strconv.Unquote(value[:end+2])
+	if err != nil {
+		return "", fmt.Errorf("invalid string %s", value[:end+2])
+	}
+	return s, nil
+}
+
+// parseTOMLStrings parses a single string or a one-line array of strings
+// without commas or brackets in them, like ["sync_status:watching", "sprite_removed"].
+func parseTOMLStrings(value string) ([]string, error) {
+	if !strings.HasPrefix(value, "[") {
+		s, err := parseTOMLString(value)
+		if err != nil {
+			return nil, err
+		}
+		return []string{s}, nil
+	}
+	end := strings.IndexByte(value, ']')
+	if end < 0 {
+		return nil, fmt.Errorf("unterminated array %s", value)
+	}
+	if rest := strings.TrimSpace(value[end+1:]); rest != "" && !strings.HasPrefix(rest, "#") {
+		return nil, fmt.Errorf("unexpected %q after the value", rest)
+	}
+	var strs []string
+	for _, item := range strings.Split(value[1:end], ",") {
+		if item = strings.TrimSpace(item); item == "" {
+			continue // a trailing comma
+		}
+		s, err := parseTOMLString(item)
+		if err != nil {
+			return nil, err
+		}
+		strs = append(strs, s)
+	}
+	return strs, nil
+}
+
+// Validate checks that the intervals, timeouts, hooks and webhooks are
+// usable.
+func (c Config) Validate() error {
+	var errs []error
+	for _, k := range configKeys {
+		d := *k.field(&c)
+		switch {
+		case d == 0 && k.zeroOK:
+		case d < time.Second:
+			errs = append(errs, fmt.Errorf("%s.%s is %v; it must be at least 1s", k.table, k.name, d))
+		}
+	}
+	if c.ConnectingRecoveryTimeout < c.SyncCheckInterval {
+		errs = append(errs, fmt.Errorf("health.connecting_timeout (%v) must be at least health.sync_interval (%v), "+
+			"or sync is rebuilt before a check can see it connect", c.ConnectingRecoveryTimeout, c.SyncCheckInterval))
+	}
+	switch c.Sprite.Backend {
+	case "", sprite.BackendCLI, sprite.BackendHTTP:
+	default:
+		errs = append(errs, fmt.Errorf("sprite.backend is %q; it must be %q or %q", c.Sprite.Backend, sprite.BackendCLI, sprite.BackendHTTP))
+	}
+	for i, h := range c.Hooks {
+		if err := h.validate(); err != nil {
+			errs = append(errs, fmt.Errorf("hook %d: %w", i+1, err))
+		}
+	}
+	for i, w := range c.Webhooks {
+		if err := w.validate(); err != nil {
+			errs = append(errs, fmt.Errorf("webhook %d: %w", i+1, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// fillDefaults sets unset intervals to their defaults, so a Config built by
+// hand (as tests do) still runs. IdleTimeout is left alone: 0 means never
+// stop.
+func (c *Config) fillDefaults() {
+	def := DefaultConfig()
+	for _, k := range configKeys {
+		if p := k.field(c); *p == 0 && !k.zeroOK {
+			*p = *k.field(&def)
+		}
+	}
+}
+
+// cfg returns the current config. Use it, rather than d.config, for the
+// settings reloadConfig can change.
+func (d *Daemon) cfg() Config {
+	d.configMu.RLock()
+	defer d.configMu.RUnlock()
+	return d.config
+}
+
+// configChanged returns a channel that's closed the next time the config is
+// reloaded with different settings, so loops can reset their tickers.
+func (d *Daemon) configChanged() <-chan struct{} {
+	d.configMu.RLock()
+	defer d.configMu.RUnlock()
+	return d.configCh
+}
+
+// reloadConfig re-reads the config file and the environment. An invalid
+// file is logged and the current settings kept. Only the intervals and
+// timeouts, the hooks and the webhooks take effect; the socket, HTTP
+// address and sprite backend need a restart.
+func (d *Daemon) reloadConfig() {
+	next, err := LoadConfig(d.config.ConfigFile)
+	if err != nil {
+		slog.Error("config: not reloading", "error", err)
+		return
+	}
+
+	d.configMu.Lock()
+	defer d.configMu.Unlock()
+	changed := false
+	for _, k := range configKeys {
+		cur, want := k.field(&d.config), *k.field(&next)
+		if *cur != want {
+			slog.Info("config: setting changed", "setting", k.table+"."+k.name, "from", *cur, "to", want)
+			*cur = want
+			changed = true
+		}
+	}
+	if !reflect.DeepEqual(d.config.Hooks, next.Hooks) {
+		slog.Info("config: hooks changed", "hooks", len(next.Hooks))
+		d.config.Hooks = next.Hooks
+	}
+	if !reflect.DeepEqual(d.config.Webhooks, next.Webhooks) {
+		slog.Info("config: webhooks changed", "webhooks", len(next.Webhooks))
+		d.config.Webhooks = next.Webhooks
+	}
+	if d.config.Sprite != next.Sprite {
+		slog.Warn("config: sprite backend changed; restart the daemon for it to take effect", "backend", next.Sprite.Backend)
+	}
+	if changed {
+		close(d.configCh)
+		d.configCh = make(chan struct{})
+	}
+}
+
+// configWatcher reloads the config on SIGHUP and whenever the file's
+// modification time or size changes, including when it's created or removed.
+// A daemon whose config didn't come from LoadConfig has nothing to reload.
+func (d *Daemon) configWatcher(ctx context.Context) {
+	path := d.config.ConfigFile
+	if path == "" {
+		return
+	}
+
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	defer signal.Stop(hup)
+
+	stamp := func() string {
+		info, err := os.Stat(path)
+		if err != nil {
+			return ""
+		}
+		return fmt.Sprintf("%d-%d", info.ModTime().UnixNano(), info.Size())
+	}
+	last := stamp()
+
+	ticker := time.NewTicker(configCheckInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-hup:
+			slog.Info("config: reloading on SIGHUP")
+			last = stamp()
+			d.reloadConfig()
+		case <-ticker.C:
+			if now := stamp(); now != last {
+				slog.Info("config: file changed, reloading", "path", path)
+				last = now
+				d.reloadConfig()
+			}
+		}
+	}
+}
@@ -0,0 +1,180 @@
+package daemon
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jphenow/sp/internal/sprite"
+)
+
+func writeConfig(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestLoadConfig(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.toml")
+
+	// A missing file gives the defaults.
+	config, err := LoadConfig(path)
+	if err != nil {
+		t.Fatalf("LoadConfig without a file: %v", err)
+	}
+	if config.HealthPollInterval != 60*time.Second || config.ConfigFile != path {
+		t.Errorf("defaults: poll %v, file %q", config.HealthPollInterval, config.ConfigFile)
+	}
+
+	writeConfig(t, path, `# metered connection
+[daemon]
+idle_timeout = "0s"
+
+[health]  # slower checks
+poll_interval = "5m"
+sync_reset_interval = '30m' # fewer rescans
+`)
+	t.Setenv("SP_HEALTH_SYNC_RESET_INTERVAL", "1h")
+	config, err = LoadConfig(path)
+	if err != nil {
+		t.Fatalf("LoadConfig: %v", err)
+	}
+	if config.IdleTimeout != 0 {
+		t.Errorf("IdleTimeout = %v, want 0", config.IdleTimeout)
+	}
+	if config.HealthPollInterval != 5*time.Minute {
+		t.Errorf("HealthPollInterval = %v, want 5m", config.HealthPollInterval)
+	}
+	if config.SyncResetInterval != time.Hour {
+		t.Errorf("SyncResetInterval = %v, want the environment's 1h", config.SyncResetInterval)
+	}
+	if config.SyncCheckInterval != 10*time.Second {
+		t.Errorf("SyncCheckInterval = %v, want the default 10s", config.SyncCheckInterval)
+	}
+}
+
+func TestLoadConfigHooks(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.toml")
+	writeConfig(t, path, `[health]
+poll_interval = "2m"
+
+[[hooks]]
+on = ["sync_status:conflicts", "event:proxy",] # trailing comma
+run = "notify-send \"sp: $SP_HOOK_EVENT\""
+match = "tag:work"
+
+[[webhooks]]
+url = "https://chat.example.com/hooks/abc"
+secret = "s3cret"
+
+[[hooks]]
+on = "sprite_status:running"
+remote = 'cd ~/app && make warm'
+timeout = "2m"
+
+[sprite]
+backend = "http"
+api_url = "https://sprites.example.com"
+`)
+	config, err := LoadConfig(path)
+	if err != nil {
+		t.Fatalf("LoadConfig: %v", err)
+	}
+	want := []Hook{
+		{On: []string{"sync_status:conflicts", "event:proxy"}, Run: `notify-send "sp: $SP_HOOK_EVENT"`, Match: "tag:work"},
+		{On: []string{"sprite_status:running"}, Remote: "cd ~/app && make warm", Timeout: 2 * time.Minute},
+	}
+	if !reflect.DeepEqual(config.Hooks, want) {
+		t.Errorf("hooks = %+v, want %+v", config.Hooks, want)
+	}
+	wantWebhooks := []Webhook{{URL: "https://chat.example.com/hooks/abc", Secret: "s3cret"}}
+	if !reflect.DeepEqual(config.Webhooks, wantWebhooks) {
+		t.Errorf("webhooks = %+v, want %+v", config.Webhooks, wantWebhooks)
+	}
+	if config.HealthPollInterval != 2*time.Minute {
+		t.Errorf("HealthPollInterval = %v; [[hooks]] shouldn't disturb the other tables", config.HealthPollInterval)
+	}
+	if want := (sprite.Config{Backend: "http", APIURL: "https://sprites.example.com"}); config.Sprite != want {
+		t.Errorf("sprite = %+v, want %+v", config.Sprite, want)
+	}
+}
+
+func TestLoadConfigErrors(t *testing.T) {
+	tests := []struct {
+		name, content, want string
+	}{
+		{"no table", `poll_interval = "5m"`, "must be under a [daemon] or [health] table"},
+		{"unknown table", "[sync]\n", "unknown table [sync]"},
+		{"unknown key", "[health]\npoll = \"5m\"", "unknown setting poll in [health]"},
+		{"unquoted", "[health]\npoll_interval = 300", "want a quoted duration"},
+		{"bad duration", "[health]\npoll_interval = \"often\"", `invalid duration "often"`},
+		{"trailing junk", "[health]\npoll_interval = \"5m\" x", "unexpected"},
+		{"too short", "[health]\npoll_interval = \"10ms\"", "health.poll_interval is 10ms; it must be at least 1s"},
+		{"zero", "[health]\nsync_interval = \"0s\"", "health.sync_interval is 0s"},
+		{"unknown array table", "[[hook]]\n", "unknown table [[hook]]"},
+		{"unknown hook setting", "[[hooks]]\ncommand = \"true\"", "unknown setting command in [[hooks]]"},
+		{"invalid hook", "[[hooks]]\non = \"woke\"\nrun = \"true\"", `hook 1: unknown event "woke"`},
+		{"unknown webhook setting", "[[webhooks]]\nheaders = \"x\"", "unknown setting headers in [[webhooks]]"},
+		{"webhook without a url", "[[webhooks]]\non = \"sprite_removed\"", `webhook 1: needs "url"`},
+		{"webhook url", "[[webhooks]]\nurl = \"chat.example.com/hook\"", "must be an http or https URL"},
+		{"unknown sprite setting", "[sprite]\ntoken = \"x\"", "unknown setting token in [sprite]"},
+		{"sprite backend", "[sprite]\nbackend = \"grpc\"", `sprite.backend is "grpc"`},
+		{"recovery before a check", "[health]\nsync_interval = \"2m\"", "connecting_timeout (1m0s) must be at least health.sync_interval (2m0s)"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "config.toml")
+			writeConfig(t, path, tt.content)
+			_, err := LoadConfig(path)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("LoadConfig error = %v, want it to contain %q", err, tt.want)
+			}
+		})
+	}
+
+	t.Run("environment", func(t *testing.T) {
+		t.Setenv("SP_DAEMON_IDLE_TIMEOUT", "soon")
+		_, err := LoadConfig(filepath.Join(t.TempDir(), "config.toml"))
+		if err == nil || !strings.Contains(err.Error(), "SP_DAEMON_IDLE_TIMEOUT") {
+			t.Errorf("LoadConfig error = %v, want it to name the variable", err)
+		}
+	})
+}
+
+func TestReloadConfig(t *testing.T) {
+	d, _ := testDaemon(t)
+	path := filepath.Join(t.TempDir(), "config.toml")
+	d.config.ConfigFile = path
+	changed := d.configChanged()
+
+	// The file matches the test daemon's settings: no notification.
+	writeConfig(t, path, "[daemon]\nidle_timeout = \"0s\"\n[health]\nnotify_interval = \"0s\"\n")
+	d.reloadConfig()
+	select {
+	case <-changed:
+		t.Fatal("reload without changes notified")
+	default:
+	}
+
+	writeConfig(t, path, "[health]\nproxy_interval = \"1m\"\n[daemon]\nidle_timeout = \"1h\"\n")
+	d.reloadConfig()
+	select {
+	case <-changed:
+	default:
+		t.Fatal("reload with changes didn't notify")
+	}
+	if got := d.cfg(); got.ProxyCheckInterval != time.Minute || got.IdleTimeout != time.Hour {
+		t.Errorf("after reload: proxy %v, idle %v", got.ProxyCheckInterval, got.IdleTimeout)
+	}
+
+	// An invalid file keeps the current settings.
+	writeConfig(t, path, "[health]\nproxy_interval = \"nope\"\n")
+	d.reloadConfig()
+	if got := d.cfg().ProxyCheckInterval; got != time.Minute {
+		t.Errorf("after invalid reload: proxy %v, want 1m kept", got)
+	}
+}
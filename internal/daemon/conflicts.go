@@ -0,0 +1,111 @@
+package daemon
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+
+	"github.com/jphenow/sp/internal/store"
+	spSync "github.com/jphenow/sp/internal/sync"
+)
+
+// Conflict resolution settles a sync session's conflicts one file at a
+// time, where a resync would overwrite a whole side. `sp conflicts` and the
+// TUI drive it through the daemon, which knows each sprite's directories
+// and engine and already serves its SSH host alias.
+
+// conflictResolver returns the resolver for a synced sprite.
+func (d *Daemon) conflictResolver(name string) (*spSync.ConflictResolver, error) {
+	s, err := d.db.GetSprite(name)
+	if err != nil {
+		return nil, err
+	}
+	if s == nil {
+		return nil, fmt.Errorf("sprite %q not found", name)
+	}
+	if s.LocalPath == "" || s.RemotePath == "" {
+		return nil, fmt.Errorf("sprite %q has no synced directory", name)
+	}
+	return &spSync.ConflictResolver{Engine: d.engine, SpriteName: name, LocalDir: s.LocalPath, RemoteDir: s.RemotePath}, nil
+}
+
+// handleListConflicts lists a sprite's sync conflicts with what each side
+// has at them.
+func (d *Daemon) handleListConflicts(params json.RawMessage) Response {
+	var req struct {
+		Name string `json:"name"`
+	}
+	if err := json.Unmarshal(params, &req); err != nil {
+		return respondError(fmt.Sprintf("invalid params: %v", err))
+	}
+	r, err := d.conflictResolver(req.Name)
+	if err != nil {
+		return respondError(err.Error())
+	}
+	conflicts, err := r.List(d.ctx)
+	if err != nil {
+		return respondError(fmt.Sprintf("listing conflicts: %v", err))
+	}
+	return respondJSON(conflicts)
+}
+
+// handleReadConflict returns both sides of a conflicting file.
+func (d *Daemon) handleReadConflict(params json.RawMessage) Response {
+	var req struct {
+		Name string `json:"name"`
+		Path string `json:"path"`
+	}
+	if err := json.Unmarshal(params, &req); err != nil {
+		return respondError(fmt.Sprintf("invalid params: %v", err))
+	}
+	r, err := d.conflictResolver(req.Name)
+	if err != nil {
+		return respondError(err.Error())
+	}
+	contents, err := r.Read(d.ctx, req.Path)
+	if err != nil {
+		return respondError(err.Error())
+	}
+	return respondJSON(contents)
+}
+
+// handleResolveConflict keeps one version of a conflicting file on both
+// sides, records it in the sprite's history, and rechecks the session so
+// its conflict count is current when the call returns.
+func (d *Daemon) handleResolveConflict(params json.RawMessage) Response {
+	var req struct {
+		Name    string            `json:"name"`
+		Path    string            `json:"path"`
+		Keep    spSync.Resolution `json:"keep"`
+		Content []byte            `json:"content,omitempty"` // the merged file, for "merged"
+	}
+	if err := json.Unmarshal(params, &req); err != nil {
+		return respondError(fmt.Sprintf("invalid params: %v", err))
+	}
+	r, err := d.conflictResolver(req.Name)
+	if err != nil {
+		return respondError(err.Error())
+	}
+
+	mu := d.spriteSyncLock(req.Name)
+	mu.Lock()
+	defer mu.Unlock()
+
+	log := slog.With("sprite", req.Name, "path", req.Path, "keep", req.Keep)
+	if err := r.Resolve(d.ctx, req.Path, req.Keep, req.Content); err != nil {
+		log.Warn("resolve_conflict: failed", "error", err)
+		return respondError(err.Error())
+	}
+	log.Info("resolve_conflict: resolved")
+
+	event := &store.Event{SpriteName: req.Name, Kind: store.EventConflict, To: string(req.Keep), Message: req.Path}
+	if err := d.db.AddEvent(event); err != nil {
+		log.Warn("resolve_conflict: recording event", "error", err)
+	} else {
+		d.broadcast(StateUpdate{Type: "event", SpriteName: req.Name, Event: event})
+	}
+	if s, err := d.db.GetSprite(req.Name); err == nil && s != nil && d.health != nil {
+		d.health.checkSpriteSync(s)
+	}
+	return respondOK(fmt.Sprintf("kept the %s %s", req.Keep, req.Path))
+}
@@ -5,6 +5,7 @@ import (
 	"crypto/sha256"
 	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"log/slog"
@@ -19,30 +20,81 @@ import (
 	"syscall"
 	"time"
 
+	"github.com/jphenow/sp/internal/retry"
 	"github.com/jphenow/sp/internal/setup"
 	"github.com/jphenow/sp/internal/sprite"
 	"github.com/jphenow/sp/internal/store"
 	spSync "github.com/jphenow/sp/internal/sync"
 )
 
-// Config holds daemon configuration.
+// Config holds daemon configuration. The intervals, timeouts, hooks and
+// webhooks can be set in config.toml (see LoadConfig) and are reloaded while
+// the daemon runs; so can the sprite backend, which takes a restart.
 type Config struct {
-	SocketPath  string        // Unix socket path
-	PIDPath     string        // PID file path
-	IdleTimeout time.Duration // Auto-stop after this idle duration (0 = no auto-stop)
+	SocketPath     string        // Unix socket path
+	PIDPath        string        // PID file path
+	IdleTimeout    time.Duration // Auto-stop after this idle duration (0 = no auto-stop)
+	EventRetention time.Duration // Prune history events older than this (0 = keep forever)
+	HTTPAddr       string        // Loopback address for the HTTP API ("" = don't serve it)
+	TokenPath      string        // HTTP API bearer token file, generated on first use
+	ConfigFile     string        // config.toml to load settings from and watch ("" = none)
+
+	HealthPollInterval   time.Duration // How often sprite status is polled from the API
+	NetworkCheckInterval time.Duration // How often the sprites API's reachability is checked
+	SyncCheckInterval    time.Duration // How often Mutagen sessions are checked
+	ProxyCheckInterval   time.Duration // How often proxy processes are checked
+	BinaryCheckInterval  time.Duration // How often to check for an upgraded sp binary
+
+	// SyncResetInterval is how often a stable ("watching") Mutagen session
+	// is reset to force a full rescan. This catches file drift that
+	// filesystem watchers miss — particularly atomic renames inside .git/
+	// (e.g., git writing a new packed-refs via tempfile + rename).
+	SyncResetInterval time.Duration
+
+	// ConnectingRecoveryTimeout is how long a sync session can be stuck in
+	// "connecting" before it is torn down and set up again.
+	ConnectingRecoveryTimeout time.Duration
+
+	// NotifyInterval is the least time between desktop notifications about
+	// one sprite's sync problems (0 = no notifications).
+	NotifyInterval time.Duration
+
+	Hooks    []Hook    // commands run on sprite and sync events
+	Webhooks []Webhook // URLs sprite and sync events are posted to
+
+	// Sprite is the [sprite] table: the backend sp talks to Sprites with,
+	// before the environment overrides it (see sprite.SetConfig). The API
+	// token only comes from the environment.
+	Sprite sprite.Config
 }
 
-// binaryCheckInterval is how often we check if the sp binary has been updated.
-const binaryCheckInterval = 10 * time.Second
+// eventPruneInterval is how often old history events are pruned.
+const eventPruneInterval = time.Hour
+
+// budgetCheckInterval is how often budgets are checked against recorded
+// running time, to warn as they run down.
+const budgetCheckInterval = 5 * time.Minute
 
 // DefaultConfig returns the default daemon configuration.
 func DefaultConfig() Config {
 	home, _ := os.UserHomeDir()
 	configDir := filepath.Join(home, ".config", "sp")
 	return Config{
-		SocketPath:  filepath.Join(configDir, "sp.sock"),
-		PIDPath:     filepath.Join(configDir, "sp.pid"),
-		IdleTimeout: 10 * time.Minute,
+		SocketPath:     filepath.Join(configDir, "sp.sock"),
+		PIDPath:        filepath.Join(configDir, "sp.pid"),
+		IdleTimeout:    10 * time.Minute,
+		EventRetention: 30 * 24 * time.Hour,
+		HTTPAddr:       os.Getenv("SP_DAEMON_HTTP"),
+		TokenPath:      filepath.Join(configDir, "api-token"),
+
+		HealthPollInterval:        60 * time.Second,
+		NetworkCheckInterval:      15 * time.Second,
+		SyncCheckInterval:         10 * time.Second,
+		ProxyCheckInterval:        15 * time.Second,
+		BinaryCheckInterval:       10 * time.Second,
+		SyncResetInterval:         5 * time.Minute,
+		ConnectingRecoveryTimeout: 60 * time.Second,
+		NotifyInterval:            5 * time.Minute,
 	}
 }
 
@@ -53,9 +105,11 @@ type Daemon struct {
 	config  Config
 	db      *store.DB
 	client  *sprite.Client
+	engine  spSync.SyncEngine // runs file sync sessions; Mutagen unless a test swaps it
 	ln      net.Listener
 	mu      sync.RWMutex
 	clients map[string]*clientConn // connected client tracking
+	ctx     context.Context        // daemon lifetime; cancelled on shutdown to abort retries
 	cancel  context.CancelFunc
 	done    chan struct{}
 
@@ -81,6 +135,36 @@ type Daemon struct {
 	// up proxies for the same sprite, each killing the other's resources.
 	syncLocks sync.Map // map[string]*sync.Mutex
 
+	// usageRunning holds whether the health poller last saw each sprite
+	// running, so recordUsage only writes on transitions and knows when it
+	// is catching up after the daemon was down.
+	usageRunning sync.Map // map[string]bool
+
+	// budgetWarned is the highest warning level (1 = past the threshold,
+	// 2 = exhausted) logged for each budget this period, keyed by label and
+	// period. Only budgetWatcher touches it.
+	budgetWarned map[string]int
+
+	// configMu guards the settings reloadConfig changes; configCh is closed
+	// (and replaced) when they do.
+	configMu sync.RWMutex
+	configCh chan struct{}
+
+	// health is the sync/proxy health monitor, set once Start creates it.
+	health *HealthMonitor
+
+	// metrics accumulates counters and RPC latencies for /metrics.
+	metrics metrics
+
+	// notifier shows sync problems on the desktop; nil, so nothing is
+	// shown, until SetNotifier sets one.
+	notifyMu sync.Mutex
+	notifier Notifier
+
+	// webhookRetry is how webhook deliveries are retried; retry.Webhook
+	// unless a test shortens it.
+	webhookRetry retry.Policy
+
 	// startBinaryHash is the SHA-256 of the sp binary at daemon startup.
 	// Used to detect when a new binary has been installed.
 	startBinaryHash string
@@ -89,9 +173,10 @@ type Daemon struct {
 
 // StateUpdate is broadcast to all connected subscribers when sprite state changes.
 type StateUpdate struct {
-	Type       string        `json:"type"` // "sprite_status", "sync_status", "sprite_added", "sprite_removed"
+	Type       string        `json:"type"` // "sprite_status", "sync_status", "sprite_added", "sprite_removed", "sprite_updated", "event", "budget", "meta"
 	SpriteName string        `json:"sprite_name"`
 	Sprite     *store.Sprite `json:"sprite,omitempty"`
+	Event      *store.Event  `json:"event,omitempty"` // the history entry, for "event"
 }
 
 // clientConn tracks a connected client (TUI or sp process).
@@ -99,18 +184,37 @@ type clientConn struct {
 	id        string
 	conn      net.Conn
 	createdAt time.Time
+
+	// Requests are handled concurrently and subscriptions push updates
+	// between replies, so writes to the connection are serialized.
+	sendMu  sync.Mutex
+	encoder *json.Encoder
+}
+
+// send writes one message to the client.
+func (cc *clientConn) send(resp Response) error {
+	cc.sendMu.Lock()
+	defer cc.sendMu.Unlock()
+	return cc.encoder.Encode(resp)
 }
 
-// Request is the wire format for client-to-daemon RPC calls.
+// Request is the wire format for client-to-daemon RPC calls. The first
+// request on a connection must be the "hello" handshake (see protocol.go).
 type Request struct {
+	ID     uint64          `json:"id,omitempty"` // echoed in the Response; unique per connection
 	Method string          `json:"method"`
 	Params json.RawMessage `json:"params,omitempty"`
 }
 
-// Response is the wire format for daemon-to-client replies.
+// Response is the wire format for everything the daemon sends a client:
+// replies, matched to their Request by ID and sent in whatever order the
+// requests finish, and state updates pushed to subscribers, which carry an
+// Event and no ID.
 type Response struct {
+	ID     uint64          `json:"id,omitempty"`
 	Result json.RawMessage `json:"result,omitempty"`
 	Error  string          `json:"error,omitempty"`
+	Event  *StateUpdate    `json:"event,omitempty"`
 }
 
 // New creates a new daemon instance. Does not start it.
@@ -118,16 +222,21 @@ type Response struct {
 func New(config Config, db *store.DB) *Daemon {
 	exePath, _ := os.Executable()
 	hash, _ := hashFile(exePath)
+	config.fillDefaults()
 
 	return &Daemon{
 		config:          config,
 		db:              db,
+		ctx:             context.Background(),
 		client:          sprite.NewClient(""),
+		engine:          spSync.NewMutagenEngine(),
 		clients:         make(map[string]*clientConn),
 		subs:            make(map[string]chan StateUpdate),
 		proxies:         make(map[string]*exec.Cmd),
 		proxyDeathChs:   make(map[string]chan struct{}),
 		done:            make(chan struct{}),
+		configCh:        make(chan struct{}),
+		webhookRetry:    retry.Webhook,
 		startBinaryHash: hash,
 		exePath:         exePath,
 	}
@@ -169,6 +278,7 @@ func BinaryHash() (string, error) {
 // and listens for client connections. Blocks until context is cancelled.
 func (d *Daemon) Start(ctx context.Context) error {
 	ctx, d.cancel = context.WithCancel(ctx)
+	d.ctx = ctx
 
 	// Write PID file
 	if err := d.writePID(); err != nil {
@@ -198,7 +308,7 @@ func (d *Daemon) Start(ctx context.Context) error {
 	signal.Notify(sigCh, syscall.SIGINT, syscall.SIGTERM)
 
 	// Clean up stale SSH config entries from prior sessions
-	if removed, err := spSync.CleanupStaleSSHConfigs(); err != nil {
+	if removed, err := spSync.CleanupStaleSSHConfigs(d.engine); err != nil {
 		slog.Debug("startup: ssh config cleanup error", "error", err)
 	} else if len(removed) > 0 {
 		slog.Info("startup: cleaned up stale SSH config entries", "count", len(removed), "entries", removed)
@@ -208,10 +318,18 @@ func (d *Daemon) Start(ctx context.Context) error {
 	go d.healthPoller(ctx)
 	go d.idleWatcher(ctx)
 	go d.binaryWatcher(ctx)
+	go d.eventPruner(ctx)
+	go d.budgetWatcher(ctx)
+	go d.configWatcher(ctx)
+	go d.hookRunner(ctx)
 
 	// Start the sync/proxy health monitor
-	monitor := NewHealthMonitor(d.db, d, d.broadcast)
-	go monitor.Run(ctx)
+	d.health = NewHealthMonitor(d.db, d, d.broadcast)
+	go d.health.Run(ctx)
+
+	if d.config.HTTPAddr != "" {
+		go d.serveHTTP(ctx)
+	}
 
 	// Accept loop
 	go func() {
@@ -259,18 +377,22 @@ func (d *Daemon) Stop() {
 	}
 }
 
-// handleConn processes a single client connection using newline-delimited JSON.
+// handleConn processes a single client connection using newline-delimited
+// JSON. After the handshake each request is handled in its own goroutine,
+// so a slow call (a resync, say) doesn't hold up the others.
 func (d *Daemon) handleConn(ctx context.Context, conn net.Conn) {
 	defer conn.Close()
 
 	clientID := fmt.Sprintf("%d-%d", os.Getpid(), time.Now().UnixNano())
-
-	d.mu.Lock()
-	d.clients[clientID] = &clientConn{
+	cc := &clientConn{
 		id:        clientID,
 		conn:      conn,
 		createdAt: time.Now(),
+		encoder:   json.NewEncoder(conn),
 	}
+
+	d.mu.Lock()
+	d.clients[clientID] = cc
 	d.mu.Unlock()
 
 	defer func() {
@@ -281,7 +403,18 @@ func (d *Daemon) handleConn(ctx context.Context, conn net.Conn) {
 	}()
 
 	decoder := json.NewDecoder(conn)
-	encoder := json.NewEncoder(conn)
+
+	var hello Request
+	if err := decoder.Decode(&hello); err != nil {
+		return
+	}
+	resp, ok := d.handshake(&hello)
+	if err := cc.send(resp); err != nil || !ok {
+		return
+	}
+
+	var inFlight sync.WaitGroup
+	defer inFlight.Wait()
 
 	for {
 		select {
@@ -295,10 +428,13 @@ func (d *Daemon) handleConn(ctx context.Context, conn net.Conn) {
 			return // Connection closed or malformed
 		}
 
-		resp := d.dispatch(ctx, clientID, &req)
-		if err := encoder.Encode(resp); err != nil {
-			return
-		}
+		inFlight.Add(1)
+		go func() {
+			defer inFlight.Done()
+			resp := d.timedDispatch(ctx, clientID, &req)
+			resp.ID = req.ID
+			cc.send(resp)
+		}()
 	}
 }
 
@@ -325,6 +461,12 @@ func (d *Daemon) dispatch(ctx context.Context, clientID string, req *Request) Re
 		return d.handleUntag(req.Params)
 	case "get_tags":
 		return d.handleGetTags(req.Params)
+	case "set_meta":
+		return d.handleSetMeta(req.Params)
+	case "delete_meta":
+		return d.handleDeleteMeta(req.Params)
+	case "get_meta":
+		return d.handleGetMeta(req.Params)
 	case "subscribe":
 		return d.handleSubscribe(ctx, clientID)
 	case "import":
@@ -337,10 +479,38 @@ func (d *Daemon) dispatch(ctx context.Context, clientID string, req *Request) Re
 		return d.handleResync(req.Params)
 	case "resync_with_mode":
 		return d.handleResyncWithMode(req.Params)
+	case "pause_sync":
+		return d.handlePauseSync(req.Params)
+	case "resume_sync":
+		return d.handleResumeSync(req.Params)
+	case "list_conflicts":
+		return d.handleListConflicts(req.Params)
+	case "read_conflict":
+		return d.handleReadConflict(req.Params)
+	case "resolve_conflict":
+		return d.handleResolveConflict(req.Params)
+	case "add_checkpoint":
+		return d.handleAddCheckpoint(req.Params)
+	case "list_checkpoints":
+		return d.handleListCheckpoints(req.Params)
+	case "find_checkpoint":
+		return d.handleFindCheckpoint(req.Params)
+	case "list_budgets":
+		return d.handleListBudgets(req.Params)
+	case "set_budget":
+		return d.handleSetBudget(req.Params)
+	case "delete_budget":
+		return d.handleDeleteBudget(req.Params)
+	case "list_usage":
+		return d.handleListUsage(req.Params)
+	case "list_events":
+		return d.handleListEvents(req.Params)
+	case "record_event":
+		return d.handleRecordEvent(req.Params)
 	case "run_setup":
 		return d.handleRunSetup(req.Params)
 	case "restart":
-		return d.handleRestart()
+		return d.handleRestart(req.Params)
 	case "ping":
 		return respondOK("pong")
 	default:
@@ -353,33 +523,115 @@ func (d *Daemon) healthPoller(ctx context.Context) {
 	// Initial poll on startup
 	d.pollSpriteHealth()
 
-	ticker := time.NewTicker(60 * time.Second)
+	ticker := time.NewTicker(d.cfg().HealthPollInterval)
 	defer ticker.Stop()
+	changed := d.configChanged()
 
 	for {
 		select {
 		case <-ctx.Done():
 			return
+		case <-changed:
+			changed = d.configChanged()
+			ticker.Reset(d.cfg().HealthPollInterval)
 		case <-ticker.C:
 			d.pollSpriteHealth()
 		}
 	}
 }
 
+// recordUsage keeps a sprite's running intervals in step with its status.
+// When the daemon didn't see the transition itself (it was down, or just
+// started), the API's last started/active times are a better guess than now.
+// open is the sprite's interval that hasn't ended, if any.
+func (d *Daemon) recordUsage(info sprite.Info, open *store.UsageInterval) {
+	running := info.Status == "running"
+	prev, seen := d.usageRunning.Swap(info.Name, running)
+	if seen && prev.(bool) == running {
+		return
+	}
+
+	now := time.Now()
+	at := now
+	var err error
+	if running {
+		if !seen && info.LastStartedAt != nil && info.LastStartedAt.Before(now) {
+			at = *info.LastStartedAt
+		}
+		// A sprite started again after the open interval began stopped
+		// in between, while the daemon wasn't watching. End the interval
+		// when it was last active (no later than the restart), so the time
+		// it was stopped isn't counted.
+		if !seen && open != nil && at.After(open.StartedAt) {
+			end := at
+			if info.LastActiveAt != nil && info.LastActiveAt.After(open.StartedAt) && info.LastActiveAt.Before(end) {
+				end = *info.LastActiveAt
+			}
+			err = d.db.EndUsage(info.Name, end)
+		}
+		if err == nil {
+			err = d.db.StartUsage(info.Name, at)
+		}
+	} else {
+		if !seen && info.LastActiveAt != nil && info.LastActiveAt.Before(now) {
+			at = *info.LastActiveAt
+		}
+		err = d.db.EndUsage(info.Name, at)
+	}
+	if err != nil {
+		d.usageRunning.Delete(info.Name) // try again next poll
+		slog.Warn("usage: recording interval failed", "sprite", info.Name, "error", err)
+	}
+}
+
+// endMissingUsage ends the open intervals of sprites the poll didn't
+// report: ones destroyed outside sp, or no longer tracked. Nothing else
+// would end them, and they'd count as running for good.
+func (d *Daemon) endMissingUsage(open map[string]*store.UsageInterval, polled map[string]bool) {
+	for name := range open {
+		if polled[name] {
+			continue
+		}
+		d.usageRunning.Delete(name)
+		if err := d.db.EndUsage(name, time.Now()); err != nil {
+			slog.Warn("usage: ending interval failed", "sprite", name, "error", err)
+			continue
+		}
+		slog.Info("usage: ended interval of a sprite no longer listed", "sprite", name)
+	}
+}
+
 // pollSpriteHealth fetches sprite status from the API and updates the database.
 func (d *Daemon) pollSpriteHealth() {
 	sprites, err := d.client.List()
 	if err != nil {
-		// Network issue - don't update status to avoid marking everything unknown
+		// Don't update status on failure to avoid marking everything unknown.
+		// Auth failures won't fix themselves, so surface those loudly.
+		if errors.Is(err, sprite.ErrUnauthorized) {
+			slog.Error("health: sprites API rejected credentials", "error", err)
+		} else {
+			slog.Debug("health: listing sprites failed", "error", err)
+		}
 		return
 	}
 
+	open := make(map[string]*store.UsageInterval)
+	if intervals, err := d.db.ListOpenUsage(); err != nil {
+		slog.Warn("usage: listing open intervals failed", "error", err)
+	} else {
+		for _, u := range intervals {
+			open[u.SpriteName] = u
+		}
+	}
+	polled := make(map[string]bool, len(sprites))
+
 	for _, info := range sprites {
 		existing, _ := d.db.GetSprite(info.Name)
 		if existing == nil {
 			// Don't auto-import sprites we don't know about
 			continue
 		}
+		polled[info.Name] = true
 
 		oldStatus := existing.Status
 		changed := false
@@ -392,6 +644,8 @@ func (d *Daemon) pollSpriteHealth() {
 			changed = true
 		}
 
+		d.recordUsage(info, open[info.Name])
+
 		// Backfill missing ID, URL, and org from API data
 		if (existing.SpriteID == "" && info.ID != "") ||
 			(existing.URL == "" && info.URL != "") ||
@@ -450,12 +704,93 @@ func (d *Daemon) pollSpriteHealth() {
 			}(info.Name)
 		}
 	}
+
+	d.endMissingUsage(open, polled)
+}
+
+// eventPruner deletes history events older than the configured retention,
+// once at startup and then every eventPruneInterval.
+func (d *Daemon) eventPruner(ctx context.Context) {
+	if d.config.EventRetention == 0 {
+		return
+	}
+
+	ticker := time.NewTicker(eventPruneInterval)
+	defer ticker.Stop()
+
+	for {
+		n, err := d.db.PruneEvents(time.Now().Add(-d.config.EventRetention))
+		if err != nil {
+			slog.Warn("events: pruning failed", "error", err)
+		} else if n > 0 {
+			slog.Info("events: pruned old history", "count", n, "retention", d.config.EventRetention)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// budgetWatcher checks budgets once at startup and then every
+// budgetCheckInterval.
+func (d *Daemon) budgetWatcher(ctx context.Context) {
+	ticker := time.NewTicker(budgetCheckInterval)
+	defer ticker.Stop()
+
+	for {
+		d.checkBudgets(time.Now())
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// checkBudgets warns, once per level per period, when a budget passes its
+// warning threshold or runs out. Holds are refused or shortened by the
+// clients that start them; the daemon only reports.
+func (d *Daemon) checkBudgets(now time.Time) {
+	statuses, err := d.db.BudgetStatuses("", now)
+	if err != nil {
+		slog.Warn("budget: checking budgets failed", "error", err)
+		return
+	}
+	if d.budgetWarned == nil {
+		d.budgetWarned = make(map[string]int)
+	}
+	period := store.BudgetPeriodStart(now).Format("2006-01")
+	for _, s := range statuses {
+		level := 0
+		if s.Exhausted() {
+			level = 2
+		} else if s.Warning() {
+			level = 1
+		}
+		key := s.Budget.Label() + " " + period
+		if level <= d.budgetWarned[key] {
+			continue
+		}
+		d.budgetWarned[key] = level
+		if level == 2 {
+			slog.Warn("budget: exhausted, new holds will be refused", "budget", s.Budget.Label(), "used_hours", s.Used.Hours(), "hours", s.Budget.Hours)
+		} else {
+			slog.Warn("budget: past warning threshold", "budget", s.Budget.Label(), "used_hours", s.Used.Hours(), "hours", s.Budget.Hours, "warn_percent", s.Budget.WarnPercent)
+		}
+		d.broadcast(StateUpdate{Type: "budget", SpriteName: s.Budget.Target})
+	}
 }
 
 // idleWatcher monitors for idle state and shuts down the daemon if there are
 // no active clients or sync sessions for the configured idle timeout.
 func (d *Daemon) idleWatcher(ctx context.Context) {
-	if d.config.IdleTimeout == 0 {
+	// HTTP clients can't start the daemon the way sp does, so it stays up
+	// while it serves them.
+	if d.config.HTTPAddr != "" {
 		return
 	}
 
@@ -473,12 +808,15 @@ func (d *Daemon) idleWatcher(ctx context.Context) {
 			hasClients := len(d.clients) > 0
 			d.mu.RUnlock()
 
-			if hasClients {
+			// An idle timeout of 0 turns auto-stop off, but a reload can
+			// turn it back on, so keep watching.
+			timeout := d.cfg().IdleTimeout
+			if hasClients || timeout == 0 {
 				lastActivity = time.Now()
 				continue
 			}
 
-			if time.Since(lastActivity) > d.config.IdleTimeout {
+			if time.Since(lastActivity) > timeout {
 				slog.Info("idle timeout reached, shutting down daemon")
 				d.Stop()
 				return
@@ -496,13 +834,17 @@ func (d *Daemon) binaryWatcher(ctx context.Context) {
 		return
 	}
 
-	ticker := time.NewTicker(binaryCheckInterval)
+	ticker := time.NewTicker(d.cfg().BinaryCheckInterval)
 	defer ticker.Stop()
+	changed := d.configChanged()
 
 	for {
 		select {
 		case <-ctx.Done():
 			return
+		case <-changed:
+			changed = d.configChanged()
+			ticker.Reset(d.cfg().BinaryCheckInterval)
 		case <-ticker.C:
 			currentHash, err := hashFile(d.exePath)
 			if err != nil {
@@ -627,6 +969,12 @@ func EnsureRunning() (string, error) {
 		return config.SocketPath, nil
 	}
 
+	// The daemon won't start with a broken config file; say why here rather
+	// than timing out waiting for it.
+	if _, err := LoadConfig(ConfigPath()); err != nil {
+		return "", fmt.Errorf("loading daemon config: %w", err)
+	}
+
 	// Start daemon in background
 	exe, err := os.Executable()
 	if err != nil {
@@ -778,9 +1126,11 @@ func (d *Daemon) handleDelete(params json.RawMessage) Response {
 	if err := json.Unmarshal(params, &req); err != nil {
 		return respondError(fmt.Sprintf("invalid params: %v", err))
 	}
+	// DeleteSprite also ends the sprite's running usage interval.
 	if err := d.db.DeleteSprite(req.Name); err != nil {
 		return respondError(err.Error())
 	}
+	d.usageRunning.Delete(req.Name)
 	d.broadcast(StateUpdate{Type: "sprite_removed", SpriteName: req.Name})
 	return respondOK("ok")
 }
@@ -845,11 +1195,74 @@ func (d *Daemon) handleGetTags(params json.RawMessage) Response {
 	return respondJSON(tags)
 }
 
+// handleSetMeta sets a key/value annotation on a tracked sprite.
+func (d *Daemon) handleSetMeta(params json.RawMessage) Response {
+	var req struct {
+		Name  string `json:"name"`
+		Key   string `json:"key"`
+		Value string `json:"value"`
+	}
+	if err := json.Unmarshal(params, &req); err != nil {
+		return respondError(fmt.Sprintf("invalid params: %v", err))
+	}
+	if req.Key == "" {
+		return respondError("metadata key must not be empty")
+	}
+	s, err := d.db.GetSprite(req.Name)
+	if err != nil {
+		return respondError(err.Error())
+	}
+	if s == nil {
+		return respondError(fmt.Sprintf("sprite %q not found", req.Name))
+	}
+	if err := d.db.SetMeta(req.Name, req.Key, req.Value); err != nil {
+		return respondError(err.Error())
+	}
+	d.broadcast(StateUpdate{Type: "meta", SpriteName: req.Name})
+	return respondOK("ok")
+}
+
+// handleDeleteMeta removes an annotation from a sprite.
+func (d *Daemon) handleDeleteMeta(params json.RawMessage) Response {
+	var req struct {
+		Name string `json:"name"`
+		Key  string `json:"key"`
+	}
+	if err := json.Unmarshal(params, &req); err != nil {
+		return respondError(fmt.Sprintf("invalid params: %v", err))
+	}
+	ok, err := d.db.DeleteMeta(req.Name, req.Key)
+	if err != nil {
+		return respondError(err.Error())
+	}
+	if !ok {
+		return respondError(fmt.Sprintf("sprite %q has no %q", req.Name, req.Key))
+	}
+	d.broadcast(StateUpdate{Type: "meta", SpriteName: req.Name})
+	return respondOK("ok")
+}
+
+// handleGetMeta returns a sprite's annotations, ordered by key.
+func (d *Daemon) handleGetMeta(params json.RawMessage) Response {
+	var req struct {
+		Name string `json:"name"`
+	}
+	if err := json.Unmarshal(params, &req); err != nil {
+		return respondError(fmt.Sprintf("invalid params: %v", err))
+	}
+	meta, err := d.db.GetMeta(req.Name)
+	if err != nil {
+		return respondError(err.Error())
+	}
+	return respondJSON(meta)
+}
+
 func (d *Daemon) handleSubscribe(ctx context.Context, clientID string) Response {
 	ch := d.subscribe(clientID)
 
 	// Send updates in a goroutine - this handler returns immediately with success,
-	// then the subscription goroutine pushes updates on the same connection
+	// then the subscription goroutine pushes updates on the same connection,
+	// interleaved with replies to the client's other calls.
 	go func() {
 		d.mu.RLock()
 		cc, ok := d.clients[clientID]
@@ -858,7 +1271,6 @@ func (d *Daemon) handleSubscribe(ctx context.Context, clientID string) Response
 			return
 		}
 
-		encoder := json.NewEncoder(cc.conn)
 		for {
 			select {
 			case <-ctx.Done():
@@ -867,7 +1279,7 @@ func (d *Daemon) handleSubscribe(ctx context.Context, clientID string) Response
 				if !ok {
 					return
 				}
-				if err := encoder.Encode(update); err != nil {
+				if err := cc.send(Response{Event: &update}); err != nil {
 					return
 				}
 			}
@@ -889,8 +1301,11 @@ func (d *Daemon) handleImport(params json.RawMessage) Response {
 
 	// Fetch sprite info from API to populate fields
 	info, err := d.client.Get(req.Name)
+	if errors.Is(err, sprite.ErrNotFound) {
+		return respondError(fmt.Sprintf("sprite %q not found in API", req.Name))
+	}
 	if err != nil {
-		return respondError(fmt.Sprintf("sprite %q not found in API: %v", req.Name, err))
+		return respondError(fmt.Sprintf("looking up sprite %q: %v", req.Name, err))
 	}
 
 	s := &store.Sprite{
@@ -948,10 +1363,11 @@ func (d *Daemon) handleResync(params json.RawMessage) Response {
 		mu := d.spriteSyncLock(req.Name)
 		mu.Lock()
 		defer mu.Unlock()
+		d.liftSyncPause(req.Name)
 
 		// 1. Flush pending changes (best-effort, 15s timeout)
 		log.Info("resync: flushing pending mutagen changes")
-		if err := spSync.FlushMutagenSession(req.Name); err != nil {
+		if err := d.engine.Flush(d.ctx, req.Name); err != nil {
 			log.Warn("resync: flush failed (continuing)", "error", err)
 		}
 
@@ -967,6 +1383,236 @@ func (d *Daemon) handleResync(params json.RawMessage) Response {
 	return respondOK("resyncing")
 }
 
+// handlePauseSync tears down sync for a sprite and keeps it down until
+// resume_sync, so the local tree isn't touched while the remote is being
+// replaced (checkpoint restore). Unlike stop_sync it is synchronous: once
+// it returns, Mutagen is no longer running for the sprite.
+func (d *Daemon) handlePauseSync(params json.RawMessage) Response {
+	var req struct {
+		Name string `json:"name"`
+	}
+	if err := json.Unmarshal(params, &req); err != nil {
+		return respondError(fmt.Sprintf("invalid params: %v", err))
+	}
+
+	mu := d.spriteSyncLock(req.Name)
+	mu.Lock()
+	defer mu.Unlock()
+
+	if err := d.db.SetSyncPaused(req.Name, true); err != nil {
+		return respondError(err.Error())
+	}
+	d.stopSyncForSprite(req.Name)
+	d.db.UpdateSyncStatus(req.Name, "paused", "")
+	d.broadcast(StateUpdate{Type: "sync_status", SpriteName: req.Name})
+	slog.Info("pause_sync: sync paused", "sprite", req.Name)
+	return respondOK("paused")
+}
+
+// handleResumeSync lifts a pause_sync and restarts sync from scratch in the
+// background. A fresh Mutagen session is used rather than resuming the old
+// one, whose snapshot no longer matches a restored remote.
+func (d *Daemon) handleResumeSync(params json.RawMessage) Response {
+	var req struct {
+		Name string `json:"name"`
+	}
+	if err := json.Unmarshal(params, &req); err != nil {
+		return respondError(fmt.Sprintf("invalid params: %v", err))
+	}
+
+	go func() {
+		mu := d.spriteSyncLock(req.Name)
+		mu.Lock()
+		defer mu.Unlock()
+
+		if err := d.db.SetSyncPaused(req.Name, false); err != nil {
+			slog.Error("resume_sync: clearing pause failed", "sprite", req.Name, "error", err)
+			return
+		}
+		s, err := d.db.GetSprite(req.Name)
+		if err != nil || s == nil || s.LocalPath == "" || s.RemotePath == "" {
+			d.db.UpdateSyncStatus(req.Name, "none", "")
+			d.broadcast(StateUpdate{Type: "sync_status", SpriteName: req.Name})
+			return
+		}
+		if err := d.restartSyncLocked(req.Name); err != nil {
+			slog.Error("resume_sync: restart failed", "sprite", req.Name, "error", err)
+		}
+	}()
+
+	return respondOK("resuming")
+}
+
+// isSyncPaused reports whether pause_sync is in effect for a sprite. The
+// pause lives in the store, so it outlasts a daemon restart along with the
+// "paused" sync status it sets; restartSync skips paused sprites so wake
+// detection and health recovery don't bring sync back mid-restore.
+func (d *Daemon) isSyncPaused(spriteName string) bool {
+	paused, err := d.db.IsSyncPaused(spriteName)
+	if err != nil {
+		slog.Warn("sync pause check failed", "sprite", spriteName, "error", err)
+	}
+	return paused
+}
+
+// liftSyncPause clears a pause_sync that a restore never resumed, because
+// it was interrupted or lost the daemon, when sync is asked for again with
+// start_sync or a resync; otherwise automatic recovery would stay off for
+// the sprite. The caller holds the sprite's sync lock.
+func (d *Daemon) liftSyncPause(spriteName string) {
+	if !d.isSyncPaused(spriteName) {
+		return
+	}
+	if err := d.db.SetSyncPaused(spriteName, false); err != nil {
+		slog.Error("sync: clearing pause failed", "sprite", spriteName, "error", err)
+		return
+	}
+	slog.Info("sync: lifted a pause left by an unfinished restore", "sprite", spriteName)
+}
+
+func (d *Daemon) handleAddCheckpoint(params json.RawMessage) Response {
+	var c store.Checkpoint
+	if err := json.Unmarshal(params, &c); err != nil {
+		return respondError(fmt.Sprintf("invalid params: %v", err))
+	}
+	if err := d.db.AddCheckpoint(&c); err != nil {
+		return respondError(err.Error())
+	}
+	return respondOK("ok")
+}
+
+func (d *Daemon) handleListCheckpoints(params json.RawMessage) Response {
+	var req struct {
+		Name string `json:"name"`
+	}
+	if err := json.Unmarshal(params, &req); err != nil {
+		return respondError(fmt.Sprintf("invalid params: %v", err))
+	}
+	checkpoints, err := d.db.ListCheckpoints(req.Name)
+	if err != nil {
+		return respondError(err.Error())
+	}
+	return respondJSON(checkpoints)
+}
+
+// handleFindCheckpoint looks up a sprite's newest checkpoint with a label,
+// replying with null when there is none.
+func (d *Daemon) handleFindCheckpoint(params json.RawMessage) Response {
+	var req struct {
+		Name  string `json:"name"`
+		Label string `json:"label"`
+	}
+	if err := json.Unmarshal(params, &req); err != nil {
+		return respondError(fmt.Sprintf("invalid params: %v", err))
+	}
+	c, err := d.db.FindCheckpointByLabel(req.Name, req.Label)
+	if err != nil {
+		return respondError(err.Error())
+	}
+	return respondJSON(c)
+}
+
+func (d *Daemon) handleListEvents(params json.RawMessage) Response {
+	var opts store.EventListOptions
+	if err := json.Unmarshal(params, &opts); err != nil {
+		return respondError(fmt.Sprintf("invalid params: %v", err))
+	}
+	events, err := d.db.ListEvents(opts)
+	if err != nil {
+		return respondError(err.Error())
+	}
+	return respondJSON(events)
+}
+
+// handleListBudgets returns the budgets that apply to a sprite (or all of
+// them if no name is given) and how much of each has been used this month.
+func (d *Daemon) handleListBudgets(params json.RawMessage) Response {
+	var req struct {
+		Name string `json:"name"`
+	}
+	if err := json.Unmarshal(params, &req); err != nil {
+		return respondError(fmt.Sprintf("invalid params: %v", err))
+	}
+	statuses, err := d.db.BudgetStatuses(req.Name, time.Now())
+	if err != nil {
+		return respondError(err.Error())
+	}
+	return respondJSON(statuses)
+}
+
+// handleSetBudget creates or replaces a budget.
+func (d *Daemon) handleSetBudget(params json.RawMessage) Response {
+	var b store.Budget
+	if err := json.Unmarshal(params, &b); err != nil {
+		return respondError(fmt.Sprintf("invalid params: %v", err))
+	}
+	switch {
+	case b.Scope != store.BudgetGlobal && b.Scope != store.BudgetTag && b.Scope != store.BudgetSprite:
+		return respondError(fmt.Sprintf("unknown budget scope %q", b.Scope))
+	case b.Scope != store.BudgetGlobal && b.Target == "":
+		return respondError(fmt.Sprintf("a %s budget needs a %s", b.Scope, b.Scope))
+	case b.Hours <= 0:
+		return respondError("budget hours must be positive")
+	}
+	if err := d.db.SetBudget(&b); err != nil {
+		return respondError(err.Error())
+	}
+	d.broadcast(StateUpdate{Type: "budget", SpriteName: b.Target})
+	return respondOK("ok")
+}
+
+// handleDeleteBudget removes a budget.
+func (d *Daemon) handleDeleteBudget(params json.RawMessage) Response {
+	var req struct {
+		Scope  string `json:"scope"`
+		Target string `json:"target"`
+	}
+	if err := json.Unmarshal(params, &req); err != nil {
+		return respondError(fmt.Sprintf("invalid params: %v", err))
+	}
+	ok, err := d.db.DeleteBudget(req.Scope, req.Target)
+	if err != nil {
+		return respondError(err.Error())
+	}
+	if !ok {
+		return respondError("no such budget")
+	}
+	d.broadcast(StateUpdate{Type: "budget", SpriteName: req.Target})
+	return respondOK("ok")
+}
+
+// handleListUsage returns the running intervals since the requested time.
+func (d *Daemon) handleListUsage(params json.RawMessage) Response {
+	var req struct {
+		Since time.Time `json:"since"`
+	}
+	if err := json.Unmarshal(params, &req); err != nil {
+		return respondError(fmt.Sprintf("invalid params: %v", err))
+	}
+	usage, err := d.db.ListUsage(req.Since)
+	if err != nil {
+		return respondError(err.Error())
+	}
+	return respondJSON(usage)
+}
+
+// handleRecordEvent appends an event reported by a client, for things that
+// happen outside the daemon such as keepalive holds.
+func (d *Daemon) handleRecordEvent(params json.RawMessage) Response {
+	var e store.Event
+	if err := json.Unmarshal(params, &e); err != nil {
+		return respondError(fmt.Sprintf("invalid params: %v", err))
+	}
+	if e.SpriteName == "" || e.Kind == "" {
+		return respondError("event needs a sprite name and kind")
+	}
+	if err := d.db.AddEvent(&e); err != nil {
+		return respondError(err.Error())
+	}
+	d.broadcast(StateUpdate{Type: "event", SpriteName: e.SpriteName, Event: &e})
+	return respondOK("ok")
+}
+
 // handleResyncWithMode tears down the current sync, creates a one-shot session
 // in the requested mode (e.g., one-way-replica), flushes it to completion, then
 // tears it down and restarts the default two-way-safe session. This lets users
@@ -988,6 +1634,9 @@ func (d *Daemon) handleResyncWithMode(params json.RawMessage) Response {
 	if s.LocalPath == "" {
 		return respondError(fmt.Sprintf("no local path configured for %s", req.Name))
 	}
+	if !spSync.MutagenInstalled() && !spSync.IsOneWayMode(req.SyncMode) {
+		return respondError(fmt.Sprintf("sync mode %q: %v", req.SyncMode, spSync.ErrOneWayOnly))
+	}
 
 	log := slog.With("sprite", req.Name, "sync_mode", req.SyncMode)
 	log.Info("resync_with_mode: beginning")
@@ -998,25 +1647,32 @@ func (d *Daemon) handleResyncWithMode(params json.RawMessage) Response {
 		mu := d.spriteSyncLock(req.Name)
 		mu.Lock()
 		defer mu.Unlock()
+		d.liftSyncPause(req.Name)
 
 		d.db.UpdateSyncStatus(req.Name, "syncing", "")
 		d.broadcast(StateUpdate{Type: "sync_status", SpriteName: req.Name})
 
 		// 1. Flush pending changes (best-effort)
 		log.Info("resync_with_mode: flushing pending changes")
-		if err := spSync.FlushMutagenSession(req.Name); err != nil {
+		if err := d.engine.Flush(d.ctx, req.Name); err != nil {
 			log.Warn("resync_with_mode: flush failed (continuing)", "error", err)
 		}
 
 		// 2. Tear down existing sync (but keep the proxy/SSH alive if possible)
 		log.Info("resync_with_mode: terminating current mutagen session")
-		if err := spSync.TerminateMutagenSession(req.Name); err != nil {
+		if err := d.engine.Terminate(context.Background(), req.Name); err != nil {
 			log.Warn("resync_with_mode: terminate failed (continuing)", "error", err)
 		}
 
-		// 3. Create a temporary session with the requested mode
+		// 3. Create a temporary session with the requested mode. Without
+		// Mutagen the built-in copy engine does the one-shot pass instead.
+		oneShot := d.engine
+		if !spSync.MutagenInstalled() {
+			log.Info("resync_with_mode: mutagen not installed, using built-in copy engine")
+			oneShot = spSync.NewCopyEngine()
+		}
 		client := sprite.NewClient(s.Org)
-		mgr := spSync.NewManager(client)
+		mgr := spSync.NewManager(client, oneShot)
 
 		// Check if we still have a live proxy; if not, do a full setup
 		d.proxiesMu.RLock()
@@ -1028,28 +1684,16 @@ func (d *Daemon) handleResyncWithMode(params json.RawMessage) Response {
 			// Full teardown + setup with the requested mode
 			d.stopSyncForSprite(req.Name)
 
-			var lastErr error
-			for attempt := 1; attempt <= maxSyncRetries; attempt++ {
-				if attempt > 1 {
-					log.Info("resync_with_mode: retrying one-shot setup", "attempt", attempt, "prev_error", lastErr)
-					time.Sleep(time.Duration(attempt) * 2 * time.Second)
-				}
-				_, err := d.attemptSyncSetup(req.Name, s.LocalPath, s.RemotePath, req.SyncMode, mgr, log)
-				if err == nil {
-					break
-				}
-				lastErr = err
-			}
-			if lastErr != nil {
-				log.Error("resync_with_mode: one-shot setup failed", "error", lastErr)
-				d.db.UpdateSyncStatus(req.Name, "error", lastErr.Error())
+			if _, _, err := d.setupSyncWithRetry(req.Name, s.LocalPath, s.RemotePath, req.SyncMode, mgr, log); err != nil {
+				log.Error("resync_with_mode: one-shot setup failed", "error", err)
+				d.db.UpdateSyncStatus(req.Name, syncFailureStatus(err), err.Error())
 				d.broadcast(StateUpdate{Type: "sync_status", SpriteName: req.Name})
 				return
 			}
 		} else {
 			// Proxy is alive — just create a new Mutagen session with the requested mode
 			log.Info("resync_with_mode: creating one-shot session", "mode", req.SyncMode)
-			_, err := mgr.StartMutagenSession(req.Name, s.LocalPath, s.RemotePath, req.SyncMode)
+			_, err := mgr.StartSession(req.Name, s.LocalPath, s.RemotePath, req.SyncMode)
 			if err != nil {
 				log.Error("resync_with_mode: one-shot session failed", "error", err)
 				d.db.UpdateSyncStatus(req.Name, "error", err.Error())
@@ -1060,21 +1704,30 @@ func (d *Daemon) handleResyncWithMode(params json.RawMessage) Response {
 
 		// 4. Flush the one-shot session to completion (up to 60s for large syncs)
 		log.Info("resync_with_mode: flushing one-shot session")
-		if err := spSync.FlushMutagenSession(req.Name); err != nil {
+		if err := oneShot.Flush(d.ctx, req.Name); err != nil {
 			log.Warn("resync_with_mode: one-shot flush failed", "error", err)
 		}
 
 		// 5. Tear down the one-shot session
 		log.Info("resync_with_mode: terminating one-shot session")
-		if err := spSync.TerminateMutagenSession(req.Name); err != nil {
+		if err := oneShot.Terminate(context.Background(), req.Name); err != nil {
 			log.Warn("resync_with_mode: one-shot terminate failed", "error", err)
 		}
 
+		// Without Mutagen there is no two-way session to go back to; leave
+		// sync stopped until the next resync.
+		if oneShot != d.engine {
+			d.stopSyncForSprite(req.Name)
+			d.broadcast(StateUpdate{Type: "sync_status", SpriteName: req.Name})
+			log.Info("resync_with_mode: complete (no ongoing sync without mutagen)")
+			return
+		}
+
 		// 6. Restart with the default two-way-safe mode (already hold the lock)
 		log.Info("resync_with_mode: restarting default two-way-safe session")
 		if hasProxy {
 			// Proxy is still alive, just create a new two-way-safe session
-			_, err := mgr.StartMutagenSession(req.Name, s.LocalPath, s.RemotePath, "")
+			_, err := mgr.StartSession(req.Name, s.LocalPath, s.RemotePath, "")
 			if err != nil {
 				log.Error("resync_with_mode: restart session failed, falling back to full restart", "error", err)
 				d.stopSyncForSprite(req.Name)
@@ -1138,11 +1791,15 @@ func (d *Daemon) handleRunSetup(params json.RawMessage) Response {
 	go func() {
 		client := sprite.NewClient(s.Org)
 		log.Info("run_setup: executing setup.conf", "files", len(conf.Files), "commands", len(conf.Commands))
-		if err := setup.RunSetupConf(client, req.Name, conf); err != nil {
+		event := &store.Event{SpriteName: req.Name, Kind: store.EventSetup, To: "ok"}
+		if err := setup.RunSetupConf(d.ctx, client, req.Name, conf, nil); err != nil {
 			log.Error("run_setup: failed", "error", err)
+			event.To, event.Message = "failed", err.Error()
 		} else {
 			log.Info("run_setup: completed successfully")
 		}
+		d.db.AddEvent(event)
+		d.broadcast(StateUpdate{Type: "event", SpriteName: req.Name, Event: event})
 	}()
 
 	return respondOK(fmt.Sprintf("running setup.conf (%d files, %d commands)", len(conf.Files), len(conf.Commands)))
@@ -1150,7 +1807,18 @@ func (d *Daemon) handleRunSetup(params json.RawMessage) Response {
 
 // handleRestart sends back an "ok" and then triggers a graceful restart.
 // The restart happens asynchronously so the response can be sent first.
-func (d *Daemon) handleRestart() Response {
+func (d *Daemon) handleRestart(params json.RawMessage) Response {
+	var req struct {
+		HTTPAddr *string `json:"http_addr"` // SP_DAEMON_HTTP for the new process, if set
+	}
+	if params != nil {
+		if err := json.Unmarshal(params, &req); err != nil {
+			return respondError(fmt.Sprintf("invalid params: %v", err))
+		}
+	}
+	if req.HTTPAddr != nil {
+		os.Setenv("SP_DAEMON_HTTP", *req.HTTPAddr)
+	}
 	slog.Info("restart: triggered via RPC")
 	go func() {
 		// Small delay to let the response get sent
@@ -1167,9 +1835,32 @@ type syncSetupResult struct {
 	ProxyPID  int
 }
 
-// maxSyncRetries is the number of times to retry the full sync pipeline when
-// the proxy dies during setup (e.g., sprite cycling warm/cold).
-const maxSyncRetries = 3
+// setupSyncWithRetry runs attemptSyncSetup under retry.SyncSetup, which
+// mostly absorbs proxies dying during setup as a sprite cycles warm/cold.
+// A missing sprite or rejected credentials stop retrying at once, and daemon
+// shutdown aborts any pending wait. Returns the number of attempts made.
+func (d *Daemon) setupSyncWithRetry(
+	spriteName, localPath, remotePath, syncMode string,
+	mgr *spSync.Manager,
+	log *slog.Logger,
+) (*syncSetupResult, int, error) {
+	p := retry.SyncSetup
+	// A missing mutagen binary won't turn up between attempts.
+	p.Retryable = func(err error) bool { return !sprite.IsPermanent(err) && !errors.Is(err, exec.ErrNotFound) }
+	p.OnRetry = func(attempt int, err error, delay time.Duration) {
+		log.Info("sync_setup: retrying", "attempt", attempt+1, "delay", delay, "prev_error", err)
+	}
+
+	var result *syncSetupResult
+	attempts := 0
+	err := retry.Do(d.ctx, p, func(context.Context) error {
+		attempts++
+		var err error
+		result, err = d.attemptSyncSetup(spriteName, localPath, remotePath, syncMode, mgr, log)
+		return err
+	})
+	return result, attempts, err
+}
 
 // handleStartSync kicks off sync setup in a background goroutine and returns
 // immediately so the RPC connection isn't blocked. The full pipeline (wake, SSH,
@@ -1199,29 +1890,21 @@ func (d *Daemon) handleStartSync(params json.RawMessage) Response {
 		mu := d.spriteSyncLock(req.SpriteName)
 		mu.Lock()
 		defer mu.Unlock()
+		d.liftSyncPause(req.SpriteName)
 
 		client := sprite.NewClient(req.Org)
-		mgr := spSync.NewManager(client)
-
-		var lastErr error
-		for attempt := 1; attempt <= maxSyncRetries; attempt++ {
-			if attempt > 1 {
-				log.Info("start_sync: retrying", "attempt", attempt, "prev_error", lastErr)
-				time.Sleep(time.Duration(attempt) * 2 * time.Second)
-			}
+		mgr := spSync.NewManager(client, d.engine)
 
-			result, err := d.attemptSyncSetup(req.SpriteName, req.LocalPath, req.RemotePath, req.SyncMode, mgr, log)
-			if err == nil {
-				log.Info("start_sync: complete",
-					"mutagen_id", result.MutagenID, "port", result.Port,
-					"proxy_pid", result.ProxyPID, "attempts", attempt)
-				return
-			}
-			lastErr = err
+		result, attempts, err := d.setupSyncWithRetry(req.SpriteName, req.LocalPath, req.RemotePath, req.SyncMode, mgr, log)
+		if err == nil {
+			log.Info("start_sync: complete",
+				"mutagen_id", result.MutagenID, "port", result.Port,
+				"proxy_pid", result.ProxyPID, "attempts", attempts)
+			return
 		}
 
-		log.Error("start_sync: failed after retries", "attempts", maxSyncRetries, "error", lastErr)
-		d.db.UpdateSyncStatus(req.SpriteName, "error", lastErr.Error())
+		log.Error("start_sync: failed after retries", "attempts", attempts, "error", err)
+		d.db.UpdateSyncStatus(req.SpriteName, syncFailureStatus(err), err.Error())
 		d.broadcast(StateUpdate{Type: "sync_status", SpriteName: req.SpriteName})
 	}()
 
@@ -1246,12 +1929,14 @@ func (d *Daemon) attemptSyncSetup(
 
 	// 0. Wake the sprite
 	if err := mgr.WakeSprite(spriteName); err != nil {
+		d.metrics.setupFailed(setupFailWake)
 		return nil, fmt.Errorf("waking sprite: %w", err)
 	}
 
 	// 1. Setup SSH server on the sprite
 	log.Info("attempt_sync: setting up SSH server")
 	if err := mgr.SetupSSHServer(spriteName); err != nil {
+		d.metrics.setupFailed(setupFailSSHServer)
 		return nil, fmt.Errorf("SSH server setup: %w", err)
 	}
 
@@ -1259,9 +1944,11 @@ func (d *Daemon) attemptSyncSetup(
 	log.Info("attempt_sync: starting proxy")
 	proxyCmd, port, err := mgr.StartProxy(spriteName)
 	if err != nil {
+		d.metrics.setupFailed(setupFailProxy)
 		return nil, fmt.Errorf("starting proxy: %w", err)
 	}
 	log.Info("attempt_sync: proxy started", "port", port, "pid", proxyCmd.Process.Pid)
+	d.metrics.proxyStarted(spriteName)
 
 	// Track and monitor the proxy
 	d.proxiesMu.Lock()
@@ -1273,6 +1960,7 @@ func (d *Daemon) attemptSyncSetup(
 	log.Info("attempt_sync: adding SSH config", "port", port)
 	if err := spSync.AddSSHConfig(spriteName, port); err != nil {
 		d.killProxy(spriteName)
+		d.metrics.setupFailed(setupFailSSHConfig)
 		return nil, fmt.Errorf("SSH config: %w", err)
 	}
 
@@ -1287,16 +1975,18 @@ func (d *Daemon) attemptSyncSetup(
 		}
 		d.killProxy(spriteName)
 		spSync.RemoveSSHConfig(spriteName)
+		d.metrics.setupFailed(setupFailSSHTest)
 		return nil, fmt.Errorf("SSH test: %w", err)
 	}
 	log.Info("attempt_sync: SSH connection verified")
 
 	// 5. Start Mutagen sync session
 	log.Info("attempt_sync: creating mutagen session")
-	mutagenID, err := mgr.StartMutagenSession(spriteName, localPath, remotePath, syncMode)
+	mutagenID, err := mgr.StartSession(spriteName, localPath, remotePath, syncMode)
 	if err != nil {
 		d.killProxy(spriteName)
 		spSync.RemoveSSHConfig(spriteName)
+		d.metrics.setupFailed(setupFailMutagen)
 		return nil, fmt.Errorf("Mutagen: %w", err)
 	}
 
@@ -1347,7 +2037,7 @@ func (d *Daemon) stopSyncForSprite(spriteName string) {
 	slog.Info("stop_sync: tearing down sync", "sprite", spriteName)
 
 	// Terminate Mutagen session (if it exists)
-	if err := spSync.TerminateMutagenSession(spriteName); err != nil {
+	if err := d.engine.Terminate(context.Background(), spriteName); err != nil {
 		slog.Debug("stop_sync: mutagen terminate", "sprite", spriteName, "error", err)
 	}
 
@@ -1454,7 +2144,7 @@ func (d *Daemon) monitorProxy(spriteName string, cmd *exec.Cmd) {
 			"sprite", spriteName, "pid", pid, "status", info.Status,
 			"stderr", stderr)
 		// Clean teardown — sprite is asleep, we'll re-sync when it wakes
-		spSync.TerminateMutagenSession(spriteName)
+		d.engine.Terminate(context.Background(), spriteName)
 		spSync.RemoveSSHConfig(spriteName)
 		d.db.DeleteSyncSession(spriteName)
 		d.db.UpdateSyncStatus(spriteName, "idle", "")
@@ -1462,7 +2152,18 @@ func (d *Daemon) monitorProxy(spriteName string, cmd *exec.Cmd) {
 		return
 	}
 
-	// Sprite is still running but proxy died — unexpected
+	// Sprite was deleted out from under us — tear down rather than retrying
+	if errors.Is(apiErr, sprite.ErrNotFound) {
+		slog.Warn("monitor_proxy: sprite no longer exists", "sprite", spriteName, "pid", pid)
+		d.engine.Terminate(context.Background(), spriteName)
+		spSync.RemoveSSHConfig(spriteName)
+		d.db.DeleteSyncSession(spriteName)
+		d.db.UpdateSyncStatus(spriteName, "error", "sprite no longer exists")
+		d.broadcast(StateUpdate{Type: "sync_status", SpriteName: spriteName})
+		return
+	}
+
+	// Sprite is still running (or we can't tell) but proxy died — unexpected
 	errMsg := "proxy exited unexpectedly"
 	if err != nil {
 		errMsg = fmt.Sprintf("proxy exited: %v", err)
@@ -1470,6 +2171,13 @@ func (d *Daemon) monitorProxy(spriteName string, cmd *exec.Cmd) {
 
 	slog.Error("monitor_proxy: unexpected exit while sprite running",
 		"sprite", spriteName, "pid", pid, "error", errMsg, "stderr", stderr)
+	message := errMsg
+	if s := strings.TrimSpace(stderr); s != "" {
+		message += ": " + s[strings.LastIndex(s, "\n")+1:] // last line, usually the cause
+	}
+	event := &store.Event{SpriteName: spriteName, Kind: store.EventProxy, Message: message}
+	d.db.AddEvent(event)
+	d.broadcast(StateUpdate{Type: "event", SpriteName: spriteName, Event: event})
 	d.db.UpdateSyncStatus(spriteName, "disconnected", errMsg)
 	d.broadcast(StateUpdate{Type: "sync_status", SpriteName: spriteName})
 }
@@ -1478,6 +2186,10 @@ func (d *Daemon) monitorProxy(spriteName string, cmd *exec.Cmd) {
 // If another sync operation is already in progress for this sprite, it returns
 // immediately without error. Safe to call from multiple goroutines concurrently.
 func (d *Daemon) restartSync(spriteName string) error {
+	if d.isSyncPaused(spriteName) {
+		slog.Info("restart_sync: skipping, sync paused", "sprite", spriteName)
+		return nil
+	}
 	mu := d.spriteSyncLock(spriteName)
 	if !mu.TryLock() {
 		slog.Info("restart_sync: skipping, sync already in progress", "sprite", spriteName)
@@ -1507,29 +2219,30 @@ func (d *Daemon) restartSyncLocked(spriteName string) error {
 	log = log.With("local", s.LocalPath, "remote", s.RemotePath, "org", s.Org)
 
 	client := sprite.NewClient(s.Org)
-	mgr := spSync.NewManager(client)
+	mgr := spSync.NewManager(client, d.engine)
 
-	var lastErr error
-	for attempt := 1; attempt <= maxSyncRetries; attempt++ {
-		if attempt > 1 {
-			log.Info("restart_sync: retrying", "attempt", attempt, "prev_error", lastErr)
-			time.Sleep(time.Duration(attempt) * 2 * time.Second)
-		}
-
-		result, err := d.attemptSyncSetup(spriteName, s.LocalPath, s.RemotePath, "", mgr, log)
-		if err == nil {
-			log.Info("restart_sync: complete",
-				"mutagen_id", result.MutagenID, "port", result.Port,
-				"proxy_pid", result.ProxyPID, "attempts", attempt)
-			return nil
-		}
-		lastErr = err
+	result, attempts, err := d.setupSyncWithRetry(spriteName, s.LocalPath, s.RemotePath, "", mgr, log)
+	if err == nil {
+		log.Info("restart_sync: complete",
+			"mutagen_id", result.MutagenID, "port", result.Port,
+			"proxy_pid", result.ProxyPID, "attempts", attempts)
+		return nil
 	}
 
-	log.Error("restart_sync: failed after retries", "attempts", maxSyncRetries, "error", lastErr)
-	d.db.UpdateSyncStatus(spriteName, "error", lastErr.Error())
+	log.Error("restart_sync: failed after retries", "attempts", attempts, "error", err)
+	d.db.UpdateSyncStatus(spriteName, syncFailureStatus(err), err.Error())
 	d.broadcast(StateUpdate{Type: "sync_status", SpriteName: spriteName})
-	return fmt.Errorf("sync setup failed after %d attempts: %w", maxSyncRetries, lastErr)
+	return fmt.Errorf("sync setup failed after %d attempts: %w", attempts, err)
+}
+
+// syncFailureStatus picks the sync status to record after setup gives up.
+// Transient failures (network, rate limiting, a sprite still waking) become
+// "disconnected" so health checks keep retrying; anything else is "error".
+func syncFailureStatus(err error) string {
+	if sprite.IsTransient(err) {
+		return "disconnected"
+	}
+	return "error"
 }
 
 // --- Response helpers ---
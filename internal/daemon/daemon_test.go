@@ -8,6 +8,7 @@ import (
 	"testing"
 	"time"
 
+	"github.com/jphenow/sp/internal/sprite"
 	"github.com/jphenow/sp/internal/store"
 )
 
@@ -91,15 +92,24 @@ func TestDaemonPingPong(t *testing.T) {
 	}
 	defer conn.Close()
 
-	// Send ping
+	// Handshake, then ping
 	encoder := json.NewEncoder(conn)
 	decoder := json.NewDecoder(conn)
 
-	if err := encoder.Encode(Request{Method: "ping"}); err != nil {
+	hello, _ := json.Marshal(map[string]int{"protocol": ProtocolVersion})
+	if err := encoder.Encode(Request{ID: 1, Method: "hello", Params: hello}); err != nil {
+		t.Fatalf("sending hello: %v", err)
+	}
+	var resp Response
+	if err := decoder.Decode(&resp); err != nil || resp.Error != "" || resp.ID != 1 {
+		t.Fatalf("handshake = %+v, %v", resp, err)
+	}
+
+	if err := encoder.Encode(Request{ID: 2, Method: "ping"}); err != nil {
 		t.Fatalf("sending ping: %v", err)
 	}
 
-	var resp Response
+	resp = Response{}
 	if err := decoder.Decode(&resp); err != nil {
 		t.Fatalf("reading response: %v", err)
 	}
@@ -107,6 +117,9 @@ func TestDaemonPingPong(t *testing.T) {
 	if resp.Error != "" {
 		t.Fatalf("ping error: %s", resp.Error)
 	}
+	if resp.ID != 2 {
+		t.Errorf("ping reply has ID %d, want 2", resp.ID)
+	}
 
 	var result string
 	if err := json.Unmarshal(resp.Result, &result); err != nil {
@@ -206,6 +219,18 @@ func TestDaemonCRUD(t *testing.T) {
 		t.Errorf("status = %q, want %q", s.Status, "warm")
 	}
 
+	// The transition is in the sprite's history
+	events, err := client.ListEvents(store.EventListOptions{SpriteName: "test-sprite"})
+	if err != nil {
+		t.Fatalf("list events: %v", err)
+	}
+	if len(events) != 1 || events[0].Change() != "running → warm" {
+		t.Errorf("events = %+v, want one running → warm", events)
+	}
+	if err := client.RecordEvent(&store.Event{SpriteName: "test-sprite"}); err == nil {
+		t.Error("recording an event without a kind succeeded")
+	}
+
 	// Delete
 	err = client.DeleteSprite("test-sprite")
 	if err != nil {
@@ -220,3 +245,219 @@ func TestDaemonCRUD(t *testing.T) {
 		t.Errorf("expected 0 sprites after delete, got %d", len(sprites))
 	}
 }
+
+func TestPauseSyncBlocksRestart(t *testing.T) {
+	d, _ := testDaemon(t)
+	if err := d.db.UpsertSprite(&store.Sprite{Name: "paused-sprite", Status: "running"}); err != nil {
+		t.Fatalf("upsert: %v", err)
+	}
+
+	params, _ := json.Marshal(map[string]string{"name": "paused-sprite"})
+	if resp := d.dispatch(context.Background(), "test", &Request{Method: "pause_sync", Params: params}); resp.Error != "" {
+		t.Fatalf("pause_sync: %s", resp.Error)
+	}
+	s, _ := d.db.GetSprite("paused-sprite")
+	if s.SyncStatus != "paused" {
+		t.Errorf("sync status = %q, want paused", s.SyncStatus)
+	}
+
+	// While paused, wake detection and recovery must not restart sync.
+	// Without paths configured restartSync would otherwise fail.
+	if err := d.restartSync("paused-sprite"); err != nil {
+		t.Errorf("restartSync while paused = %v, want skipped", err)
+	}
+	// The pause is stored, so a restarted daemon still honours it.
+	if restarted := New(Config{}, d.db); !restarted.isSyncPaused("paused-sprite") {
+		t.Error("pause lost by a new daemon on the same store")
+	}
+
+	if resp := d.dispatch(context.Background(), "test", &Request{Method: "resume_sync", Params: params}); resp.Error != "" {
+		t.Fatalf("resume_sync: %s", resp.Error)
+	}
+	// resume_sync finishes in the background; with no sync paths it just
+	// clears the paused status.
+	for i := 0; i < 50 && d.isSyncPaused("paused-sprite"); i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if d.isSyncPaused("paused-sprite") {
+		t.Fatal("still paused after resume_sync")
+	}
+	mu := d.spriteSyncLock("paused-sprite") // wait for the resume goroutine
+	mu.Lock()
+	mu.Unlock()
+	if err := d.restartSync("paused-sprite"); err == nil {
+		t.Error("restartSync after resume = nil, want error for unconfigured sprite")
+	}
+}
+
+func TestResyncLiftsStalePause(t *testing.T) {
+	d, _ := testDaemon(t)
+	if err := d.db.UpsertSprite(&store.Sprite{Name: "stranded", Status: "running"}); err != nil {
+		t.Fatalf("upsert: %v", err)
+	}
+
+	// A restore that paused sync and died before resuming it.
+	params, _ := json.Marshal(map[string]string{"name": "stranded"})
+	if resp := d.dispatch(context.Background(), "test", &Request{Method: "pause_sync", Params: params}); resp.Error != "" {
+		t.Fatalf("pause_sync: %s", resp.Error)
+	}
+
+	if resp := d.dispatch(context.Background(), "test", &Request{Method: "resync", Params: params}); resp.Error != "" {
+		t.Fatalf("resync: %s", resp.Error)
+	}
+	for i := 0; i < 50 && d.isSyncPaused("stranded"); i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if d.isSyncPaused("stranded") {
+		t.Error("still paused after resync; automatic recovery would stay off")
+	}
+	mu := d.spriteSyncLock("stranded") // wait for the resync goroutine
+	mu.Lock()
+	mu.Unlock()
+}
+
+func TestRecordUsageAfterRestart(t *testing.T) {
+	d, _ := testDaemon(t)
+	d.db.UpsertSprite(&store.Sprite{Name: "alpha"})
+	t0 := time.Now().Add(-5 * time.Hour).Truncate(time.Second)
+	if err := d.db.StartUsage("alpha", t0); err != nil {
+		t.Fatal(err)
+	}
+
+	// While the daemon was down the sprite went idle after an hour and
+	// started again at hour 3. The stopped time must not count.
+	stopped, started := t0.Add(time.Hour), t0.Add(3*time.Hour)
+	d.recordUsage(sprite.Info{Name: "alpha", Status: "running", LastActiveAt: &stopped, LastStartedAt: &started},
+		&store.UsageInterval{SpriteName: "alpha", StartedAt: t0})
+
+	usage, err := d.db.ListUsage(t0.Add(-time.Minute))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(usage) != 2 ||
+		!usage[0].StartedAt.Equal(t0) || !usage[0].EndedAt.Equal(stopped) ||
+		!usage[1].StartedAt.Equal(started) || !usage[1].EndedAt.IsZero() {
+		t.Errorf("usage = %+v, want [t0, t0+1h] and open from t0+3h", usage)
+	}
+
+	// A sprite that kept running throughout keeps its interval.
+	d2 := New(Config{}, d.db)
+	d2.recordUsage(sprite.Info{Name: "alpha", Status: "running", LastActiveAt: &stopped, LastStartedAt: &started}, usage[1])
+	if after, _ := d.db.ListUsage(t0.Add(-time.Minute)); len(after) != 2 {
+		t.Errorf("usage after a second restart = %+v, want unchanged", after)
+	}
+}
+
+func TestFindCheckpoint(t *testing.T) {
+	d, _ := testDaemon(t)
+	call := func(method string, params any) Response {
+		raw, _ := json.Marshal(params)
+		return d.dispatch(context.Background(), "test", &Request{Method: method, Params: raw})
+	}
+	if err := d.db.UpsertSprite(&store.Sprite{Name: "alpha"}); err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	for i, id := range []string{"v1", "v2", "v3"} {
+		label := "stable"
+		if id == "v3" {
+			label = "wip"
+		}
+		cp := store.Checkpoint{SpriteName: "alpha", CheckpointID: id, Label: label, CreatedAt: now.Add(time.Duration(i) * time.Minute)}
+		if resp := call("add_checkpoint", cp); resp.Error != "" {
+			t.Fatalf("add_checkpoint: %s", resp.Error)
+		}
+	}
+
+	resp := call("find_checkpoint", map[string]string{"name": "alpha", "label": "stable"})
+	var found *store.Checkpoint
+	if resp.Error != "" || json.Unmarshal(resp.Result, &found) != nil || found == nil || found.CheckpointID != "v2" {
+		t.Errorf("find_checkpoint(stable) = %s %s, want v2 (the newest with the label)", resp.Result, resp.Error)
+	}
+	resp = call("find_checkpoint", map[string]string{"name": "alpha", "label": "nope"})
+	found = &store.Checkpoint{}
+	if resp.Error != "" || json.Unmarshal(resp.Result, &found) != nil || found != nil {
+		t.Errorf("find_checkpoint(nope) = %s %s, want null", resp.Result, resp.Error)
+	}
+}
+
+func TestBudgets(t *testing.T) {
+	d, _ := testDaemon(t)
+	call := func(method string, params any) Response {
+		raw, _ := json.Marshal(params)
+		return d.dispatch(context.Background(), "test", &Request{Method: method, Params: raw})
+	}
+
+	for _, bad := range []store.Budget{
+		{Scope: "team", Hours: 1},
+		{Scope: store.BudgetTag, Hours: 1},
+		{Scope: store.BudgetGlobal, Hours: 0},
+	} {
+		if resp := call("set_budget", bad); resp.Error == "" {
+			t.Errorf("set_budget(%+v) succeeded", bad)
+		}
+	}
+	if resp := call("set_budget", store.Budget{Scope: store.BudgetSprite, Target: "api", Hours: 2}); resp.Error != "" {
+		t.Fatalf("set_budget: %s", resp.Error)
+	}
+
+	// Warnings are logged once per level as the budget runs down.
+	now := time.Now()
+	period := store.BudgetPeriodStart(now)
+	if now.Sub(period) < 2*time.Hour || !store.BudgetPeriodStart(now.Add(time.Hour)).Equal(period) {
+		t.Skip("too close to the start or end of the month")
+	}
+	d.db.StartUsage("api", now.Add(-100*time.Minute))
+	d.checkBudgets(now)
+	d.checkBudgets(now)
+	key := "sprite api " + period.Format("2006-01")
+	if level := d.budgetWarned[key]; level != 1 {
+		t.Errorf("warning level at 83%% = %d, want 1", level)
+	}
+	d.checkBudgets(now.Add(time.Hour))
+	if level := d.budgetWarned[key]; level != 2 {
+		t.Errorf("warning level once exhausted = %d, want 2", level)
+	}
+
+	if resp := call("delete_budget", map[string]string{"scope": store.BudgetSprite, "target": "api"}); resp.Error != "" {
+		t.Fatalf("delete_budget: %s", resp.Error)
+	}
+	if resp := call("delete_budget", map[string]string{"scope": store.BudgetSprite, "target": "api"}); resp.Error == "" {
+		t.Error("deleting a missing budget succeeded")
+	}
+}
+
+func TestMeta(t *testing.T) {
+	d, _ := testDaemon(t)
+	call := func(method string, params any) Response {
+		raw, _ := json.Marshal(params)
+		return d.dispatch(context.Background(), "test", &Request{Method: method, Params: raw})
+	}
+
+	if resp := call("set_meta", map[string]string{"name": "ghost", "key": "owner", "value": "me"}); resp.Error == "" {
+		t.Error("set_meta on an untracked sprite succeeded")
+	}
+	d.db.UpsertSprite(&store.Sprite{Name: "api"})
+	if resp := call("set_meta", map[string]string{"name": "api", "key": "", "value": "x"}); resp.Error == "" {
+		t.Error("set_meta with an empty key succeeded")
+	}
+	if resp := call("set_meta", map[string]string{"name": "api", "key": store.MetaNote, "value": "don't prune"}); resp.Error != "" {
+		t.Fatalf("set_meta: %s", resp.Error)
+	}
+
+	resp := call("get_meta", map[string]string{"name": "api"})
+	var meta []*store.Meta
+	if err := json.Unmarshal(resp.Result, &meta); err != nil {
+		t.Fatalf("decoding get_meta: %v (%s)", err, resp.Error)
+	}
+	if len(meta) != 1 || meta[0].Key != store.MetaNote || meta[0].Value != "don't prune" {
+		t.Errorf("get_meta = %+v, want the note", meta)
+	}
+
+	if resp := call("delete_meta", map[string]string{"name": "api", "key": store.MetaNote}); resp.Error != "" {
+		t.Fatalf("delete_meta: %s", resp.Error)
+	}
+	if resp := call("delete_meta", map[string]string{"name": "api", "key": store.MetaNote}); resp.Error == "" {
+		t.Error("deleting a missing key succeeded")
+	}
+}
@@ -0,0 +1,329 @@
+package daemon
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jphenow/sp/internal/fakebin"
+	"github.com/jphenow/sp/internal/store"
+	spSync "github.com/jphenow/sp/internal/sync"
+)
+
+func TestMain(m *testing.M) { fakebin.Main(m) }
+
+// syncedSprite registers a running fake sprite with a local directory to
+// sync, and tears down whatever sync the test leaves behind.
+func syncedSprite(t *testing.T, env *fakebin.Env, d *Daemon, name string) string {
+	t.Helper()
+	env.AddSprite(name, "running")
+	local := t.TempDir()
+	if err := os.WriteFile(filepath.Join(local, "main.go"), []byte("package main\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	err := d.db.UpsertSprite(&store.Sprite{
+		Name:       name,
+		LocalPath:  local,
+		RemotePath: "/home/sprite/proj",
+		Status:     "running",
+		SyncStatus: "none",
+	})
+	if err != nil {
+		t.Fatalf("upserting sprite: %v", err)
+	}
+	t.Cleanup(func() {
+		mu := d.spriteSyncLock(name)
+		mu.Lock()
+		defer mu.Unlock()
+		d.stopSyncForSprite(name)
+	})
+	return local
+}
+
+// waitSyncStatus polls the store until the sprite's sync status is want.
+func waitSyncStatus(t *testing.T, d *Daemon, name, want string) *store.Sprite {
+	t.Helper()
+	deadline := time.Now().Add(30 * time.Second)
+	var s *store.Sprite
+	for time.Now().Before(deadline) {
+		s, _ = d.db.GetSprite(name)
+		if s != nil && s.SyncStatus == want {
+			return s
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+	if s == nil {
+		t.Fatalf("sprite %s not in store", name)
+	}
+	t.Fatalf("sync status = %q (%s), want %q", s.SyncStatus, s.SyncError, want)
+	return nil
+}
+
+func TestStartSyncEndToEnd(t *testing.T) {
+	if testing.Short() {
+		t.Skip("end-to-end sync test")
+	}
+	env := fakebin.New(t)
+	d, _ := testDaemon(t)
+	const name = "fake-daemon-start"
+	local := syncedSprite(t, env, d, name)
+
+	params, _ := json.Marshal(map[string]string{
+		"sprite_name": name,
+		"local_path":  local,
+		"remote_path": "/home/sprite/proj",
+	})
+	if resp := d.handleStartSync(params); resp.Error != "" {
+		t.Fatalf("start_sync: %s", resp.Error)
+	}
+	waitSyncStatus(t, d, name, "watching")
+
+	sess := env.Session(spSync.SessionName(name))
+	if sess == nil {
+		t.Fatal("no mutagen session created")
+	}
+	if want := spSync.SSHHostAlias(name) + ":/home/sprite/proj"; sess.Alpha != local || sess.Beta != want {
+		t.Errorf("session endpoints = %s -> %s, want %s -> %s", sess.Alpha, sess.Beta, local, want)
+	}
+	if _, err := os.Stat(env.Path(name, "/home/sprite/proj/main.go")); err != nil {
+		t.Errorf("local files not synced to the sprite: %v", err)
+	}
+	if _, err := os.Stat(env.Path(name, "/home/sprite/.ssh/authorized_keys")); err != nil {
+		t.Errorf("SSH key not installed: %v", err)
+	}
+	if ss, _ := d.db.GetSyncSession(name); ss == nil || ss.ProxyPID == 0 {
+		t.Errorf("sync session = %+v, want one with the proxy pid", ss)
+	}
+}
+
+func TestHealthPollSleepWake(t *testing.T) {
+	if testing.Short() {
+		t.Skip("end-to-end sync test")
+	}
+	env := fakebin.New(t)
+	d, _ := testDaemon(t)
+	const name = "fake-daemon-wake"
+	syncedSprite(t, env, d, name)
+
+	if err := d.restartSync(name); err != nil {
+		t.Fatalf("restartSync: %v", err)
+	}
+	waitSyncStatus(t, d, name, "watching")
+
+	// The sprite idles out: its proxy dies and the poller tears sync down
+	// as a clean "idle" rather than an error.
+	env.SetStatus(name, "cold")
+	d.pollSpriteHealth()
+	s := waitSyncStatus(t, d, name, "idle")
+	if s.Status != "cold" {
+		t.Errorf("status = %q, want cold", s.Status)
+	}
+	if env.Session(spSync.SessionName(name)) != nil {
+		t.Error("mutagen session survived the sprite sleeping")
+	}
+	d.proxiesMu.RLock()
+	_, hasProxy := d.proxies[name]
+	d.proxiesMu.RUnlock()
+	if hasProxy {
+		t.Error("proxy still tracked after the sprite slept")
+	}
+
+	// It wakes up: the next poll brings sync back on its own.
+	env.SetStatus(name, "running")
+	d.pollSpriteHealth()
+	waitSyncStatus(t, d, name, "watching")
+	if env.Session(spSync.SessionName(name)) == nil {
+		t.Error("no mutagen session after wake")
+	}
+
+	// Both the sleep and the wake are in the sprite's history.
+	events, err := d.db.ListEvents(store.EventListOptions{SpriteName: name, Kinds: []string{store.EventStatus}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var changes []string
+	for _, e := range events {
+		changes = append(changes, e.Change())
+	}
+	if got := strings.Join(changes, ", "); !strings.HasSuffix(got, "running → cold, cold → running") {
+		t.Errorf("status events = %q", got)
+	}
+
+	// Usage was counted from the wake, and stops when it sleeps again.
+	usage, err := d.db.ListUsage(time.Now().Add(-time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(usage) != 1 || !usage[0].EndedAt.IsZero() {
+		t.Fatalf("usage = %+v, want one open interval", usage)
+	}
+	env.SetStatus(name, "cold")
+	d.pollSpriteHealth()
+	waitSyncStatus(t, d, name, "idle")
+	if usage, _ = d.db.ListUsage(time.Now().Add(-time.Hour)); len(usage) != 1 || usage[0].EndedAt.IsZero() {
+		t.Errorf("usage after sleeping = %+v, want one closed interval", usage)
+	}
+}
+
+func TestHealthPollEndsUsageOfRemovedSprites(t *testing.T) {
+	env := fakebin.New(t)
+	d, _ := testDaemon(t)
+	for _, name := range []string{"usage-destroyed", "usage-deleted"} {
+		env.AddSprite(name, "running")
+		if err := d.db.UpsertSprite(&store.Sprite{Name: name, Status: "running"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	openUsage := func() []string {
+		t.Helper()
+		open, err := d.db.ListOpenUsage()
+		if err != nil {
+			t.Fatal(err)
+		}
+		var names []string
+		for _, u := range open {
+			names = append(names, u.SpriteName)
+		}
+		return names
+	}
+
+	d.pollSpriteHealth()
+	if got := openUsage(); len(got) != 2 {
+		t.Fatalf("open usage = %v, want both sprites", got)
+	}
+
+	// Destroyed outside sp: the sprite drops out of the API list, and the
+	// next poll ends its interval.
+	if err := d.client.Destroy("usage-destroyed"); err != nil {
+		t.Fatal(err)
+	}
+	d.pollSpriteHealth()
+	if got := openUsage(); len(got) != 1 || got[0] != "usage-deleted" {
+		t.Errorf("open usage after destroy = %v, want [usage-deleted]", got)
+	}
+
+	// Removed with sp rm: the interval ends with the record, even though
+	// the sprite itself still runs.
+	params, _ := json.Marshal(map[string]string{"name": "usage-deleted"})
+	if resp := d.handleDelete(params); resp.Error != "" {
+		t.Fatalf("delete: %s", resp.Error)
+	}
+	if got := openUsage(); len(got) != 0 {
+		t.Errorf("open usage after delete = %v, want none", got)
+	}
+	d.pollSpriteHealth()
+	if got := openUsage(); len(got) != 0 {
+		t.Errorf("open usage after polling an untracked sprite = %v, want none", got)
+	}
+}
+
+func TestResyncWithModeWithoutMutagen(t *testing.T) {
+	if testing.Short() {
+		t.Skip("end-to-end sync test")
+	}
+	env := fakebin.New(t)
+	if err := os.Remove(filepath.Join(env.Root, "bin", "mutagen")); err != nil {
+		t.Fatal(err)
+	}
+	d, _ := testDaemon(t)
+	const name = "fake-daemon-copy"
+	syncedSprite(t, env, d, name)
+
+	params, _ := json.Marshal(map[string]string{"name": name, "sync_mode": "two-way-safe"})
+	if resp := d.handleResyncWithMode(params); resp.Error == "" {
+		t.Error("two-way resync without mutagen succeeded, want an error")
+	}
+
+	params, _ = json.Marshal(map[string]string{"name": name, "sync_mode": "one-way-replica-to-remote"})
+	if resp := d.handleResyncWithMode(params); resp.Error != "" {
+		t.Fatalf("resync_with_mode: %s", resp.Error)
+	}
+	remote := env.Path(name, "/home/sprite/proj/main.go")
+	deadline := time.Now().Add(30 * time.Second)
+	for {
+		if _, err := os.Stat(remote); err == nil {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("copy engine never uploaded main.go")
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+	// With no Mutagen to hand back to, sync ends up stopped: the proxy the
+	// copy used is gone and the status is back to "none".
+	for {
+		d.proxiesMu.RLock()
+		_, hasProxy := d.proxies[name]
+		d.proxiesMu.RUnlock()
+		s, _ := d.db.GetSprite(name)
+		if !hasProxy && s != nil && s.SyncStatus == "none" {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("sync not stopped after one-shot copy: proxy=%v sprite=%+v", hasProxy, s)
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+}
+
+func TestResolveConflictEndToEnd(t *testing.T) {
+	if testing.Short() {
+		t.Skip("end-to-end sync test")
+	}
+	env := fakebin.New(t)
+	d, _ := testDaemon(t)
+	const name = "fake-daemon-conflict"
+	local := syncedSprite(t, env, d, name)
+	if err := d.restartSync(name); err != nil {
+		t.Fatalf("restartSync: %v", err)
+	}
+	waitSyncStatus(t, d, name, "watching")
+
+	// Both sides edit main.go.
+	os.WriteFile(filepath.Join(local, "main.go"), []byte("package main // local\n"), 0o644)
+	os.WriteFile(env.Path(name, "/home/sprite/proj/main.go"), []byte("package main // remote\n"), 0o644)
+	env.SetConflicts(spSync.SessionName(name), "main.go")
+
+	params, _ := json.Marshal(map[string]string{"name": name})
+	resp := d.handleListConflicts(params)
+	if resp.Error != "" {
+		t.Fatalf("list_conflicts: %s", resp.Error)
+	}
+	var conflicts []spSync.ConflictDetail
+	json.Unmarshal(resp.Result, &conflicts)
+	if len(conflicts) != 1 || conflicts[0].Path != "main.go" || conflicts[0].LocalChange != "modified" ||
+		conflicts[0].Remote.Size != int64(len("package main // remote\n")) {
+		t.Fatalf("list_conflicts = %+v, want main.go modified on both sides", conflicts)
+	}
+
+	params, _ = json.Marshal(map[string]string{"name": name, "path": "main.go"})
+	resp = d.handleReadConflict(params)
+	var contents spSync.ConflictContents
+	json.Unmarshal(resp.Result, &contents)
+	if resp.Error != "" || string(contents.LocalData) != "package main // local\n" || string(contents.RemoteData) != "package main // remote\n" {
+		t.Fatalf("read_conflict = %+v, %s", contents, resp.Error)
+	}
+
+	params, _ = json.Marshal(map[string]string{"name": name, "path": "main.go", "keep": "remote"})
+	if resp := d.handleResolveConflict(params); resp.Error != "" {
+		t.Fatalf("resolve_conflict: %s", resp.Error)
+	}
+	if data, _ := os.ReadFile(filepath.Join(local, "main.go")); string(data) != "package main // remote\n" {
+		t.Errorf("local main.go = %q, want the remote version", data)
+	}
+	if sess := env.Session(spSync.SessionName(name)); len(sess.Conflicts) != 0 {
+		t.Errorf("conflicts after resolving = %v, want none", sess.Conflicts)
+	}
+	events, _ := d.db.ListEvents(store.EventListOptions{SpriteName: name, Kinds: []string{store.EventConflict}})
+	if len(events) != 1 || events[0].To != "remote" || events[0].Message != "main.go" {
+		t.Errorf("conflict events = %+v, want one keeping the remote main.go", events)
+	}
+
+	params, _ = json.Marshal(map[string]string{"name": name, "path": "main.go", "keep": "theirs"})
+	if resp := d.handleResolveConflict(params); resp.Error == "" {
+		t.Error("resolve_conflict with an unknown resolution succeeded")
+	}
+}
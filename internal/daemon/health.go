@@ -2,15 +2,17 @@ package daemon
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log/slog"
-	"math"
 	"net/http"
 	"os"
 	"sync"
 	"syscall"
 	"time"
 
+	"github.com/jphenow/sp/internal/retry"
+	"github.com/jphenow/sp/internal/sprite"
 	"github.com/jphenow/sp/internal/store"
 	spSync "github.com/jphenow/sp/internal/sync"
 )
@@ -20,11 +22,12 @@ import (
 type HealthMonitor struct {
 	db     *store.DB
 	daemon *Daemon // reference to owning daemon for proxy management and restart
+	engine spSync.SyncEngine
 	mu     sync.RWMutex
 	online bool // can we reach the sprites API?
 
-	// Per-sprite backoff tracking
-	backoffs   map[string]*backoffState
+	// Per-sprite backoff tracking, following retry.HealthCheck
+	backoffs   map[string]*retry.Backoff
 	backoffsMu sync.RWMutex
 
 	// Tracks when sprites entered the "connecting" state for auto-recovery
@@ -37,36 +40,21 @@ type HealthMonitor struct {
 	lastReset   map[string]time.Time
 	lastResetMu sync.RWMutex
 
+	// Sync problems already shown as desktop notifications
+	alerts syncAlerts
+
 	// Callback when state changes
 	onUpdate func(StateUpdate)
 }
 
-// connectingRecoveryTimeout is how long we wait before attempting recovery
-// for a sync session stuck in "connecting" state.
-const connectingRecoveryTimeout = 60 * time.Second
-
-// syncResetInterval controls how often we reset a stable ("watching") Mutagen
-// session to force a full rescan. This catches file drift that filesystem
-// watchers miss — particularly atomic renames inside .git/ (e.g., git writing
-// a new packed-refs via tempfile + rename). Five minutes balances freshness
-// against unnecessary churn.
-const syncResetInterval = 5 * time.Minute
-
-// backoffState tracks exponential backoff for individual sprite polling.
-type backoffState struct {
-	failures  int
-	nextPoll  time.Time
-	baseDelay time.Duration
-	maxDelay  time.Duration
-}
-
 // NewHealthMonitor creates a new health monitor that tracks sprites and network state.
 func NewHealthMonitor(db *store.DB, daemon *Daemon, onUpdate func(StateUpdate)) *HealthMonitor {
 	return &HealthMonitor{
 		db:              db,
 		daemon:          daemon,
+		engine:          daemon.engine,
 		online:          true,
-		backoffs:        make(map[string]*backoffState),
+		backoffs:        make(map[string]*retry.Backoff),
 		connectingSince: make(map[string]time.Time),
 		lastReset:       make(map[string]time.Time),
 		onUpdate:        onUpdate,
@@ -89,17 +77,25 @@ func (h *HealthMonitor) Run(ctx context.Context) {
 	h.checkAllSyncStatus()
 	h.checkAllProxyLiveness()
 
-	networkTicker := time.NewTicker(15 * time.Second)
-	syncTicker := time.NewTicker(10 * time.Second)
-	proxyTicker := time.NewTicker(15 * time.Second)
+	config := h.daemon.cfg()
+	networkTicker := time.NewTicker(config.NetworkCheckInterval)
+	syncTicker := time.NewTicker(config.SyncCheckInterval)
+	proxyTicker := time.NewTicker(config.ProxyCheckInterval)
 	defer networkTicker.Stop()
 	defer syncTicker.Stop()
 	defer proxyTicker.Stop()
+	changed := h.daemon.configChanged()
 
 	for {
 		select {
 		case <-ctx.Done():
 			return
+		case <-changed:
+			changed = h.daemon.configChanged()
+			config := h.daemon.cfg()
+			networkTicker.Reset(config.NetworkCheckInterval)
+			syncTicker.Reset(config.SyncCheckInterval)
+			proxyTicker.Reset(config.ProxyCheckInterval)
 		case <-networkTicker.C:
 			h.checkNetwork()
 		case <-syncTicker.C:
@@ -118,12 +114,14 @@ func (h *HealthMonitor) checkNetwork() {
 	resp, err := client.Get("https://api.sprites.dev/v1/sprites")
 	wasOnline := h.IsOnline()
 
-	h.mu.Lock()
-	if err != nil || resp.StatusCode >= 500 {
-		h.online = false
-	} else {
-		h.online = true
+	// Anything but a network-class failure means the API is reachable; an
+	// unauthenticated probe gets 401, which still counts as online.
+	if err == nil && errors.Is(sprite.ErrorForStatus(resp.StatusCode), sprite.ErrNetwork) {
+		err = fmt.Errorf("sprites API returned %s", resp.Status)
 	}
+
+	h.mu.Lock()
+	h.online = err == nil
 	h.mu.Unlock()
 
 	if resp != nil {
@@ -140,7 +138,9 @@ func (h *HealthMonitor) checkNetwork() {
 }
 
 // checkAllSyncStatus checks Mutagen sync status for all tracked sprites
-// that have sync enabled.
+// that have sync enabled, except those sp itself paused (pause_sync). A
+// session paused by hand with `mutagen sync pause` reports "paused" too, but
+// is still checked, so its status follows it when it's resumed.
 func (h *HealthMonitor) checkAllSyncStatus() {
 	sprites, err := h.db.ListSprites(store.ListOptions{})
 	if err != nil {
@@ -151,6 +151,9 @@ func (h *HealthMonitor) checkAllSyncStatus() {
 		if s.SyncStatus == "none" || s.SyncStatus == "" {
 			continue
 		}
+		if paused, _ := h.db.IsSyncPaused(s.Name); paused {
+			continue
+		}
 
 		// Check if this sprite is in backoff
 		if h.isInBackoff(s.Name) {
@@ -164,7 +167,7 @@ func (h *HealthMonitor) checkAllSyncStatus() {
 // checkSpriteSync checks the sync status of a single sprite and updates the database.
 // If sync has been stuck in "connecting" for too long, triggers auto-recovery.
 func (h *HealthMonitor) checkSpriteSync(s *store.Sprite) {
-	state, err := spSync.GetMutagenStatus(s.Name)
+	state, err := h.engine.Status(context.Background(), s.Name)
 	if err != nil {
 		h.recordFailure(s.Name)
 		return
@@ -173,6 +176,8 @@ func (h *HealthMonitor) checkSpriteSync(s *store.Sprite) {
 	// Reset backoff on success
 	h.resetBackoff(s.Name)
 
+	h.recordSessionState(s.Name, state)
+
 	oldSyncStatus := s.SyncStatus
 	newSyncStatus := state.Status
 	syncError := state.LastError
@@ -193,7 +198,7 @@ func (h *HealthMonitor) checkSpriteSync(s *store.Sprite) {
 		h.connectingSinceMu.RLock()
 		since, exists := h.connectingSince[s.Name]
 		h.connectingSinceMu.RUnlock()
-		if exists && time.Since(since) > connectingRecoveryTimeout {
+		if exists && time.Since(since) > h.daemon.cfg().ConnectingRecoveryTimeout {
 			slog.Warn("health: sync stuck in connecting, attempting recovery",
 				"sprite", s.Name, "duration", time.Since(since).Round(time.Second))
 			h.connectingSinceMu.Lock()
@@ -211,7 +216,13 @@ func (h *HealthMonitor) checkSpriteSync(s *store.Sprite) {
 	// two-way-safe mode mean files are stuck and won't sync until resolved.
 	if newSyncStatus == "watching" && state.Conflicts > 0 {
 		newSyncStatus = "conflicts"
-		syncError = fmt.Sprintf("%d conflicting file(s) — use sync menu to force-push or force-pull", state.Conflicts)
+		syncError = fmt.Sprintf("%d conflicting file(s) — run sp conflicts to resolve them", state.Conflicts)
+	}
+
+	if interval := h.daemon.cfg().NotifyInterval; interval > 0 {
+		if n := h.alerts.check(s.Name, oldSyncStatus, newSyncStatus, state.Conflicts, syncError, interval, time.Now()); n != nil {
+			h.daemon.notify(s.Name, *n)
+		}
 	}
 
 	if oldSyncStatus != newSyncStatus || s.SyncError != syncError {
@@ -239,10 +250,10 @@ func (h *HealthMonitor) checkSpriteSync(s *store.Sprite) {
 		last, exists := h.lastReset[s.Name]
 		h.lastResetMu.RUnlock()
 
-		if !exists || time.Since(last) > syncResetInterval {
+		if !exists || time.Since(last) > h.daemon.cfg().SyncResetInterval {
 			slog.Info("health: periodic sync reset (forcing rescan)",
 				"sprite", s.Name, "last_reset", last)
-			if err := spSync.ResetMutagenSession(s.Name); err != nil {
+			if err := h.engine.Reset(context.Background(), s.Name); err != nil {
 				slog.Warn("health: periodic reset failed", "sprite", s.Name, "error", err)
 			} else {
 				h.lastResetMu.Lock()
@@ -261,30 +272,59 @@ func (h *HealthMonitor) isInBackoff(name string) bool {
 	if !ok {
 		return false
 	}
-	return time.Now().Before(bs.nextPoll)
+	return !bs.Ready(time.Now())
 }
 
-// recordFailure increments the failure count and calculates the next poll time
-// using exponential backoff.
+// recordSessionState copies the connection and conflict state Mutagen
+// reports into the sprite's stored sync session, when it has changed.
+func (h *HealthMonitor) recordSessionState(name string, state *spSync.SessionState) {
+	ss, err := h.db.GetSyncSession(name)
+	if err != nil || ss == nil {
+		return
+	}
+	if ss.AlphaConnected == state.AlphaConnected && ss.BetaConnected == state.BetaConnected &&
+		ss.Conflicts == state.Conflicts && ss.LastError == state.LastError {
+		return
+	}
+	ss.AlphaConnected = state.AlphaConnected
+	ss.BetaConnected = state.BetaConnected
+	ss.Conflicts = state.Conflicts
+	ss.LastError = state.LastError
+	if err := h.db.UpsertSyncSession(ss); err != nil {
+		slog.Debug("health: recording sync session state", "sprite", name, "error", err)
+	}
+}
+
+// backoffState is a sprite's health-check backoff, as reported by /metrics.
+type backoffState struct {
+	failures int  // consecutive failed checks
+	waiting  bool // still waiting out the delay before the next check
+}
+
+// backoffSnapshot returns the backoff state of every sprite with recent
+// failed checks.
+func (h *HealthMonitor) backoffSnapshot(now time.Time) map[string]backoffState {
+	h.backoffsMu.RLock()
+	defer h.backoffsMu.RUnlock()
+	snap := make(map[string]backoffState, len(h.backoffs))
+	for name, bs := range h.backoffs {
+		snap[name] = backoffState{failures: bs.Failures(), waiting: !bs.Ready(now)}
+	}
+	return snap
+}
+
+// recordFailure increments the failure count and schedules the next poll
+// using the retry.HealthCheck backoff schedule.
 func (h *HealthMonitor) recordFailure(name string) {
 	h.backoffsMu.Lock()
 	defer h.backoffsMu.Unlock()
 
 	bs, ok := h.backoffs[name]
 	if !ok {
-		bs = &backoffState{
-			baseDelay: 5 * time.Second,
-			maxDelay:  2 * time.Minute,
-		}
+		bs = retry.NewBackoff(retry.HealthCheck)
 		h.backoffs[name] = bs
 	}
-
-	bs.failures++
-	delay := time.Duration(math.Min(
-		float64(bs.baseDelay)*math.Pow(2, float64(bs.failures-1)),
-		float64(bs.maxDelay),
-	))
-	bs.nextPoll = time.Now().Add(delay)
+	bs.Failure(time.Now())
 }
 
 // resetBackoff clears the backoff state for a sprite.
@@ -298,7 +338,7 @@ func (h *HealthMonitor) resetBackoff(name string) {
 func (h *HealthMonitor) resetAllBackoffs() {
 	h.backoffsMu.Lock()
 	defer h.backoffsMu.Unlock()
-	h.backoffs = make(map[string]*backoffState)
+	h.backoffs = make(map[string]*retry.Backoff)
 }
 
 // AttemptSyncRecovery tries to fully recover sync for a sprite by tearing
@@ -318,7 +358,7 @@ func (h *HealthMonitor) AttemptSyncRecovery(spriteName string) error {
 
 	if err := h.daemon.restartSync(spriteName); err != nil {
 		slog.Error("recovery: failed", "sprite", spriteName, "error", err)
-		h.db.UpdateSyncStatus(spriteName, "error", fmt.Sprintf("recovery failed: %v", err))
+		h.db.UpdateSyncStatus(spriteName, syncFailureStatus(err), fmt.Sprintf("recovery failed: %v", err))
 		if h.onUpdate != nil {
 			h.onUpdate(StateUpdate{Type: "sync_status", SpriteName: spriteName})
 		}
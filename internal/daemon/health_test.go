@@ -0,0 +1,100 @@
+package daemon
+
+import (
+	"errors"
+	"slices"
+	"testing"
+
+	"github.com/jphenow/sp/internal/store"
+	spSync "github.com/jphenow/sp/internal/sync"
+	"github.com/jphenow/sp/internal/sync/synctest"
+)
+
+// healthWithEngine returns a health monitor over a test daemon whose sync
+// engine is an in-memory fake, with one sprite whose sync is "connecting".
+func healthWithEngine(t *testing.T, name string) (*HealthMonitor, *synctest.Engine, *Daemon) {
+	t.Helper()
+	d, _ := testDaemon(t)
+	engine := synctest.New()
+	d.engine = engine
+	if err := d.db.UpsertSprite(&store.Sprite{Name: name, Status: "running", SyncStatus: "connecting"}); err != nil {
+		t.Fatalf("upserting sprite: %v", err)
+	}
+	return NewHealthMonitor(d.db, d, nil), engine, d
+}
+
+func TestCheckSpriteSyncConflicts(t *testing.T) {
+	h, engine, d := healthWithEngine(t, "web")
+	engine.Set(spSync.SessionState{Name: spSync.SessionName("web"), Status: "watching", Conflicts: 2})
+
+	s, _ := d.db.GetSprite("web")
+	h.checkSpriteSync(s)
+
+	s, _ = d.db.GetSprite("web")
+	if s.SyncStatus != "conflicts" {
+		t.Errorf("sync status = %q, want conflicts", s.SyncStatus)
+	}
+	if s.SyncError == "" {
+		t.Error("sync error should describe the conflicts")
+	}
+}
+
+func TestCheckSpriteSyncWatchingResets(t *testing.T) {
+	h, engine, d := healthWithEngine(t, "web")
+	engine.Set(spSync.SessionState{Name: spSync.SessionName("web"), Status: "watching"})
+
+	s, _ := d.db.GetSprite("web")
+	h.checkSpriteSync(s)
+
+	if s, _ := d.db.GetSprite("web"); s.SyncStatus != "watching" {
+		t.Errorf("sync status = %q, want watching", s.SyncStatus)
+	}
+	// The first check of a stable session forces a rescan; the next one
+	// within the sync reset interval doesn't.
+	h.checkSpriteSync(s)
+	want := []string{"Status web", "Reset web", "Status web"}
+	if got := engine.Calls(); !slices.Equal(got, want) {
+		t.Errorf("calls = %q, want %q", got, want)
+	}
+}
+
+func TestCheckSpriteSyncEngineError(t *testing.T) {
+	h, engine, d := healthWithEngine(t, "web")
+	engine.Err = errors.New("mutagen daemon not running")
+
+	s, _ := d.db.GetSprite("web")
+	h.checkSpriteSync(s)
+
+	if !h.isInBackoff("web") {
+		t.Error("engine failure should put the sprite in backoff")
+	}
+	if s, _ := d.db.GetSprite("web"); s.SyncStatus != "connecting" {
+		t.Errorf("sync status = %q, want it unchanged", s.SyncStatus)
+	}
+}
+
+func TestCheckAllSyncStatusFollowsMutagenPause(t *testing.T) {
+	h, engine, d := healthWithEngine(t, "web")
+
+	// Paused by hand with `mutagen sync pause`, then resumed outside sp.
+	engine.Set(spSync.SessionState{Name: spSync.SessionName("web"), Status: "paused"})
+	h.checkAllSyncStatus()
+	if s, _ := d.db.GetSprite("web"); s.SyncStatus != "paused" {
+		t.Fatalf("sync status = %q, want paused", s.SyncStatus)
+	}
+	engine.Set(spSync.SessionState{Name: spSync.SessionName("web"), Status: "watching"})
+	h.checkAllSyncStatus()
+	if s, _ := d.db.GetSprite("web"); s.SyncStatus != "watching" {
+		t.Errorf("sync status after resuming = %q, want watching", s.SyncStatus)
+	}
+
+	// Paused by sp for a restore: left alone until resume_sync.
+	if err := d.db.SetSyncPaused("web", true); err != nil {
+		t.Fatal(err)
+	}
+	calls := len(engine.Calls())
+	h.checkAllSyncStatus()
+	if got := engine.Calls()[calls:]; len(got) != 0 {
+		t.Errorf("calls while paused by sp = %q, want none", got)
+	}
+}
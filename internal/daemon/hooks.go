@@ -0,0 +1,332 @@
+package daemon
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/exec"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/jphenow/sp/internal/sprite"
+	"github.com/jphenow/sp/internal/store"
+)
+
+// Hooks run the user's own commands when something happens to a sprite: it
+// woke, sync reached "watching", a conflict appeared, the proxy died, it was
+// deleted. They're configured as [[hooks]] tables in config.toml:
+//
+//	[[hooks]]
+//	on = ["sync_status:conflicts", "event:proxy"]
+//	run = "notify-send \"sp: $SP_HOOK_EVENT on $SP_SPRITE\""
+//	match = "tag:work"
+//
+// The hook runner subscribes to the daemon's broadcasts like any client, so
+// hooks fire on the same transitions the TUI sees. Each run is recorded in
+// the sprite's history as an EventHook.
+
+// defaultHookTimeout bounds a hook's commands when it doesn't set a timeout.
+const defaultHookTimeout = 30 * time.Second
+
+// hookSubscriber is the subscriber ID the hook runner uses.
+const hookSubscriber = "hooks"
+
+// hookEventTypes are the event types a hook's "on" can name. All but
+// sprite_added and sprite_removed take an optional ":value": the new status
+// for sprite_status and sync_status, the history kind for event.
+var hookEventTypes = []string{"sprite_added", "sprite_removed", "sprite_status", "sync_status", "event"}
+
+// Hook is a [[hooks]] table from config.toml.
+type Hook struct {
+	On      []string      // events that fire it, e.g. "sprite_status:running" or "sprite_removed"
+	Run     string        // local command, run with sh -c and the event as JSON on stdin
+	Remote  string        // command run on the sprite with sprite exec
+	Match   string        // only sprites matching this query (see store.Query)
+	Timeout time.Duration // bounds each command (0 = defaultHookTimeout)
+}
+
+// validate checks that the hook names known events and has something to run.
+func (h Hook) validate() error {
+	if len(h.On) == 0 {
+		return errors.New(`needs "on", the events that fire it`)
+	}
+	if err := validateEventFilter(h.On, h.Match, h.Timeout); err != nil {
+		return err
+	}
+	if h.Run == "" && h.Remote == "" {
+		return errors.New(`needs "run" or "remote", a command to run`)
+	}
+	return nil
+}
+
+// validateEventFilter checks the "on", "match" and "timeout" settings
+// shared by hooks and webhooks.
+func validateEventFilter(on []string, match string, timeout time.Duration) error {
+	for _, on := range on {
+		typ, value, hasValue := strings.Cut(on, ":")
+		switch {
+		case !slices.Contains(hookEventTypes, typ):
+			return fmt.Errorf("unknown event %q (want one of %s)", on, strings.Join(hookEventTypes, ", "))
+		case hasValue && value == "":
+			return fmt.Errorf("event %q has an empty value", on)
+		case hasValue && (typ == "sprite_added" || typ == "sprite_removed"):
+			return fmt.Errorf("event %q takes no value", on)
+		}
+	}
+	if match != "" {
+		if _, err := store.ParseQuery(match); err != nil {
+			return fmt.Errorf("match: %w", err)
+		}
+	}
+	if timeout != 0 && timeout < time.Second {
+		return fmt.Errorf("timeout is %v; it must be at least 1s", timeout)
+	}
+	return nil
+}
+
+// fires reports whether the hook's "on" names ev.
+func (h Hook) fires(ev hookEvent) bool {
+	return eventSelected(h.On, ev)
+}
+
+// eventSelected reports whether ev is one of the "on" events; an empty list
+// selects every event.
+func eventSelected(on []string, ev hookEvent) bool {
+	for _, on := range on {
+		typ, value, hasValue := strings.Cut(on, ":")
+		if typ == ev.Type && (!hasValue || value == ev.To) {
+			return true
+		}
+	}
+	return len(on) == 0
+}
+
+// hookEvent is what a hook learns about the event that fired it. It's
+// written to a local command's stdin, and posted to webhooks, as JSON.
+type hookEvent struct {
+	Event     string        `json:"event"` // type:value, e.g. "sync_status:watching"
+	Type      string        `json:"type"`
+	Sprite    string        `json:"sprite"`
+	From      string        `json:"from,omitempty"`       // the previous status, for transitions
+	To        string        `json:"to,omitempty"`         // the new status, or the history kind for "event"
+	SyncError string        `json:"sync_error,omitempty"` // for sync_status
+	Conflicts int           `json:"conflicts,omitempty"`  // unresolved sync conflicts, for sync_status
+	Message   string        `json:"message,omitempty"`    // the history entry, for "event"
+	Time      time.Time     `json:"time"`
+	Info      *store.Sprite `json:"sprite_info,omitempty"` // the sprite as it is now; nil once removed
+}
+
+// hookSpriteState is what the hook runner remembers about a sprite, to tell
+// real transitions from repeated broadcasts.
+type hookSpriteState struct {
+	status, syncStatus string
+}
+
+// hookRunner fires hooks and webhooks for state updates until ctx is
+// cancelled.
+func (d *Daemon) hookRunner(ctx context.Context) {
+	ch := d.subscribe(hookSubscriber)
+	defer d.unsubscribe(hookSubscriber)
+
+	seen := map[string]hookSpriteState{}
+	if sprites, err := d.db.ListSprites(store.ListOptions{}); err == nil {
+		for _, s := range sprites {
+			seen[s.Name] = hookSpriteState{s.Status, s.SyncStatus}
+		}
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case update, ok := <-ch:
+			if !ok {
+				return
+			}
+			for _, ev := range d.hookEvents(update, seen, time.Now()) {
+				d.fireHooks(ctx, ev)
+				d.fireWebhooks(ctx, ev)
+			}
+		}
+	}
+}
+
+// hookEvents turns a state update into the events hooks can fire on, using
+// and updating seen, the last state of each sprite.
+func (d *Daemon) hookEvents(update StateUpdate, seen map[string]hookSpriteState, now time.Time) []hookEvent {
+	name := update.SpriteName
+	event := func(typ, from, to string) hookEvent {
+		ev := hookEvent{Event: typ, Type: typ, Sprite: name, From: from, To: to, Time: now}
+		if to != "" {
+			ev.Event += ":" + to
+		}
+		return ev
+	}
+
+	switch update.Type {
+	case "sprite_removed":
+		delete(seen, name)
+		return []hookEvent{event("sprite_removed", "", "")}
+
+	case "event":
+		// Hook runs are history events too; never fire hooks on them.
+		if update.Event == nil || update.Event.Kind == store.EventHook {
+			return nil
+		}
+		ev := event("event", "", update.Event.Kind)
+		ev.Message = strings.TrimSpace(update.Event.Change() + " " + update.Event.Message)
+		ev.Info, _ = d.db.GetSprite(name)
+		return []hookEvent{ev}
+
+	case "sprite_added", "sprite_status", "sync_status", "sprite_updated":
+		s, err := d.db.GetSprite(name)
+		if err != nil || s == nil {
+			return nil
+		}
+		cur := hookSpriteState{s.Status, s.SyncStatus}
+		prev, known := seen[name]
+		seen[name] = cur
+
+		var events []hookEvent
+		if !known {
+			if update.Type == "sprite_added" {
+				events = append(events, event("sprite_added", "", ""))
+			}
+		} else {
+			if prev.status != cur.status {
+				events = append(events, event("sprite_status", prev.status, cur.status))
+			}
+			if prev.syncStatus != cur.syncStatus {
+				ev := event("sync_status", prev.syncStatus, cur.syncStatus)
+				ev.SyncError = s.SyncError
+				if ss, _ := d.db.GetSyncSession(name); ss != nil {
+					ev.Conflicts = ss.Conflicts
+				}
+				events = append(events, ev)
+			}
+		}
+		for i := range events {
+			events[i].Info = s
+		}
+		return events
+	}
+	return nil
+}
+
+// fireHooks starts every configured hook that fires on ev.
+func (d *Daemon) fireHooks(ctx context.Context, ev hookEvent) {
+	for _, h := range d.cfg().Hooks {
+		if h.fires(ev) && d.hookMatches(h.Match, ev) {
+			go d.runHook(ctx, h, ev)
+		}
+	}
+}
+
+// hookMatches reports whether the event's sprite matches a hook or
+// webhook's query. A removed sprite can't be matched against anything.
+func (d *Daemon) hookMatches(query string, ev hookEvent) bool {
+	if query == "" {
+		return true
+	}
+	if ev.Info == nil {
+		return false
+	}
+	name := ev.Sprite
+	sprites, err := d.db.ListSprites(store.ListOptions{Query: query, NameFilter: name})
+	if err != nil {
+		slog.Warn("hooks: match failed", "query", query, "error", err)
+		return false
+	}
+	return slices.ContainsFunc(sprites, func(s *store.Sprite) bool { return s.Name == name })
+}
+
+// runHook runs a hook's commands for ev and records the outcome of each in
+// the sprite's history.
+func (d *Daemon) runHook(ctx context.Context, h Hook, ev hookEvent) {
+	timeout := cmp.Or(h.Timeout, defaultHookTimeout)
+	if h.Run != "" {
+		out, err := runLocalHook(ctx, h.Run, ev, timeout)
+		d.recordHook(ev, h.Run, out, err)
+	}
+	if h.Remote != "" {
+		if ev.Info == nil {
+			slog.Info("hooks: skipping remote command, the sprite is gone", "sprite", ev.Sprite, "event", ev.Event)
+			return
+		}
+		out, err := runRemoteHook(ctx, h.Remote, ev, timeout)
+		d.recordHook(ev, "remote: "+h.Remote, out, err)
+	}
+}
+
+// hookEnv is the environment a hook's commands get, besides their own.
+func hookEnv(ev hookEvent) map[string]string {
+	return map[string]string{
+		"SP_HOOK_EVENT": ev.Event,
+		"SP_SPRITE":     ev.Sprite,
+		"SP_HOOK_FROM":  ev.From,
+		"SP_HOOK_TO":    ev.To,
+	}
+}
+
+// runLocalHook runs command with sh -c, the event as JSON on stdin, and
+// returns its combined output.
+func runLocalHook(ctx context.Context, command string, ev hookEvent, timeout time.Duration) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	payload, err := json.Marshal(ev)
+	if err != nil {
+		return nil, fmt.Errorf("encoding event: %w", err)
+	}
+	cmd := exec.CommandContext(ctx, "sh", "-c", command)
+	cmd.Stdin = bytes.NewReader(payload)
+	cmd.Env = os.Environ()
+	for k, v := range hookEnv(ev) {
+		cmd.Env = append(cmd.Env, k+"="+v)
+	}
+	// Don't wait on a background child still holding the output pipe.
+	cmd.WaitDelay = time.Second
+
+	out, err := cmd.CombinedOutput()
+	if ctx.Err() == context.DeadlineExceeded {
+		return out, fmt.Errorf("timed out after %v", timeout)
+	}
+	return out, err
+}
+
+// runRemoteHook runs command on the sprite with sprite exec.
+func runRemoteHook(ctx context.Context, command string, ev hookEvent, timeout time.Duration) ([]byte, error) {
+	return sprite.NewClient(ev.Info.Org).ExecContext(ctx, sprite.ExecOptions{
+		Sprite:  ev.Sprite,
+		Command: []string{"bash", "-c", command},
+		Env:     hookEnv(ev),
+		Timeout: timeout,
+	})
+}
+
+// recordHook logs a hook command's outcome and adds it to the history.
+func (d *Daemon) recordHook(ev hookEvent, command string, out []byte, err error) {
+	e := &store.Event{SpriteName: ev.Sprite, Kind: store.EventHook, To: "ok", Message: ev.Event + ": " + command}
+	if err != nil {
+		e.To = "failed"
+		e.Message += ": " + err.Error()
+		if s := strings.TrimSpace(string(out)); s != "" {
+			e.Message += ": " + s[strings.LastIndex(s, "\n")+1:] // last line, usually the cause
+		}
+		slog.Warn("hooks: command failed", "sprite", ev.Sprite, "event", ev.Event, "command", command,
+			"error", err, "output", string(out))
+	} else {
+		slog.Info("hooks: command ran", "sprite", ev.Sprite, "event", ev.Event, "command", command)
+	}
+	if err := d.db.AddEvent(e); err != nil {
+		slog.Warn("hooks: recording run", "error", err)
+		return
+	}
+	d.broadcast(StateUpdate{Type: "event", SpriteName: ev.Sprite, Event: e})
+}
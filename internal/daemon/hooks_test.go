@@ -0,0 +1,164 @@
+package daemon
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jphenow/sp/internal/store"
+)
+
+func TestHookEvents(t *testing.T) {
+	d, _ := testDaemon(t)
+	seen := map[string]hookSpriteState{}
+	now := time.Now()
+	events := func(update StateUpdate) []string {
+		t.Helper()
+		var names []string
+		for _, ev := range d.hookEvents(update, seen, now) {
+			names = append(names, ev.Event)
+		}
+		return names
+	}
+	check := func(update StateUpdate, want ...string) {
+		t.Helper()
+		if got := events(update); strings.Join(got, " ") != strings.Join(want, " ") {
+			t.Errorf("%s: events = %q, want %q", update.Type, got, want)
+		}
+	}
+
+	d.db.UpsertSprite(&store.Sprite{Name: "api", Status: "cold", SyncStatus: "none"})
+	check(StateUpdate{Type: "sprite_added", SpriteName: "api"}, "sprite_added")
+	// Upserting a sprite again isn't adding it.
+	check(StateUpdate{Type: "sprite_added", SpriteName: "api"})
+
+	d.db.UpdateSpriteStatus("api", "running")
+	check(StateUpdate{Type: "sprite_status", SpriteName: "api"}, "sprite_status:running")
+	check(StateUpdate{Type: "sprite_status", SpriteName: "api"})
+
+	d.db.UpdateSyncStatus("api", "watching", "")
+	evs := d.hookEvents(StateUpdate{Type: "sync_status", SpriteName: "api"}, seen, now)
+	if len(evs) != 1 || evs[0].Event != "sync_status:watching" || evs[0].From != "none" || evs[0].Info == nil {
+		t.Errorf("sync_status events = %+v", evs)
+	}
+
+	d.db.UpsertSyncSession(&store.SyncSession{SpriteName: "api", Conflicts: 2})
+	d.db.UpdateSyncStatus("api", "conflicts", "2 files differ")
+	evs = d.hookEvents(StateUpdate{Type: "sync_status", SpriteName: "api"}, seen, now)
+	if len(evs) != 1 || evs[0].Conflicts != 2 || evs[0].SyncError != "2 files differ" {
+		t.Errorf("sync_status conflicts events = %+v", evs)
+	}
+
+	check(StateUpdate{Type: "event", SpriteName: "api", Event: &store.Event{Kind: store.EventProxy}}, "event:proxy")
+	check(StateUpdate{Type: "event", SpriteName: "api", Event: &store.Event{Kind: store.EventHook}})
+	check(StateUpdate{Type: "meta", SpriteName: "api"})
+	check(StateUpdate{Type: "sprite_removed", SpriteName: "api"}, "sprite_removed")
+	if _, ok := seen["api"]; ok {
+		t.Error("removed sprite is still remembered")
+	}
+}
+
+func TestHookFires(t *testing.T) {
+	h := Hook{On: []string{"sync_status:conflicts", "sprite_removed"}}
+	for ev, want := range map[hookEvent]bool{
+		{Type: "sync_status", To: "conflicts"}: true,
+		{Type: "sync_status", To: "watching"}:  false,
+		{Type: "sprite_removed"}:               true,
+		{Type: "sprite_status", To: "running"}: false,
+	} {
+		if got := h.fires(ev); got != want {
+			t.Errorf("fires(%s:%s) = %v, want %v", ev.Type, ev.To, got, want)
+		}
+	}
+}
+
+func TestHookValidate(t *testing.T) {
+	tests := []struct {
+		hook Hook
+		want string // "" = valid
+	}{
+		{Hook{On: []string{"sprite_status:running"}, Run: "true"}, ""},
+		{Hook{On: []string{"event:proxy"}, Remote: "true", Match: "tag:work", Timeout: time.Minute}, ""},
+		{Hook{Run: "true"}, `needs "on"`},
+		{Hook{On: []string{"woke"}, Run: "true"}, `unknown event "woke"`},
+		{Hook{On: []string{"sync_status:"}, Run: "true"}, "empty value"},
+		{Hook{On: []string{"sprite_removed:x"}, Run: "true"}, "takes no value"},
+		{Hook{On: []string{"sprite_added"}}, `needs "run" or "remote"`},
+		{Hook{On: []string{"sprite_added"}, Run: "true", Match: "("}, "match: invalid query"},
+		{Hook{On: []string{"sprite_added"}, Run: "true", Timeout: time.Millisecond}, "at least 1s"},
+	}
+	for _, tt := range tests {
+		err := tt.hook.validate()
+		if tt.want == "" && err != nil {
+			t.Errorf("%+v: %v", tt.hook, err)
+		}
+		if tt.want != "" && (err == nil || !strings.Contains(err.Error(), tt.want)) {
+			t.Errorf("%+v: error %v, want %q", tt.hook, err, tt.want)
+		}
+	}
+}
+
+func TestHookMatches(t *testing.T) {
+	d, _ := testDaemon(t)
+	d.db.UpsertSprite(&store.Sprite{Name: "api"})
+	d.db.UpsertSprite(&store.Sprite{Name: "api-2"})
+	d.db.AddTag("api-2", "work")
+	api, _ := d.db.GetSprite("api")
+	api2, _ := d.db.GetSprite("api-2")
+	if d.hookMatches("tag:work", hookEvent{Sprite: "api", Info: api}) {
+		t.Error("api matched tag:work")
+	}
+	if !d.hookMatches("tag:work", hookEvent{Sprite: "api-2", Info: api2}) {
+		t.Error("api-2 didn't match tag:work")
+	}
+	if d.hookMatches("tag:work", hookEvent{Sprite: "api-2"}) {
+		t.Error("a removed sprite matched a query")
+	}
+	if !d.hookMatches("", hookEvent{Sprite: "gone"}) {
+		t.Error("no query should match everything")
+	}
+}
+
+func TestRunHook(t *testing.T) {
+	d, _ := testDaemon(t)
+	d.db.UpsertSprite(&store.Sprite{Name: "api", Status: "running"})
+	dir := t.TempDir()
+	ev := hookEvent{Event: "sprite_status:running", Type: "sprite_status", Sprite: "api", From: "cold", To: "running"}
+
+	payload := filepath.Join(dir, "event.json")
+	env := filepath.Join(dir, "env")
+	d.runHook(context.Background(), Hook{Run: "cat > " + payload + "; echo $SP_HOOK_EVENT $SP_SPRITE > " + env}, ev)
+	d.runHook(context.Background(), Hook{Run: "echo starting; echo boom; exit 3"}, ev)
+	d.runHook(context.Background(), Hook{Run: "sleep 10", Timeout: 100 * time.Millisecond}, ev)
+
+	var got hookEvent
+	if data, err := os.ReadFile(payload); err != nil {
+		t.Fatal(err)
+	} else if err := json.Unmarshal(data, &got); err != nil || got.Event != ev.Event || got.From != "cold" {
+		t.Errorf("stdin = %s (%v)", data, err)
+	}
+	if data, _ := os.ReadFile(env); strings.TrimSpace(string(data)) != "sprite_status:running api" {
+		t.Errorf("environment = %q", data)
+	}
+
+	history, err := d.db.ListEvents(store.EventListOptions{SpriteName: "api", Kinds: []string{store.EventHook}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var outcomes []string
+	for _, e := range history {
+		outcomes = append(outcomes, e.To+" "+e.Message)
+	}
+	want := []string{
+		"ok sprite_status:running: cat > " + payload + "; echo $SP_HOOK_EVENT $SP_SPRITE > " + env,
+		"failed sprite_status:running: echo starting; echo boom; exit 3: exit status 3: boom",
+		"failed sprite_status:running: sleep 10: timed out after 100ms",
+	}
+	if strings.Join(outcomes, "\n") != strings.Join(want, "\n") {
+		t.Errorf("history:\n%s\nwant:\n%s", strings.Join(outcomes, "\n"), strings.Join(want, "\n"))
+	}
+}
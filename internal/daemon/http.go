@@ -0,0 +1,349 @@
+package daemon
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	_ "embed"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/jphenow/sp/internal/store"
+)
+
+// The HTTP API serves a subset of the socket protocol — listing and reading
+// sprites, tags, sync control and setup — as loopback HTTP/JSON for editor
+// plugins and scripts, plus a server-sent event stream of StateUpdates and
+// Prometheus metrics at /metrics (see metrics.go).
+// Every endpoint but the OpenAPI description needs the bearer token from
+// Config.TokenPath. Handlers go through dispatch, so both transports behave
+// the same.
+
+//go:embed openapi.json
+var openAPISpec []byte
+
+// sseKeepaliveInterval is how often the event stream sends a comment line,
+// so idle connections aren't dropped by the client or anything in between.
+const sseKeepaliveInterval = 30 * time.Second
+
+// serveHTTP runs the HTTP API on Config.HTTPAddr until ctx is cancelled.
+// The API is optional, so problems are logged rather than stopping the daemon.
+func (d *Daemon) serveHTTP(ctx context.Context) {
+	token, err := APIToken(d.config.TokenPath)
+	if err != nil {
+		slog.Error("http: not serving the HTTP API", "error", err)
+		return
+	}
+	ln, err := listenLoopback(d.config.HTTPAddr)
+	if err != nil {
+		slog.Error("http: not serving the HTTP API", "error", err)
+		return
+	}
+
+	srv := &http.Server{Handler: d.httpHandler(token), ReadHeaderTimeout: 10 * time.Second}
+	go func() {
+		<-ctx.Done()
+		srv.Close()
+	}()
+
+	slog.Info("http: serving the HTTP API", "addr", ln.Addr().String())
+	if err := srv.Serve(ln); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		slog.Error("http: server stopped", "error", err)
+	}
+}
+
+// listenLoopback listens on addr, refusing anything but a loopback address:
+// the API controls local files, so it is never exposed to the network.
+func listenLoopback(addr string) (net.Listener, error) {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, fmt.Errorf("invalid HTTP address %q: %w", addr, err)
+	}
+	if ip := net.ParseIP(host); host != "localhost" && (ip == nil || !ip.IsLoopback()) {
+		return nil, fmt.Errorf("HTTP address %q is not a loopback address", addr)
+	}
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("listening on %s: %w", addr, err)
+	}
+	return ln, nil
+}
+
+// APIToken returns the HTTP API's bearer token from path, generating one
+// (readable only by the user) the first time.
+func APIToken(path string) (string, error) {
+	if data, err := os.ReadFile(path); err == nil {
+		if token := strings.TrimSpace(string(data)); token != "" {
+			return token, nil
+		}
+	} else if !os.IsNotExist(err) {
+		return "", fmt.Errorf("reading API token: %w", err)
+	}
+
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", fmt.Errorf("generating API token: %w", err)
+	}
+	token := hex.EncodeToString(buf)
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return "", fmt.Errorf("creating config directory: %w", err)
+	}
+	if err := os.WriteFile(path, []byte(token+"\n"), 0o600); err != nil {
+		return "", fmt.Errorf("writing API token: %w", err)
+	}
+	return token, nil
+}
+
+// httpHandler routes the HTTP API.
+func (d *Daemon) httpHandler(token string) http.Handler {
+	api := http.NewServeMux()
+	api.HandleFunc("GET /v1/sprites", d.httpListSprites)
+	api.HandleFunc("GET /v1/sprites/{name}", d.httpGetSprite)
+	api.HandleFunc("GET /v1/sprites/{name}/tags", d.httpGetTags)
+	api.HandleFunc("POST /v1/sprites/{name}/tags", d.httpAddTag)
+	api.HandleFunc("DELETE /v1/sprites/{name}/tags/{tag}", d.httpRemoveTag)
+	api.HandleFunc("POST /v1/sprites/{name}/sync/start", d.httpStartSync)
+	api.HandleFunc("POST /v1/sprites/{name}/sync/stop", d.httpStopSync)
+	api.HandleFunc("POST /v1/sprites/{name}/resync", d.httpResync)
+	api.HandleFunc("POST /v1/sprites/{name}/setup", d.httpRunSetup)
+	api.HandleFunc("GET /v1/events", d.httpEvents)
+	api.HandleFunc("GET /metrics", d.httpMetrics)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /v1/openapi.json", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(openAPISpec)
+	})
+	mux.Handle("/v1/", requireToken(token, api))
+	mux.Handle("/metrics", requireToken(token, api))
+	return mux
+}
+
+// requireToken rejects requests without the bearer token.
+func requireToken(token string, next http.Handler) http.Handler {
+	want := []byte("Bearer " + token)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if subtle.ConstantTimeCompare([]byte(r.Header.Get("Authorization")), want) != 1 {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="sp"`)
+			httpError(w, http.StatusUnauthorized, "missing or invalid bearer token")
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// httpError writes an error as {"error": msg}.
+func httpError(w http.ResponseWriter, status int, msg string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]string{"error": msg})
+}
+
+// httpCall runs a socket protocol method for an HTTP request and writes its
+// result, or its error as a 500.
+func (d *Daemon) httpCall(w http.ResponseWriter, r *http.Request, method string, params any) {
+	raw, err := json.Marshal(params)
+	if err != nil {
+		httpError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	resp := d.timedDispatch(r.Context(), "http", &Request{Method: method, Params: raw})
+	if resp.Error != "" {
+		httpError(w, http.StatusInternalServerError, resp.Error)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(append(resp.Result, '\n'))
+}
+
+// httpSprite looks up the sprite named in the path, writing a 404 if it
+// isn't tracked.
+func (d *Daemon) httpSprite(w http.ResponseWriter, r *http.Request) (*store.Sprite, bool) {
+	name := r.PathValue("name")
+	s, err := d.db.GetSprite(name)
+	if err != nil {
+		httpError(w, http.StatusInternalServerError, err.Error())
+		return nil, false
+	}
+	if s == nil {
+		httpError(w, http.StatusNotFound, fmt.Sprintf("sprite %q not found", name))
+		return nil, false
+	}
+	return s, true
+}
+
+// httpBody decodes an optional JSON request body into v.
+func httpBody(w http.ResponseWriter, r *http.Request, v any) bool {
+	if err := json.NewDecoder(r.Body).Decode(v); err != nil && !errors.Is(err, io.EOF) {
+		httpError(w, http.StatusBadRequest, fmt.Sprintf("invalid body: %v", err))
+		return false
+	}
+	return true
+}
+
+// httpListSprites lists sprites, filtered by ?tag= (comma-separated or
+// repeated), ?prefix= (local path) and ?q= (a query; see store.Query).
+func (d *Daemon) httpListSprites(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	opts := store.ListOptions{PathPrefix: q.Get("prefix"), Query: q.Get("q")}
+	for _, tags := range q["tag"] {
+		opts.Tags = append(opts.Tags, strings.Split(tags, ",")...)
+	}
+	if _, err := store.ParseQuery(opts.Query); err != nil {
+		httpError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	d.httpCall(w, r, "list", opts)
+}
+
+func (d *Daemon) httpGetSprite(w http.ResponseWriter, r *http.Request) {
+	if s, ok := d.httpSprite(w, r); ok {
+		d.httpCall(w, r, "get", map[string]string{"name": s.Name})
+	}
+}
+
+func (d *Daemon) httpGetTags(w http.ResponseWriter, r *http.Request) {
+	if s, ok := d.httpSprite(w, r); ok {
+		d.httpCall(w, r, "get_tags", map[string]string{"name": s.Name})
+	}
+}
+
+func (d *Daemon) httpAddTag(w http.ResponseWriter, r *http.Request) {
+	s, ok := d.httpSprite(w, r)
+	if !ok {
+		return
+	}
+	var body struct {
+		Tag string `json:"tag"`
+	}
+	if !httpBody(w, r, &body) {
+		return
+	}
+	if body.Tag == "" {
+		httpError(w, http.StatusBadRequest, "tag must not be empty")
+		return
+	}
+	d.httpCall(w, r, "tag", map[string]string{"name": s.Name, "tag": body.Tag})
+}
+
+func (d *Daemon) httpRemoveTag(w http.ResponseWriter, r *http.Request) {
+	if s, ok := d.httpSprite(w, r); ok {
+		d.httpCall(w, r, "untag", map[string]string{"name": s.Name, "tag": r.PathValue("tag")})
+	}
+}
+
+// httpStartSync starts sync between the sprite's recorded local and remote
+// paths, optionally in a given sync mode.
+func (d *Daemon) httpStartSync(w http.ResponseWriter, r *http.Request) {
+	s, ok := d.httpSprite(w, r)
+	if !ok {
+		return
+	}
+	var body struct {
+		SyncMode SyncMode `json:"sync_mode"`
+	}
+	if !httpBody(w, r, &body) {
+		return
+	}
+	if s.LocalPath == "" {
+		httpError(w, http.StatusConflict, fmt.Sprintf("sprite %q has no local path to sync", s.Name))
+		return
+	}
+	d.httpCall(w, r, "start_sync", StartSyncRequest{
+		SpriteName: s.Name,
+		LocalPath:  s.LocalPath,
+		RemotePath: s.RemotePath,
+		Org:        s.Org,
+		SyncMode:   body.SyncMode,
+	})
+}
+
+func (d *Daemon) httpStopSync(w http.ResponseWriter, r *http.Request) {
+	if s, ok := d.httpSprite(w, r); ok {
+		d.httpCall(w, r, "stop_sync", map[string]string{"name": s.Name})
+	}
+}
+
+// httpResync resets sync, or with a sync_mode makes a one-shot copy in that
+// direction first (see Client.ResyncWithMode).
+func (d *Daemon) httpResync(w http.ResponseWriter, r *http.Request) {
+	s, ok := d.httpSprite(w, r)
+	if !ok {
+		return
+	}
+	var body struct {
+		SyncMode SyncMode `json:"sync_mode"`
+	}
+	if !httpBody(w, r, &body) {
+		return
+	}
+	if s.LocalPath == "" {
+		httpError(w, http.StatusConflict, fmt.Sprintf("sprite %q has no local path to sync", s.Name))
+		return
+	}
+	if body.SyncMode != "" {
+		d.httpCall(w, r, "resync_with_mode", map[string]string{"name": s.Name, "sync_mode": string(body.SyncMode)})
+		return
+	}
+	d.httpCall(w, r, "resync", map[string]string{
+		"name":        s.Name,
+		"local_path":  s.LocalPath,
+		"remote_path": s.RemotePath,
+		"org":         s.Org,
+	})
+}
+
+func (d *Daemon) httpRunSetup(w http.ResponseWriter, r *http.Request) {
+	if s, ok := d.httpSprite(w, r); ok {
+		d.httpCall(w, r, "run_setup", map[string]string{"name": s.Name})
+	}
+}
+
+// httpEvents streams StateUpdates as server-sent events, named by their
+// Type, until the client goes away or the daemon stops.
+func (d *Daemon) httpEvents(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		httpError(w, http.StatusInternalServerError, "streaming not supported")
+		return
+	}
+
+	id := fmt.Sprintf("http-%d", time.Now().UnixNano())
+	ch := d.subscribe(id)
+	defer d.unsubscribe(id)
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	fmt.Fprint(w, ": subscribed\n\n")
+	flusher.Flush()
+
+	keepalive := time.NewTicker(sseKeepaliveInterval)
+	defer keepalive.Stop()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-d.ctx.Done():
+			return
+		case <-keepalive.C:
+			fmt.Fprint(w, ": keepalive\n\n")
+		case update, ok := <-ch:
+			if !ok {
+				return
+			}
+			data, _ := json.Marshal(update)
+			fmt.Fprintf(w, "event: %s\ndata: %s\n\n", update.Type, data)
+		}
+		flusher.Flush()
+	}
+}
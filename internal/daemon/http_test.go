@@ -0,0 +1,162 @@
+package daemon
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jphenow/sp/internal/store"
+)
+
+func TestHTTPAPI(t *testing.T) {
+	d, _ := testDaemon(t)
+	d.db.UpsertSprite(&store.Sprite{Name: "api", Status: "running"})
+	d.db.UpsertSprite(&store.Sprite{Name: "web", Status: "cold"})
+
+	srv := httptest.NewServer(d.httpHandler("secret"))
+	defer srv.Close()
+
+	do := func(method, path, token, body string) (*http.Response, string) {
+		t.Helper()
+		req, _ := http.NewRequest(method, srv.URL+path, strings.NewReader(body))
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("%s %s: %v", method, path, err)
+		}
+		defer resp.Body.Close()
+		b, _ := io.ReadAll(resp.Body)
+		return resp, string(b)
+	}
+
+	for _, token := range []string{"", "wrong"} {
+		if resp, _ := do("GET", "/v1/sprites", token, ""); resp.StatusCode != http.StatusUnauthorized {
+			t.Errorf("token %q: status %d, want 401", token, resp.StatusCode)
+		}
+	}
+
+	// The description is public, and is valid JSON.
+	resp, body := do("GET", "/v1/openapi.json", "", "")
+	var spec map[string]any
+	if resp.StatusCode != http.StatusOK || json.Unmarshal([]byte(body), &spec) != nil || spec["paths"] == nil {
+		t.Errorf("openapi.json: status %d, body %.80q", resp.StatusCode, body)
+	}
+
+	if resp, _ := do("GET", "/metrics", "", ""); resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("metrics without a token: status %d, want 401", resp.StatusCode)
+	}
+	if resp, body := do("GET", "/metrics", "secret", ""); resp.StatusCode != http.StatusOK || !strings.Contains(body, `sp_sprites{status="cold"} 1`) {
+		t.Errorf("metrics: status %d, body %.200q", resp.StatusCode, body)
+	}
+
+	resp, body = do("GET", "/v1/sprites?q=status:running", "secret", "")
+	var sprites []*store.Sprite
+	json.Unmarshal([]byte(body), &sprites)
+	if resp.StatusCode != http.StatusOK || len(sprites) != 1 || sprites[0].Name != "api" {
+		t.Errorf("list running: status %d, body %s", resp.StatusCode, body)
+	}
+	if resp, _ := do("GET", "/v1/sprites?q=(", "secret", ""); resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("invalid query: status %d, want 400", resp.StatusCode)
+	}
+	if resp, _ := do("GET", "/v1/sprites/ghost", "secret", ""); resp.StatusCode != http.StatusNotFound {
+		t.Errorf("unknown sprite: status %d, want 404", resp.StatusCode)
+	}
+	if resp, _ := do("POST", "/v1/sprites/web/sync/start", "secret", ""); resp.StatusCode != http.StatusConflict {
+		t.Errorf("sync without a local path: status %d, want 409", resp.StatusCode)
+	}
+
+	if resp, body := do("POST", "/v1/sprites/web/tags", "secret", `{"tag":"work"}`); resp.StatusCode != http.StatusOK {
+		t.Fatalf("add tag: status %d, body %s", resp.StatusCode, body)
+	}
+	if _, body := do("GET", "/v1/sprites/web/tags", "secret", ""); strings.TrimSpace(body) != `["work"]` {
+		t.Errorf("tags = %s, want [\"work\"]", body)
+	}
+	do("DELETE", "/v1/sprites/web/tags/work", "secret", "")
+	if _, body := do("GET", "/v1/sprites/web/tags", "secret", ""); strings.TrimSpace(body) != "null" {
+		t.Errorf("tags after delete = %s, want none", body)
+	}
+}
+
+func TestHTTPEvents(t *testing.T) {
+	d, _ := testDaemon(t)
+	srv := httptest.NewServer(d.httpHandler("secret"))
+	defer srv.Close()
+
+	req, _ := http.NewRequest("GET", srv.URL+"/v1/events", nil)
+	req.Header.Set("Authorization", "Bearer secret")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if ct := resp.Header.Get("Content-Type"); ct != "text/event-stream" {
+		t.Fatalf("Content-Type = %q", ct)
+	}
+
+	// The subscription exists once the stream's opening comment arrives.
+	lines := bufio.NewScanner(resp.Body)
+	lines.Scan()
+	d.broadcast(StateUpdate{Type: "sync_status", SpriteName: "api"})
+
+	got := make(chan string, 1)
+	go func() {
+		var event []string
+		for lines.Scan() {
+			if lines.Text() == "" && len(event) > 0 {
+				got <- strings.Join(event, "\n")
+				return
+			}
+			if lines.Text() != "" && !strings.HasPrefix(lines.Text(), ":") {
+				event = append(event, lines.Text())
+			}
+		}
+	}()
+	select {
+	case event := <-got:
+		want := "event: sync_status\n" + `data: {"type":"sync_status","sprite_name":"api"}`
+		if event != want {
+			t.Errorf("event =\n%s\nwant\n%s", event, want)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("no event arrived")
+	}
+}
+
+func TestListenLoopback(t *testing.T) {
+	for _, addr := range []string{"0.0.0.0:0", "192.0.2.1:0", "example.com:80", "7375"} {
+		if ln, err := listenLoopback(addr); err == nil {
+			ln.Close()
+			t.Errorf("listenLoopback(%q) succeeded", addr)
+		}
+	}
+	ln, err := listenLoopback("127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listenLoopback(127.0.0.1:0): %v", err)
+	}
+	ln.Close()
+}
+
+func TestAPIToken(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "sp", "api-token")
+	token, err := APIToken(path)
+	if err != nil || len(token) != 64 {
+		t.Fatalf("APIToken = %q, %v; want a 64-character token", token, err)
+	}
+	if info, err := os.Stat(path); err != nil {
+		t.Error(err)
+	} else if info.Mode().Perm() != 0o600 {
+		t.Errorf("token file mode = %v, want 0600", info.Mode().Perm())
+	}
+	if again, _ := APIToken(path); again != token {
+		t.Errorf("second APIToken = %q, want the stored %q", again, token)
+	}
+}
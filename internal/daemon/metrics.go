@@ -0,0 +1,224 @@
+package daemon
+
+import (
+	"bufio"
+	"cmp"
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"maps"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jphenow/sp/internal/store"
+)
+
+// The daemon serves Prometheus metrics at /metrics on the HTTP API's
+// listener, in the text exposition format. Gauges describing current state
+// (sprites by status, conflicts, health-check backoff) are read from the
+// database and health monitor at scrape time; counters and RPC latencies
+// are accumulated in metrics as things happen.
+
+// rpcBuckets are the upper bounds, in seconds, of the RPC latency histogram.
+// Most calls are a database read; sync setup and checkpoints take seconds.
+var rpcBuckets = []float64{0.001, 0.005, 0.01, 0.05, 0.1, 0.5, 1, 5, 30}
+
+// Reasons attemptSyncSetup fails, named for the step that failed.
+const (
+	setupFailWake      = "wake"
+	setupFailSSHServer = "ssh_server"
+	setupFailProxy     = "proxy"
+	setupFailSSHConfig = "ssh_config"
+	setupFailSSHTest   = "ssh_test"
+	setupFailMutagen   = "mutagen"
+)
+
+// metrics accumulates the daemon's counters and RPC latencies. The zero
+// value is ready to use.
+type metrics struct {
+	mu            sync.Mutex
+	rpc           map[string]*histogram // by method
+	proxyStarts   map[string]int        // by sprite
+	setupFailures map[string]int        // by reason
+}
+
+// histogram is a cumulative Prometheus histogram over rpcBuckets.
+type histogram struct {
+	counts []int // per bucket, not cumulative
+	count  int
+	sum    float64
+}
+
+// observeRPC records how long a call to method took.
+func (m *metrics) observeRPC(method string, d time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.rpc == nil {
+		m.rpc = make(map[string]*histogram)
+	}
+	h, ok := m.rpc[method]
+	if !ok {
+		h = &histogram{counts: make([]int, len(rpcBuckets))}
+		m.rpc[method] = h
+	}
+	secs := d.Seconds()
+	if i, _ := slices.BinarySearch(rpcBuckets, secs); i < len(rpcBuckets) {
+		h.counts[i]++
+	}
+	h.count++
+	h.sum += secs
+}
+
+// proxyStarted records that a proxy was started for a sprite.
+func (m *metrics) proxyStarted(spriteName string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.proxyStarts == nil {
+		m.proxyStarts = make(map[string]int)
+	}
+	m.proxyStarts[spriteName]++
+}
+
+// setupFailed records a failed attemptSyncSetup.
+func (m *metrics) setupFailed(reason string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.setupFailures == nil {
+		m.setupFailures = make(map[string]int)
+	}
+	m.setupFailures[reason]++
+}
+
+// timedDispatch dispatches a request, recording its latency. Methods the
+// daemon doesn't know are counted together, so a misbehaving client can't
+// grow the metrics without bound.
+func (d *Daemon) timedDispatch(ctx context.Context, clientID string, req *Request) Response {
+	start := time.Now()
+	resp := d.dispatch(ctx, clientID, req)
+	method := req.Method
+	if strings.HasPrefix(resp.Error, "unknown method: ") {
+		method = "unknown"
+	}
+	d.metrics.observeRPC(method, time.Since(start))
+	return resp
+}
+
+// httpMetrics serves the metrics in the Prometheus text format.
+func (d *Daemon) httpMetrics(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	if err := d.writeMetrics(w); err != nil {
+		slog.Warn("http: writing metrics", "error", err)
+	}
+}
+
+// writeMetrics writes every metric in the Prometheus text format.
+func (d *Daemon) writeMetrics(out io.Writer) error {
+	sprites, err := d.db.ListSprites(store.ListOptions{})
+	if err != nil {
+		return fmt.Errorf("listing sprites: %w", err)
+	}
+	byStatus := map[string]int{}
+	bySyncStatus := map[string]int{}
+	conflicts := map[string]int{}
+	for _, s := range sprites {
+		byStatus[cmp.Or(s.Status, "unknown")]++
+		bySyncStatus[cmp.Or(s.SyncStatus, "none")]++
+		ss, err := d.db.GetSyncSession(s.Name)
+		if err != nil {
+			return err
+		}
+		if ss != nil {
+			conflicts[s.Name] = ss.Conflicts
+		}
+	}
+
+	w := bufio.NewWriter(out)
+	family(w, "sp_sprites", "gauge", "Tracked sprites by status.")
+	for _, status := range slices.Sorted(maps.Keys(byStatus)) {
+		sample(w, "sp_sprites", byStatus[status], "status", status)
+	}
+	family(w, "sp_sprites_sync", "gauge", "Tracked sprites by sync status.")
+	for _, status := range slices.Sorted(maps.Keys(bySyncStatus)) {
+		sample(w, "sp_sprites_sync", bySyncStatus[status], "sync_status", status)
+	}
+	family(w, "sp_sync_conflicts", "gauge", "Unresolved Mutagen conflicts per sync session.")
+	for _, name := range slices.Sorted(maps.Keys(conflicts)) {
+		sample(w, "sp_sync_conflicts", conflicts[name], "sprite", name)
+	}
+
+	if h := d.health; h != nil {
+		family(w, "sp_online", "gauge", "Whether the sprites API is reachable.")
+		sample(w, "sp_online", boolMetric(h.IsOnline()))
+		backoffs := h.backoffSnapshot(time.Now())
+		family(w, "sp_health_backoff", "gauge", "Whether health checks for a sprite are backing off after failures.")
+		for _, name := range slices.Sorted(maps.Keys(backoffs)) {
+			sample(w, "sp_health_backoff", boolMetric(backoffs[name].waiting), "sprite", name)
+		}
+		family(w, "sp_health_check_failures", "gauge", "Consecutive failed health checks per sprite.")
+		for _, name := range slices.Sorted(maps.Keys(backoffs)) {
+			sample(w, "sp_health_check_failures", backoffs[name].failures, "sprite", name)
+		}
+	}
+
+	d.metrics.mu.Lock()
+	defer d.metrics.mu.Unlock()
+	m := &d.metrics
+
+	family(w, "sp_proxy_restarts_total", "counter", "Sprite proxies started after the sprite's first, since the daemon started.")
+	for _, name := range slices.Sorted(maps.Keys(m.proxyStarts)) {
+		sample(w, "sp_proxy_restarts_total", m.proxyStarts[name]-1, "sprite", name)
+	}
+	family(w, "sp_sync_setup_failures_total", "counter", "Failed sync setup attempts by the step that failed.")
+	for _, reason := range slices.Sorted(maps.Keys(m.setupFailures)) {
+		sample(w, "sp_sync_setup_failures_total", m.setupFailures[reason], "reason", reason)
+	}
+	family(w, "sp_rpc_duration_seconds", "histogram", "Daemon RPC latency by method.")
+	for _, method := range slices.Sorted(maps.Keys(m.rpc)) {
+		h := m.rpc[method]
+		cumulative := 0
+		for i, le := range rpcBuckets {
+			cumulative += h.counts[i]
+			sample(w, "sp_rpc_duration_seconds_bucket", cumulative, "method", method, "le", strconv.FormatFloat(le, 'g', -1, 64))
+		}
+		sample(w, "sp_rpc_duration_seconds_bucket", h.count, "method", method, "le", "+Inf")
+		sample(w, "sp_rpc_duration_seconds_sum", h.sum, "method", method)
+		sample(w, "sp_rpc_duration_seconds_count", h.count, "method", method)
+	}
+	return w.Flush()
+}
+
+// family writes the HELP and TYPE lines that introduce a metric.
+func family(w io.Writer, name, typ, help string) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, typ)
+}
+
+// labelEscaper escapes label values for the text format.
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// sample writes one sample line; labels are name, value pairs.
+func sample[V int | float64](w io.Writer, name string, value V, labels ...string) {
+	fmt.Fprint(w, name)
+	for i := 0; i+1 < len(labels); i += 2 {
+		sep := ","
+		if i == 0 {
+			sep = "{"
+		}
+		fmt.Fprintf(w, `%s%s="%s"`, sep, labels[i], labelEscaper.Replace(labels[i+1]))
+	}
+	if len(labels) > 0 {
+		fmt.Fprint(w, "}")
+	}
+	fmt.Fprintf(w, " %v\n", value)
+}
+
+func boolMetric(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,70 @@
+package daemon
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jphenow/sp/internal/retry"
+	"github.com/jphenow/sp/internal/store"
+)
+
+func TestMetrics(t *testing.T) {
+	d, _ := testDaemon(t)
+	d.db.UpsertSprite(&store.Sprite{Name: "api", Status: "running", SyncStatus: "conflicts"})
+	d.db.UpsertSprite(&store.Sprite{Name: "web", Status: "running"})
+	d.db.UpsertSprite(&store.Sprite{Name: "old", Status: "cold"})
+	d.db.UpsertSyncSession(&store.SyncSession{SpriteName: "api", Conflicts: 3})
+
+	d.health = NewHealthMonitor(d.db, d, nil)
+	d.health.recordFailure("web")
+	d.health.backoffs["old"] = retry.NewBackoff(retry.HealthCheck)
+	d.health.backoffs["old"].Failure(time.Now().Add(-time.Hour))
+
+	d.timedDispatch(context.Background(), "test", &Request{Method: "list"})
+	d.timedDispatch(context.Background(), "test", &Request{Method: "no_such_method"})
+	d.metrics.proxyStarted("api")
+	d.metrics.proxyStarted("api")
+	d.metrics.setupFailed(setupFailSSHTest)
+
+	var buf bytes.Buffer
+	if err := d.writeMetrics(&buf); err != nil {
+		t.Fatal(err)
+	}
+	out := buf.String()
+	for _, want := range []string{
+		"# TYPE sp_sprites gauge\n",
+		`sp_sprites{status="running"} 2` + "\n",
+		`sp_sprites{status="cold"} 1` + "\n",
+		`sp_sprites_sync{sync_status="conflicts"} 1` + "\n",
+		`sp_sprites_sync{sync_status="none"} 2` + "\n",
+		`sp_sync_conflicts{sprite="api"} 3` + "\n",
+		"sp_online 1\n",
+		`sp_health_backoff{sprite="web"} 1` + "\n",
+		`sp_health_backoff{sprite="old"} 0` + "\n",
+		`sp_health_check_failures{sprite="web"} 1` + "\n",
+		`sp_proxy_restarts_total{sprite="api"} 1` + "\n",
+		`sp_sync_setup_failures_total{reason="ssh_test"} 1` + "\n",
+		"# TYPE sp_rpc_duration_seconds histogram\n",
+		`sp_rpc_duration_seconds_bucket{method="list",le="+Inf"} 1` + "\n",
+		`sp_rpc_duration_seconds_count{method="list"} 1` + "\n",
+		`sp_rpc_duration_seconds_count{method="unknown"} 1` + "\n",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("metrics missing %q; got:\n%s", want, out)
+		}
+	}
+	if strings.Contains(out, "no_such_method") {
+		t.Error("unknown methods should be counted as method=\"unknown\"")
+	}
+}
+
+func TestSampleEscapesLabels(t *testing.T) {
+	var buf bytes.Buffer
+	sample(&buf, "m", 1.5, "a", `x"y\z`+"\n")
+	if got, want := buf.String(), `m{a="x\"y\\z\n"} 1.5`+"\n"; got != want {
+		t.Errorf("sample = %q, want %q", got, want)
+	}
+}
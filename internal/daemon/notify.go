@@ -0,0 +1,190 @@
+package daemon
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"log/slog"
+	"os/exec"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+)
+
+// The daemon raises a desktop notification when the health monitor sees a
+// sprite's sync conflicts increase or its sync enter "error", so a stuck
+// two-way-safe session doesn't sit unnoticed until the next `sp status`.
+// Each condition is notified once, until it clears, and each sprite gets at
+// most one notification per health.notify_interval; a condition held back
+// by the limit is notified when the interval is up, if it still holds.
+
+// notifyTimeout bounds a notifier command.
+const notifyTimeout = 5 * time.Second
+
+// Notification is a desktop notification.
+type Notification struct {
+	Title  string
+	Body   string
+	Urgent bool // stays on screen until dismissed, where the desktop supports it
+}
+
+// Notifier shows desktop notifications.
+type Notifier interface {
+	Notify(ctx context.Context, n Notification) error
+}
+
+// DesktopNotifier returns the notifier for this machine: notify-send, or
+// the freedesktop D-Bus service through gdbus, on Linux; osascript on macOS.
+// It returns nil if none is available.
+func DesktopNotifier() Notifier {
+	switch runtime.GOOS {
+	case "linux":
+		if path, err := exec.LookPath("notify-send"); err == nil {
+			return execNotifier{path, notifySendArgs}
+		}
+		if path, err := exec.LookPath("gdbus"); err == nil {
+			return execNotifier{path, gdbusArgs}
+		}
+	case "darwin":
+		if path, err := exec.LookPath("osascript"); err == nil {
+			return execNotifier{path, osascriptArgs}
+		}
+	}
+	return nil
+}
+
+// execNotifier shows notifications by running a command.
+type execNotifier struct {
+	path string
+	args func(Notification) []string
+}
+
+func (e execNotifier) Notify(ctx context.Context, n Notification) error {
+	ctx, cancel := context.WithTimeout(ctx, notifyTimeout)
+	defer cancel()
+	out, err := exec.CommandContext(ctx, e.path, e.args(n)...).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("%s: %w: %s", e.path, err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
+
+func notifySendArgs(n Notification) []string {
+	urgency := "normal"
+	if n.Urgent {
+		urgency = "critical"
+	}
+	return []string{"--app-name=sp", "--urgency=" + urgency, n.Title, n.Body}
+}
+
+// gdbusArgs calls org.freedesktop.Notifications.Notify directly, for
+// desktops without notify-send.
+func gdbusArgs(n Notification) []string {
+	urgency := 1
+	if n.Urgent {
+		urgency = 2
+	}
+	quote := strings.NewReplacer(`\`, `\\`, `"`, `\"`)
+	return []string{
+		"call", "--session",
+		"--dest", "org.freedesktop.Notifications",
+		"--object-path", "/org/freedesktop/Notifications",
+		"--method", "org.freedesktop.Notifications.Notify",
+		`"sp"`, "0", `""`,
+		`"` + quote.Replace(n.Title) + `"`, `"` + quote.Replace(n.Body) + `"`,
+		"[]", fmt.Sprintf("{'urgency': <byte %d>}", urgency), "-1",
+	}
+}
+
+func osascriptArgs(n Notification) []string {
+	quote := strings.NewReplacer(`\`, `\\`, `"`, `\"`)
+	return []string{"-e", fmt.Sprintf(`display notification "%s" with title "%s"`, quote.Replace(n.Body), quote.Replace(n.Title))}
+}
+
+// SetNotifier sets the notifier the daemon shows sync problems with, e.g.
+// DesktopNotifier(). A daemon has none until this is called; nil turns
+// notifications off again.
+func (d *Daemon) SetNotifier(n Notifier) {
+	d.notifyMu.Lock()
+	defer d.notifyMu.Unlock()
+	d.notifier = n
+}
+
+// notify shows n in the background, so a hung desktop service can't stall
+// the health monitor.
+func (d *Daemon) notify(spriteName string, n Notification) {
+	d.notifyMu.Lock()
+	notifier := d.notifier
+	d.notifyMu.Unlock()
+	if notifier == nil {
+		slog.Debug("notify: no notifier", "sprite", spriteName, "title", n.Title)
+		return
+	}
+	go func() {
+		if err := notifier.Notify(d.ctx, n); err != nil {
+			slog.Warn("notify: failed", "sprite", spriteName, "error", err)
+			return
+		}
+		slog.Info("notify: sent", "sprite", spriteName, "title", n.Title)
+	}()
+}
+
+// syncAlerts decides which sync problems are worth a notification,
+// deduplicating them and rate limiting each sprite.
+type syncAlerts struct {
+	mu      sync.Mutex
+	sprites map[string]*syncAlertState
+}
+
+// syncAlertState is what a sprite was last notified about.
+type syncAlertState struct {
+	conflicts int  // conflicts already notified; a higher count is news
+	inError   bool // its "error" status was already notified
+	sent      time.Time
+}
+
+// check returns the notification, if any, for a sprite whose sync went from
+// oldStatus to status with conflicts unresolved files. A sprite's first
+// check takes its old status as already known, so a restarted daemon
+// doesn't repeat itself. interval is the per-sprite rate limit.
+func (a *syncAlerts) check(name, oldStatus, status string, conflicts int, syncError string, interval time.Duration, now time.Time) *Notification {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.sprites == nil {
+		a.sprites = make(map[string]*syncAlertState)
+	}
+	st, ok := a.sprites[name]
+	if !ok {
+		st = &syncAlertState{inError: oldStatus == "error"}
+		if oldStatus == "conflicts" {
+			st.conflicts = conflicts
+		}
+		a.sprites[name] = st
+	}
+
+	var n *Notification
+	switch {
+	case status == "error" && !st.inError:
+		n = &Notification{
+			Title:  "sp: sync error on " + name,
+			Body:   cmp.Or(syncError, "Sync stopped with an error."),
+			Urgent: true,
+		}
+	case conflicts > st.conflicts:
+		n = &Notification{
+			Title: "sp: sync conflicts on " + name,
+			Body:  fmt.Sprintf("%d conflicting file(s). Sync is stuck on them until they're resolved.", conflicts),
+		}
+	}
+
+	if n != nil && now.Sub(st.sent) < interval {
+		return nil // held back; notified on a later check if it still holds
+	}
+	if n != nil {
+		st.sent = now
+	}
+	st.conflicts = conflicts
+	st.inError = status == "error"
+	return n
+}
@@ -0,0 +1,90 @@
+package daemon
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	spSync "github.com/jphenow/sp/internal/sync"
+)
+
+// fakeNotifier records notifications.
+type fakeNotifier chan Notification
+
+func (f fakeNotifier) Notify(_ context.Context, n Notification) error {
+	f <- n
+	return nil
+}
+
+func TestSyncAlerts(t *testing.T) {
+	var a syncAlerts
+	start := time.Now()
+	check := func(at time.Duration, oldStatus, status string, conflicts int, want string) {
+		t.Helper()
+		n := a.check("api", oldStatus, status, conflicts, "", time.Minute, start.Add(at))
+		got := ""
+		if n != nil {
+			got = n.Title
+		}
+		if got != want {
+			t.Errorf("at %v, %s with %d conflicts: notification %q, want %q", at, status, conflicts, got, want)
+		}
+	}
+
+	// Conflicts already there when the daemon started aren't news.
+	check(0, "conflicts", "conflicts", 2, "")
+	check(time.Second, "conflicts", "conflicts", 3, "sp: sync conflicts on api")
+	check(2*time.Second, "conflicts", "conflicts", 3, "")
+	// Within the interval, an error is held back until it's up.
+	check(3*time.Second, "conflicts", "error", 3, "")
+	check(time.Minute+time.Second, "error", "error", 3, "sp: sync error on api")
+	check(2*time.Minute, "error", "error", 3, "")
+	// Once resolved, new conflicts are news again.
+	check(3*time.Minute, "error", "watching", 0, "")
+	check(4*time.Minute, "watching", "conflicts", 1, "sp: sync conflicts on api")
+}
+
+func TestCheckSpriteSyncNotifies(t *testing.T) {
+	h, engine, d := healthWithEngine(t, "web")
+	notified := make(fakeNotifier, 10)
+	d.SetNotifier(notified)
+	d.config.NotifyInterval = time.Minute
+
+	engine.Set(spSync.SessionState{Name: spSync.SessionName("web"), Status: "watching", Conflicts: 2})
+	s, _ := d.db.GetSprite("web")
+	h.checkSpriteSync(s)
+	select {
+	case n := <-notified:
+		if !strings.Contains(n.Body, "2 conflicting") || n.Urgent {
+			t.Errorf("notification = %+v", n)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("no notification for new conflicts")
+	}
+
+	s, _ = d.db.GetSprite("web")
+	h.checkSpriteSync(s)
+	d.config.NotifyInterval = 0
+	engine.Set(spSync.SessionState{Name: spSync.SessionName("web"), Status: "error", LastError: "beta disconnected"})
+	h.checkSpriteSync(s)
+	select {
+	case n := <-notified:
+		t.Errorf("unexpected notification %+v", n)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func TestNotifierArgs(t *testing.T) {
+	n := Notification{Title: `sp: "api"`, Body: `C:\path`, Urgent: true}
+	if got := strings.Join(notifySendArgs(n), " "); got != `--app-name=sp --urgency=critical sp: "api" C:\path` {
+		t.Errorf("notify-send args = %s", got)
+	}
+	args := gdbusArgs(n)
+	if title, body := args[11], args[12]; title != `"sp: \"api\""` || body != `"C:\\path"` {
+		t.Errorf("gdbus title, body = %s, %s", title, body)
+	}
+	if hints := args[14]; hints != "{'urgency': <byte 2>}" {
+		t.Errorf("gdbus hints = %s", hints)
+	}
+}
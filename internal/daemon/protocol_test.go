@@ -0,0 +1,162 @@
+package daemon
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/jphenow/sp/internal/store"
+)
+
+// startTestDaemon runs a test daemon and waits for its socket.
+func startTestDaemon(t *testing.T) (*Daemon, Config) {
+	t.Helper()
+	d, config := testDaemon(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	go d.Start(ctx)
+	for i := 0; i < 50; i++ {
+		if conn, err := net.Dial("unix", config.SocketPath); err == nil {
+			conn.Close()
+			return d, config
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+	t.Fatal("daemon socket never appeared")
+	return nil, config
+}
+
+func TestHandshake(t *testing.T) {
+	_, config := startTestDaemon(t)
+
+	// send opens a raw connection, sends req and returns the first reply.
+	send := func(req Request) Response {
+		t.Helper()
+		conn, err := net.Dial("unix", config.SocketPath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer conn.Close()
+		json.NewEncoder(conn).Encode(req)
+		var resp Response
+		if err := json.NewDecoder(conn).Decode(&resp); err != nil {
+			t.Fatalf("reading reply to %s: %v", req.Method, err)
+		}
+		return resp
+	}
+
+	// A protocol 1 client calls straight away, and is told it's out of date.
+	if resp := send(Request{Method: "ping"}); !strings.Contains(resp.Error, "this sp is older than the running daemon") {
+		t.Errorf("protocol 1 ping = %+v, want an out-of-date error", resp)
+	}
+
+	params, _ := json.Marshal(hello{Protocol: ProtocolVersion + 1})
+	resp := send(Request{ID: 1, Method: "hello", Params: params})
+	var h hello
+	json.Unmarshal(resp.Result, &h)
+	if resp.ID != 1 || resp.Error == "" || h.Protocol != ProtocolVersion {
+		t.Errorf("newer hello = %+v, want an error naming protocol %d", resp, ProtocolVersion)
+	}
+}
+
+func TestHandshakeWithOldDaemon(t *testing.T) {
+	// A protocol 1 daemon answers hello like any unknown method.
+	socket := filepath.Join(t.TempDir(), "old.sock")
+	ln, err := net.Listen("unix", socket)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		var req Request
+		json.NewDecoder(conn).Decode(&req)
+		json.NewEncoder(conn).Encode(Response{Error: "unknown method: " + req.Method})
+	}()
+
+	_, err = ConnectTo(socket)
+	var perr *ProtocolError
+	if !errors.As(err, &perr) || perr.Daemon != 1 || perr.Client != ProtocolVersion {
+		t.Fatalf("ConnectTo(old daemon) = %v, want a ProtocolError for daemon protocol 1", err)
+	}
+	if !daemonOutdated(err) {
+		t.Error("daemonOutdated = false for a protocol 1 daemon")
+	}
+}
+
+func TestClientConcurrentCalls(t *testing.T) {
+	d, config := startTestDaemon(t)
+	d.db.UpsertSprite(&store.Sprite{Name: "api"})
+
+	client, err := ConnectTo(config.SocketPath)
+	if err != nil {
+		t.Fatalf("connecting: %v", err)
+	}
+	defer client.Close()
+
+	updates, err := client.Subscribe()
+	if err != nil {
+		t.Fatalf("subscribe: %v", err)
+	}
+
+	// Many calls in flight at once on the one connection, each getting
+	// its own reply.
+	var wg sync.WaitGroup
+	errs := make(chan error, 40)
+	for i := 0; i < 20; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			errs <- client.Ping()
+		}()
+		go func() {
+			defer wg.Done()
+			key := fmt.Sprintf("k%02d", i)
+			if err := client.SetMeta("api", key, key); err != nil {
+				errs <- err
+				return
+			}
+			meta, err := client.GetMeta("api")
+			if err == nil && len(meta) == 0 {
+				err = fmt.Errorf("GetMeta after setting %s returned nothing", key)
+			}
+			errs <- err
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Error(err)
+		}
+	}
+
+	// The subscription saw the updates on the same connection.
+	select {
+	case u, ok := <-updates:
+		if !ok || u.Type != "meta" || u.SpriteName != "api" {
+			t.Errorf("update = %+v (open %v), want a meta update for api", u, ok)
+		}
+	case <-time.After(2 * time.Second):
+		t.Error("no state update arrived")
+	}
+
+	// Once the connection closes, calls fail and the subscription ends.
+	client.Close()
+	if err := client.Ping(); err == nil {
+		t.Error("Ping after Close succeeded")
+	}
+	for range updates {
+	}
+}
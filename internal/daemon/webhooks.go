@@ -0,0 +1,199 @@
+package daemon
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/jphenow/sp/internal/retry"
+)
+
+// Webhooks post the same events hooks fire on to HTTP endpoints, so sprite
+// and sync problems can land in a chat channel without a local command.
+// They're configured as [[webhooks]] tables in config.toml:
+//
+//	[[webhooks]]
+//	url = "https://chat.example.com/hooks/abc123"
+//	on = ["sync_status:error", "sync_status:conflicts", "event:proxy"]
+//	secret = "shared-secret"
+//
+// Each event is POSTed as JSON (a hookEvent plus a one-line "text" summary,
+// which chat services' incoming webhooks display as-is). With a secret, the
+// body is signed with HMAC-SHA256 in the X-Sp-Signature-256 header as
+// "sha256=<hex>". Network errors, 5xx and 429 responses are retried under
+// retry.Webhook; other responses are final. Deliveries run concurrently, so
+// a slow receiver may see events out of order.
+
+// defaultWebhookTimeout bounds each delivery attempt when the webhook
+// doesn't set a timeout.
+const defaultWebhookTimeout = 10 * time.Second
+
+// Webhook is a [[webhooks]] table from config.toml.
+type Webhook struct {
+	URL     string        // http or https endpoint to POST events to
+	On      []string      // events to send, as for Hook.On (none = every event)
+	Match   string        // only sprites matching this query (see store.Query)
+	Secret  string        // HMAC-SHA256 key for X-Sp-Signature-256 ("" = unsigned)
+	Timeout time.Duration // bounds each attempt (0 = defaultWebhookTimeout)
+}
+
+// validate checks that the webhook has a usable URL and names known events.
+func (w Webhook) validate() error {
+	u, err := url.Parse(w.URL)
+	switch {
+	case w.URL == "":
+		return errors.New(`needs "url", where to send events`)
+	case err != nil:
+		return fmt.Errorf("url: %w", err)
+	case u.Scheme != "http" && u.Scheme != "https", u.Host == "":
+		return fmt.Errorf("url %q must be an http or https URL", w.URL)
+	}
+	return validateEventFilter(w.On, w.Match, w.Timeout)
+}
+
+// webhookPayload is the JSON body of a webhook delivery.
+type webhookPayload struct {
+	hookEvent
+	Text string `json:"text"` // one-line summary for chat services
+}
+
+// webhookText summarizes ev in a line, e.g. "sp: api sync watching → error: ssh: connection refused".
+func webhookText(ev hookEvent) string {
+	text := "sp: " + ev.Sprite + " "
+	switch ev.Type {
+	case "sprite_added":
+		text += "added"
+	case "sprite_removed":
+		text += "removed"
+	case "sprite_status":
+		text += ev.From + " → " + ev.To
+	case "sync_status":
+		text += "sync " + cmp.Or(ev.From, "none") + " → " + ev.To
+		switch {
+		case ev.Conflicts == 1:
+			text += " (1 conflict)"
+		case ev.Conflicts > 1:
+			text += fmt.Sprintf(" (%d conflicts)", ev.Conflicts)
+		}
+		if ev.SyncError != "" {
+			text += ": " + ev.SyncError
+		}
+	default:
+		text += ev.To + ": " + ev.Message
+	}
+	return text
+}
+
+// fireWebhooks starts delivering ev to every configured webhook that wants it.
+func (d *Daemon) fireWebhooks(ctx context.Context, ev hookEvent) {
+	for _, w := range d.cfg().Webhooks {
+		if eventSelected(w.On, ev) && d.hookMatches(w.Match, ev) {
+			go func() {
+				if err := d.deliverWebhook(ctx, w, ev); err != nil {
+					slog.Warn("webhooks: delivery failed", "url", redactURL(w.URL), "sprite", ev.Sprite,
+						"event", ev.Event, "error", err)
+				}
+			}()
+		}
+	}
+}
+
+// webhookStatusError is a delivery the receiver answered with a non-2xx
+// status.
+type webhookStatusError struct {
+	code int
+	body string
+}
+
+func (e *webhookStatusError) Error() string {
+	if e.body == "" {
+		return fmt.Sprintf("status %d", e.code)
+	}
+	return fmt.Sprintf("status %d: %s", e.code, e.body)
+}
+
+// deliverWebhook POSTs ev to the webhook under d.webhookRetry. Every attempt
+// carries the same X-Sp-Delivery ID so the receiver can drop duplicates.
+func (d *Daemon) deliverWebhook(ctx context.Context, w Webhook, ev hookEvent) error {
+	body, err := json.Marshal(webhookPayload{ev, webhookText(ev)})
+	if err != nil {
+		return fmt.Errorf("encoding event: %w", err)
+	}
+	id := make([]byte, 8)
+	rand.Read(id)
+	delivery := hex.EncodeToString(id)
+
+	p := d.webhookRetry
+	p.Retryable = func(err error) bool {
+		var status *webhookStatusError
+		if errors.As(err, &status) {
+			return status.code >= 500 || status.code == http.StatusTooManyRequests
+		}
+		return ctx.Err() == nil
+	}
+	p.OnRetry = func(attempt int, err error, delay time.Duration) {
+		slog.Info("webhooks: retrying", "url", redactURL(w.URL), "event", ev.Event, "attempt", attempt+1,
+			"delay", delay, "prev_error", err)
+	}
+	return retry.Do(ctx, p, func(ctx context.Context) error {
+		return postWebhook(ctx, w, ev.Event, delivery, body)
+	})
+}
+
+// postWebhook makes one delivery attempt.
+func postWebhook(ctx context.Context, w Webhook, event, delivery string, body []byte) error {
+	ctx, cancel := context.WithTimeout(ctx, cmp.Or(w.Timeout, defaultWebhookTimeout))
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.URL, bytes.NewReader(body))
+	if err != nil {
+		return retry.Permanent(err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "sp-daemon")
+	req.Header.Set("X-Sp-Event", event)
+	req.Header.Set("X-Sp-Delivery", delivery)
+	if w.Secret != "" {
+		req.Header.Set("X-Sp-Signature-256", signWebhook(w.Secret, body))
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return &webhookStatusError{code: resp.StatusCode, body: string(bytes.TrimSpace(msg))}
+	}
+	return nil
+}
+
+// signWebhook returns the X-Sp-Signature-256 header value for body.
+func signWebhook(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// redactURL drops the path and query from a webhook URL for logging; chat
+// services put the credential there.
+func redactURL(raw string) string {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return "(invalid url)"
+	}
+	return u.Scheme + "://" + u.Host + "/…"
+}
@@ -0,0 +1,159 @@
+package daemon
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/jphenow/sp/internal/retry"
+	"github.com/jphenow/sp/internal/store"
+)
+
+// webhookReceiver is an httptest server that records deliveries and answers
+// each with the next of its statuses (200 once they run out).
+type webhookReceiver struct {
+	*httptest.Server
+	mu       sync.Mutex
+	statuses []int
+	requests []*http.Request
+	bodies   [][]byte
+}
+
+func newWebhookReceiver(t *testing.T, statuses ...int) *webhookReceiver {
+	t.Helper()
+	r := &webhookReceiver{statuses: statuses}
+	r.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		body, _ := io.ReadAll(req.Body)
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		r.requests = append(r.requests, req)
+		r.bodies = append(r.bodies, body)
+		status := http.StatusOK
+		if len(r.statuses) > 0 {
+			status, r.statuses = r.statuses[0], r.statuses[1:]
+		}
+		w.WriteHeader(status)
+	}))
+	t.Cleanup(r.Close)
+	return r
+}
+
+func (r *webhookReceiver) deliveries() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return len(r.requests)
+}
+
+func TestDeliverWebhook(t *testing.T) {
+	d, _ := testDaemon(t)
+	d.webhookRetry = retry.Policy{MaxAttempts: 3, InitialDelay: time.Millisecond}
+	recv := newWebhookReceiver(t, http.StatusServiceUnavailable)
+
+	w := Webhook{URL: recv.URL + "/hook", Secret: "s3cret"}
+	ev := hookEvent{Event: "sync_status:error", Type: "sync_status", Sprite: "api", From: "watching", To: "error",
+		SyncError: "ssh: connection refused", Conflicts: 1, Time: time.Now()}
+	if err := d.deliverWebhook(context.Background(), w, ev); err != nil {
+		t.Fatalf("deliverWebhook: %v", err)
+	}
+	if n := recv.deliveries(); n != 2 {
+		t.Fatalf("deliveries = %d, want a retry after the 503", n)
+	}
+
+	req, body := recv.requests[1], recv.bodies[1]
+	if got, want := req.Header.Get("X-Sp-Signature-256"), signWebhook("s3cret", body); got != want {
+		t.Errorf("signature = %q, want %q", got, want)
+	}
+	if req.Header.Get("X-Sp-Event") != "sync_status:error" || req.Header.Get("Content-Type") != "application/json" {
+		t.Errorf("headers = %v", req.Header)
+	}
+	if first, second := recv.requests[0].Header.Get("X-Sp-Delivery"), req.Header.Get("X-Sp-Delivery"); first == "" || first != second {
+		t.Errorf("delivery IDs %q, %q; want the same ID on a retry", first, second)
+	}
+	var got map[string]any
+	if err := json.Unmarshal(body, &got); err != nil {
+		t.Fatalf("body %s: %v", body, err)
+	}
+	if got["sprite"] != "api" || got["from"] != "watching" || got["to"] != "error" ||
+		got["sync_error"] != "ssh: connection refused" || got["conflicts"] != 1.0 {
+		t.Errorf("body = %s", body)
+	}
+	if want := "sp: api sync watching → error (1 conflict): ssh: connection refused"; got["text"] != want {
+		t.Errorf("text = %q, want %q", got["text"], want)
+	}
+}
+
+func TestDeliverWebhookGivesUp(t *testing.T) {
+	d, _ := testDaemon(t)
+	d.webhookRetry = retry.Policy{MaxAttempts: 3, InitialDelay: time.Millisecond}
+	ev := hookEvent{Event: "sprite_removed", Type: "sprite_removed", Sprite: "api"}
+
+	// A 4xx won't get better by trying again.
+	recv := newWebhookReceiver(t, http.StatusNotFound)
+	err := d.deliverWebhook(context.Background(), Webhook{URL: recv.URL}, ev)
+	if err == nil || !strings.Contains(err.Error(), "status 404") || recv.deliveries() != 1 {
+		t.Errorf("after a 404: error %v, %d deliveries; want one", err, recv.deliveries())
+	}
+	if recv.requests[0].Header.Get("X-Sp-Signature-256") != "" {
+		t.Error("a webhook without a secret was signed")
+	}
+
+	recv = newWebhookReceiver(t, 500, 502, 429, 500)
+	err = d.deliverWebhook(context.Background(), Webhook{URL: recv.URL}, ev)
+	if err == nil || recv.deliveries() != 3 {
+		t.Errorf("while failing: error %v, %d deliveries; want MaxAttempts", err, recv.deliveries())
+	}
+}
+
+func TestFireWebhooks(t *testing.T) {
+	d, _ := testDaemon(t)
+	d.db.UpsertSprite(&store.Sprite{Name: "api", Status: "running"})
+	d.db.AddTag("api", "work")
+	api, _ := d.db.GetSprite("api")
+
+	all := newWebhookReceiver(t)
+	conflicts := newWebhookReceiver(t)
+	other := newWebhookReceiver(t)
+	d.config.Webhooks = []Webhook{
+		{URL: all.URL},
+		{URL: conflicts.URL, On: []string{"sync_status:conflicts"}, Match: "tag:work"},
+		{URL: other.URL, Match: "tag:home"},
+	}
+
+	d.fireWebhooks(context.Background(), hookEvent{Event: "sync_status:conflicts", Type: "sync_status", Sprite: "api", To: "conflicts", Info: api})
+	d.fireWebhooks(context.Background(), hookEvent{Event: "sprite_status:warm", Type: "sprite_status", Sprite: "api", To: "warm", Info: api})
+	deadline := time.Now().Add(2 * time.Second)
+	for (all.deliveries() < 2 || conflicts.deliveries() < 1) && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	time.Sleep(50 * time.Millisecond) // let any stray delivery land
+	if all.deliveries() != 2 || conflicts.deliveries() != 1 || other.deliveries() != 0 {
+		t.Errorf("deliveries: all %d, conflicts %d, other %d; want 2, 1, 0",
+			all.deliveries(), conflicts.deliveries(), other.deliveries())
+	}
+}
+
+func TestWebhookValidate(t *testing.T) {
+	tests := []struct {
+		webhook Webhook
+		want    string // "" = valid
+	}{
+		{Webhook{URL: "https://chat.example.com/hooks/abc"}, ""},
+		{Webhook{URL: "http://127.0.0.1:9000", On: []string{"event:proxy"}, Match: "tag:work", Timeout: time.Minute}, ""},
+		{Webhook{}, `needs "url"`},
+		{Webhook{URL: "ftp://example.com"}, "must be an http or https URL"},
+		{Webhook{URL: "https://example.com", On: []string{"woke"}}, `unknown event "woke"`},
+		{Webhook{URL: "https://example.com", Timeout: time.Millisecond}, "at least 1s"},
+	}
+	for _, tt := range tests {
+		err := tt.webhook.validate()
+		if (tt.want == "") != (err == nil) || (err != nil && !strings.Contains(err.Error(), tt.want)) {
+			t.Errorf("validate(%+v) = %v, want %q", tt.webhook, err, tt.want)
+		}
+	}
+}
@@ -0,0 +1,478 @@
+// Package fakebin provides stand-ins for the external programs sp drives —
+// the `sprite` CLI, `mutagen`, `ssh` and `lsof` — so connect, the daemon's
+// sync pipeline and the commands built on them can be exercised end-to-end
+// in `go test` without a network or a Sprites account.
+//
+// The fakes are the test binary itself: New symlinks it onto a temp PATH
+// under each program's name, and Main (called from the package's TestMain)
+// runs the matching fake instead of the tests when invoked under one of
+// those names. Every sprite is a local directory standing in for its root
+// filesystem; exec'd commands run there with /home/sprite, /tmp and similar
+// paths rewritten into it. All fakes share one JSON state file, so tests can
+// inspect and change what the "platform" reports (e.g. put a sprite to sleep)
+// while sp is running.
+package fakebin
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/jphenow/sp/internal/sprite"
+)
+
+// Environment variables the fakes read. New sets them for the test process,
+// and children inherit them.
+const (
+	envRoot = "SP_FAKEBIN_ROOT" // state directory
+	envPath = "SP_FAKEBIN_PATH" // PATH before New prepended the fakes
+	envHome = "SP_FAKEBIN_HOME" // the test's $HOME, for fakes run "on" a sprite
+	// envSprite names the sprite a remote command runs on, for shims like
+	// sprite-env that act on the sprite's own state.
+	envSprite = "SP_FAKEBIN_SPRITE"
+)
+
+// localFakes replace programs sp runs on this machine. remoteFakes only go on
+// the PATH of commands run on a fake sprite, standing in for the sprite's
+// system tools (and refusing network access).
+var (
+	localFakes = map[string]func(args []string) int{
+		"sprite":     runSprite,
+		"mutagen":    runMutagen,
+		"ssh":        runSSH,
+		"ssh-keygen": runNoop,
+		"lsof":       runLsof,
+	}
+	remoteFakes = map[string]func(args []string) int{
+		"sprite-env": runSpriteEnv,
+		"sudo":       runSudo,
+		"chown":      runNoop,
+		"systemctl":  runNoop,
+		"service":    runNoop,
+		"apt-get":    runNoop,
+		"ss":         runSS,
+		"curl":       runOffline,
+		"wget":       runOffline,
+	}
+)
+
+// Main runs the fake named by os.Args[0] when the test binary was invoked
+// through one of New's symlinks, and the tests otherwise. Call it from
+// TestMain:
+//
+//	func TestMain(m *testing.M) { fakebin.Main(m) }
+func Main(m *testing.M) {
+	name := filepath.Base(os.Args[0])
+	if fn, ok := localFakes[name]; ok {
+		os.Exit(fn(os.Args[1:]))
+	}
+	if fn, ok := remoteFakes[name]; ok {
+		os.Exit(fn(os.Args[1:]))
+	}
+	os.Exit(m.Run())
+}
+
+// Env is a fake Sprites platform for one test. It owns a temp HOME (so SSH
+// config, ~/.config/sp and the daemon socket are isolated) and the state
+// the fakes share.
+type Env struct {
+	Root string // fake state and sprite filesystems
+	Home string // the test's $HOME
+	t    testing.TB
+}
+
+// New installs the fakes on PATH, points HOME at a fresh directory with an
+// SSH key pair, and returns the empty platform. Only usable from packages
+// whose TestMain calls Main. Because it sets environment variables, tests
+// using it cannot run in parallel.
+func New(t testing.TB) *Env {
+	t.Helper()
+	exe, err := os.Executable()
+	if err != nil {
+		t.Fatalf("fakebin: locating test binary: %v", err)
+	}
+
+	e := &Env{Root: t.TempDir(), Home: t.TempDir(), t: t}
+	localBin := filepath.Join(e.Root, "bin")
+	remoteBin := filepath.Join(e.Root, "remote-bin")
+	for dir, fakes := range map[string]map[string]func([]string) int{localBin: localFakes, remoteBin: remoteFakes} {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			t.Fatalf("fakebin: %v", err)
+		}
+		for name := range fakes {
+			if err := os.Symlink(exe, filepath.Join(dir, name)); err != nil {
+				t.Fatalf("fakebin: installing %s: %v", name, err)
+			}
+		}
+	}
+
+	sshDir := filepath.Join(e.Home, ".ssh")
+	if err := os.MkdirAll(sshDir, 0o700); err != nil {
+		t.Fatalf("fakebin: %v", err)
+	}
+	os.WriteFile(filepath.Join(sshDir, "id_ed25519"), []byte("fake private key\n"), 0o600)
+	os.WriteFile(filepath.Join(sshDir, "id_ed25519.pub"), []byte("ssh-ed25519 AAAAfake sp-test\n"), 0o644)
+
+	t.Setenv(envPath, os.Getenv("PATH"))
+	t.Setenv("PATH", localBin+string(os.PathListSeparator)+os.Getenv("PATH"))
+	t.Setenv("HOME", e.Home)
+	t.Setenv(envHome, e.Home)
+	t.Setenv(envRoot, e.Root)
+	t.Setenv(sprite.EnvBackend, sprite.BackendCLI)
+
+	if err := saveState(e.Root, newState()); err != nil {
+		t.Fatalf("fakebin: %v", err)
+	}
+	t.Cleanup(e.killProxies)
+	return e
+}
+
+// killProxies stops any fake proxies still running when the test ends, so
+// they don't outlive it holding ports.
+func (e *Env) killProxies() {
+	st, err := readState(e.Root)
+	if err != nil {
+		return
+	}
+	for _, pid := range st.Proxies {
+		syscall.Kill(pid, syscall.SIGTERM)
+	}
+}
+
+// AddSprite creates a sprite with the given status ("running", "warm" or
+// "cold"), as if it had been created earlier with `sprite create`.
+func (e *Env) AddSprite(name, status string) {
+	e.t.Helper()
+	err := updateState(e.Root, func(st *state) error {
+		if _, ok := st.Sprites[name]; ok {
+			return fmt.Errorf("sprite %q already exists", name)
+		}
+		return createSprite(e.Root, st, name, "")
+	})
+	if err != nil {
+		e.t.Fatalf("fakebin: adding sprite: %v", err)
+	}
+	e.SetStatus(name, status)
+}
+
+// SetStatus changes what the API reports for a sprite. Moving a sprite out
+// of "running" makes its proxies exit, as they do when a real sprite sleeps.
+func (e *Env) SetStatus(name, status string) {
+	e.t.Helper()
+	err := updateState(e.Root, func(st *state) error {
+		s, ok := st.Sprites[name]
+		if !ok {
+			return fmt.Errorf("no sprite %q", name)
+		}
+		s.Status = status
+		s.UpdatedAt = time.Now().UTC()
+		return nil
+	})
+	if err != nil {
+		e.t.Fatalf("fakebin: setting status: %v", err)
+	}
+}
+
+// Sprite returns the API's view of a sprite, or nil if it doesn't exist.
+func (e *Env) Sprite(name string) *sprite.Info {
+	e.t.Helper()
+	st := e.state()
+	if s, ok := st.Sprites[name]; ok {
+		info := s.info()
+		return &info
+	}
+	return nil
+}
+
+// Path returns the local path backing remotePath on a sprite, e.g.
+// Path("alpha", "/home/sprite/proj").
+func (e *Env) Path(name, remotePath string) string {
+	return filepath.Join(spriteRoot(e.Root, name), filepath.FromSlash(remotePath))
+}
+
+// Services returns the sprite-env services defined on a sprite.
+func (e *Env) Services(name string) []sprite.Service {
+	e.t.Helper()
+	if s, ok := e.state().Sprites[name]; ok {
+		return s.Services
+	}
+	return nil
+}
+
+// Checkpoints returns a sprite's checkpoints, oldest first.
+func (e *Env) Checkpoints(name string) []sprite.Checkpoint {
+	e.t.Helper()
+	if s, ok := e.state().Sprites[name]; ok {
+		return s.Checkpoints
+	}
+	return nil
+}
+
+// Session returns the Mutagen session with the given name, or nil.
+func (e *Env) Session(name string) *Session {
+	e.t.Helper()
+	return e.state().Sessions[name]
+}
+
+// SetConflicts marks paths (relative to the synced directories) in the
+// named Mutagen session as conflicting.
+func (e *Env) SetConflicts(session string, paths ...string) {
+	e.t.Helper()
+	err := updateState(e.Root, func(st *state) error {
+		s, ok := st.Sessions[session]
+		if !ok {
+			return fmt.Errorf("no session %q", session)
+		}
+		s.Conflicts = paths
+		return nil
+	})
+	if err != nil {
+		e.t.Fatalf("fakebin: setting conflicts: %v", err)
+	}
+}
+
+// Fail makes every fake invocation whose command line starts with prefix
+// (e.g. "sprite api" or "mutagen sync create") print output to stderr and
+// exit 1. An empty output clears the failure.
+func (e *Env) Fail(prefix, output string) {
+	e.t.Helper()
+	err := updateState(e.Root, func(st *state) error {
+		if output == "" {
+			delete(st.Failures, prefix)
+		} else {
+			st.Failures[prefix] = output
+		}
+		return nil
+	})
+	if err != nil {
+		e.t.Fatalf("fakebin: %v", err)
+	}
+}
+
+// Calls returns the command line of every sprite and mutagen invocation so
+// far, in order, e.g. ["sprite", "destroy", "--force", "alpha"].
+func (e *Env) Calls() [][]string {
+	e.t.Helper()
+	return e.state().Calls
+}
+
+// Called reports whether any recorded call starts with the given words.
+func (e *Env) Called(words ...string) bool {
+	e.t.Helper()
+	want := strings.Join(words, " ")
+	for _, c := range e.Calls() {
+		if got := strings.Join(c, " "); got == want || strings.HasPrefix(got, want+" ") {
+			return true
+		}
+	}
+	return false
+}
+
+func (e *Env) state() *state {
+	e.t.Helper()
+	st, err := readState(e.Root)
+	if err != nil {
+		e.t.Fatalf("fakebin: reading state: %v", err)
+	}
+	return st
+}
+
+// --- shared state ---
+
+// state is everything the fakes know, persisted as JSON in the root.
+type state struct {
+	Sprites  map[string]*fakeSprite `json:"sprites"`
+	Sessions map[string]*Session    `json:"sessions"`
+	Proxies  map[int]int            `json:"proxies"`  // local port → fake proxy pid
+	Failures map[string]string      `json:"failures"` // command prefix → output
+	Calls    [][]string             `json:"calls"`
+	Seq      int                    `json:"seq"` // for IDs
+}
+
+func newState() *state {
+	return &state{
+		Sprites:  make(map[string]*fakeSprite),
+		Sessions: make(map[string]*Session),
+		Proxies:  make(map[int]int),
+		Failures: make(map[string]string),
+	}
+}
+
+// fakeSprite is a sprite as the fake platform records it.
+type fakeSprite struct {
+	Name        string              `json:"name"`
+	ID          string              `json:"id"`
+	Org         string              `json:"org"`
+	Status      string              `json:"status"`
+	CreatedAt   time.Time           `json:"created_at"`
+	UpdatedAt   time.Time           `json:"updated_at"`
+	Checkpoints []sprite.Checkpoint `json:"checkpoints"`
+	Services    []sprite.Service    `json:"services"`
+}
+
+// info renders the sprite the way the API does.
+func (s *fakeSprite) info() sprite.Info {
+	return sprite.Info{
+		ID:           s.ID,
+		Name:         s.Name,
+		Status:       s.Status,
+		URL:          "https://" + s.Name + ".sprites.test",
+		Organization: s.Org,
+		CreatedAt:    s.CreatedAt,
+		UpdatedAt:    s.UpdatedAt,
+	}
+}
+
+// Session is a Mutagen sync session recorded by the fake mutagen.
+type Session struct {
+	Name       string   `json:"name"`
+	Identifier string   `json:"identifier"`
+	Mode       string   `json:"mode"`
+	Alpha      string   `json:"alpha"`
+	Beta       string   `json:"beta"`
+	Ignores    []string `json:"ignores,omitempty"`
+	Paused     bool     `json:"paused"`
+	Flushes    int      `json:"flushes"`
+	Resets     int      `json:"resets"`
+	// Conflicts are paths both sides changed. Syncing leaves them alone,
+	// and a flush drops the ones whose two sides have come to agree.
+	Conflicts []string `json:"conflicts,omitempty"`
+}
+
+// spriteRoot is the directory standing in for a sprite's "/".
+func spriteRoot(root, name string) string {
+	return filepath.Join(root, "sprites", name)
+}
+
+// createSprite records a new running sprite and lays out its filesystem.
+func createSprite(root string, st *state, name, org string) error {
+	st.Seq++
+	now := time.Now().UTC()
+	if org == "" {
+		org = "fake-org"
+	}
+	st.Sprites[name] = &fakeSprite{
+		Name:      name,
+		ID:        fmt.Sprintf("spr_%04d", st.Seq),
+		Org:       org,
+		Status:    "running",
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	for _, dir := range []string{"home/sprite", "tmp", "etc/ssh", "var/log", "run", ".sprite/logs/services"} {
+		if err := os.MkdirAll(filepath.Join(spriteRoot(root, name), dir), 0o755); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func statePath(root string) string { return filepath.Join(root, "state.json") }
+
+// lockState takes an flock on the state directory; fakes run as separate,
+// often concurrent, processes.
+func lockState(root string, how int) (func(), error) {
+	f, err := os.OpenFile(filepath.Join(root, "state.lock"), os.O_CREATE|os.O_RDWR, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	if err := syscall.Flock(int(f.Fd()), how); err != nil {
+		f.Close()
+		return nil, err
+	}
+	return func() {
+		syscall.Flock(int(f.Fd()), syscall.LOCK_UN)
+		f.Close()
+	}, nil
+}
+
+func loadState(root string) (*state, error) {
+	data, err := os.ReadFile(statePath(root))
+	if err != nil {
+		return nil, err
+	}
+	st := newState()
+	if err := json.Unmarshal(data, st); err != nil {
+		return nil, fmt.Errorf("parsing fake state: %w", err)
+	}
+	return st, nil
+}
+
+func saveState(root string, st *state) error {
+	data, err := json.MarshalIndent(st, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := statePath(root) + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, statePath(root))
+}
+
+// readState returns a snapshot of the state.
+func readState(root string) (*state, error) {
+	unlock, err := lockState(root, syscall.LOCK_SH)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+	return loadState(root)
+}
+
+// updateState applies fn to the state under an exclusive lock, saving the
+// result unless fn fails.
+func updateState(root string, fn func(*state) error) error {
+	unlock, err := lockState(root, syscall.LOCK_EX)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	st, err := loadState(root)
+	if err != nil {
+		return err
+	}
+	if err := fn(st); err != nil {
+		return err
+	}
+	return saveState(root, st)
+}
+
+// begin is the common prologue of the sprite and mutagen fakes: it records
+// the call and reports an injected failure, if any, as a non-zero exit code.
+func begin(root string, argv []string) (int, bool) {
+	line := strings.Join(argv, " ")
+	failure := ""
+	err := updateState(root, func(st *state) error {
+		st.Calls = append(st.Calls, argv)
+		for prefix, out := range st.Failures {
+			if line == prefix || strings.HasPrefix(line, prefix+" ") {
+				failure = out
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "fakebin: %v\n", err)
+		return 1, false
+	}
+	if failure != "" {
+		fmt.Fprintln(os.Stderr, failure)
+		return 1, false
+	}
+	return 0, true
+}
+
+// fakeRoot returns the state directory of the Env that installed the fakes.
+func fakeRoot() (string, error) {
+	root := os.Getenv(envRoot)
+	if root == "" {
+		return "", fmt.Errorf("%s is not set; fakes only run under fakebin.New", envRoot)
+	}
+	return root, nil
+}
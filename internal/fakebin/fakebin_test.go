@@ -0,0 +1,187 @@
+package fakebin
+
+import (
+	"context"
+	"errors"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jphenow/sp/internal/retry"
+	"github.com/jphenow/sp/internal/sprite"
+	spSync "github.com/jphenow/sp/internal/sync"
+)
+
+func TestMain(m *testing.M) { Main(m) }
+
+func TestRewritePaths(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{"/home/sprite/proj", "/R/home/sprite/proj"},
+		{"cd /home/sprite && tar xzf /tmp/upload.tar.gz", "cd /R/home/sprite && tar xzf /R/tmp/upload.tar.gz"},
+		{"sudo chown sprite:sprite /home/sprite", "sudo chown sprite:sprite /R/home/sprite"},
+		{`echo "$(ls -ld /home/sprite/.ssh)"`, `echo "$(ls -ld /R/home/sprite/.ssh)"`},
+		{"/bin/sh /usr/bin/env /tmpfile", "/bin/sh /usr/bin/env /tmpfile"},
+		{"--dir=/etc/ssh", "--dir=/R/etc/ssh"},
+	}
+	for _, tt := range tests {
+		if got := rewritePaths("/R", tt.in); got != tt.want {
+			t.Errorf("rewritePaths(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestSpriteLifecycle(t *testing.T) {
+	e := New(t)
+	client := sprite.NewClient("")
+
+	if err := client.Create("alpha"); err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	if err := client.Create("alpha"); err == nil {
+		t.Error("second Create = nil error, want already exists")
+	}
+	info, err := client.Get("alpha")
+	if err != nil || info.Status != "running" {
+		t.Fatalf("Get = %+v, %v; want a running sprite", info, err)
+	}
+
+	out, err := client.Exec(sprite.ExecOptions{
+		Sprite:  "alpha",
+		Command: []string{"sh", "-c", "echo hi > /home/sprite/greeting && cat greeting"},
+	})
+	if err != nil || strings.TrimSpace(string(out)) != "hi" {
+		t.Fatalf("Exec = %q, %v; want hi", out, err)
+	}
+	if _, err := os.Stat(e.Path("alpha", "/home/sprite/greeting")); err != nil {
+		t.Errorf("file not written inside the sprite: %v", err)
+	}
+
+	e.SetStatus("alpha", "cold")
+	if _, err := client.Exec(sprite.ExecOptions{Sprite: "alpha", Command: []string{"true"}}); err != nil {
+		t.Fatalf("Exec on cold sprite: %v", err)
+	}
+	if got := e.Sprite("alpha").Status; got != "running" {
+		t.Errorf("status after exec = %q, want exec to wake it", got)
+	}
+
+	if err := client.Destroy("alpha"); err != nil {
+		t.Fatalf("Destroy: %v", err)
+	}
+	if _, err := client.Get("alpha"); !errors.Is(err, sprite.ErrNotFound) {
+		t.Errorf("Get after Destroy = %v, want ErrNotFound", err)
+	}
+	if !e.Called("sprite", "destroy", "--force", "alpha") {
+		t.Errorf("calls = %q, want a forced destroy", e.Calls())
+	}
+}
+
+func TestFail(t *testing.T) {
+	e := New(t)
+	e.AddSprite("alpha", "running")
+	e.Fail("sprite api", "Error: dial tcp: lookup api.sprites.dev: no such host")
+
+	client := sprite.NewClient("")
+	client.SetRetryPolicy(retry.Policy{MaxAttempts: 1})
+	if _, err := client.Get("alpha"); !errors.Is(err, sprite.ErrNetwork) {
+		t.Errorf("Get = %v, want ErrNetwork", err)
+	}
+
+	e.Fail("sprite api", "")
+	if _, err := client.Get("alpha"); err != nil {
+		t.Errorf("Get after clearing failure: %v", err)
+	}
+}
+
+func TestServicesAndCheckpoints(t *testing.T) {
+	e := New(t)
+	e.AddSprite("alpha", "running")
+	client := sprite.NewClient("")
+	ctx := context.Background()
+
+	err := client.CreateService(ctx, "alpha", sprite.ServiceOptions{Name: "web", Cmd: "/home/sprite/bin/web", Args: []string{"serve"}, HTTPPort: 8080})
+	if err != nil {
+		t.Fatalf("CreateService: %v", err)
+	}
+	svc, err := client.GetService(ctx, "alpha", "web")
+	if err != nil {
+		t.Fatalf("GetService: %v", err)
+	}
+	if svc.Cmd != "/home/sprite/bin/web" || svc.HTTPPort != 8080 || svc.State == nil || svc.State.Status != "running" {
+		t.Errorf("service = %+v", svc)
+	}
+
+	os.WriteFile(e.Path("alpha", "/home/sprite/file"), []byte("before"), 0o644)
+	cp, err := client.CreateCheckpoint("alpha", "snap")
+	if err != nil {
+		t.Fatalf("CreateCheckpoint: %v", err)
+	}
+	os.WriteFile(e.Path("alpha", "/home/sprite/file"), []byte("after"), 0o644)
+	if err := client.RestoreCheckpoint("alpha", cp.ID); err != nil {
+		t.Fatalf("RestoreCheckpoint: %v", err)
+	}
+	if data, _ := os.ReadFile(e.Path("alpha", "/home/sprite/file")); string(data) != "before" {
+		t.Errorf("file after restore = %q, want %q", data, "before")
+	}
+}
+
+func TestProxySSHAndMutagen(t *testing.T) {
+	e := New(t)
+	e.AddSprite("fakebin-sync", "running")
+	ctx := context.Background()
+	engine := spSync.NewMutagenEngine()
+	mgr := spSync.NewManager(sprite.NewClient(""), engine)
+
+	if err := mgr.SetupSSHServer("fakebin-sync"); err != nil {
+		t.Fatalf("SetupSSHServer: %v", err)
+	}
+	proxy, port, err := mgr.StartProxy("fakebin-sync")
+	if err != nil {
+		t.Fatalf("StartProxy: %v", err)
+	}
+	exited := make(chan struct{})
+	go func() {
+		proxy.Wait()
+		close(exited)
+	}()
+
+	if err := spSync.AddSSHConfig("fakebin-sync", port); err != nil {
+		t.Fatalf("AddSSHConfig: %v", err)
+	}
+	if err := spSync.TestSSHConnection("fakebin-sync", port, exited); err != nil {
+		t.Fatalf("TestSSHConnection: %v", err)
+	}
+
+	local := t.TempDir()
+	os.WriteFile(local+"/main.go", []byte("package main\n"), 0o644)
+	if _, err := mgr.StartSession("fakebin-sync", local, "/home/sprite/proj", ""); err != nil {
+		t.Fatalf("StartMutagenSession: %v", err)
+	}
+	if _, err := os.Stat(e.Path("fakebin-sync", "/home/sprite/proj/main.go")); err != nil {
+		t.Errorf("initial sync did not copy files: %v", err)
+	}
+	state, err := engine.Status(ctx, "fakebin-sync")
+	if err != nil || state.Status != "watching" {
+		t.Fatalf("GetMutagenStatus = %+v, %v; want watching", state, err)
+	}
+
+	// A sleeping sprite drops its proxy, and Mutagen loses the beta side.
+	e.SetStatus("fakebin-sync", "cold")
+	select {
+	case <-exited:
+	case <-time.After(5 * time.Second):
+		t.Fatal("proxy still running after the sprite went cold")
+	}
+	if state, _ := engine.Status(ctx, "fakebin-sync"); state.Status != "connecting" {
+		t.Errorf("status after sleep = %q, want connecting", state.Status)
+	}
+
+	if err := engine.Terminate(ctx, "fakebin-sync"); err != nil {
+		t.Fatalf("Terminate: %v", err)
+	}
+	if _, err := engine.Status(ctx, "fakebin-sync"); !errors.Is(err, spSync.ErrNoSession) {
+		t.Errorf("Status after terminate = %v, want ErrNoSession", err)
+	}
+}
@@ -0,0 +1,318 @@
+package fakebin
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// runMutagen is the fake `mutagen`. Sessions are records in the shared
+// state; creating or flushing one copies alpha onto beta once (no deletions,
+// no watching, conflicting paths left alone), and a session reports
+// "watching" only while its remote endpoint's proxy answers.
+func runMutagen(args []string) int {
+	root, err := fakeRoot()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Error:", err)
+		return 1
+	}
+	if code, ok := begin(root, append([]string{"mutagen"}, args...)); !ok {
+		return code
+	}
+	if len(args) < 2 || args[0] != "sync" {
+		fmt.Fprintln(os.Stderr, "Error: fake mutagen only supports sync commands")
+		return 1
+	}
+
+	switch args[1] {
+	case "create":
+		return mutagenCreate(root, args[2:])
+	case "list":
+		return mutagenList(root, args[2:])
+	case "flush", "reset", "pause", "resume", "terminate":
+		if len(args) != 3 {
+			fmt.Fprintf(os.Stderr, "Error: sync %s takes one session name\n", args[1])
+			return 1
+		}
+		return mutagenSession(root, args[1], args[2])
+	}
+	fmt.Fprintf(os.Stderr, "Error: unknown sync command %q\n", args[1])
+	return 1
+}
+
+func mutagenCreate(root string, args []string) int {
+	sess := &Session{Mode: "two-way-safe"}
+	var endpoints []string
+	for i := 0; i < len(args); i++ {
+		flag, value, hasValue := strings.Cut(args[i], "=")
+		switch flag {
+		case "--name", "--sync-mode", "--ignore":
+			if !hasValue {
+				if i+1 >= len(args) {
+					fmt.Fprintf(os.Stderr, "Error: flag needs an argument: %s\n", flag)
+					return 1
+				}
+				i++
+				value = args[i]
+			}
+			switch flag {
+			case "--name":
+				sess.Name = value
+			case "--sync-mode":
+				sess.Mode = value
+			default:
+				sess.Ignores = append(sess.Ignores, value)
+			}
+		default:
+			if strings.HasPrefix(args[i], "-") {
+				fmt.Fprintf(os.Stderr, "Error: unknown flag: %s\n", args[i])
+				return 1
+			}
+			endpoints = append(endpoints, args[i])
+		}
+	}
+	if sess.Name == "" || len(endpoints) != 2 {
+		fmt.Fprintln(os.Stderr, "Error: sync create needs --name, an alpha and a beta")
+		return 1
+	}
+	sess.Alpha, sess.Beta = endpoints[0], endpoints[1]
+
+	if err := syncOnce(root, sess); err != nil {
+		fmt.Fprintln(os.Stderr, "Error:", err)
+		return 1
+	}
+	err := updateState(root, func(st *state) error {
+		// Real Mutagen allows duplicate names, but sp always terminates
+		// first, so a duplicate here means a leaked session.
+		if _, ok := st.Sessions[sess.Name]; ok {
+			return fmt.Errorf("a session named %q already exists", sess.Name)
+		}
+		st.Seq++
+		sess.Identifier = fmt.Sprintf("sync_fake%04d", st.Seq)
+		st.Sessions[sess.Name] = sess
+		return nil
+	})
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Error:", err)
+		return 1
+	}
+	fmt.Printf("Created session %s\n", sess.Identifier)
+	return 0
+}
+
+// mutagenList prints sessions, all of them or just the named ones, as
+// `mutagen sync list --template '{{ json . }}'` does. Only that template is
+// supported.
+func mutagenList(root string, args []string) int {
+	var names []string
+	template := ""
+	for i := 0; i < len(args); i++ {
+		switch {
+		case args[i] == "--template" && i+1 < len(args):
+			i++
+			template = args[i]
+		case strings.HasPrefix(args[i], "--template="):
+			template = strings.TrimPrefix(args[i], "--template=")
+		case strings.HasPrefix(args[i], "-"):
+			fmt.Fprintf(os.Stderr, "Error: unknown flag: %s\n", args[i])
+			return 1
+		default:
+			names = append(names, args[i])
+		}
+	}
+	if strings.Join(strings.Fields(template), " ") != "{{ json . }}" {
+		fmt.Fprintln(os.Stderr, "Error: fake mutagen only supports --template '{{ json . }}'")
+		return 1
+	}
+
+	st, err := readState(root)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Error:", err)
+		return 1
+	}
+	var sessions []*Session
+	if len(names) == 0 {
+		for _, s := range st.Sessions {
+			sessions = append(sessions, s)
+		}
+		sort.Slice(sessions, func(i, j int) bool { return sessions[i].Name < sessions[j].Name })
+	}
+	for _, name := range names {
+		s, ok := st.Sessions[name]
+		if !ok {
+			fmt.Fprintln(os.Stderr, "Error: unable to locate requested sessions")
+			return 1
+		}
+		sessions = append(sessions, s)
+	}
+
+	out := make([]map[string]any, 0, len(sessions))
+	for _, s := range sessions {
+		_, alphaErr := endpointDir(root, s.Alpha)
+		_, betaErr := endpointDir(root, s.Beta)
+		status := "watching"
+		switch {
+		case s.Paused:
+			status = "disconnected"
+		case alphaErr != nil:
+			status = "connecting-alpha"
+		case betaErr != nil:
+			status = "connecting-beta"
+		}
+		conflicts := make([]map[string]any, len(s.Conflicts))
+		for i, p := range s.Conflicts {
+			changes := []map[string]any{{"path": p, "old": map[string]any{"kind": "file"}, "new": map[string]any{"kind": "file"}}}
+			conflicts[i] = map[string]any{"root": p, "alphaChanges": changes, "betaChanges": changes}
+		}
+		out = append(out, map[string]any{
+			"identifier": s.Identifier,
+			"name":       s.Name,
+			"mode":       s.Mode,
+			"alpha":      endpointJSON(s.Alpha, !s.Paused && alphaErr == nil),
+			"beta":       endpointJSON(s.Beta, !s.Paused && betaErr == nil),
+			"paused":     s.Paused,
+			"status":     status,
+			"conflicts":  conflicts,
+		})
+	}
+	data, err := json.Marshal(out)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Error:", err)
+		return 1
+	}
+	fmt.Println(string(data))
+	return 0
+}
+
+// endpointJSON renders an endpoint URL the way Mutagen's JSON model does.
+func endpointJSON(endpoint string, connected bool) map[string]any {
+	ep := map[string]any{"protocol": "local", "path": endpoint, "connected": connected}
+	if !strings.HasPrefix(endpoint, "/") && strings.Contains(endpoint, ":") {
+		host, path, _ := strings.Cut(endpoint, ":")
+		ep["protocol"], ep["path"] = "ssh", path
+		if user, h, ok := strings.Cut(host, "@"); ok {
+			ep["user"], host = user, h
+		}
+		ep["host"] = host
+	}
+	return ep
+}
+
+// mutagenSession runs flush, reset, pause, resume or terminate on a named
+// session.
+func mutagenSession(root, command, name string) int {
+	var flush *Session
+	err := updateState(root, func(st *state) error {
+		s, ok := st.Sessions[name]
+		if !ok {
+			return fmt.Errorf("unable to locate requested sessions")
+		}
+		switch command {
+		case "flush":
+			if s.Paused {
+				return fmt.Errorf("session is paused")
+			}
+			s.Flushes++
+			flush = s
+		case "reset":
+			s.Resets++
+		case "pause":
+			s.Paused = true
+		case "resume":
+			s.Paused = false
+		case "terminate":
+			delete(st.Sessions, name)
+		}
+		return nil
+	})
+	if err == nil && flush != nil {
+		err = syncOnce(root, flush)
+	}
+	if err == nil && flush != nil && len(flush.Conflicts) > 0 {
+		err = dropResolvedConflicts(root, flush)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Error:", err)
+		return 1
+	}
+	return 0
+}
+
+// syncOnce copies the session's alpha onto its beta, skipping ignored paths.
+func syncOnce(root string, s *Session) error {
+	alpha, err := endpointDir(root, s.Alpha)
+	if err != nil {
+		return fmt.Errorf("unable to connect to alpha: %w", err)
+	}
+	beta, err := endpointDir(root, s.Beta)
+	if err != nil {
+		return fmt.Errorf("unable to connect to beta: %w", err)
+	}
+	if err := os.MkdirAll(beta, 0o755); err != nil {
+		return err
+	}
+	return copyTree(alpha, beta, func(rel string) bool {
+		if slices.Contains(s.Conflicts, rel) {
+			return true
+		}
+		for _, pattern := range s.Ignores {
+			pattern = strings.Trim(pattern, "/")
+			if ok, _ := filepath.Match(pattern, rel); ok {
+				return true
+			}
+			if ok, _ := filepath.Match(pattern, filepath.Base(rel)); ok {
+				return true
+			}
+		}
+		return false
+	})
+}
+
+// dropResolvedConflicts forgets the session's conflicts whose alpha and
+// beta files now hold the same contents, or are both gone.
+func dropResolvedConflicts(root string, s *Session) error {
+	alpha, err := endpointDir(root, s.Alpha)
+	if err != nil {
+		return err
+	}
+	beta, err := endpointDir(root, s.Beta)
+	if err != nil {
+		return err
+	}
+	return updateState(root, func(st *state) error {
+		sess, ok := st.Sessions[s.Name]
+		if !ok {
+			return nil
+		}
+		sess.Conflicts = slices.DeleteFunc(sess.Conflicts, func(p string) bool {
+			a, aErr := os.ReadFile(filepath.Join(alpha, filepath.FromSlash(p)))
+			b, bErr := os.ReadFile(filepath.Join(beta, filepath.FromSlash(p)))
+			if os.IsNotExist(aErr) && os.IsNotExist(bErr) {
+				return true
+			}
+			return aErr == nil && bErr == nil && string(a) == string(b)
+		})
+		return nil
+	})
+}
+
+// endpointDir maps a Mutagen endpoint to a local directory: a plain path is
+// itself, and HOST:PATH goes through the fake proxy HOST resolves to.
+func endpointDir(root, endpoint string) (string, error) {
+	if strings.HasPrefix(endpoint, "/") || !strings.Contains(endpoint, ":") {
+		return endpoint, nil
+	}
+	host, path, _ := strings.Cut(endpoint, ":")
+	if at := strings.LastIndex(host, "@"); at >= 0 {
+		host = host[at+1:]
+	}
+	name, err := dialSprite(host)
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(spriteRoot(root, name), filepath.FromSlash(path)), nil
+}
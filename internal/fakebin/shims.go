@@ -0,0 +1,327 @@
+package fakebin
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/jphenow/sp/internal/sprite"
+)
+
+// sshValueOpts are ssh's single-letter options that take an argument.
+const sshValueOpts = "BbcDEeFIiJLlmOoPpQRSWw"
+
+// runSSH is the fake `ssh`: it resolves the host through ~/.ssh/config,
+// connects to the fake proxy listening there to learn which sprite it
+// reaches, and runs the command on that sprite.
+func runSSH(args []string) int {
+	var host string
+	var command []string
+	for i := 0; i < len(args); i++ {
+		a := args[i]
+		if host == "" && len(a) >= 2 && a[0] == '-' {
+			if len(a) == 2 && strings.ContainsRune(sshValueOpts, rune(a[1])) {
+				i++
+			}
+			continue
+		}
+		if host == "" {
+			host = a
+			continue
+		}
+		command = args[i:]
+		break
+	}
+	if host == "" {
+		fmt.Fprintln(os.Stderr, "usage: ssh destination [command]")
+		return 255
+	}
+	if at := strings.LastIndex(host, "@"); at >= 0 {
+		host = host[at+1:]
+	}
+
+	name, err := dialSprite(host)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "ssh: connect to host %s: %v\n", host, err)
+		return 255
+	}
+	if len(command) == 0 {
+		return 0
+	}
+	root, err := fakeRoot()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "ssh:", err)
+		return 255
+	}
+	sroot := spriteRoot(root, name)
+	script := rewritePaths(sroot, strings.Join(command, " "))
+	return remoteExec(name, sroot, filepath.Join(sroot, "home", "sprite"), []string{"sh", "-c", script}, nil)
+}
+
+// dialSprite connects to an SSH host alias and returns the name of the
+// sprite whose fake proxy answered.
+func dialSprite(alias string) (string, error) {
+	hostName, port := resolveSSHHost(alias)
+	conn, err := net.DialTimeout("tcp", net.JoinHostPort(hostName, port), 5*time.Second)
+	if err != nil {
+		return "", err
+	}
+	defer conn.Close()
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	line, err := bufio.NewReader(conn).ReadString('\n')
+	if err != nil {
+		return "", fmt.Errorf("reading from proxy: %w", err)
+	}
+	return strings.TrimSpace(line), nil
+}
+
+// resolveSSHHost looks up HostName and Port for alias in ~/.ssh/config,
+// defaulting to the alias itself and port 22.
+func resolveSSHHost(alias string) (hostName, port string) {
+	hostName, port = alias, "22"
+	home := os.Getenv(envHome)
+	if home == "" {
+		home, _ = os.UserHomeDir()
+	}
+	data, err := os.ReadFile(filepath.Join(home, ".ssh", "config"))
+	if err != nil {
+		return hostName, port
+	}
+	matched, gotHost, gotPort := false, false, false
+	for _, line := range strings.Split(string(data), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 2 || strings.HasPrefix(fields[0], "#") {
+			continue
+		}
+		switch strings.ToLower(fields[0]) {
+		case "host":
+			matched = false
+			for _, pattern := range fields[1:] {
+				matched = matched || pattern == alias
+			}
+		case "hostname":
+			if matched && !gotHost {
+				hostName, gotHost = fields[1], true
+			}
+		case "port":
+			if matched && !gotPort {
+				port, gotPort = fields[1], true
+			}
+		}
+	}
+	return hostName, port
+}
+
+// runLsof is the fake `lsof`, supporting only the `-i tcp:PORT` listener
+// checks sp makes. A port counts as listening if it accepts a connection;
+// -t prints the fake proxy's pid when it is the listener.
+func runLsof(args []string) int {
+	port, terse := 0, false
+	for i, a := range args {
+		switch {
+		case a == "-t":
+			terse = true
+		case a == "-i" && i+1 < len(args):
+			port, _ = strconv.Atoi(strings.TrimPrefix(strings.ToLower(args[i+1]), "tcp:"))
+		}
+	}
+	if port == 0 {
+		fmt.Fprintln(os.Stderr, "lsof: fake only supports -i tcp:PORT")
+		return 1
+	}
+	conn, err := net.DialTimeout("tcp", fmt.Sprintf("127.0.0.1:%d", port), time.Second)
+	if err != nil {
+		return 1
+	}
+	conn.Close()
+
+	pid := 0
+	if root, err := fakeRoot(); err == nil {
+		if st, err := readState(root); err == nil {
+			pid = st.Proxies[port]
+		}
+	}
+	if terse {
+		if pid > 0 {
+			fmt.Println(pid)
+		}
+		return 0
+	}
+	fmt.Println("COMMAND   PID USER   FD   TYPE DEVICE SIZE/OFF NODE NAME")
+	fmt.Printf("sprite  %5d sp      3u  IPv4      0      0t0  TCP 127.0.0.1:%d (LISTEN)\n", pid, port)
+	return 0
+}
+
+// runSpriteEnv is the sprite's `sprite-env services` supervisor. Services
+// are recorded with a log file but their processes are not started.
+func runSpriteEnv(args []string) int {
+	root, err := fakeRoot()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "sprite-env:", err)
+		return 1
+	}
+	name := os.Getenv(envSprite)
+	if len(args) < 2 || args[0] != "services" {
+		fmt.Fprintln(os.Stderr, "sprite-env: fake only supports services list|create|delete")
+		return 2
+	}
+	sroot := spriteRoot(root, name)
+
+	switch args[1] {
+	case "list":
+		st, err := readState(root)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "sprite-env:", err)
+			return 1
+		}
+		services := []sprite.Service{}
+		if s, ok := st.Sprites[name]; ok {
+			services = append(services, s.Services...)
+		}
+		json.NewEncoder(os.Stdout).Encode(services)
+		return 0
+
+	case "create":
+		svc, err := parseServiceCreate(args[2:])
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "sprite-env:", err)
+			return 2
+		}
+		// Store paths as the sprite sees them, not as rewritten for the fake.
+		svc.Cmd = strings.ReplaceAll(svc.Cmd, sroot, "")
+		svc.Dir = strings.ReplaceAll(svc.Dir, sroot, "")
+		err = updateState(root, func(st *state) error {
+			s, ok := st.Sprites[name]
+			if !ok {
+				return fmt.Errorf("sprite not found: %s", name)
+			}
+			for _, existing := range s.Services {
+				if existing.Name == svc.Name {
+					return fmt.Errorf("service %q already exists", svc.Name)
+				}
+			}
+			st.Seq++
+			svc.State = &sprite.ServiceState{Status: "running", PID: 1000 + st.Seq}
+			s.Services = append(s.Services, *svc)
+			logPath := filepath.Join(sroot, ".sprite", "logs", "services", svc.Name+".log")
+			return os.WriteFile(logPath, []byte("started "+svc.Cmd+"\n"), 0o644)
+		})
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "sprite-env:", err)
+			return 1
+		}
+		return 0
+
+	case "delete":
+		if len(args) != 3 {
+			fmt.Fprintln(os.Stderr, "sprite-env: services delete takes a name")
+			return 2
+		}
+		err := updateState(root, func(st *state) error {
+			s, ok := st.Sprites[name]
+			if !ok {
+				return fmt.Errorf("sprite not found: %s", name)
+			}
+			for i, existing := range s.Services {
+				if existing.Name == args[2] {
+					s.Services = append(s.Services[:i], s.Services[i+1:]...)
+					return nil
+				}
+			}
+			return fmt.Errorf("service %q not found", args[2])
+		})
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "sprite-env:", err)
+			return 1
+		}
+		return 0
+	}
+	fmt.Fprintf(os.Stderr, "sprite-env: unknown services command %q\n", args[1])
+	return 2
+}
+
+// parseServiceCreate parses `services create NAME --cmd X [--args a,b]
+// [--dir D] [--http-port N] [--duration D]`.
+func parseServiceCreate(args []string) (*sprite.Service, error) {
+	if len(args) == 0 || strings.HasPrefix(args[0], "-") {
+		return nil, fmt.Errorf("services create needs a name")
+	}
+	svc := &sprite.Service{Name: args[0]}
+	for i := 1; i < len(args); i++ {
+		if i+1 >= len(args) {
+			return nil, fmt.Errorf("flag needs an argument: %s", args[i])
+		}
+		flag, value := args[i], args[i+1]
+		i++
+		switch flag {
+		case "--cmd":
+			svc.Cmd = value
+		case "--args":
+			svc.Args = strings.Split(value, ",")
+		case "--dir":
+			svc.Dir = value
+		case "--http-port":
+			port, err := strconv.Atoi(value)
+			if err != nil {
+				return nil, fmt.Errorf("invalid --http-port %q", value)
+			}
+			svc.HTTPPort = port
+		case "--duration":
+			if _, err := time.ParseDuration(value); err != nil {
+				return nil, fmt.Errorf("invalid --duration %q", value)
+			}
+		default:
+			return nil, fmt.Errorf("unknown flag: %s", flag)
+		}
+	}
+	if svc.Cmd == "" {
+		return nil, fmt.Errorf("--cmd is required")
+	}
+	return svc, nil
+}
+
+// runSudo drops sudo's options and runs the command as-is.
+func runSudo(args []string) int {
+	for len(args) > 0 && strings.HasPrefix(args[0], "-") {
+		if args[0] == "-u" && len(args) > 1 {
+			args = args[1:]
+		}
+		args = args[1:]
+	}
+	if len(args) == 0 {
+		return 0
+	}
+	path, err := exec.LookPath(args[0])
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "sudo: %s: command not found\n", args[0])
+		return 1
+	}
+	err = syscall.Exec(path, args, os.Environ())
+	fmt.Fprintf(os.Stderr, "sudo: %v\n", err)
+	return 1
+}
+
+// runSS reports sshd listening, which is what the SSH setup script checks
+// for after "restarting" it.
+func runSS(args []string) int {
+	fmt.Println("State  Recv-Q Send-Q Local Address:Port Peer Address:Port Process")
+	fmt.Println(`LISTEN 0      128    0.0.0.0:22         0.0.0.0:*         users:(("sshd",pid=1,fd=3))`)
+	return 0
+}
+
+// runOffline stands in for network tools on a fake sprite, so setup steps
+// that download things fail fast instead of reaching the internet.
+func runOffline(args []string) int {
+	fmt.Fprintf(os.Stderr, "%s: network access is disabled on fake sprites\n", filepath.Base(os.Args[0]))
+	return 7
+}
+
+func runNoop(args []string) int { return 0 }
@@ -0,0 +1,561 @@
+package fakebin
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"os/exec"
+	"os/signal"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/jphenow/sp/internal/sprite"
+)
+
+// cliArgs is a parsed `sprite` command line.
+type cliArgs struct {
+	flags map[string]string // org, sprite, dir, env, comment
+	bools map[string]bool   // tty, detach, force, skip-console
+	files []string          // --file local:remote, in order
+	pos   []string          // positional args before --
+	cmd   []string          // everything after --
+}
+
+// spriteValueFlags maps the value-taking flags sp passes to their names.
+var spriteValueFlags = map[string]string{
+	"-o": "org", "--org": "org",
+	"-s": "sprite", "--sprite": "sprite",
+	"--dir": "dir", "--env": "env", "--comment": "comment",
+}
+
+// parseSpriteArgs parses flags the way the sprite CLI accepts them, except
+// that unknown flags are errors so a changed call site fails loudly.
+func parseSpriteArgs(args []string) (*cliArgs, error) {
+	a := &cliArgs{flags: make(map[string]string), bools: make(map[string]bool)}
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		if arg == "--" {
+			a.cmd = args[i+1:]
+			break
+		}
+		if !strings.HasPrefix(arg, "-") {
+			a.pos = append(a.pos, arg)
+			continue
+		}
+		flag, value, hasValue := strings.Cut(arg, "=")
+		if flag == "--file" || spriteValueFlags[flag] != "" {
+			if !hasValue {
+				if i+1 >= len(args) {
+					return nil, fmt.Errorf("flag needs an argument: %s", flag)
+				}
+				i++
+				value = args[i]
+			}
+			if flag == "--file" {
+				a.files = append(a.files, value)
+			} else {
+				a.flags[spriteValueFlags[flag]] = value
+			}
+			continue
+		}
+		switch strings.TrimLeft(flag, "-") {
+		case "tty", "detach", "force", "skip-console":
+			a.bools[strings.TrimLeft(flag, "-")] = true
+		default:
+			return nil, fmt.Errorf("unknown flag: %s", flag)
+		}
+	}
+	return a, nil
+}
+
+// runSprite is the fake `sprite` CLI.
+func runSprite(args []string) int {
+	root, err := fakeRoot()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Error:", err)
+		return 1
+	}
+	if code, ok := begin(root, append([]string{"sprite"}, args...)); !ok {
+		return code
+	}
+	if len(args) == 0 {
+		fmt.Fprintln(os.Stderr, "Error: missing command")
+		return 2
+	}
+	a, err := parseSpriteArgs(args[1:])
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Error:", err)
+		return 2
+	}
+
+	switch args[0] {
+	case "api":
+		return spriteAPI(root, a)
+	case "create":
+		return spriteCreate(root, a)
+	case "destroy":
+		return spriteDestroy(root, a)
+	case "url":
+		return withSprite(root, a.flags["sprite"], func(s *fakeSprite) int {
+			fmt.Println(s.info().URL)
+			return 0
+		})
+	case "use":
+		return 0
+	case "sessions":
+		// No TTY sessions survive in the fake, so the list is always empty.
+		return withSprite(root, a.flags["sprite"], func(*fakeSprite) int { return 0 })
+	case "checkpoint":
+		return spriteCheckpoint(root, a)
+	case "restore":
+		return spriteRestore(root, a)
+	case "exec":
+		return spriteExec(root, a)
+	case "proxy":
+		return spriteProxy(root, a)
+	}
+	fmt.Fprintf(os.Stderr, "Error: unknown command %q for fake sprite\n", args[0])
+	return 2
+}
+
+// withSprite runs fn on a snapshot of the named sprite, failing like the CLI
+// when it doesn't exist.
+func withSprite(root, name string, fn func(*fakeSprite) int) int {
+	st, err := readState(root)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Error:", err)
+		return 1
+	}
+	s, ok := st.Sprites[name]
+	if !ok {
+		fmt.Fprintf(os.Stderr, "Error: sprite not found: %s\n", name)
+		return 1
+	}
+	return fn(s)
+}
+
+// spriteAPI answers the handful of REST paths sp reads through `sprite api`.
+// Like the real command it prints API errors as a JSON body and exits 0.
+func spriteAPI(root string, a *cliArgs) int {
+	if len(a.pos) != 1 {
+		fmt.Fprintln(os.Stderr, "Error: api takes exactly one path")
+		return 2
+	}
+	path, _, _ := strings.Cut(a.pos[0], "?")
+	st, err := readState(root)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Error:", err)
+		return 1
+	}
+	name := a.flags["sprite"]
+	s := st.Sprites[name]
+
+	var body any
+	switch {
+	case name == "" && path == "/sprites":
+		resp := sprite.ListResponse{Sprites: []sprite.Info{}}
+		for _, s := range st.Sprites {
+			resp.Sprites = append(resp.Sprites, s.info())
+		}
+		sort.Slice(resp.Sprites, func(i, j int) bool { return resp.Sprites[i].Name < resp.Sprites[j].Name })
+		body = resp
+	case name != "" && s == nil:
+		body = map[string]string{"error": "sprite not found"}
+	case path == "/":
+		body = s.info()
+	case path == "/checkpoints":
+		body = append([]sprite.Checkpoint{}, s.Checkpoints...)
+	default:
+		body = map[string]string{"error": "not found"}
+	}
+	json.NewEncoder(os.Stdout).Encode(body)
+	return 0
+}
+
+func spriteCreate(root string, a *cliArgs) int {
+	if len(a.pos) != 1 {
+		fmt.Fprintln(os.Stderr, "Error: create takes a sprite name")
+		return 2
+	}
+	name := a.pos[0]
+	err := updateState(root, func(st *state) error {
+		if _, ok := st.Sprites[name]; ok {
+			return fmt.Errorf("sprite %q already exists", name)
+		}
+		return createSprite(root, st, name, a.flags["org"])
+	})
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Error:", err)
+		return 1
+	}
+	fmt.Printf("Created sprite %s\n", name)
+	return 0
+}
+
+func spriteDestroy(root string, a *cliArgs) int {
+	if len(a.pos) != 1 {
+		fmt.Fprintln(os.Stderr, "Error: destroy takes a sprite name")
+		return 2
+	}
+	if !a.bools["force"] {
+		// The real CLI prompts on the tty here, which hangs a caller that
+		// captures its output; fail instead so tests catch it.
+		fmt.Fprintln(os.Stderr, "Error: destroy without --force would prompt for confirmation")
+		return 1
+	}
+	name := a.pos[0]
+	err := updateState(root, func(st *state) error {
+		if _, ok := st.Sprites[name]; !ok {
+			return fmt.Errorf("sprite not found: %s", name)
+		}
+		delete(st.Sprites, name)
+		return os.RemoveAll(spriteRoot(root, name))
+	})
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Error:", err)
+		return 1
+	}
+	fmt.Printf("Destroyed sprite %s\n", name)
+	return 0
+}
+
+// spriteCheckpoint snapshots the sprite's directory.
+func spriteCheckpoint(root string, a *cliArgs) int {
+	if len(a.pos) != 1 || a.pos[0] != "create" {
+		fmt.Fprintln(os.Stderr, "Error: only checkpoint create is supported")
+		return 2
+	}
+	name := a.flags["sprite"]
+	var id string
+	err := updateState(root, func(st *state) error {
+		s, ok := st.Sprites[name]
+		if !ok {
+			return fmt.Errorf("sprite not found: %s", name)
+		}
+		id = fmt.Sprintf("v%d", len(s.Checkpoints)+1)
+		if err := copyTree(spriteRoot(root, name), filepath.Join(root, "checkpoints", name, id)); err != nil {
+			return err
+		}
+		s.Checkpoints = append(s.Checkpoints, sprite.Checkpoint{
+			ID:         id,
+			CreateTime: time.Now().UTC(),
+			Comment:    a.flags["comment"],
+		})
+		return nil
+	})
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Error:", err)
+		return 1
+	}
+	fmt.Printf("Checkpoint %s created\n", id)
+	return 0
+}
+
+// spriteRestore replaces the sprite's directory with a checkpoint's copy.
+func spriteRestore(root string, a *cliArgs) int {
+	if len(a.pos) != 1 {
+		fmt.Fprintln(os.Stderr, "Error: restore takes a checkpoint ID")
+		return 2
+	}
+	name, id := a.flags["sprite"], a.pos[0]
+	err := updateState(root, func(st *state) error {
+		s, ok := st.Sprites[name]
+		if !ok {
+			return fmt.Errorf("sprite not found: %s", name)
+		}
+		found := false
+		for _, cp := range s.Checkpoints {
+			found = found || cp.ID == id
+		}
+		if !found {
+			return fmt.Errorf("checkpoint %s not found", id)
+		}
+		if err := os.RemoveAll(spriteRoot(root, name)); err != nil {
+			return err
+		}
+		return copyTree(filepath.Join(root, "checkpoints", name, id), spriteRoot(root, name))
+	})
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Error:", err)
+		return 1
+	}
+	fmt.Printf("Restored %s to %s\n", name, id)
+	return 0
+}
+
+// wake marks a sprite running, as any exec or proxy against it does.
+func wake(root, name string) error {
+	return updateState(root, func(st *state) error {
+		s, ok := st.Sprites[name]
+		if !ok {
+			return fmt.Errorf("sprite not found: %s", name)
+		}
+		if s.Status != "running" {
+			s.Status = "running"
+			s.UpdatedAt = time.Now().UTC()
+		}
+		return nil
+	})
+}
+
+// spriteExec uploads any --file arguments and runs the command in the
+// sprite's directory. The command replaces this process, so its exit status
+// and signals behave as they would through the real CLI.
+func spriteExec(root string, a *cliArgs) int {
+	name := a.flags["sprite"]
+	if err := wake(root, name); err != nil {
+		fmt.Fprintln(os.Stderr, "Error:", err)
+		return 1
+	}
+	sroot := spriteRoot(root, name)
+	for _, f := range a.files {
+		local, remote, ok := strings.Cut(f, ":")
+		if !ok {
+			fmt.Fprintf(os.Stderr, "Error: invalid --file %q\n", f)
+			return 2
+		}
+		if err := copyFile(local, filepath.Join(sroot, remote)); err != nil {
+			fmt.Fprintln(os.Stderr, "Error: uploading file:", err)
+			return 1
+		}
+	}
+	if len(a.cmd) == 0 {
+		return 0
+	}
+
+	dir := filepath.Join(sroot, "home", "sprite")
+	if a.flags["dir"] != "" {
+		dir = filepath.Join(sroot, a.flags["dir"])
+	}
+	var env []string
+	if a.flags["env"] != "" {
+		env = strings.Split(a.flags["env"], ",")
+	}
+	argv := make([]string, len(a.cmd))
+	for i, arg := range a.cmd {
+		argv[i] = rewritePaths(sroot, arg)
+	}
+
+	if a.bools["detach"] {
+		path, env, err := prepareRemote(name, sroot, argv[0], env)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 127
+		}
+		cmd := exec.Command(path, argv[1:]...)
+		cmd.Dir, cmd.Env = dir, env
+		cmd.SysProcAttr = &syscall.SysProcAttr{Setsid: true}
+		if err := cmd.Start(); err != nil {
+			fmt.Fprintln(os.Stderr, "Error:", err)
+			return 1
+		}
+		return 0
+	}
+	return remoteExec(name, sroot, dir, argv, env)
+}
+
+// remotePathRe matches absolute paths sp uses on a sprite that must land
+// inside the fake sprite's directory rather than on this machine.
+var remotePathRe = regexp.MustCompile(`(^|[\s'"=:;|&(<>])(/home/sprite|/tmp|/etc|/usr/local|/var/log|/run|/\.sprite)\b`)
+
+// rewritePaths prefixes sprite paths in s (a path, argument or whole shell
+// script) with the sprite's directory.
+func rewritePaths(sroot, s string) string {
+	return remotePathRe.ReplaceAllStringFunc(s, func(m string) string {
+		i := strings.IndexByte(m, '/')
+		return m[:i] + sroot + m[i:]
+	})
+}
+
+// prepareRemote resolves a command against the sprite's PATH and builds the
+// environment it runs with: the sprite's HOME and user, the remote shims
+// ahead of the original PATH, and any extra VAR=value pairs.
+func prepareRemote(name, sroot, command string, extra []string) (string, []string, error) {
+	pathVar := filepath.Join(os.Getenv(envRoot), "remote-bin") + string(os.PathListSeparator) + os.Getenv(envPath)
+	var env []string
+	for _, kv := range os.Environ() {
+		switch k, _, _ := strings.Cut(kv, "="); k {
+		case "PATH", "HOME", "USER", "PWD", envSprite:
+		default:
+			env = append(env, kv)
+		}
+	}
+	env = append(env,
+		"PATH="+pathVar,
+		"HOME="+filepath.Join(sroot, "home", "sprite"),
+		"USER=sprite",
+		envSprite+"="+name,
+	)
+	env = append(env, extra...)
+
+	os.Setenv("PATH", pathVar)
+	path, err := exec.LookPath(command)
+	if err != nil {
+		return "", nil, fmt.Errorf("sh: 1: %s: not found", command)
+	}
+	return path, env, nil
+}
+
+// remoteExec replaces this process with argv run on the sprite. It only
+// returns on failure.
+func remoteExec(name, sroot, dir string, argv, extra []string) int {
+	path, env, err := prepareRemote(name, sroot, argv[0], extra)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 127
+	}
+	if err := os.Chdir(dir); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: changing to %s: %v\n", dir, err)
+		return 1
+	}
+	err = syscall.Exec(path, argv, env)
+	fmt.Fprintf(os.Stderr, "Error: exec %s: %v\n", argv[0], err)
+	return 126
+}
+
+// spriteProxy forwards nothing: it listens on the local port and tells each
+// connection which sprite it reached, which is all the fake ssh and lsof
+// need. Like the real proxy it exits when the sprite stops running.
+func spriteProxy(root string, a *cliArgs) int {
+	name := a.flags["sprite"]
+	if len(a.pos) == 0 {
+		fmt.Fprintln(os.Stderr, "Error: proxy needs a LOCAL:REMOTE port mapping")
+		return 2
+	}
+	local, _, _ := strings.Cut(a.pos[0], ":")
+	port, err := strconv.Atoi(local)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: invalid port mapping %q\n", a.pos[0])
+		return 2
+	}
+	if err := wake(root, name); err != nil {
+		fmt.Fprintln(os.Stderr, "Error:", err)
+		return 1
+	}
+
+	ln, err := net.Listen("tcp", fmt.Sprintf("127.0.0.1:%d", port))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Error:", err)
+		return 1
+	}
+	defer ln.Close()
+	pid := os.Getpid()
+	updateState(root, func(st *state) error {
+		st.Proxies[port] = pid
+		return nil
+	})
+	defer updateState(root, func(st *state) error {
+		if st.Proxies[port] == pid {
+			delete(st.Proxies, port)
+		}
+		return nil
+	})
+
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			io.WriteString(conn, name+"\n")
+			conn.Close()
+		}
+	}()
+
+	sigCh := make(chan os.Signal, 1)
+	signal.Notify(sigCh, syscall.SIGTERM, syscall.SIGINT)
+	ticker := time.NewTicker(200 * time.Millisecond)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-sigCh:
+			return 0
+		case <-ticker.C:
+			st, err := readState(root)
+			if err != nil {
+				continue
+			}
+			s, ok := st.Sprites[name]
+			if !ok {
+				fmt.Fprintf(os.Stderr, "Error: sprite not found: %s\n", name)
+				return 1
+			}
+			if s.Status != "running" {
+				fmt.Fprintf(os.Stderr, "Error: connection closed: sprite %s is %s\n", name, s.Status)
+				return 1
+			}
+		}
+	}
+}
+
+// copyFile copies a regular file, creating the destination's parents.
+func copyFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	fi, err := in.Stat()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(dst), 0o755); err != nil {
+		return err
+	}
+	out, err := os.OpenFile(dst, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, fi.Mode().Perm())
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
+
+// copyTree copies src over dst: directories, regular files and symlinks.
+// Files in dst that aren't in src are left alone. skip, if non-nil, prunes
+// entries by their slash-separated path relative to src.
+func copyTree(src, dst string, skip ...func(rel string) bool) error {
+	return filepath.WalkDir(src, func(path string, d os.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+		if rel != "." {
+			for _, s := range skip {
+				if s(filepath.ToSlash(rel)) {
+					if d.IsDir() {
+						return filepath.SkipDir
+					}
+					return nil
+				}
+			}
+		}
+		target := filepath.Join(dst, rel)
+		switch {
+		case d.IsDir():
+			return os.MkdirAll(target, 0o755)
+		case d.Type()&os.ModeSymlink != 0:
+			link, err := os.Readlink(path)
+			if err != nil {
+				return err
+			}
+			os.Remove(target)
+			return os.Symlink(link, target)
+		case d.Type().IsRegular():
+			return copyFile(path, target)
+		}
+		return nil
+	})
+}
@@ -0,0 +1,206 @@
+// Package retry provides the shared retry and backoff policy for sprite
+// operations: exponential backoff with jitter, bounded by attempts and/or
+// total elapsed time, cancellable via context, with per-call classification
+// of which errors are worth retrying.
+package retry
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"math/rand/v2"
+	"time"
+)
+
+// Policy describes how an operation is retried. The zero value makes a
+// single attempt.
+type Policy struct {
+	InitialDelay time.Duration // delay after the first failure
+	MaxDelay     time.Duration // cap on any single delay (0 = uncapped)
+	Multiplier   float64       // growth factor per failure (<1 is treated as 1)
+	Jitter       float64       // ± fraction of each delay randomized, 0..1
+	MaxAttempts  int           // total attempts including the first (0 = no limit)
+	MaxElapsed   time.Duration // give up once this much time has passed (0 = no limit)
+
+	// Retryable reports whether an error is worth another attempt. nil
+	// retries every error. Errors wrapped with Permanent are never retried.
+	Retryable func(error) bool
+
+	// OnRetry, if set, is called before sleeping with the 1-based number of
+	// the attempt that just failed, its error and the upcoming delay.
+	OnRetry func(attempt int, err error, delay time.Duration)
+}
+
+// Default policies for sprite operations. They live here so the whole
+// retry behaviour of sp can be tuned in one place; callers copy a policy
+// and set Retryable/OnRetry as needed.
+var (
+	// API covers idempotent Sprites API reads (list, get, url).
+	API = Policy{
+		InitialDelay: 500 * time.Millisecond,
+		MaxDelay:     5 * time.Second,
+		Multiplier:   2,
+		Jitter:       0.2,
+		MaxAttempts:  4,
+	}
+
+	// SpriteReady covers waiting for a freshly created or waking sprite to
+	// answer a trivial exec.
+	SpriteReady = Policy{
+		InitialDelay: 1 * time.Second,
+		MaxDelay:     5 * time.Second,
+		Multiplier:   1.5,
+		Jitter:       0.2,
+		MaxElapsed:   60 * time.Second,
+	}
+
+	// SyncSetup covers retries of the full wake → SSH → proxy → Mutagen
+	// pipeline, which mostly fails when the proxy dies as a sprite cycles
+	// warm/cold.
+	SyncSetup = Policy{
+		InitialDelay: 2 * time.Second,
+		MaxDelay:     10 * time.Second,
+		Multiplier:   2,
+		Jitter:       0.2,
+		MaxAttempts:  3,
+	}
+
+	// Webhook covers delivering an event to a webhook URL, riding out a
+	// receiver that's briefly down or rate limiting.
+	Webhook = Policy{
+		InitialDelay: 1 * time.Second,
+		MaxDelay:     30 * time.Second,
+		Multiplier:   2,
+		Jitter:       0.2,
+		MaxAttempts:  5,
+	}
+
+	// HealthCheck spaces out the health monitor's per-sprite checks after
+	// consecutive failures. Only its delay schedule is used (see Backoff).
+	HealthCheck = Policy{
+		InitialDelay: 5 * time.Second,
+		MaxDelay:     2 * time.Minute,
+		Multiplier:   2,
+		Jitter:       0.1,
+	}
+)
+
+// permanentError marks an error that must not be retried.
+type permanentError struct{ err error }
+
+func (e *permanentError) Error() string { return e.err.Error() }
+func (e *permanentError) Unwrap() error { return e.err }
+
+// Permanent wraps err so Do returns it immediately regardless of the
+// policy's Retryable func. Do unwraps it before returning.
+func Permanent(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &permanentError{err: err}
+}
+
+// Delay returns the un-jittered delay that follows the given failed attempt
+// (1-based).
+func (p Policy) Delay(attempt int) time.Duration {
+	if attempt < 1 {
+		attempt = 1
+	}
+	mult := p.Multiplier
+	if mult < 1 {
+		mult = 1
+	}
+	d := float64(p.InitialDelay) * math.Pow(mult, float64(attempt-1))
+	if p.MaxDelay > 0 && d > float64(p.MaxDelay) {
+		d = float64(p.MaxDelay)
+	}
+	return time.Duration(d)
+}
+
+// jittered applies the policy's jitter to d.
+func (p Policy) jittered(d time.Duration) time.Duration {
+	if p.Jitter <= 0 || d <= 0 {
+		return d
+	}
+	j := min(p.Jitter, 1)
+	return time.Duration(float64(d) * (1 - j + 2*j*rand.Float64()))
+}
+
+// Do calls fn until it succeeds, returns a non-retryable error, or the
+// policy's attempt/elapsed limits are reached, sleeping between attempts.
+// The last error is returned unwrapped so callers can still match it with
+// errors.Is. If ctx is cancelled while waiting, the context error is
+// returned wrapping the last attempt's error.
+func Do(ctx context.Context, p Policy, fn func(ctx context.Context) error) error {
+	start := time.Now()
+	for attempt := 1; ; attempt++ {
+		err := fn(ctx)
+		if err == nil {
+			return nil
+		}
+
+		var perm *permanentError
+		if errors.As(err, &perm) {
+			return perm.err
+		}
+		if ctx.Err() != nil {
+			return err
+		}
+		if p.Retryable != nil && !p.Retryable(err) {
+			return err
+		}
+		if p.MaxAttempts > 0 && attempt >= p.MaxAttempts {
+			return err
+		}
+
+		delay := p.jittered(p.Delay(attempt))
+		if p.MaxElapsed > 0 && time.Since(start)+delay > p.MaxElapsed {
+			return err
+		}
+		if p.OnRetry != nil {
+			p.OnRetry(attempt, err, delay)
+		}
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return fmt.Errorf("%w (last error: %w)", ctx.Err(), err)
+		case <-timer.C:
+		}
+	}
+}
+
+// Backoff tracks consecutive failures of a recurring operation (such as a
+// periodic health check) and when it may next run, using a Policy's delay
+// schedule. The zero value is not usable; create one with NewBackoff.
+type Backoff struct {
+	policy   Policy
+	failures int
+	next     time.Time
+}
+
+// NewBackoff returns a Backoff following p's delay schedule.
+func NewBackoff(p Policy) *Backoff {
+	return &Backoff{policy: p}
+}
+
+// Failure records a failure at now and returns the delay before the
+// operation should run again.
+func (b *Backoff) Failure(now time.Time) time.Duration {
+	b.failures++
+	delay := b.policy.jittered(b.policy.Delay(b.failures))
+	b.next = now.Add(delay)
+	return delay
+}
+
+// Ready reports whether the backoff period has elapsed at now.
+func (b *Backoff) Ready(now time.Time) bool {
+	return !now.Before(b.next)
+}
+
+// Failures returns the number of consecutive failures recorded.
+func (b *Backoff) Failures() int {
+	return b.failures
+}
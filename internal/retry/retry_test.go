@@ -0,0 +1,151 @@
+package retry
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// fast is a policy with tiny delays so tests don't sleep noticeably.
+var fast = Policy{InitialDelay: time.Millisecond, MaxDelay: 2 * time.Millisecond, Multiplier: 2}
+
+func TestDoSucceedsAfterRetries(t *testing.T) {
+	calls := 0
+	err := Do(context.Background(), fast, func(ctx context.Context) error {
+		calls++
+		if calls < 3 {
+			return errors.New("flaky")
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Do: %v", err)
+	}
+	if calls != 3 {
+		t.Errorf("calls = %d, want 3", calls)
+	}
+}
+
+func TestDoMaxAttempts(t *testing.T) {
+	p := fast
+	p.MaxAttempts = 4
+	calls := 0
+	sentinel := errors.New("boom")
+	err := Do(context.Background(), p, func(ctx context.Context) error {
+		calls++
+		return sentinel
+	})
+	if !errors.Is(err, sentinel) {
+		t.Errorf("err = %v, want sentinel", err)
+	}
+	if calls != 4 {
+		t.Errorf("calls = %d, want 4", calls)
+	}
+}
+
+func TestDoRetryableAndPermanent(t *testing.T) {
+	transient := errors.New("transient")
+	fatal := errors.New("fatal")
+
+	tests := []struct {
+		name      string
+		errs      []error
+		wantCalls int
+		wantErr   error
+	}{
+		{"non-retryable stops", []error{fatal}, 1, fatal},
+		{"retryable then non-retryable", []error{transient, fatal}, 2, fatal},
+		{"permanent stops", []error{Permanent(transient)}, 1, transient},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := fast
+			p.Retryable = func(err error) bool { return errors.Is(err, transient) }
+			calls := 0
+			err := Do(context.Background(), p, func(ctx context.Context) error {
+				e := tt.errs[min(calls, len(tt.errs)-1)]
+				calls++
+				return e
+			})
+			if calls != tt.wantCalls {
+				t.Errorf("calls = %d, want %d", calls, tt.wantCalls)
+			}
+			if err != tt.wantErr {
+				t.Errorf("err = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestDoMaxElapsed(t *testing.T) {
+	p := Policy{InitialDelay: 20 * time.Millisecond, MaxElapsed: 50 * time.Millisecond}
+	calls := 0
+	start := time.Now()
+	Do(context.Background(), p, func(ctx context.Context) error {
+		calls++
+		return errors.New("nope")
+	})
+	if elapsed := time.Since(start); elapsed > 200*time.Millisecond {
+		t.Errorf("elapsed = %v, want under MaxElapsed-ish", elapsed)
+	}
+	if calls < 2 || calls > 3 {
+		t.Errorf("calls = %d, want 2 or 3", calls)
+	}
+}
+
+func TestDoContextCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	p := Policy{InitialDelay: time.Hour}
+	sentinel := errors.New("boom")
+	p.OnRetry = func(int, error, time.Duration) { cancel() }
+
+	err := Do(ctx, p, func(ctx context.Context) error { return sentinel })
+	if !errors.Is(err, context.Canceled) || !errors.Is(err, sentinel) {
+		t.Errorf("err = %v, want context.Canceled wrapping sentinel", err)
+	}
+}
+
+func TestPolicyDelay(t *testing.T) {
+	p := Policy{InitialDelay: time.Second, MaxDelay: 5 * time.Second, Multiplier: 2}
+	want := []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 5 * time.Second, 5 * time.Second}
+	for i, w := range want {
+		if got := p.Delay(i + 1); got != w {
+			t.Errorf("Delay(%d) = %v, want %v", i+1, got, w)
+		}
+	}
+}
+
+func TestJitterBounds(t *testing.T) {
+	p := Policy{Jitter: 0.5}
+	for range 100 {
+		d := p.jittered(time.Second)
+		if d < 500*time.Millisecond || d > 1500*time.Millisecond {
+			t.Fatalf("jittered = %v, want within ±50%% of 1s", d)
+		}
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	b := NewBackoff(Policy{InitialDelay: 5 * time.Second, MaxDelay: 20 * time.Second, Multiplier: 2})
+	now := time.Now()
+	if !b.Ready(now) {
+		t.Error("new Backoff not ready")
+	}
+	if d := b.Failure(now); d != 5*time.Second {
+		t.Errorf("first delay = %v, want 5s", d)
+	}
+	if b.Ready(now.Add(4 * time.Second)) {
+		t.Error("Ready before delay elapsed")
+	}
+	if !b.Ready(now.Add(5 * time.Second)) {
+		t.Error("not Ready after delay elapsed")
+	}
+	b.Failure(now)
+	if d := b.Failure(now); d != 20*time.Second {
+		t.Errorf("third delay = %v, want 20s (capped)", d)
+	}
+	if b.Failures() != 3 {
+		t.Errorf("Failures = %d, want 3", b.Failures())
+	}
+}
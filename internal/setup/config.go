@@ -2,10 +2,14 @@ package setup
 
 import (
 	"bufio"
+	"context"
+	"errors"
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
 	"strings"
+	"time"
 
 	"github.com/jphenow/sp/internal/sprite"
 )
@@ -149,31 +153,49 @@ func expandRemoteHome(path string) string {
 	return path
 }
 
-// RunSetupConf executes a parsed setup.conf against a sprite.
-func RunSetupConf(client *sprite.Client, spriteName string, conf *SetupConf) error {
+// Timeouts for individual setup.conf steps, so a hung `sprite exec` can't
+// wedge the connect spinner or a daemon goroutine forever.
+const (
+	setupFileTimeout    = 2 * time.Minute
+	setupCommandTimeout = 10 * time.Minute
+)
+
+// RunSetupConf executes a parsed setup.conf against a sprite. A failed step
+// doesn't stop the run; the failures are returned together once it's done,
+// so callers can warn and record them. Cancelling ctx (e.g. Ctrl-C) aborts
+// the remaining steps, and the error then also matches ctx.Err(). When
+// output is non-nil, each command's stdout/stderr is streamed to it live.
+func RunSetupConf(ctx context.Context, client *sprite.Client, spriteName string, conf *SetupConf, output io.Writer) error {
 	if conf == nil {
 		return nil
 	}
+	var errs []error
 
 	// Process files
 	for _, f := range conf.Files {
-		if err := copySetupFile(client, spriteName, f); err != nil {
-			fmt.Fprintf(os.Stderr, "Warning: failed to copy %s: %v\n", f.Source, err)
+		if ctx.Err() != nil {
+			break
+		}
+		if err := copySetupFile(ctx, client, spriteName, f); err != nil && ctx.Err() == nil {
+			errs = append(errs, fmt.Errorf("copying %s: %w", f.Source, err))
 		}
 	}
 
 	// Process commands
 	for _, c := range conf.Commands {
-		if err := runSetupCommand(client, spriteName, c); err != nil {
-			fmt.Fprintf(os.Stderr, "Warning: failed to run command: %v\n", err)
+		if ctx.Err() != nil {
+			break
+		}
+		if err := runSetupCommand(ctx, client, spriteName, c, output); err != nil && ctx.Err() == nil {
+			errs = append(errs, err)
 		}
 	}
 
-	return nil
+	return errors.Join(append(errs, ctx.Err())...)
 }
 
 // copySetupFile uploads a single file to the sprite, respecting the mode.
-func copySetupFile(client *sprite.Client, spriteName string, entry FileEntry) error {
+func copySetupFile(ctx context.Context, client *sprite.Client, spriteName string, entry FileEntry) error {
 	// Check if source exists locally
 	if _, err := os.Stat(entry.Source); err != nil {
 		return fmt.Errorf("source file %q not found: %w", entry.Source, err)
@@ -189,9 +211,10 @@ func copySetupFile(client *sprite.Client, spriteName string, entry FileEntry) er
 		localMtime := localInfo.ModTime().Unix()
 
 		// Get remote mtime
-		out, err := client.Exec(sprite.ExecOptions{
+		out, err := client.ExecContext(ctx, sprite.ExecOptions{
 			Sprite:  spriteName,
 			Command: []string{"stat", "-c", "%Y", entry.Dest},
+			Timeout: setupFileTimeout,
 		})
 		if err == nil {
 			remoteMtime := strings.TrimSpace(string(out))
@@ -209,18 +232,20 @@ func copySetupFile(client *sprite.Client, spriteName string, entry FileEntry) er
 
 	// Ensure remote directory exists
 	remoteDir := filepath.Dir(entry.Dest)
-	if _, err := client.Exec(sprite.ExecOptions{
+	if _, err := client.ExecContext(ctx, sprite.ExecOptions{
 		Sprite:  spriteName,
 		Command: []string{"mkdir", "-p", remoteDir},
+		Timeout: setupFileTimeout,
 	}); err != nil {
 		return fmt.Errorf("creating remote directory %q: %w", remoteDir, err)
 	}
 
 	// Upload the file
-	if _, err := client.Exec(sprite.ExecOptions{
+	if _, err := client.ExecContext(ctx, sprite.ExecOptions{
 		Sprite:  spriteName,
 		Command: []string{"true"},
 		Files:   map[string]string{entry.Source: entry.Dest},
+		Timeout: setupFileTimeout,
 	}); err != nil {
 		return fmt.Errorf("uploading %q to %q: %w", entry.Source, entry.Dest, err)
 	}
@@ -228,9 +253,10 @@ func copySetupFile(client *sprite.Client, spriteName string, entry FileEntry) er
 	// Preserve executable bit
 	localInfo, err := os.Stat(entry.Source)
 	if err == nil && localInfo.Mode()&0o111 != 0 {
-		if _, err := client.Exec(sprite.ExecOptions{
+		if _, err := client.ExecContext(ctx, sprite.ExecOptions{
 			Sprite:  spriteName,
 			Command: []string{"chmod", "+x", entry.Dest},
+			Timeout: setupFileTimeout,
 		}); err != nil {
 			return fmt.Errorf("setting executable bit on %q: %w", entry.Dest, err)
 		}
@@ -239,8 +265,9 @@ func copySetupFile(client *sprite.Client, spriteName string, entry FileEntry) er
 	return nil
 }
 
-// runSetupCommand executes a conditional command on the sprite.
-func runSetupCommand(client *sprite.Client, spriteName string, entry CommandEntry) error {
+// runSetupCommand executes a conditional command on the sprite. When output
+// is non-nil the command's output is streamed to it as it runs.
+func runSetupCommand(ctx context.Context, client *sprite.Client, spriteName string, entry CommandEntry, output io.Writer) error {
 	// Check condition if present
 	if entry.Condition != "" {
 		conditionCmd := entry.Condition
@@ -252,10 +279,14 @@ func runSetupCommand(client *sprite.Client, spriteName string, entry CommandEntr
 			conditionCmd = strings.TrimPrefix(conditionCmd, "! ")
 		}
 
-		_, err := client.Exec(sprite.ExecOptions{
+		_, err := client.ExecContext(ctx, sprite.ExecOptions{
 			Sprite:  spriteName,
 			Command: []string{"sh", "-c", conditionCmd},
+			Timeout: setupFileTimeout,
 		})
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
 		conditionMet := err == nil
 		if negate {
 			conditionMet = !conditionMet
@@ -267,13 +298,24 @@ func runSetupCommand(client *sprite.Client, spriteName string, entry CommandEntr
 	}
 
 	// Run the command
-	if _, err := client.Exec(sprite.ExecOptions{
+	opts := sprite.ExecOptions{
 		Sprite:  spriteName,
 		Command: []string{"sh", "-c", entry.Command},
-	}); err != nil {
+		Timeout: setupCommandTimeout,
+	}
+	if output == nil {
+		if _, err := client.ExecContext(ctx, opts); err != nil {
+			return fmt.Errorf("running command %q: %w", entry.Command, err)
+		}
+		return nil
+	}
+	code, err := client.ExecStreamTo(ctx, opts, output, output)
+	if err != nil {
 		return fmt.Errorf("running command %q: %w", entry.Command, err)
 	}
-
+	if code != 0 {
+		return fmt.Errorf("running command %q: exit status %d", entry.Command, code)
+	}
 	return nil
 }
 
@@ -1,11 +1,18 @@
 package setup
 
 import (
+	"context"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
+
+	"github.com/jphenow/sp/internal/fakebin"
+	"github.com/jphenow/sp/internal/sprite"
 )
 
+func TestMain(m *testing.M) { fakebin.Main(m) }
+
 func TestParseSetupConf(t *testing.T) {
 	dir := t.TempDir()
 	confPath := filepath.Join(dir, "setup.conf")
@@ -159,3 +166,40 @@ func TestExpandRemoteHome(t *testing.T) {
 		}
 	}
 }
+
+func TestRunSetupConfReportsFailures(t *testing.T) {
+	env := fakebin.New(t)
+	env.AddSprite("alpha", "running")
+	dir := t.TempDir()
+	present := filepath.Join(dir, "gitconfig")
+	os.WriteFile(present, []byte("[user]\n"), 0o644)
+	missing := filepath.Join(dir, "missing")
+
+	conf := &SetupConf{
+		Files: []FileEntry{
+			{Source: present, Dest: "/home/sprite/.gitconfig", Mode: "always"},
+			{Source: missing, Dest: "/home/sprite/.missing", Mode: "always"},
+		},
+		Commands: []CommandEntry{
+			{Command: "exit 3"},
+			{Command: "touch /home/sprite/ran"},
+			{Condition: "false", Command: "exit 4"},
+		},
+	}
+	err := RunSetupConf(context.Background(), sprite.NewClient(""), "alpha", conf, nil)
+	if err == nil {
+		t.Fatal("RunSetupConf = nil, want the failed steps")
+	}
+	for _, want := range []string{"copying " + missing, `running command "exit 3"`} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("error = %q, want it to contain %q", err, want)
+		}
+	}
+	if strings.Contains(err.Error(), "exit 4") || strings.Contains(err.Error(), present) {
+		t.Errorf("error = %q, want only the failed steps", err)
+	}
+	// A failed step doesn't stop the ones after it.
+	if _, err := os.Stat(env.Path("alpha", "/home/sprite/ran")); err != nil {
+		t.Errorf("command after a failure didn't run: %v", err)
+	}
+}
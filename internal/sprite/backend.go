@@ -0,0 +1,129 @@
+package sprite
+
+import (
+	"context"
+	"log/slog"
+	"os"
+	"strings"
+	"sync"
+)
+
+// Backend is the transport a Client uses to talk to the Sprites platform.
+// The CLI backend forks the `sprite` binary for every call; the HTTP backend
+// speaks the REST API directly, which is much cheaper for hot paths like the
+// daemon's health poller and lets tests substitute an httptest server.
+type Backend interface {
+	// ListPage returns one page of sprites visible to the caller. Callers
+	// normally use Client.List or Client.All, which walk every page.
+	ListPage(opts ListOptions) (*ListResponse, error)
+	// Get returns a single sprite's info by name.
+	Get(name string) (*Info, error)
+	// Create creates a sprite without waiting for it to become ready.
+	Create(name string) error
+	// Destroy deletes a sprite without any confirmation prompt.
+	Destroy(name string) error
+	// GetURL returns the sprite's public URL.
+	GetURL(name string) (string, error)
+	// CreateCheckpoint snapshots a sprite, returning the new checkpoint's
+	// ID once it is complete, or "" if the platform didn't report it.
+	CreateCheckpoint(name, comment string) (string, error)
+	// ListCheckpoints returns a sprite's checkpoints.
+	ListCheckpoints(name string) ([]Checkpoint, error)
+	// RestoreCheckpoint rolls a sprite back to a checkpoint, returning once
+	// the restore is complete.
+	RestoreCheckpoint(name, id string) error
+	// ExecContext runs a non-interactive command and returns its combined
+	// output, killing it if ctx is done first.
+	ExecContext(ctx context.Context, opts ExecOptions) ([]byte, error)
+	// ExecStream starts a non-interactive command and returns its separate
+	// output streams and a way to wait for its exit code.
+	ExecStream(ctx context.Context, opts ExecOptions) (*ExecProcess, error)
+}
+
+// Backend kinds accepted by Config.Backend.
+const (
+	BackendCLI  = "cli"
+	BackendHTTP = "http"
+)
+
+// Environment variables read by ConfigFromEnv. Each overrides the
+// corresponding setting given to SetConfig.
+const (
+	EnvBackend = "SP_SPRITE_BACKEND" // "cli" (default) or "http"
+	EnvAPIURL  = "SPRITES_API_URL"   // override for the REST API base URL
+	EnvToken   = "SPRITES_TOKEN"     // API token used by the HTTP backend
+)
+
+// DefaultAPIURL is the base URL of the Sprites REST API.
+const DefaultAPIURL = "https://api.sprites.dev"
+
+// Config selects and configures the backend used by NewClient.
+type Config struct {
+	Backend string // BackendCLI or BackendHTTP; empty means BackendCLI
+	APIURL  string // REST API base URL; empty means DefaultAPIURL
+	Token   string // API token; required for BackendHTTP
+}
+
+// baseConfig is the configuration set with SetConfig, before the
+// environment is applied.
+var (
+	baseConfigMu sync.RWMutex
+	baseConfig   Config
+)
+
+// SetConfig sets the backend configuration NewClient starts from, normally
+// the [sprite] table of sp's config.toml. The environment still overrides it.
+func SetConfig(cfg Config) {
+	baseConfigMu.Lock()
+	defer baseConfigMu.Unlock()
+	baseConfig = cfg
+}
+
+// ConfigFromEnv returns the configuration set with SetConfig, with the
+// SP_SPRITE_BACKEND, SPRITES_API_URL and SPRITES_TOKEN environment
+// variables, where set, applied over it.
+func ConfigFromEnv() Config {
+	baseConfigMu.RLock()
+	cfg := baseConfig
+	baseConfigMu.RUnlock()
+	if v := os.Getenv(EnvBackend); v != "" {
+		cfg.Backend = v
+	}
+	if v := os.Getenv(EnvAPIURL); v != "" {
+		cfg.APIURL = v
+	}
+	if v := os.Getenv(EnvToken); v != "" {
+		cfg.Token = v
+	}
+	cfg.Backend = strings.ToLower(strings.TrimSpace(cfg.Backend))
+	return cfg
+}
+
+// newBackend constructs the backend described by cfg. The HTTP backend needs
+// a token, and its token is scoped to one organization, so it can't serve a
+// client asked for a specific org. In either case we stay on the CLI, which
+// carries its own login and selects orgs with -o, and log why, once per
+// process, so a misconfigured SP_SPRITE_BACKEND doesn't go unnoticed.
+func newBackend(org string, cfg Config) Backend {
+	cli := &cliBackend{org: org}
+	if cfg.Backend != BackendHTTP {
+		return cli
+	}
+	if cfg.Token == "" {
+		warnNoToken.Do(func() {
+			slog.Warn("sprite: HTTP backend requested without "+EnvToken+"; using the sprite CLI", "backend", cfg.Backend)
+		})
+		return cli
+	}
+	if org != "" {
+		warnOrg.Do(func() {
+			slog.Warn("sprite: the HTTP backend can't select an organization; using the sprite CLI for org requests", "org", org)
+		})
+		return cli
+	}
+	return NewHTTPBackend(cfg.APIURL, cfg.Token, cli)
+}
+
+// warnNoToken and warnOrg make newBackend's fallback warnings one-shot;
+// clients are created per call, so they'd otherwise repeat on every request.
+var warnNoToken, warnOrg sync.Once
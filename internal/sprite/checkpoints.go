@@ -0,0 +1,82 @@
+package sprite
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+)
+
+// CreateCheckpoint snapshots a sprite and returns the new checkpoint,
+// looked up by the ID the platform reports for it. Should a backend not
+// report one, it is the checkpoint that wasn't there before, as long as
+// exactly one appeared; the newest isn't necessarily ours when checkpoints
+// are being made concurrently.
+func (c *Client) CreateCheckpoint(name, comment string) (*Checkpoint, error) {
+	before, err := c.ListCheckpoints(name)
+	if err != nil {
+		return nil, err
+	}
+	id, err := c.backend.CreateCheckpoint(name, comment)
+	if err != nil {
+		return nil, err
+	}
+	after, err := c.ListCheckpoints(name)
+	if err != nil {
+		return nil, err
+	}
+
+	if id != "" {
+		for i := range after {
+			if after[i].ID == id {
+				return &after[i], nil
+			}
+		}
+		return nil, fmt.Errorf("checkpointing sprite %q: checkpoint %s not found after create", name, id)
+	}
+	var created []Checkpoint
+	for _, cp := range after {
+		if !slices.ContainsFunc(before, func(b Checkpoint) bool { return b.ID == cp.ID }) {
+			created = append(created, cp)
+		}
+	}
+	if len(created) != 1 {
+		return nil, fmt.Errorf("checkpointing sprite %q: %d new checkpoints after create, can't tell which is ours", name, len(created))
+	}
+	return &created[0], nil
+}
+
+// checkpointIDPattern finds a checkpoint ID, e.g. "v3", in a message about
+// the checkpoint such as "Checkpoint v3 created".
+var checkpointIDPattern = regexp.MustCompile(`(?i)checkpoint\s+"?(v[0-9]+)\b`)
+
+// checkpointIDIn returns the checkpoint ID a create message reports, or "".
+func checkpointIDIn(text string) string {
+	if m := checkpointIDPattern.FindStringSubmatch(text); m != nil {
+		return m[1]
+	}
+	return ""
+}
+
+// ListCheckpoints returns a sprite's checkpoints, newest first.
+func (c *Client) ListCheckpoints(name string) ([]Checkpoint, error) {
+	var checkpoints []Checkpoint
+	err := c.read(func() error {
+		var err error
+		checkpoints, err = c.backend.ListCheckpoints(name)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	slices.SortStableFunc(checkpoints, func(a, b Checkpoint) int {
+		return b.CreateTime.Compare(a.CreateTime)
+	})
+	return checkpoints, nil
+}
+
+// RestoreCheckpoint rolls a sprite back to the checkpoint with the given ID.
+// Everything written since the checkpoint is lost and running processes are
+// restarted.
+func (c *Client) RestoreCheckpoint(name, id string) error {
+	return c.backend.RestoreCheckpoint(name, id)
+}
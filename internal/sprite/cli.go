@@ -0,0 +1,295 @@
+package sprite
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// cliBackend implements Backend by shelling out to the `sprite` CLI and
+// parsing its output. It relies on the CLI's own stored login.
+type cliBackend struct {
+	org string // default organization
+}
+
+// ListPage returns one page of sprites from the Sprites API.
+func (b *cliBackend) ListPage(opts ListOptions) (*ListResponse, error) {
+	args := []string{"api"}
+	if b.org != "" {
+		args = append(args, "-o", b.org)
+	}
+	path := "/sprites"
+	if q := opts.query().Encode(); q != "" {
+		path += "?" + q
+	}
+	args = append(args, path)
+
+	out, err := exec.Command("sprite", args...).Output()
+	if err != nil {
+		return nil, fmt.Errorf("listing sprites: %w", withKind(classifyOutput(err, nil, false), err))
+	}
+
+	var resp ListResponse
+	if err := json.Unmarshal(out, &resp); err != nil {
+		return nil, fmt.Errorf("parsing sprite list: %w", err)
+	}
+	return &resp, nil
+}
+
+// Get returns a single sprite's info by name from the Sprites API.
+func (b *cliBackend) Get(name string) (*Info, error) {
+	args := []string{"api"}
+	if b.org != "" {
+		args = append(args, "-o", b.org)
+	}
+	args = append(args, "-s", name, "/")
+
+	out, err := exec.Command("sprite", args...).Output()
+	if err != nil {
+		return nil, fmt.Errorf("getting sprite %q: %w", name, withKind(classifyOutput(err, out, false), err))
+	}
+
+	// `sprite api` prints the response body even for API errors, so a
+	// missing sprite can come back as {"error": "..."} or an empty/null body
+	// that deserialises into a zero-value Info.
+	var info struct {
+		Info
+		Error string `json:"error"`
+	}
+	if err := json.Unmarshal(out, &info); err != nil && len(bytes.TrimSpace(out)) > 0 {
+		return nil, fmt.Errorf("parsing sprite info: %w", err)
+	}
+	if info.Error != "" {
+		apiErr := errors.New(info.Error)
+		kind := classifyOutput(nil, []byte(info.Error), false)
+		if kind == nil {
+			kind = ErrNotFound
+		}
+		return nil, fmt.Errorf("getting sprite %q: %w", name, withKind(kind, apiErr))
+	}
+	if info.ID == "" {
+		return nil, fmt.Errorf("getting sprite %q: %w", name, ErrNotFound)
+	}
+	return &info.Info, nil
+}
+
+// Create creates a new sprite with the given name.
+func (b *cliBackend) Create(name string) error {
+	args := []string{"create", "-skip-console"}
+	if b.org != "" {
+		args = append(args, "-o", b.org)
+	}
+	args = append(args, name)
+
+	cmd := exec.Command("sprite", args...)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("creating sprite %q: %w\n%s", name, withKind(classifyOutput(err, out, false), err), string(out))
+	}
+	return nil
+}
+
+// Destroy destroys a sprite by name. Always passes --force: without it,
+// sprite destroy blocks on a tty prompt that CombinedOutput never answers,
+// so the caller hangs indefinitely.
+func (b *cliBackend) Destroy(name string) error {
+	args := []string{"destroy", "--force"}
+	if b.org != "" {
+		args = append(args, "-o", b.org)
+	}
+	args = append(args, name)
+
+	cmd := exec.Command("sprite", args...)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("destroying sprite %q: %w\n%s", name, withKind(classifyOutput(err, out, false), err), string(out))
+	}
+	return nil
+}
+
+// GetURL returns the public URL for a sprite.
+func (b *cliBackend) GetURL(name string) (string, error) {
+	args := []string{"url"}
+	if b.org != "" {
+		args = append(args, "-o", b.org)
+	}
+	args = append(args, "-s", name)
+
+	out, err := exec.Command("sprite", args...).Output()
+	if err != nil {
+		return "", fmt.Errorf("getting URL for sprite %q: %w", name, withKind(classifyOutput(err, out, false), err))
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+// CreateCheckpoint snapshots a sprite with `sprite checkpoint create`,
+// which reports the new checkpoint's ID in its output.
+func (b *cliBackend) CreateCheckpoint(name, comment string) (string, error) {
+	args := []string{"checkpoint", "create"}
+	if b.org != "" {
+		args = append(args, "-o", b.org)
+	}
+	args = append(args, "-s", name)
+	if comment != "" {
+		args = append(args, "--comment", comment)
+	}
+
+	out, err := exec.Command("sprite", args...).CombinedOutput()
+	if err != nil {
+		return "", fmt.Errorf("checkpointing sprite %q: %w\n%s", name, withKind(classifyOutput(err, out, false), err), string(out))
+	}
+	return checkpointIDIn(string(out)), nil
+}
+
+// ListCheckpoints returns a sprite's checkpoints from the Sprites API.
+func (b *cliBackend) ListCheckpoints(name string) ([]Checkpoint, error) {
+	args := []string{"api"}
+	if b.org != "" {
+		args = append(args, "-o", b.org)
+	}
+	args = append(args, "-s", name, "/checkpoints")
+
+	out, err := exec.Command("sprite", args...).Output()
+	if err != nil {
+		return nil, fmt.Errorf("listing checkpoints for sprite %q: %w", name, withKind(classifyOutput(err, out, false), err))
+	}
+
+	var checkpoints []Checkpoint
+	if err := json.Unmarshal(out, &checkpoints); err != nil {
+		// Errors come back as {"error": "..."} with a zero exit status.
+		var apiErr struct {
+			Error string `json:"error"`
+		}
+		if json.Unmarshal(out, &apiErr) == nil && apiErr.Error != "" {
+			e := errors.New(apiErr.Error)
+			return nil, fmt.Errorf("listing checkpoints for sprite %q: %w", name, withKind(classifyOutput(nil, out, false), e))
+		}
+		return nil, fmt.Errorf("parsing checkpoints: %w", err)
+	}
+	return checkpoints, nil
+}
+
+// RestoreCheckpoint rolls a sprite back with `sprite restore`.
+func (b *cliBackend) RestoreCheckpoint(name, id string) error {
+	args := []string{"restore"}
+	if b.org != "" {
+		args = append(args, "-o", b.org)
+	}
+	args = append(args, "-s", name, id)
+
+	out, err := exec.Command("sprite", args...).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("restoring sprite %q to %s: %w\n%s", name, id, withKind(classifyOutput(err, out, false), err), string(out))
+	}
+	return nil
+}
+
+// execWaitDelay bounds how long Wait lingers for output after a cancelled
+// exec is killed; the sprite CLI can leave children holding its pipes.
+const execWaitDelay = 2 * time.Second
+
+// ExecContext runs a command on a sprite and returns its combined output.
+func (b *cliBackend) ExecContext(ctx context.Context, opts ExecOptions) ([]byte, error) {
+	args := buildExecArgs(b.org, opts)
+	cmd := exec.CommandContext(ctx, "sprite", args...)
+	cmd.WaitDelay = execWaitDelay
+	out, err := cmd.CombinedOutput()
+	if ctx.Err() != nil {
+		return out, fmt.Errorf("exec on sprite %q: %w\n%s", opts.Sprite, ctx.Err(), string(out))
+	}
+	if err != nil {
+		return out, fmt.Errorf("exec on sprite %q: %w\n%s", opts.Sprite, withKind(classifyOutput(err, out, true), err), string(out))
+	}
+	return out, nil
+}
+
+// ExecStream starts a command on a sprite with stdout and stderr piped
+// separately. The sprite CLI exits with the remote command's status, which
+// Wait reports as the exit code.
+func (b *cliBackend) ExecStream(ctx context.Context, opts ExecOptions) (*ExecProcess, error) {
+	args := buildExecArgs(b.org, opts)
+	cmd := exec.CommandContext(ctx, "sprite", args...)
+	cmd.WaitDelay = execWaitDelay
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, fmt.Errorf("exec on sprite %q: %w", opts.Sprite, err)
+	}
+	stderr, err := cmd.StderrPipe()
+	if err != nil {
+		return nil, fmt.Errorf("exec on sprite %q: %w", opts.Sprite, err)
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("exec on sprite %q: %w", opts.Sprite, err)
+	}
+
+	return &ExecProcess{
+		Stdout: stdout,
+		Stderr: stderr,
+		wait: func() (int, error) {
+			err := cmd.Wait()
+			if ctx.Err() != nil {
+				return -1, fmt.Errorf("exec on sprite %q: %w", opts.Sprite, ctx.Err())
+			}
+			var exitErr *exec.ExitError
+			if errors.As(err, &exitErr) {
+				return exitErr.ExitCode(), nil
+			}
+			if err != nil {
+				return -1, fmt.Errorf("exec on sprite %q: %w", opts.Sprite, err)
+			}
+			return 0, nil
+		},
+	}, nil
+}
+
+// buildExecArgs constructs the argument list for sprite exec, falling back
+// to defaultOrg when opts.Org is empty.
+func buildExecArgs(defaultOrg string, opts ExecOptions) []string {
+	args := []string{"exec"}
+	org := opts.Org
+	if org == "" {
+		org = defaultOrg
+	}
+	if org != "" {
+		args = append(args, "-o", org)
+	}
+	if opts.Sprite != "" {
+		args = append(args, "-s", opts.Sprite)
+	}
+	if opts.TTY {
+		args = append(args, "--tty")
+	}
+	if opts.Dir != "" {
+		args = append(args, "--dir", opts.Dir)
+	}
+	if opts.Detach {
+		args = append(args, "-detach")
+	}
+	if len(opts.Env) > 0 {
+		var envParts []string
+		for k, v := range opts.Env {
+			envParts = append(envParts, k+"="+v)
+		}
+		args = append(args, "--env", strings.Join(envParts, ","))
+	}
+	// Multi-char flags must use the double-dash long form: the sprite CLI's
+	// flag parser treats a single-dash multi-char token as grouped
+	// shorthands (e.g. `-file` -> `-f -i -l -e`), which fails on the second
+	// occurrence with "unknown shorthand flag: 'f'". Only -o/-s are real
+	// single-char shorthands.
+	for local, remote := range opts.Files {
+		args = append(args, "--file", local+":"+remote)
+	}
+	// The sprite CLI requires a `--` separator before the command so that
+	// command flags (e.g. `sh -c`) aren't parsed as sprite's own flags.
+	// Without it, `sprite exec -s <name> sh -c '...'` fails with
+	// "unknown shorthand flag: 'c'" and exit status 2.
+	if len(opts.Command) > 0 {
+		args = append(args, "--")
+		args = append(args, opts.Command...)
+	}
+	return args
+}
@@ -2,107 +2,148 @@ package sprite
 
 import (
 	"bytes"
-	"encoding/json"
+	"context"
+	"errors"
 	"fmt"
+	"iter"
 	"os/exec"
-	"strings"
+
+	"github.com/jphenow/sp/internal/retry"
 )
 
-// Client provides access to the Sprites API and CLI.
+// Client provides access to the Sprites API and CLI. Core API operations go
+// through a Backend (CLI or HTTP); interactive exec, proxies, sessions and
+// `sprite use` always need the CLI binary.
 type Client struct {
-	org string // default organization
+	org      string // default organization
+	backend  Backend
+	pageSize int          // page size used by List; 0 for the API default
+	retry    retry.Policy // applied to idempotent API reads
 }
 
 // NewClient creates a new sprite client with an optional default organization.
+// The backend is selected by SetConfig and the environment (see ConfigFromEnv).
 func NewClient(org string) *Client {
-	return &Client{org: org}
+	return NewClientWithConfig(org, ConfigFromEnv())
+}
+
+// NewClientWithConfig creates a sprite client using the backend described by cfg.
+func NewClientWithConfig(org string, cfg Config) *Client {
+	return NewClientWithBackend(org, newBackend(org, cfg))
 }
 
-// List returns all sprites visible to the current user from the Sprites API.
+// NewClientWithBackend creates a sprite client that routes API operations
+// through the given backend. Mainly useful for tests.
+func NewClientWithBackend(org string, b Backend) *Client {
+	return &Client{org: org, backend: b, retry: retry.API}
+}
+
+// SetRetryPolicy replaces the policy used to retry idempotent API reads
+// (Get, List, GetURL). Only network and rate-limit failures are retried
+// regardless of the policy's own Retryable func.
+func (c *Client) SetRetryPolicy(p retry.Policy) {
+	c.retry = p
+}
+
+// read runs an idempotent API call under the client's retry policy,
+// retrying only failures that can clear up on their own.
+func (c *Client) read(fn func() error) error {
+	p := c.retry
+	p.Retryable = func(err error) bool {
+		return errors.Is(err, ErrNetwork) || errors.Is(err, ErrRateLimited)
+	}
+	return retry.Do(context.Background(), p, func(context.Context) error { return fn() })
+}
+
+// List returns all sprites visible to the current user from the Sprites API,
+// following continuation tokens until every page has been fetched.
 func (c *Client) List() ([]Info, error) {
-	args := []string{"api"}
-	if c.org != "" {
-		args = append(args, "-o", c.org)
+	var sprites []Info
+	for info, err := range c.All(c.pageSize) {
+		if err != nil {
+			return nil, err
+		}
+		sprites = append(sprites, info)
 	}
-	args = append(args, "/sprites")
+	return sprites, nil
+}
 
-	out, err := exec.Command("sprite", args...).Output()
-	if err != nil {
-		return nil, fmt.Errorf("listing sprites: %w", err)
-	}
+// ListPage returns a single page of sprites. Use the returned
+// NextContinuationToken in opts.ContinuationToken to fetch the next page.
+func (c *Client) ListPage(opts ListOptions) (*ListResponse, error) {
+	var page *ListResponse
+	err := c.read(func() (err error) {
+		page, err = c.backend.ListPage(opts)
+		return err
+	})
+	return page, err
+}
 
-	var resp ListResponse
-	if err := json.Unmarshal(out, &resp); err != nil {
-		return nil, fmt.Errorf("parsing sprite list: %w", err)
+// All iterates over every sprite visible to the current user, fetching pages
+// of pageSize (0 for the API default) lazily as the caller ranges. Iteration
+// stops after yielding the first error. A page that claims more results but
+// repeats or omits its continuation token is reported as an error rather
+// than looping forever.
+func (c *Client) All(pageSize int) iter.Seq2[Info, error] {
+	return func(yield func(Info, error) bool) {
+		opts := ListOptions{PageSize: pageSize}
+		for {
+			page, err := c.ListPage(opts)
+			if err != nil {
+				yield(Info{}, err)
+				return
+			}
+			for _, info := range page.Sprites {
+				if !yield(info, nil) {
+					return
+				}
+			}
+			if !page.HasMore {
+				return
+			}
+			if page.NextContinuationToken == nil || *page.NextContinuationToken == "" || *page.NextContinuationToken == opts.ContinuationToken {
+				yield(Info{}, fmt.Errorf("listing sprites: API reported more results without a new continuation token"))
+				return
+			}
+			opts.ContinuationToken = *page.NextContinuationToken
+		}
 	}
-	return resp.Sprites, nil
+}
+
+// SetPageSize sets the page size List uses when walking the sprite list.
+// 0 (the default) lets the API choose.
+func (c *Client) SetPageSize(n int) {
+	c.pageSize = n
 }
 
 // Get returns a single sprite's info by name from the Sprites API.
 func (c *Client) Get(name string) (*Info, error) {
-	args := []string{"api"}
-	if c.org != "" {
-		args = append(args, "-o", c.org)
-	}
-	args = append(args, "-s", name, "/")
-
-	out, err := exec.Command("sprite", args...).Output()
-	if err != nil {
-		return nil, fmt.Errorf("getting sprite %q: %w", name, err)
-	}
-
-	var info Info
-	if err := json.Unmarshal(out, &info); err != nil {
-		return nil, fmt.Errorf("parsing sprite info: %w", err)
-	}
-	return &info, nil
+	var info *Info
+	err := c.read(func() (err error) {
+		info, err = c.backend.Get(name)
+		return err
+	})
+	return info, err
 }
 
 // Create creates a new sprite with the given name. Returns once the sprite exists
 // but does not wait for it to be fully ready.
 func (c *Client) Create(name string) error {
-	args := []string{"create", "-skip-console"}
-	if c.org != "" {
-		args = append(args, "-o", c.org)
-	}
-	args = append(args, name)
-
-	cmd := exec.Command("sprite", args...)
-	if out, err := cmd.CombinedOutput(); err != nil {
-		return fmt.Errorf("creating sprite %q: %w\n%s", name, err, string(out))
-	}
-	return nil
+	return c.backend.Create(name)
 }
 
-// Destroy destroys a sprite by name. Always passes --force to skip the
-// interactive confirmation prompt — callers are expected to do their own
-// confirmation up-front (e.g. sp rm's safety rails, sp prune's dry-run).
-// Without --force, sprite destroy blocks on a tty prompt that CombinedOutput
-// never answers, so the caller hangs indefinitely.
+// Destroy destroys a sprite by name without prompting — callers are expected
+// to do their own confirmation up-front (e.g. sp rm's safety rails, sp prune's
+// dry-run).
 func (c *Client) Destroy(name string) error {
-	args := []string{"destroy", "--force"}
-	if c.org != "" {
-		args = append(args, "-o", c.org)
-	}
-	args = append(args, name)
-
-	cmd := exec.Command("sprite", args...)
-	if out, err := cmd.CombinedOutput(); err != nil {
-		return fmt.Errorf("destroying sprite %q: %w\n%s", name, err, string(out))
-	}
-	return nil
+	return c.backend.Destroy(name)
 }
 
 // Exec runs a command on a sprite and returns its combined output.
+// It is ExecContext without cancellation (opts.Timeout still applies).
 // For interactive (TTY) sessions, use ExecInteractive instead.
 func (c *Client) Exec(opts ExecOptions) ([]byte, error) {
-	args := c.BuildExecArgs(opts)
-	cmd := exec.Command("sprite", args...)
-	out, err := cmd.CombinedOutput()
-	if err != nil {
-		return out, fmt.Errorf("exec on sprite %q: %w\n%s", opts.Sprite, err, string(out))
-	}
-	return out, nil
+	return c.ExecContext(context.Background(), opts)
 }
 
 // ExecInteractive runs an interactive command on a sprite with TTY attached.
@@ -121,50 +162,7 @@ func (c *Client) ExecInteractive(opts ExecOptions) error {
 // BuildExecArgs constructs the argument list for sprite exec.
 // Exported for use by the connect command which needs the raw args for process replacement.
 func (c *Client) BuildExecArgs(opts ExecOptions) []string {
-	args := []string{"exec"}
-	org := opts.Org
-	if org == "" {
-		org = c.org
-	}
-	if org != "" {
-		args = append(args, "-o", org)
-	}
-	if opts.Sprite != "" {
-		args = append(args, "-s", opts.Sprite)
-	}
-	if opts.TTY {
-		args = append(args, "--tty")
-	}
-	if opts.Dir != "" {
-		args = append(args, "--dir", opts.Dir)
-	}
-	if opts.Detach {
-		args = append(args, "-detach")
-	}
-	if len(opts.Env) > 0 {
-		var envParts []string
-		for k, v := range opts.Env {
-			envParts = append(envParts, k+"="+v)
-		}
-		args = append(args, "--env", strings.Join(envParts, ","))
-	}
-	// Multi-char flags must use the double-dash long form: the sprite CLI's
-	// flag parser treats a single-dash multi-char token as grouped
-	// shorthands (e.g. `-file` -> `-f -i -l -e`), which fails on the second
-	// occurrence with "unknown shorthand flag: 'f'". Only -o/-s are real
-	// single-char shorthands.
-	for local, remote := range opts.Files {
-		args = append(args, "--file", local+":"+remote)
-	}
-	// The sprite CLI requires a `--` separator before the command so that
-	// command flags (e.g. `sh -c`) aren't parsed as sprite's own flags.
-	// Without it, `sprite exec -s <name> sh -c '...'` fails with
-	// "unknown shorthand flag: 'c'" and exit status 2.
-	if len(opts.Command) > 0 {
-		args = append(args, "--")
-		args = append(args, opts.Command...)
-	}
-	return args
+	return buildExecArgs(c.org, opts)
 }
 
 // StartProxy starts a sprite proxy for port forwarding and returns the command
@@ -206,40 +204,39 @@ func ProxyStderr(cmd *exec.Cmd) string {
 
 // GetURL returns the public URL for a sprite.
 func (c *Client) GetURL(name string) (string, error) {
-	args := []string{"url"}
-	if c.org != "" {
-		args = append(args, "-o", c.org)
-	}
-	args = append(args, "-s", name)
-
-	out, err := exec.Command("sprite", args...).Output()
-	if err != nil {
-		return "", fmt.Errorf("getting URL for sprite %q: %w", name, err)
-	}
-	return strings.TrimSpace(string(out)), nil
+	var url string
+	err := c.read(func() (err error) {
+		url, err = c.backend.GetURL(name)
+		return err
+	})
+	return url, err
 }
 
-// Exists checks if a sprite with the given name exists by attempting to get it.
-// Returns true only when the API returns a sprite with a non-empty ID.
+// Exists checks if a sprite with the given name exists. A definite
+// not-found answer returns (false, nil); network, auth and rate-limit
+// failures are returned as errors so callers never mistake an outage for a
+// missing sprite. Unclassified Get failures fall back to scanning the list.
 func (c *Client) Exists(name string) (bool, error) {
 	info, err := c.Get(name)
-	if err != nil {
-		// If we get an error, the sprite might not exist or there's a network issue.
-		// Check the sprite list as fallback.
-		sprites, listErr := c.List()
-		if listErr != nil {
-			return false, fmt.Errorf("checking sprite existence: %w", err)
-		}
-		for _, s := range sprites {
-			if s.Name == name {
-				return true, nil
-			}
-		}
+	switch {
+	case err == nil:
+		return info != nil && info.ID != "", nil
+	case errors.Is(err, ErrNotFound):
 		return false, nil
+	case errors.Is(err, ErrNetwork), errors.Is(err, ErrUnauthorized), errors.Is(err, ErrRateLimited):
+		return false, fmt.Errorf("checking sprite existence: %w", err)
+	}
+
+	sprites, listErr := c.List()
+	if listErr != nil {
+		return false, fmt.Errorf("checking sprite existence: %w", err)
+	}
+	for _, s := range sprites {
+		if s.Name == name {
+			return true, nil
+		}
 	}
-	// Guard against the API returning an empty/null JSON body that
-	// deserialises into a zero-value Info struct.
-	return info != nil && info.ID != "", nil
+	return false, nil
 }
 
 // Use associates the current directory with a sprite name (creates .sprite file).
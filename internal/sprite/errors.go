@@ -0,0 +1,203 @@
+package sprite
+
+import (
+	"errors"
+	"net/http"
+	"os/exec"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Sentinel errors for failures callers need to tell apart. Errors returned
+// by Client wrap one of these when the cause is recognizable, so callers
+// should test with errors.Is rather than matching message text.
+var (
+	ErrNotFound       = errors.New("sprite not found")
+	ErrUnauthorized   = errors.New("not authorized with the Sprites API")
+	ErrNetwork        = errors.New("cannot reach the Sprites API")
+	ErrRateLimited    = errors.New("rate limited by the Sprites API")
+	ErrSpriteNotReady = errors.New("sprite not ready")
+)
+
+// IsTransient reports whether err is a failure that may succeed if retried
+// later: network trouble, rate limiting, or a sprite that is still waking.
+func IsTransient(err error) bool {
+	return errors.Is(err, ErrNetwork) ||
+		errors.Is(err, ErrRateLimited) ||
+		errors.Is(err, ErrSpriteNotReady)
+}
+
+// IsPermanent reports whether err can't be fixed by waiting: the sprite
+// doesn't exist or the API rejected our credentials.
+func IsPermanent(err error) bool {
+	return errors.Is(err, ErrNotFound) || errors.Is(err, ErrUnauthorized)
+}
+
+// classifiedError attaches a sentinel kind to an underlying error without
+// changing its message.
+type classifiedError struct {
+	kind error
+	err  error
+}
+
+func (e *classifiedError) Error() string   { return e.err.Error() }
+func (e *classifiedError) Unwrap() []error { return []error{e.kind, e.err} }
+
+// withKind wraps err so errors.Is(err, kind) holds. A nil kind returns err
+// unchanged.
+func withKind(kind, err error) error {
+	if kind == nil || err == nil {
+		return err
+	}
+	return &classifiedError{kind: kind, err: err}
+}
+
+// ErrorForStatus maps an HTTP status code from the Sprites API to a sentinel
+// error, or nil when the status is not one of the recognized failures.
+// Server errors count as ErrNetwork since they are just as transient.
+func ErrorForStatus(code int) error {
+	switch {
+	case code == http.StatusNotFound:
+		return ErrNotFound
+	case code == http.StatusUnauthorized || code == http.StatusForbidden:
+		return ErrUnauthorized
+	case code == http.StatusTooManyRequests:
+		return ErrRateLimited
+	case code == http.StatusConflict:
+		// The API answers 409 to requests against a sprite still booting.
+		return ErrSpriteNotReady
+	case code >= 500:
+		return ErrNetwork
+	}
+	return nil
+}
+
+// outputPatterns maps lowercase phrases in `sprite` CLI output to the
+// sentinel they indicate. The first match wins, so specific phrases come
+// before generic ones. Patterns with inExec set are specific enough to the
+// CLI that they can be matched against exec output too; the others (bare
+// "not found", status codes) could just as well come from the remote
+// command, e.g. "sh: foo: not found". A phrase only matches as whole words,
+// so a sprite named e.g. "forbidden-api", which the output may echo, doesn't.
+var outputPatterns = []struct {
+	substr string
+	kind   error
+	inExec bool
+}{
+	{"sprite not found", ErrNotFound, true},
+	{"no such sprite", ErrNotFound, true},
+	{"not logged in", ErrUnauthorized, true},
+	{"sprite login", ErrUnauthorized, true},
+	{"sprite not ready", ErrSpriteNotReady, true},
+	{"sprite is starting", ErrSpriteNotReady, true},
+	{"dial tcp", ErrNetwork, true},
+	{"no such host", ErrNetwork, true},
+	{"tls handshake", ErrNetwork, true},
+	{"not found", ErrNotFound, false},
+	{"does not exist", ErrNotFound, false},
+	{"unauthorized", ErrUnauthorized, false},
+	{"forbidden", ErrUnauthorized, false},
+	{"too many requests", ErrRateLimited, false},
+	{"rate limit", ErrRateLimited, false},
+	{"not ready", ErrSpriteNotReady, false},
+	{"connection refused", ErrNetwork, false},
+	{"connection reset", ErrNetwork, false},
+	{"network is unreachable", ErrNetwork, false},
+	{"i/o timeout", ErrNetwork, false},
+	{"timed out", ErrNetwork, false},
+	{"deadline exceeded", ErrNetwork, false},
+	{"timeout exceeded", ErrNetwork, false},
+	{"bad gateway", ErrNetwork, false},
+	{"service unavailable", ErrNetwork, false},
+}
+
+// statusPattern finds an HTTP status code in CLI output. A bare number could
+// be part of a sprite name or ID ("api-403"), so only codes introduced as one
+// count: "status 404", "status code: 429", "HTTP 403", "HTTP/1.1 502",
+// "request failed: 404".
+var statusPattern = regexp.MustCompile(`(?:^|[^\w-])(?:status(?: code)?|http(?:/[0-9.]+)?|error|failed)[: ]+([1-5][0-9]{2})(?:[^\w-]|$)`)
+
+// reasonPattern finds a status code followed by text, to accept the
+// "404 Not Found" form when the text is the code's own reason phrase.
+var reasonPattern = regexp.MustCompile(`(?:^|[^\w.-])([1-5][0-9]{2}) ([a-z][a-z ]*)`)
+
+// statusInOutput returns the sentinel for the first HTTP status code CLI
+// output reports (see statusPattern and reasonPattern), or nil.
+func statusInOutput(text string) error {
+	for _, m := range statusPattern.FindAllStringSubmatch(text, -1) {
+		code, _ := strconv.Atoi(m[1])
+		if kind := ErrorForStatus(code); kind != nil {
+			return kind
+		}
+	}
+	for _, m := range reasonPattern.FindAllStringSubmatch(text, -1) {
+		code, _ := strconv.Atoi(m[1])
+		reason := strings.ToLower(http.StatusText(code))
+		if reason != "" && strings.HasPrefix(m[2], reason) {
+			if kind := ErrorForStatus(code); kind != nil {
+				return kind
+			}
+		}
+	}
+	return nil
+}
+
+// containsWord reports whether phrase occurs in text with no letter, digit,
+// '_' or '-' directly on either side.
+func containsWord(text, phrase string) bool {
+	for i := 0; ; {
+		j := strings.Index(text[i:], phrase)
+		if j < 0 {
+			return false
+		}
+		start, end := i+j, i+j+len(phrase)
+		if !isNameByte(text, start-1) && !isNameByte(text, end) {
+			return true
+		}
+		i = start + 1
+	}
+}
+
+// isNameByte reports whether text[i] could be part of a sprite name. Out of
+// range indexes aren't.
+func isNameByte(text string, i int) bool {
+	if i < 0 || i >= len(text) {
+		return false
+	}
+	c := text[i]
+	return c >= 'a' && c <= 'z' || c >= '0' && c <= '9' || c == '_' || c == '-'
+}
+
+// classifyOutput determines the sentinel kind for a failed `sprite` CLI
+// invocation from its output and, for *exec.ExitError, its captured stderr
+// (exec.Cmd.Output stores it there). remote restricts matching to patterns
+// that cannot come from a remote command's own output. Returns nil when
+// nothing matches.
+func classifyOutput(err error, output []byte, remote bool) error {
+	text := string(output)
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		text += "\n" + string(exitErr.Stderr)
+	}
+	text = strings.ToLower(text)
+	checkedStatus := false
+	for _, p := range outputPatterns {
+		if !p.inExec {
+			if remote {
+				continue
+			}
+			// A status code the CLI reports outranks the generic phrases.
+			if !checkedStatus {
+				if kind := statusInOutput(text); kind != nil {
+					return kind
+				}
+				checkedStatus = true
+			}
+		}
+		if containsWord(text, p.substr) {
+			return p.kind
+		}
+	}
+	return nil
+}
@@ -0,0 +1,87 @@
+package sprite
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func TestClassifyOutput(t *testing.T) {
+	tests := []struct {
+		name   string
+		output string
+		remote bool
+		want   error
+	}{
+		{"api not found", `Error: sprite not found`, false, ErrNotFound},
+		{"api 404", `request failed: 404`, false, ErrNotFound},
+		{"login", `Error: not logged in, run sprite login`, false, ErrUnauthorized},
+		{"rate limit", `429 Too Many Requests`, false, ErrRateLimited},
+		{"dns", `dial tcp: lookup api.sprites.dev: no such host`, false, ErrNetwork},
+		{"unknown", `something odd happened`, false, nil},
+		{"exec sprite missing", `Error: sprite not found`, true, ErrNotFound},
+		{"exec network", `dial tcp 1.2.3.4:443: connect: connection refused`, true, ErrNetwork},
+		{"exec remote not found", `sh: 1: foo: not found`, true, nil},
+		{"exec remote 404", `curl: (22) The requested URL returned error: 404`, true, nil},
+		{"status code", `Error: request failed with status 403`, false, ErrUnauthorized},
+		{"http status line", `HTTP/1.1 502 Bad Gateway`, false, ErrNetwork},
+		{"reason phrase", `404 Not Found`, false, ErrNotFound},
+		{"io timeout", `read tcp 10.0.0.1:5000: i/o timeout`, false, ErrNetwork},
+		{"name with code", `Error: sprite "api-403" not found`, false, ErrNotFound},
+		{"name with code refused", `Error: connecting to api-401: connection refused`, false, ErrNetwork},
+		{"name with code only", `Error: sprite api-429 failed to start`, false, nil},
+		{"id with code", `created sprite sp_4035 in org acme`, false, nil},
+		{"name with timeout", `Error: sprite "timeout-test" not found`, false, ErrNotFound},
+		{"name with timeout only", `sprite timeout-test is cold`, false, nil},
+		{"name ending in prefix", `Error: connecting to api-failed: 503`, false, nil},
+		{"name with word", `Error: sprite forbidden-api is cold`, false, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := classifyOutput(errors.New("exit status 1"), []byte(tt.output), tt.remote)
+			if got != tt.want {
+				t.Errorf("classifyOutput(%q) = %v, want %v", tt.output, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestErrorForStatus(t *testing.T) {
+	tests := []struct {
+		code int
+		want error
+	}{
+		{200, nil},
+		{400, nil},
+		{401, ErrUnauthorized},
+		{403, ErrUnauthorized},
+		{404, ErrNotFound},
+		{409, ErrSpriteNotReady},
+		{429, ErrRateLimited},
+		{500, ErrNetwork},
+		{503, ErrNetwork},
+	}
+	for _, tt := range tests {
+		if got := ErrorForStatus(tt.code); got != tt.want {
+			t.Errorf("ErrorForStatus(%d) = %v, want %v", tt.code, got, tt.want)
+		}
+	}
+}
+
+func TestWithKindPreservesMessageAndChain(t *testing.T) {
+	base := errors.New("exit status 1")
+	err := fmt.Errorf("getting sprite %q: %w", "x", withKind(ErrNotFound, base))
+
+	if !errors.Is(err, ErrNotFound) {
+		t.Error("errors.Is(err, ErrNotFound) = false, want true")
+	}
+	if !errors.Is(err, base) {
+		t.Error("errors.Is(err, base) = false, want true")
+	}
+	if want := `getting sprite "x": exit status 1`; err.Error() != want {
+		t.Errorf("Error() = %q, want %q", err.Error(), want)
+	}
+	if !IsTransient(withKind(ErrRateLimited, base)) || IsTransient(err) {
+		t.Error("IsTransient misclassified rate-limited or not-found errors")
+	}
+}
@@ -0,0 +1,103 @@
+package sprite
+
+import (
+	"context"
+	"io"
+	"sync"
+)
+
+// ExecProcess is a running non-interactive command started by ExecStream.
+// Stdout and Stderr must be drained (concurrently, since either can fill
+// its pipe) before calling Wait, which closes them.
+type ExecProcess struct {
+	Stdout io.Reader
+	Stderr io.Reader
+
+	wait func() (int, error)
+}
+
+// Wait blocks until the command finishes and returns the remote exit code.
+// A non-zero exit is not an error; err is only set when the command could
+// not be run to completion (context cancelled or timed out, transport
+// failure), in which case the exit code is -1.
+func (p *ExecProcess) Wait() (exitCode int, err error) {
+	return p.wait()
+}
+
+// ExecContext runs a command on a sprite and returns its combined output.
+// The command is killed when ctx is done or opts.Timeout elapses, and the
+// returned error then wraps ctx.Err() (context.DeadlineExceeded for
+// timeouts).
+func (c *Client) ExecContext(ctx context.Context, opts ExecOptions) ([]byte, error) {
+	if opts.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, opts.Timeout)
+		defer cancel()
+	}
+	return c.backend.ExecContext(ctx, opts)
+}
+
+// ExecStream starts a command on a sprite and returns its stdout and stderr
+// as separate streams, for callers that want to show output live. The
+// command is killed when ctx is done or opts.Timeout elapses.
+func (c *Client) ExecStream(ctx context.Context, opts ExecOptions) (*ExecProcess, error) {
+	cancel := context.CancelFunc(func() {})
+	if opts.Timeout > 0 {
+		ctx, cancel = context.WithTimeout(ctx, opts.Timeout)
+	}
+	proc, err := c.backend.ExecStream(ctx, opts)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	wait := proc.wait
+	proc.wait = func() (int, error) {
+		defer cancel()
+		return wait()
+	}
+	return proc, nil
+}
+
+// ExecStreamTo runs a command on a sprite, copying its stdout and stderr to
+// the given writers as output arrives (nil discards), and returns the remote
+// exit code. See ExecProcess.Wait for how errors and exit codes relate.
+//
+// The two streams are copied by separate goroutines, so the writers are
+// called concurrently; ExecStreamTo holds one lock around every write, which
+// makes passing the same writer (say, one buffer for combined output) as
+// both safe. A writer that other goroutines also use must still be safe for
+// concurrent use on its own.
+func (c *Client) ExecStreamTo(ctx context.Context, opts ExecOptions, stdout, stderr io.Writer) (int, error) {
+	proc, err := c.ExecStream(ctx, opts)
+	if err != nil {
+		return -1, err
+	}
+	if stdout == nil {
+		stdout = io.Discard
+	}
+	if stderr == nil {
+		stderr = io.Discard
+	}
+	var mu sync.Mutex
+	done := make(chan struct{})
+	go func() {
+		io.Copy(&lockedWriter{mu: &mu, w: stderr}, proc.Stderr)
+		close(done)
+	}()
+	io.Copy(&lockedWriter{mu: &mu, w: stdout}, proc.Stdout)
+	<-done
+	return proc.Wait()
+}
+
+// lockedWriter serializes writes to w with writes to every other
+// lockedWriter sharing mu.
+type lockedWriter struct {
+	mu *sync.Mutex
+	w  io.Writer
+}
+
+func (l *lockedWriter) Write(p []byte) (int, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.w.Write(p)
+}
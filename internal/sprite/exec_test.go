@@ -0,0 +1,127 @@
+package sprite
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// fakeSpriteCLI puts a `sprite` shell script on PATH that ignores its exec
+// flags and runs everything after `--` locally.
+func fakeSpriteCLI(t *testing.T) {
+	t.Helper()
+	dir := t.TempDir()
+	script := `#!/bin/sh
+while [ "$#" -gt 0 ] && [ "$1" != "--" ]; do shift; done
+shift
+exec "$@"
+`
+	if err := os.WriteFile(filepath.Join(dir, "sprite"), []byte(script), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+}
+
+func TestCLIExecStreamSeparatesOutput(t *testing.T) {
+	fakeSpriteCLI(t)
+	client := NewClientWithBackend("", &cliBackend{})
+
+	var stdout, stderr bytes.Buffer
+	code, err := client.ExecStreamTo(context.Background(), ExecOptions{
+		Sprite:  "alpha",
+		Command: []string{"sh", "-c", "echo out; echo err >&2; exit 3"},
+	}, &stdout, &stderr)
+	if err != nil {
+		t.Fatalf("ExecStreamTo: %v", err)
+	}
+	if code != 3 {
+		t.Errorf("exit code = %d, want 3", code)
+	}
+	if stdout.String() != "out\n" || stderr.String() != "err\n" {
+		t.Errorf("stdout = %q, stderr = %q; want %q, %q", stdout.String(), stderr.String(), "out\n", "err\n")
+	}
+}
+
+// TestCLIExecStreamSharedWriter passes one buffer as both stdout and
+// stderr, as callers collecting combined output do; run with -race to check
+// the two copies never write it at once.
+func TestCLIExecStreamSharedWriter(t *testing.T) {
+	fakeSpriteCLI(t)
+	client := NewClientWithBackend("", &cliBackend{})
+
+	var out bytes.Buffer
+	script := `i=0; while [ $i -lt 200 ]; do echo out; echo err >&2; i=$((i+1)); done`
+	code, err := client.ExecStreamTo(context.Background(), ExecOptions{
+		Sprite:  "alpha",
+		Command: []string{"sh", "-c", script},
+	}, &out, &out)
+	if err != nil {
+		t.Fatalf("ExecStreamTo: %v", err)
+	}
+	if code != 0 {
+		t.Errorf("exit code = %d, want 0", code)
+	}
+	if got, want := out.Len(), 200*len("out\n")+200*len("err\n"); got != want {
+		t.Errorf("combined output is %d bytes, want %d", got, want)
+	}
+}
+
+func TestCLIExecContextTimeout(t *testing.T) {
+	fakeSpriteCLI(t)
+	client := NewClientWithBackend("", &cliBackend{})
+
+	start := time.Now()
+	_, err := client.ExecContext(context.Background(), ExecOptions{
+		Sprite:  "alpha",
+		Command: []string{"sleep", "5"},
+		Timeout: 100 * time.Millisecond,
+	})
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("err = %v, want context.DeadlineExceeded", err)
+	}
+	if elapsed := time.Since(start); elapsed > 3*time.Second {
+		t.Errorf("ExecContext took %v, want it killed at the timeout", elapsed)
+	}
+}
+
+func TestCLIExecStreamCancel(t *testing.T) {
+	fakeSpriteCLI(t)
+	client := NewClientWithBackend("", &cliBackend{})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(100*time.Millisecond, cancel)
+	code, err := client.ExecStreamTo(ctx, ExecOptions{Sprite: "alpha", Command: []string{"sleep", "5"}}, nil, nil)
+	if !errors.Is(err, context.Canceled) || code != -1 {
+		t.Errorf("ExecStreamTo = %d, %v; want -1, context.Canceled", code, err)
+	}
+}
+
+func TestHTTPExecStream(t *testing.T) {
+	_, client := newFakeAPI(t)
+
+	var stdout, stderr bytes.Buffer
+	code, err := client.ExecStreamTo(context.Background(), ExecOptions{Sprite: "alpha", Command: []string{"false"}}, &stdout, &stderr)
+	if err != nil {
+		t.Fatalf("ExecStreamTo: %v", err)
+	}
+	if code != 1 || stdout.String() != "" || stderr.String() != "failed\n" {
+		t.Errorf("ExecStreamTo = %d, stdout %q, stderr %q; want 1, \"\", \"failed\\n\"", code, stdout.String(), stderr.String())
+	}
+}
+
+func TestHTTPExecContextTimeout(t *testing.T) {
+	_, client := newFakeAPI(t)
+
+	_, err := client.ExecContext(context.Background(), ExecOptions{
+		Sprite:  "alpha",
+		Command: []string{"sleep"},
+		Timeout: 50 * time.Millisecond,
+	})
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("err = %v, want context.DeadlineExceeded", err)
+	}
+}
@@ -0,0 +1,382 @@
+package sprite
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// apiTimeout bounds every REST call except exec, whose duration depends on
+// the command being run, and checkpoint operations.
+const apiTimeout = 30 * time.Second
+
+// checkpointTimeout bounds checkpoint create and restore, which copy the
+// sprite's whole filesystem.
+const checkpointTimeout = 10 * time.Minute
+
+// HTTPBackend implements Backend against the Sprites REST API. API tokens
+// are scoped to a single organization, so unlike the CLI backend there is no
+// per-call org selection; newBackend only picks it for clients without an org.
+type HTTPBackend struct {
+	baseURL  string
+	token    string
+	http     *http.Client
+	fallback Backend // handles exec features the REST endpoint lacks (file uploads, detach)
+}
+
+// NewHTTPBackend returns a REST backend for the API at baseURL (DefaultAPIURL
+// when empty), authenticating with token. fallback, which may be nil, is used
+// for exec calls that need file uploads or detached execution.
+func NewHTTPBackend(baseURL, token string, fallback Backend) *HTTPBackend {
+	if baseURL == "" {
+		baseURL = DefaultAPIURL
+	}
+	return &HTTPBackend{
+		baseURL:  strings.TrimRight(baseURL, "/"),
+		token:    token,
+		http:     &http.Client{},
+		fallback: fallback,
+	}
+}
+
+// apiError is returned for non-2xx API responses.
+type apiError struct {
+	StatusCode int
+	Message    string
+}
+
+// Unwrap exposes the sentinel matching the status code, so errors.Is works
+// on API failures.
+func (e *apiError) Unwrap() error {
+	return ErrorForStatus(e.StatusCode)
+}
+
+func (e *apiError) Error() string {
+	if e.Message == "" {
+		return fmt.Sprintf("sprites API: %s", http.StatusText(e.StatusCode))
+	}
+	return fmt.Sprintf("sprites API: %s: %s", http.StatusText(e.StatusCode), e.Message)
+}
+
+// do sends a request with the bearer token and decodes a JSON response into
+// out (when non-nil). Error bodies are surfaced as *apiError, preferring the
+// API's {"error": "..."} message when present.
+func (b *HTTPBackend) do(ctx context.Context, method, path string, body, out any) error {
+	var reqBody io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return fmt.Errorf("encoding request: %w", err)
+		}
+		reqBody = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, b.baseURL+path, reqBody)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+b.token)
+	req.Header.Set("Accept", "application/json")
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := b.http.Do(req)
+	if err != nil {
+		// Context cancellation is the caller's doing, not a network fault.
+		if ctx.Err() == context.Canceled {
+			return err
+		}
+		return withKind(ErrNetwork, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		data, _ := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+		var e struct {
+			Error string `json:"error"`
+		}
+		msg := strings.TrimSpace(string(data))
+		if json.Unmarshal(data, &e) == nil && e.Error != "" {
+			msg = e.Error
+		}
+		return &apiError{StatusCode: resp.StatusCode, Message: msg}
+	}
+
+	if out == nil {
+		return drainEvents(resp.Body, nil)
+	}
+	if events, ok := out.(*[]progressEvent); ok {
+		return drainEvents(resp.Body, events)
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("decoding response: %w", err)
+	}
+	return nil
+}
+
+// progressEvent is one line of a long-running endpoint's response.
+type progressEvent struct {
+	Type  string `json:"type"`
+	Data  string `json:"data"`
+	Error string `json:"error"`
+	ID    string `json:"id"` // set on some completion events
+}
+
+// drainEvents reads a response body to the end. Long-running endpoints
+// (checkpoint, restore) stream newline-delimited JSON progress events and
+// only finish once the operation does; an event of type "error" means it
+// failed even though the status was 200. Lines that aren't events, and
+// empty bodies, are ignored. Events are appended to events when it isn't
+// nil; passing a *[]progressEvent as do's out gets them.
+func drainEvents(body io.Reader, events *[]progressEvent) error {
+	scanner := bufio.NewScanner(body)
+	scanner.Buffer(make([]byte, 64<<10), 1<<20)
+	for scanner.Scan() {
+		var ev progressEvent
+		if json.Unmarshal(scanner.Bytes(), &ev) != nil {
+			continue
+		}
+		if events != nil {
+			*events = append(*events, ev)
+		}
+		if ev.Type == "error" || ev.Error != "" {
+			msg := ev.Error
+			if msg == "" {
+				msg = ev.Data
+			}
+			return fmt.Errorf("sprites API: %s", msg)
+		}
+	}
+	return scanner.Err()
+}
+
+// spritePath returns the API path for a named sprite, with the name escaped.
+func spritePath(name string) string {
+	return "/v1/sprites/" + url.PathEscape(name)
+}
+
+// ListPage returns one page of sprites visible to the token's organization.
+func (b *HTTPBackend) ListPage(opts ListOptions) (*ListResponse, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), apiTimeout)
+	defer cancel()
+
+	path := "/v1/sprites"
+	if q := opts.query().Encode(); q != "" {
+		path += "?" + q
+	}
+	var resp ListResponse
+	if err := b.do(ctx, http.MethodGet, path, nil, &resp); err != nil {
+		return nil, fmt.Errorf("listing sprites: %w", err)
+	}
+	return &resp, nil
+}
+
+// Get returns a single sprite's info by name.
+func (b *HTTPBackend) Get(name string) (*Info, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), apiTimeout)
+	defer cancel()
+
+	var info Info
+	if err := b.do(ctx, http.MethodGet, spritePath(name), nil, &info); err != nil {
+		return nil, fmt.Errorf("getting sprite %q: %w", name, err)
+	}
+	return &info, nil
+}
+
+// Create creates a new sprite with the given name.
+func (b *HTTPBackend) Create(name string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), apiTimeout)
+	defer cancel()
+
+	body := map[string]string{"name": name}
+	if err := b.do(ctx, http.MethodPost, "/v1/sprites", body, nil); err != nil {
+		return fmt.Errorf("creating sprite %q: %w", name, err)
+	}
+	return nil
+}
+
+// Destroy deletes a sprite by name.
+func (b *HTTPBackend) Destroy(name string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), apiTimeout)
+	defer cancel()
+
+	if err := b.do(ctx, http.MethodDelete, spritePath(name), nil, nil); err != nil {
+		return fmt.Errorf("destroying sprite %q: %w", name, err)
+	}
+	return nil
+}
+
+// GetURL returns the public URL for a sprite, read from its API record.
+func (b *HTTPBackend) GetURL(name string) (string, error) {
+	info, err := b.Get(name)
+	if err != nil {
+		return "", fmt.Errorf("getting URL for sprite %q: %w", name, err)
+	}
+	return info.URL, nil
+}
+
+// CreateCheckpoint snapshots a sprite and waits for the checkpoint to
+// complete, taking its ID from the progress events.
+func (b *HTTPBackend) CreateCheckpoint(name, comment string) (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), checkpointTimeout)
+	defer cancel()
+
+	var events []progressEvent
+	body := map[string]string{"comment": comment}
+	if err := b.do(ctx, http.MethodPost, spritePath(name)+"/checkpoint", body, &events); err != nil {
+		return "", fmt.Errorf("checkpointing sprite %q: %w", name, err)
+	}
+	for i := len(events) - 1; i >= 0; i-- {
+		if id := events[i].ID; id != "" {
+			return id, nil
+		}
+		if id := checkpointIDIn(events[i].Data); id != "" {
+			return id, nil
+		}
+	}
+	return "", nil
+}
+
+// ListCheckpoints returns a sprite's checkpoints.
+func (b *HTTPBackend) ListCheckpoints(name string) ([]Checkpoint, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), apiTimeout)
+	defer cancel()
+
+	var checkpoints []Checkpoint
+	if err := b.do(ctx, http.MethodGet, spritePath(name)+"/checkpoints", nil, &checkpoints); err != nil {
+		return nil, fmt.Errorf("listing checkpoints for sprite %q: %w", name, err)
+	}
+	return checkpoints, nil
+}
+
+// RestoreCheckpoint rolls a sprite back to a checkpoint and waits for the
+// restore to complete.
+func (b *HTTPBackend) RestoreCheckpoint(name, id string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), checkpointTimeout)
+	defer cancel()
+
+	path := spritePath(name) + "/checkpoints/" + url.PathEscape(id) + "/restore"
+	if err := b.do(ctx, http.MethodPost, path, nil, nil); err != nil {
+		return fmt.Errorf("restoring sprite %q to %s: %w", name, id, err)
+	}
+	return nil
+}
+
+// execRequest is the body of POST /v1/sprites/{name}/exec.
+type execRequest struct {
+	Cmd []string          `json:"cmd"`
+	Dir string            `json:"dir,omitempty"`
+	Env map[string]string `json:"env,omitempty"`
+}
+
+// execResponse is the result of a completed REST exec.
+type execResponse struct {
+	Stdout   string `json:"stdout"`
+	Stderr   string `json:"stderr"`
+	ExitCode int    `json:"exit_code"`
+}
+
+// needsFallback reports whether an exec uses features the REST endpoint
+// lacks: file uploads, detached execution or a TTY.
+func needsFallback(opts ExecOptions) bool {
+	return len(opts.Files) > 0 || opts.Detach || opts.TTY
+}
+
+// exec runs a command to completion over the REST exec endpoint.
+func (b *HTTPBackend) exec(ctx context.Context, opts ExecOptions) (*execResponse, error) {
+	req := execRequest{Cmd: opts.Command, Dir: opts.Dir, Env: opts.Env}
+	var resp execResponse
+	if err := b.do(ctx, http.MethodPost, spritePath(opts.Sprite)+"/exec", req, &resp); err != nil {
+		if ctx.Err() != nil {
+			return nil, fmt.Errorf("exec on sprite %q: %w", opts.Sprite, ctx.Err())
+		}
+		return nil, fmt.Errorf("exec on sprite %q: %w", opts.Sprite, err)
+	}
+	return &resp, nil
+}
+
+// ExecContext runs a command on a sprite over the REST exec endpoint and
+// returns stdout followed by stderr, mirroring the CLI backend's
+// CombinedOutput. Calls that upload files, detach or need a TTY are
+// delegated to the fallback backend because the REST endpoint has no
+// equivalent.
+func (b *HTTPBackend) ExecContext(ctx context.Context, opts ExecOptions) ([]byte, error) {
+	if needsFallback(opts) {
+		if b.fallback == nil {
+			return nil, fmt.Errorf("exec on sprite %q: file uploads, detach and tty are not supported by the HTTP backend", opts.Sprite)
+		}
+		return b.fallback.ExecContext(ctx, opts)
+	}
+
+	resp, err := b.exec(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+	out := []byte(resp.Stdout + resp.Stderr)
+	if resp.ExitCode != 0 {
+		return out, fmt.Errorf("exec on sprite %q: exit status %d\n%s", opts.Sprite, resp.ExitCode, string(out))
+	}
+	return out, nil
+}
+
+// ExecStream runs a command over the REST exec endpoint. The endpoint only
+// returns output once the command completes, so both streams become
+// readable at the end rather than live; use the CLI backend when live
+// output matters. Fallback rules match ExecContext.
+func (b *HTTPBackend) ExecStream(ctx context.Context, opts ExecOptions) (*ExecProcess, error) {
+	if needsFallback(opts) {
+		if b.fallback == nil {
+			return nil, fmt.Errorf("exec on sprite %q: file uploads, detach and tty are not supported by the HTTP backend", opts.Sprite)
+		}
+		return b.fallback.ExecStream(ctx, opts)
+	}
+
+	type result struct {
+		resp *execResponse
+		err  error
+	}
+	done := make(chan result, 1)
+	stdoutR, stdoutW := io.Pipe()
+	stderrR, stderrW := io.Pipe()
+	go func() {
+		resp, err := b.exec(ctx, opts)
+		if err != nil {
+			stdoutW.CloseWithError(err)
+			stderrW.CloseWithError(err)
+			done <- result{err: err}
+			return
+		}
+		// Write the two streams concurrently so a caller reading them in
+		// either order can't deadlock against the unbuffered pipes.
+		finished := make(chan struct{})
+		go func() {
+			io.WriteString(stderrW, resp.Stderr)
+			stderrW.Close()
+			close(finished)
+		}()
+		io.WriteString(stdoutW, resp.Stdout)
+		stdoutW.Close()
+		<-finished
+		done <- result{resp: resp}
+	}()
+
+	return &ExecProcess{
+		Stdout: stdoutR,
+		Stderr: stderrR,
+		wait: func() (int, error) {
+			r := <-done
+			if r.err != nil {
+				return -1, r.err
+			}
+			return r.resp.ExitCode, nil
+		},
+	}, nil
+}
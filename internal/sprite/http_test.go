@@ -0,0 +1,491 @@
+package sprite
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/jphenow/sp/internal/retry"
+)
+
+// fakeAPI is an in-memory stand-in for the Sprites REST API.
+type fakeAPI struct {
+	mu      sync.Mutex
+	sprites map[string]Info
+	execs   []execRequest
+
+	checkpoints map[string][]Checkpoint
+	restored    []string
+
+	listCalls int
+}
+
+// newFakeAPI starts an httptest server serving the fake API and returns a
+// Client wired to it through the HTTP backend.
+func newFakeAPI(t *testing.T) (*fakeAPI, *Client) {
+	t.Helper()
+	f := &fakeAPI{sprites: make(map[string]Info), checkpoints: make(map[string][]Checkpoint)}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /v1/sprites", func(w http.ResponseWriter, r *http.Request) {
+		f.mu.Lock()
+		defer f.mu.Unlock()
+		f.listCalls++
+		names := make([]string, 0, len(f.sprites))
+		for name := range f.sprites {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		// Pages start after the sprite named by the continuation token.
+		start := 0
+		if tok := r.URL.Query().Get("continuation_token"); tok != "" {
+			start = sort.SearchStrings(names, tok) + 1
+		}
+		size := len(names)
+		if n, err := strconv.Atoi(r.URL.Query().Get("max_results")); err == nil && n > 0 {
+			size = n
+		}
+		end := min(start+size, len(names))
+
+		var resp ListResponse
+		for _, name := range names[start:end] {
+			resp.Sprites = append(resp.Sprites, f.sprites[name])
+		}
+		if end < len(names) {
+			tok := names[end-1]
+			resp.NextContinuationToken = &tok
+			resp.HasMore = true
+		}
+		json.NewEncoder(w).Encode(resp)
+	})
+	mux.HandleFunc("POST /v1/sprites", func(w http.ResponseWriter, r *http.Request) {
+		var body struct {
+			Name string `json:"name"`
+		}
+		json.NewDecoder(r.Body).Decode(&body)
+		f.mu.Lock()
+		defer f.mu.Unlock()
+		if _, ok := f.sprites[body.Name]; ok {
+			w.WriteHeader(http.StatusConflict)
+			json.NewEncoder(w).Encode(map[string]string{"error": "sprite already exists"})
+			return
+		}
+		f.sprites[body.Name] = Info{ID: "id-" + body.Name, Name: body.Name, Status: "cold", URL: "https://" + body.Name + ".sprites.app"}
+		w.WriteHeader(http.StatusCreated)
+	})
+	mux.HandleFunc("GET /v1/sprites/{name}", func(w http.ResponseWriter, r *http.Request) {
+		f.mu.Lock()
+		defer f.mu.Unlock()
+		s, ok := f.sprites[r.PathValue("name")]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			json.NewEncoder(w).Encode(map[string]string{"error": "sprite not found"})
+			return
+		}
+		json.NewEncoder(w).Encode(s)
+	})
+	mux.HandleFunc("DELETE /v1/sprites/{name}", func(w http.ResponseWriter, r *http.Request) {
+		f.mu.Lock()
+		defer f.mu.Unlock()
+		delete(f.sprites, r.PathValue("name"))
+		w.WriteHeader(http.StatusNoContent)
+	})
+	mux.HandleFunc("POST /v1/sprites/{name}/exec", func(w http.ResponseWriter, r *http.Request) {
+		var req execRequest
+		json.NewDecoder(r.Body).Decode(&req)
+		f.mu.Lock()
+		f.execs = append(f.execs, req)
+		f.mu.Unlock()
+		resp := execResponse{Stdout: strings.Join(req.Cmd, " ") + "\n"}
+		if len(req.Cmd) > 0 && req.Cmd[0] == "false" {
+			resp = execResponse{Stderr: "failed\n", ExitCode: 1}
+		}
+		if len(req.Cmd) > 0 && req.Cmd[0] == "sleep" {
+			select {
+			case <-r.Context().Done():
+				return
+			case <-time.After(5 * time.Second):
+			}
+		}
+		json.NewEncoder(w).Encode(resp)
+	})
+
+	mux.HandleFunc("POST /v1/sprites/{name}/checkpoint", func(w http.ResponseWriter, r *http.Request) {
+		var body struct {
+			Comment string `json:"comment"`
+		}
+		json.NewDecoder(r.Body).Decode(&body)
+		// Progress is streamed as NDJSON; a failure arrives as an error
+		// event after a 200.
+		enc := json.NewEncoder(w)
+		enc.Encode(map[string]string{"type": "info", "data": "creating checkpoint"})
+		if body.Comment == "fail" {
+			enc.Encode(map[string]string{"type": "error", "error": "disk full"})
+			return
+		}
+		f.mu.Lock()
+		name := r.PathValue("name")
+		add := func(comment string) string {
+			n := len(f.checkpoints[name]) + 1
+			f.checkpoints[name] = append(f.checkpoints[name], Checkpoint{
+				ID:         "v" + strconv.Itoa(n),
+				CreateTime: time.Date(2026, 1, 1, 0, n, 0, 0, time.UTC),
+				Comment:    comment,
+			})
+			return "v" + strconv.Itoa(n)
+		}
+		id := add(body.Comment)
+		if body.Comment == "race" {
+			add("someone else's") // a concurrent create finishing just after ours
+		}
+		f.mu.Unlock()
+		enc.Encode(map[string]string{"type": "complete", "data": "Checkpoint " + id + " created"})
+	})
+	mux.HandleFunc("GET /v1/sprites/{name}/checkpoints", func(w http.ResponseWriter, r *http.Request) {
+		f.mu.Lock()
+		defer f.mu.Unlock()
+		json.NewEncoder(w).Encode(f.checkpoints[r.PathValue("name")])
+	})
+	mux.HandleFunc("POST /v1/sprites/{name}/checkpoints/{id}/restore", func(w http.ResponseWriter, r *http.Request) {
+		f.mu.Lock()
+		defer f.mu.Unlock()
+		for _, cp := range f.checkpoints[r.PathValue("name")] {
+			if cp.ID == r.PathValue("id") {
+				f.restored = append(f.restored, cp.ID)
+				json.NewEncoder(w).Encode(map[string]string{"type": "complete"})
+				return
+			}
+		}
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(map[string]string{"error": "checkpoint not found"})
+	})
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer test-token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		mux.ServeHTTP(w, r)
+	}))
+	t.Cleanup(srv.Close)
+
+	client := NewClientWithConfig("", Config{Backend: BackendHTTP, APIURL: srv.URL, Token: "test-token"})
+	return f, client
+}
+
+func TestHTTPBackendLifecycle(t *testing.T) {
+	_, client := newFakeAPI(t)
+
+	if err := client.Create("alpha"); err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	if err := client.Create("alpha"); err == nil || !strings.Contains(err.Error(), "already exists") {
+		t.Errorf("Create duplicate error = %v, want already exists", err)
+	}
+
+	info, err := client.Get("alpha")
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	if info.ID != "id-alpha" || info.Status != "cold" {
+		t.Errorf("Get = %+v, want id-alpha/cold", info)
+	}
+
+	url, err := client.GetURL("alpha")
+	if err != nil {
+		t.Fatalf("GetURL: %v", err)
+	}
+	if url != "https://alpha.sprites.app" {
+		t.Errorf("GetURL = %q, want %q", url, "https://alpha.sprites.app")
+	}
+
+	sprites, err := client.List()
+	if err != nil {
+		t.Fatalf("List: %v", err)
+	}
+	if len(sprites) != 1 || sprites[0].Name != "alpha" {
+		t.Errorf("List = %+v, want [alpha]", sprites)
+	}
+
+	if err := client.Destroy("alpha"); err != nil {
+		t.Fatalf("Destroy: %v", err)
+	}
+	if _, err := client.Get("alpha"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Get after Destroy error = %v, want ErrNotFound", err)
+	}
+	exists, err := client.Exists("alpha")
+	if err != nil || exists {
+		t.Errorf("Exists after Destroy = %v, %v; want false, nil", exists, err)
+	}
+}
+
+func TestListWalksAllPages(t *testing.T) {
+	f, client := newFakeAPI(t)
+	for _, name := range []string{"a", "b", "c", "d", "e"} {
+		f.sprites[name] = Info{ID: "id-" + name, Name: name}
+	}
+	client.SetPageSize(2)
+
+	sprites, err := client.List()
+	if err != nil {
+		t.Fatalf("List: %v", err)
+	}
+	var names []string
+	for _, s := range sprites {
+		names = append(names, s.Name)
+	}
+	if got := strings.Join(names, ","); got != "a,b,c,d,e" {
+		t.Errorf("List names = %q, want %q", got, "a,b,c,d,e")
+	}
+	if f.listCalls != 3 {
+		t.Errorf("list calls = %d, want 3", f.listCalls)
+	}
+}
+
+func TestAllStopsEarly(t *testing.T) {
+	f, client := newFakeAPI(t)
+	for _, name := range []string{"a", "b", "c", "d", "e"} {
+		f.sprites[name] = Info{ID: "id-" + name, Name: name}
+	}
+
+	var seen int
+	for _, err := range client.All(2) {
+		if err != nil {
+			t.Fatalf("All: %v", err)
+		}
+		seen++
+		if seen == 2 {
+			break
+		}
+	}
+	if f.listCalls != 1 {
+		t.Errorf("list calls = %d, want 1 (later pages fetched lazily)", f.listCalls)
+	}
+}
+
+// stuckPager is a Backend whose list endpoint always claims more results
+// but never advances the continuation token.
+type stuckPager struct {
+	Backend
+}
+
+func (stuckPager) ListPage(opts ListOptions) (*ListResponse, error) {
+	tok := "same"
+	return &ListResponse{Sprites: []Info{{Name: "x"}}, NextContinuationToken: &tok, HasMore: true}, nil
+}
+
+func TestListDetectsStuckContinuationToken(t *testing.T) {
+	client := NewClientWithBackend("", stuckPager{})
+	if _, err := client.List(); err == nil {
+		t.Error("List with repeating continuation token succeeded, want error")
+	}
+}
+
+func TestListOptionsQuery(t *testing.T) {
+	tests := []struct {
+		opts ListOptions
+		want string
+	}{
+		{ListOptions{}, ""},
+		{ListOptions{PageSize: 50}, "max_results=50"},
+		{ListOptions{PageSize: 10, ContinuationToken: "abc"}, "continuation_token=abc&max_results=10"},
+	}
+	for _, tt := range tests {
+		if got := tt.opts.query().Encode(); got != tt.want {
+			t.Errorf("query(%+v) = %q, want %q", tt.opts, got, tt.want)
+		}
+	}
+}
+
+func TestHTTPBackendExec(t *testing.T) {
+	f, client := newFakeAPI(t)
+
+	out, err := client.Exec(ExecOptions{
+		Sprite:  "alpha",
+		Command: []string{"echo", "hi"},
+		Dir:     "/home/sprite",
+		Env:     map[string]string{"A": "1"},
+	})
+	if err != nil {
+		t.Fatalf("Exec: %v", err)
+	}
+	if string(out) != "echo hi\n" {
+		t.Errorf("Exec output = %q, want %q", out, "echo hi\n")
+	}
+	if len(f.execs) != 1 || f.execs[0].Dir != "/home/sprite" || f.execs[0].Env["A"] != "1" {
+		t.Errorf("exec request = %+v, want dir and env forwarded", f.execs)
+	}
+
+	out, err = client.Exec(ExecOptions{Sprite: "alpha", Command: []string{"false"}})
+	if err == nil {
+		t.Fatal("Exec of failing command succeeded, want error")
+	}
+	if string(out) != "failed\n" {
+		t.Errorf("failing Exec output = %q, want %q", out, "failed\n")
+	}
+}
+
+// recordingBackend is a Backend that records exec calls, used to verify
+// HTTPBackend's fallback routing.
+type recordingBackend struct {
+	Backend
+	execs []ExecOptions
+}
+
+func (r *recordingBackend) ExecContext(ctx context.Context, opts ExecOptions) ([]byte, error) {
+	r.execs = append(r.execs, opts)
+	return nil, nil
+}
+
+func TestHTTPBackendExecFallback(t *testing.T) {
+	fallback := &recordingBackend{}
+	b := NewHTTPBackend("http://127.0.0.1:0", "tok", fallback)
+
+	if _, err := b.ExecContext(context.Background(), ExecOptions{Sprite: "alpha", Command: []string{"true"}, Files: map[string]string{"/a": "/b"}}); err != nil {
+		t.Fatalf("Exec with files: %v", err)
+	}
+	if len(fallback.execs) != 1 {
+		t.Errorf("fallback execs = %d, want 1", len(fallback.execs))
+	}
+}
+
+func TestHTTPBackendUnauthorized(t *testing.T) {
+	_, client := newFakeAPI(t)
+	client.backend.(*HTTPBackend).token = "wrong"
+
+	_, err := client.List()
+	if !errors.Is(err, ErrUnauthorized) {
+		t.Errorf("List error = %v, want ErrUnauthorized", err)
+	}
+	if _, err := client.Exists("alpha"); !errors.Is(err, ErrUnauthorized) {
+		t.Errorf("Exists error = %v, want ErrUnauthorized", err)
+	}
+}
+
+func TestHTTPBackendNetworkError(t *testing.T) {
+	client := NewClientWithConfig("", Config{Backend: BackendHTTP, APIURL: "http://127.0.0.1:1", Token: "tok"})
+	client.SetRetryPolicy(retry.Policy{MaxAttempts: 2, InitialDelay: time.Millisecond})
+	if _, err := client.Exists("alpha"); !errors.Is(err, ErrNetwork) {
+		t.Errorf("Exists error = %v, want ErrNetwork", err)
+	}
+}
+
+func TestNewBackendSelection(t *testing.T) {
+	tests := []struct {
+		name string
+		org  string
+		cfg  Config
+		http bool
+	}{
+		{"default", "", Config{}, false},
+		{"cli", "", Config{Backend: BackendCLI, Token: "x"}, false},
+		{"http without token", "", Config{Backend: BackendHTTP}, false},
+		{"http", "", Config{Backend: BackendHTTP, Token: "x"}, true},
+		{"http with org", "acme", Config{Backend: BackendHTTP, Token: "x"}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, isHTTP := newBackend(tt.org, tt.cfg).(*HTTPBackend)
+			if isHTTP != tt.http {
+				t.Errorf("HTTP backend = %v, want %v", isHTTP, tt.http)
+			}
+		})
+	}
+}
+
+func TestConfigFromEnv(t *testing.T) {
+	SetConfig(Config{Backend: "HTTP", APIURL: "https://file.example.com"})
+	t.Cleanup(func() { SetConfig(Config{}) })
+	t.Setenv(EnvBackend, "")
+	t.Setenv(EnvAPIURL, "")
+	t.Setenv(EnvToken, "tok")
+
+	want := Config{Backend: BackendHTTP, APIURL: "https://file.example.com", Token: "tok"}
+	if got := ConfigFromEnv(); got != want {
+		t.Errorf("ConfigFromEnv() = %+v, want the file's settings %+v", got, want)
+	}
+
+	t.Setenv(EnvBackend, "cli")
+	t.Setenv(EnvAPIURL, "https://env.example.com")
+	want = Config{Backend: BackendCLI, APIURL: "https://env.example.com", Token: "tok"}
+	if got := ConfigFromEnv(); got != want {
+		t.Errorf("ConfigFromEnv() = %+v, want the environment's overrides %+v", got, want)
+	}
+}
+
+func TestClientRetriesTransientReads(t *testing.T) {
+	calls := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		if calls == 1 {
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		json.NewEncoder(w).Encode(Info{ID: "id-alpha", Name: "alpha"})
+	}))
+	defer srv.Close()
+
+	client := NewClientWithConfig("", Config{Backend: BackendHTTP, APIURL: srv.URL, Token: "tok"})
+	client.SetRetryPolicy(retry.Policy{MaxAttempts: 3, InitialDelay: time.Millisecond})
+
+	info, err := client.Get("alpha")
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	if info.ID != "id-alpha" || calls != 2 {
+		t.Errorf("Get = %+v after %d calls, want id-alpha after 2", info, calls)
+	}
+}
+
+func TestHTTPBackendCheckpoints(t *testing.T) {
+	f, client := newFakeAPI(t)
+	client.Create("alpha")
+
+	first, err := client.CreateCheckpoint("alpha", "before refactor")
+	if err != nil {
+		t.Fatalf("CreateCheckpoint: %v", err)
+	}
+	second, err := client.CreateCheckpoint("alpha", "")
+	if err != nil {
+		t.Fatalf("CreateCheckpoint: %v", err)
+	}
+	if first.ID != "v1" || first.Comment != "before refactor" || second.ID != "v2" {
+		t.Errorf("created %+v, %+v; want v1 (with comment), v2", first, second)
+	}
+
+	list, err := client.ListCheckpoints("alpha")
+	if err != nil {
+		t.Fatalf("ListCheckpoints: %v", err)
+	}
+	if len(list) != 2 || list[0].ID != "v2" || list[1].ID != "v1" {
+		t.Errorf("ListCheckpoints = %+v, want v2, v1 (newest first)", list)
+	}
+
+	// Another checkpoint made at the same time is newer, but not ours.
+	if cp, err := client.CreateCheckpoint("alpha", "race"); err != nil || cp.ID != "v3" || cp.Comment != "race" {
+		t.Errorf("CreateCheckpoint with a concurrent create = %+v, %v; want v3", cp, err)
+	}
+
+	if _, err := client.CreateCheckpoint("alpha", "fail"); err == nil || !strings.Contains(err.Error(), "disk full") {
+		t.Errorf("CreateCheckpoint with error event = %v, want disk full", err)
+	}
+
+	if err := client.RestoreCheckpoint("alpha", "v1"); err != nil {
+		t.Fatalf("RestoreCheckpoint: %v", err)
+	}
+	if len(f.restored) != 1 || f.restored[0] != "v1" {
+		t.Errorf("restored = %v, want [v1]", f.restored)
+	}
+	if err := client.RestoreCheckpoint("alpha", "v9"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("RestoreCheckpoint(v9) = %v, want ErrNotFound", err)
+	}
+}
@@ -0,0 +1,198 @@
+package sprite
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// serviceTimeout bounds sprite-env calls that only talk to the local
+	// supervisor (list, delete). Creates add the requested start duration.
+	serviceTimeout = time.Minute
+	// serviceLogDir is where the sprite supervisor writes each service's
+	// combined output, one <name>.log per service.
+	serviceLogDir = "/.sprite/logs/services"
+)
+
+// serviceNameRe limits service names to characters that are safe in a log
+// path and on a sprite-env command line.
+var serviceNameRe = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9_.-]*$`)
+
+func validServiceName(name string) error {
+	if !serviceNameRe.MatchString(name) {
+		return fmt.Errorf("invalid service name %q: use letters, digits, '.', '_' and '-'", name)
+	}
+	return nil
+}
+
+// ListServices returns the services defined on a sprite.
+func (c *Client) ListServices(ctx context.Context, spriteName string) ([]Service, error) {
+	out, err := c.ExecContext(ctx, ExecOptions{
+		Sprite:  spriteName,
+		Command: []string{"sprite-env", "services", "list"},
+		Timeout: serviceTimeout,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("listing services on sprite %q: %w", spriteName, err)
+	}
+	services, err := parseServices(out)
+	if err != nil {
+		return nil, fmt.Errorf("parsing services on sprite %q: %w", spriteName, err)
+	}
+	return services, nil
+}
+
+// parseServices decodes `sprite-env services list` output, which is either a
+// bare JSON array or an object with a "services" array. Empty output means
+// no services.
+func parseServices(out []byte) ([]Service, error) {
+	out = bytes.TrimSpace(out)
+	if len(out) == 0 {
+		return nil, nil
+	}
+	var services []Service
+	if out[0] == '[' {
+		if err := json.Unmarshal(out, &services); err != nil {
+			return nil, err
+		}
+		return services, nil
+	}
+	var wrapped struct {
+		Services []Service `json:"services"`
+	}
+	if err := json.Unmarshal(out, &wrapped); err != nil {
+		return nil, err
+	}
+	return wrapped.Services, nil
+}
+
+// GetService returns a single service by name, wrapping ErrNotFound when the
+// sprite has no such service.
+func (c *Client) GetService(ctx context.Context, spriteName, name string) (*Service, error) {
+	services, err := c.ListServices(ctx, spriteName)
+	if err != nil {
+		return nil, err
+	}
+	for i := range services {
+		if services[i].Name == name {
+			return &services[i], nil
+		}
+	}
+	return nil, withKind(ErrNotFound, fmt.Errorf("service %q not found on sprite %q", name, spriteName))
+}
+
+// CreateService defines and starts a service on a sprite. sprite-env refuses
+// to create a service whose name is taken, so callers replacing a service
+// should DeleteService first.
+func (c *Client) CreateService(ctx context.Context, spriteName string, opts ServiceOptions) error {
+	if err := validServiceName(opts.Name); err != nil {
+		return err
+	}
+	if opts.Cmd == "" {
+		return fmt.Errorf("service %q: command is required", opts.Name)
+	}
+
+	cmd := []string{"sprite-env", "services", "create", opts.Name, "--cmd", opts.Cmd}
+	if len(opts.Args) > 0 {
+		// sprite-env takes args as one comma-separated list, so an
+		// argument containing a comma can't be passed through.
+		for _, a := range opts.Args {
+			if strings.Contains(a, ",") {
+				return fmt.Errorf("service %q: argument %q contains a comma, which sprite-env cannot represent", opts.Name, a)
+			}
+		}
+		cmd = append(cmd, "--args", strings.Join(opts.Args, ","))
+	}
+	if opts.Dir != "" {
+		cmd = append(cmd, "--dir", opts.Dir)
+	}
+	if opts.HTTPPort > 0 {
+		cmd = append(cmd, "--http-port", strconv.Itoa(opts.HTTPPort))
+	}
+	if opts.Duration > 0 {
+		cmd = append(cmd, "--duration", opts.Duration.String())
+	}
+
+	_, err := c.ExecContext(ctx, ExecOptions{
+		Sprite:  spriteName,
+		Command: cmd,
+		Timeout: serviceTimeout + opts.Duration,
+	})
+	if err != nil {
+		return fmt.Errorf("creating service %q on sprite %q: %w", opts.Name, spriteName, err)
+	}
+	return nil
+}
+
+// DeleteService stops and removes a service from a sprite.
+func (c *Client) DeleteService(ctx context.Context, spriteName, name string) error {
+	if err := validServiceName(name); err != nil {
+		return err
+	}
+	_, err := c.ExecContext(ctx, ExecOptions{
+		Sprite:  spriteName,
+		Command: []string{"sprite-env", "services", "delete", name},
+		Timeout: serviceTimeout,
+	})
+	if err != nil {
+		return fmt.Errorf("deleting service %q on sprite %q: %w", name, spriteName, err)
+	}
+	return nil
+}
+
+// RestartService restarts a service by deleting it and recreating it from
+// its current definition, which is what sprite-env does on a cold wake.
+func (c *Client) RestartService(ctx context.Context, spriteName, name string, duration time.Duration) error {
+	svc, err := c.GetService(ctx, spriteName, name)
+	if err != nil {
+		return err
+	}
+	if err := c.DeleteService(ctx, spriteName, name); err != nil {
+		return err
+	}
+	return c.CreateService(ctx, spriteName, ServiceOptions{
+		Name:     svc.Name,
+		Cmd:      svc.Cmd,
+		Args:     svc.Args,
+		Dir:      svc.Dir,
+		HTTPPort: svc.HTTPPort,
+		Duration: duration,
+	})
+}
+
+// ServiceLogs copies a service's log to w: the last opts.Lines lines (all
+// of it when 0), then, with opts.Follow, new output until ctx is done.
+func (c *Client) ServiceLogs(ctx context.Context, spriteName, name string, opts ServiceLogOptions, w io.Writer) error {
+	if err := validServiceName(name); err != nil {
+		return err
+	}
+	lines := "+1" // tail's "from the first line"
+	if opts.Lines > 0 {
+		lines = strconv.Itoa(opts.Lines)
+	}
+	cmd := []string{"tail", "-n", lines}
+	if opts.Follow {
+		cmd = append(cmd, "-F")
+	}
+	cmd = append(cmd, serviceLogDir+"/"+name+".log")
+
+	var stderr bytes.Buffer
+	code, err := c.ExecStreamTo(ctx, ExecOptions{Sprite: spriteName, Command: cmd}, w, &stderr)
+	if err != nil {
+		if opts.Follow && ctx.Err() == context.Canceled {
+			return nil
+		}
+		return fmt.Errorf("reading logs for service %q on sprite %q: %w", name, spriteName, err)
+	}
+	if code != 0 {
+		return fmt.Errorf("reading logs for service %q on sprite %q: exit status %d\n%s", name, spriteName, code, stderr.String())
+	}
+	return nil
+}
@@ -0,0 +1,144 @@
+package sprite
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// serviceBackend is a Backend that answers `sprite-env services list` with a
+// canned response and records every exec'd command.
+type serviceBackend struct {
+	Backend
+	list     string
+	commands [][]string
+}
+
+func (b *serviceBackend) ExecContext(ctx context.Context, opts ExecOptions) ([]byte, error) {
+	b.commands = append(b.commands, opts.Command)
+	if strings.Join(opts.Command, " ") == "sprite-env services list" {
+		return []byte(b.list), nil
+	}
+	return nil, nil
+}
+
+func TestParseServices(t *testing.T) {
+	want := []Service{{Name: "web", Cmd: "/usr/bin/npm", Args: []string{"run", "dev"}, HTTPPort: 3000, State: &ServiceState{Status: "running", PID: 42}}}
+	tests := []struct {
+		name string
+		out  string
+		want []Service
+	}{
+		{"empty", "  \n", nil},
+		{"array", `[{"name":"web","cmd":"/usr/bin/npm","args":["run","dev"],"http_port":3000,"state":{"status":"running","pid":42}}]`, want},
+		{"wrapped", `{"services":[{"name":"web","cmd":"/usr/bin/npm","args":["run","dev"],"http_port":3000,"state":{"status":"running","pid":42}}]}`, want},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseServices([]byte(tt.out))
+			if err != nil {
+				t.Fatalf("parseServices: %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseServices = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+
+	if _, err := parseServices([]byte("not json")); err == nil {
+		t.Error("parseServices(garbage) = nil error, want error")
+	}
+}
+
+func TestCreateServiceCommand(t *testing.T) {
+	b := &serviceBackend{}
+	client := NewClientWithBackend("", b)
+
+	err := client.CreateService(context.Background(), "alpha", ServiceOptions{
+		Name:     "web",
+		Cmd:      "/usr/bin/npm",
+		Args:     []string{"run", "dev"},
+		Dir:      "/home/sprite/app",
+		HTTPPort: 3000,
+		Duration: 10 * time.Second,
+	})
+	if err != nil {
+		t.Fatalf("CreateService: %v", err)
+	}
+	want := []string{"sprite-env", "services", "create", "web", "--cmd", "/usr/bin/npm", "--args", "run,dev", "--dir", "/home/sprite/app", "--http-port", "3000", "--duration", "10s"}
+	if len(b.commands) != 1 || !reflect.DeepEqual(b.commands[0], want) {
+		t.Errorf("commands = %q, want [%q]", b.commands, want)
+	}
+}
+
+func TestCreateServiceValidation(t *testing.T) {
+	tests := []struct {
+		name string
+		opts ServiceOptions
+	}{
+		{"bad name", ServiceOptions{Name: "../etc", Cmd: "/bin/true"}},
+		{"no command", ServiceOptions{Name: "web"}},
+		{"comma in arg", ServiceOptions{Name: "web", Cmd: "/bin/echo", Args: []string{"a,b"}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := &serviceBackend{}
+			client := NewClientWithBackend("", b)
+			if err := client.CreateService(context.Background(), "alpha", tt.opts); err == nil {
+				t.Error("CreateService = nil error, want error")
+			}
+			if len(b.commands) != 0 {
+				t.Errorf("commands = %q, want none", b.commands)
+			}
+		})
+	}
+}
+
+func TestRestartServiceRecreates(t *testing.T) {
+	b := &serviceBackend{list: `[{"name":"web","cmd":"/usr/bin/npm","args":["start"],"http_port":8080}]`}
+	client := NewClientWithBackend("", b)
+
+	if err := client.RestartService(context.Background(), "alpha", "web", 5*time.Second); err != nil {
+		t.Fatalf("RestartService: %v", err)
+	}
+	want := [][]string{
+		{"sprite-env", "services", "list"},
+		{"sprite-env", "services", "delete", "web"},
+		{"sprite-env", "services", "create", "web", "--cmd", "/usr/bin/npm", "--args", "start", "--http-port", "8080", "--duration", "5s"},
+	}
+	if !reflect.DeepEqual(b.commands, want) {
+		t.Errorf("commands = %q, want %q", b.commands, want)
+	}
+}
+
+func TestGetServiceNotFound(t *testing.T) {
+	client := NewClientWithBackend("", &serviceBackend{list: `[]`})
+	_, err := client.GetService(context.Background(), "alpha", "web")
+	if !errors.Is(err, ErrNotFound) {
+		t.Errorf("err = %v, want ErrNotFound", err)
+	}
+}
+
+func TestServiceErrorShowsOutputOnce(t *testing.T) {
+	fakeSpriteCLI(t)
+	dir := t.TempDir()
+	script := "#!/bin/sh\necho 'service web is busy'\nexit 1\n"
+	if err := os.WriteFile(filepath.Join(dir, "sprite-env"), []byte(script), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+	client := NewClientWithBackend("", &cliBackend{})
+
+	err := client.DeleteService(context.Background(), "alpha", "web")
+	if err == nil {
+		t.Fatal("DeleteService = nil error, want error")
+	}
+	if n := strings.Count(err.Error(), "service web is busy"); n != 1 {
+		t.Errorf("error shows the command's output %d times, want once: %v", n, err)
+	}
+}
@@ -1,6 +1,10 @@
 package sprite
 
-import "time"
+import (
+	"net/url"
+	"strconv"
+	"time"
+)
 
 // Info represents a sprite as returned by the Sprites API.
 type Info struct {
@@ -30,11 +34,69 @@ type ListResponse struct {
 	HasMore               bool    `json:"has_more"`
 }
 
-// Service represents a sprite service configuration.
+// ListOptions controls a single page request to the sprite list endpoint.
+type ListOptions struct {
+	PageSize          int    // max sprites per page; 0 uses the API default
+	ContinuationToken string // token from a previous page's NextContinuationToken
+}
+
+// query encodes the options as list endpoint query parameters.
+func (o ListOptions) query() url.Values {
+	q := url.Values{}
+	if o.PageSize > 0 {
+		q.Set("max_results", strconv.Itoa(o.PageSize))
+	}
+	if o.ContinuationToken != "" {
+		q.Set("continuation_token", o.ContinuationToken)
+	}
+	return q
+}
+
+// Checkpoint is a point-in-time snapshot of a sprite's filesystem that the
+// sprite can be restored to.
+type Checkpoint struct {
+	ID         string    `json:"id"` // e.g. "v3"
+	CreateTime time.Time `json:"create_time"`
+	Comment    string    `json:"comment,omitempty"`
+}
+
+// Service is a sprite-env service: a long-running process the sprite
+// supervises, restarting it on boot and after a cold wake. Services with an
+// HTTPPort also receive the sprite URL's traffic and wake the sprite on
+// request.
 type Service struct {
-	Port    int    `json:"port"`
-	Command string `json:"command"`
-	Primary bool   `json:"primary"`
+	Name     string        `json:"name"`
+	Cmd      string        `json:"cmd"`
+	Args     []string      `json:"args,omitempty"`
+	Dir      string        `json:"dir,omitempty"`
+	HTTPPort int           `json:"http_port,omitempty"`
+	State    *ServiceState `json:"state,omitempty"`
+}
+
+// ServiceState is the supervisor's view of a service's process, as reported
+// by `sprite-env services list`.
+type ServiceState struct {
+	Status string `json:"status"` // e.g. "running", "stopped", "failed"
+	PID    int    `json:"pid,omitempty"`
+	Error  string `json:"error,omitempty"`
+}
+
+// ServiceOptions configures CreateService. Duration is how long sprite-env
+// waits for the process to stay up before reporting the create as
+// successful (0 uses sprite-env's default).
+type ServiceOptions struct {
+	Name     string
+	Cmd      string // absolute path; services don't source login profiles
+	Args     []string
+	Dir      string
+	HTTPPort int
+	Duration time.Duration
+}
+
+// ServiceLogOptions configures Client.ServiceLogs.
+type ServiceLogOptions struct {
+	Lines  int  // trailing lines to show; 0 shows the whole log
+	Follow bool // keep streaming new output until the context is done
 }
 
 // ExecOptions configures a sprite exec call.
@@ -47,6 +109,7 @@ type ExecOptions struct {
 	Env     map[string]string
 	Files   map[string]string // local:remote pairs
 	Detach  bool
+	Timeout time.Duration // bounds ExecContext/ExecStream; 0 means only the context applies
 }
 
 // ProxyOptions configures a sprite proxy call.
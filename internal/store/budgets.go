@@ -0,0 +1,202 @@
+package store
+
+import (
+	"fmt"
+	"time"
+)
+
+// Budget scopes: a budget caps the running hours of every sprite, of the
+// sprites with a tag, or of a single sprite.
+const (
+	BudgetGlobal = "global"
+	BudgetTag    = "tag"
+	BudgetSprite = "sprite"
+)
+
+// DefaultBudgetWarnPercent is how much of a budget can be used before sp
+// starts warning about it.
+const DefaultBudgetWarnPercent = 80
+
+// Budget caps the running hours a set of sprites may use each calendar month.
+type Budget struct {
+	Scope       string // one of the Budget* scopes
+	Target      string // tag or sprite name; empty for the global budget
+	Hours       float64
+	WarnPercent int // warn once this much of the budget is used
+}
+
+// Label names what the budget applies to, e.g. "tag work".
+func (b *Budget) Label() string {
+	if b.Scope == BudgetGlobal {
+		return "global"
+	}
+	return b.Scope + " " + b.Target
+}
+
+// BudgetStatus is a budget and how much of it has been used this period.
+type BudgetStatus struct {
+	Budget *Budget
+	Used   time.Duration
+}
+
+// Remaining returns the running time left in the budget, or zero once it is
+// exhausted.
+func (s *BudgetStatus) Remaining() time.Duration {
+	left := time.Duration(s.Budget.Hours*float64(time.Hour)) - s.Used
+	return max(left, 0)
+}
+
+// Percent returns how much of the budget has been used, in percent.
+func (s *BudgetStatus) Percent() float64 {
+	if s.Budget.Hours <= 0 {
+		return 100
+	}
+	return 100 * s.Used.Hours() / s.Budget.Hours
+}
+
+// Exhausted reports whether the budget has no running time left.
+func (s *BudgetStatus) Exhausted() bool {
+	return s.Remaining() == 0
+}
+
+// Warning reports whether the budget has passed its warning threshold.
+func (s *BudgetStatus) Warning() bool {
+	return s.Percent() >= float64(s.Budget.WarnPercent)
+}
+
+// BudgetPeriodStart returns the start of the calendar month containing t,
+// which is when budgets reset.
+func BudgetPeriodStart(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, t.Location())
+}
+
+// SetBudget creates or replaces a budget.
+func (d *DB) SetBudget(b *Budget) error {
+	if b.Scope == BudgetGlobal {
+		b.Target = ""
+	}
+	if b.WarnPercent == 0 {
+		b.WarnPercent = DefaultBudgetWarnPercent
+	}
+	_, err := d.db.Exec(`
+		INSERT INTO budgets (scope, target, hours, warn_percent)
+		VALUES (?, ?, ?, ?)
+		ON CONFLICT(scope, target) DO UPDATE SET
+			hours = excluded.hours,
+			warn_percent = excluded.warn_percent
+	`, b.Scope, b.Target, b.Hours, b.WarnPercent)
+	if err != nil {
+		return fmt.Errorf("setting %s budget: %w", b.Label(), err)
+	}
+	return nil
+}
+
+// DeleteBudget removes a budget. It reports whether there was one.
+func (d *DB) DeleteBudget(scope, target string) (bool, error) {
+	res, err := d.db.Exec(`DELETE FROM budgets WHERE scope = ? AND target = ?`, scope, target)
+	if err != nil {
+		return false, fmt.Errorf("deleting budget: %w", err)
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
+
+// ListBudgets returns every budget, global first.
+func (d *DB) ListBudgets() ([]*Budget, error) {
+	rows, err := d.db.Query(`
+		SELECT scope, target, hours, warn_percent FROM budgets
+		ORDER BY CASE scope WHEN 'global' THEN 0 WHEN 'tag' THEN 1 ELSE 2 END, target
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("listing budgets: %w", err)
+	}
+	defer rows.Close()
+
+	var budgets []*Budget
+	for rows.Next() {
+		b := &Budget{}
+		if err := rows.Scan(&b.Scope, &b.Target, &b.Hours, &b.WarnPercent); err != nil {
+			return nil, fmt.Errorf("scanning budget: %w", err)
+		}
+		budgets = append(budgets, b)
+	}
+	return budgets, rows.Err()
+}
+
+// BudgetStatuses returns the budgets that apply to a sprite (all of them if
+// spriteName is empty) with the running time used against each between the
+// start of the current period and now. Tag budgets count the sprites that
+// have the tag now; the global budget also counts deleted sprites, up to
+// their deletion.
+func (d *DB) BudgetStatuses(spriteName string, now time.Time) ([]*BudgetStatus, error) {
+	budgets, err := d.ListBudgets()
+	if err != nil {
+		return nil, err
+	}
+	if len(budgets) == 0 {
+		return nil, nil
+	}
+
+	tags, err := d.allTags()
+	if err != nil {
+		return nil, err
+	}
+	hasTag := func(name, tag string) bool {
+		for _, t := range tags[name] {
+			if t == tag {
+				return true
+			}
+		}
+		return false
+	}
+	applies := func(b *Budget, name string) bool {
+		switch b.Scope {
+		case BudgetGlobal:
+			return true
+		case BudgetTag:
+			return hasTag(name, b.Target)
+		default:
+			return b.Target == name
+		}
+	}
+
+	from := BudgetPeriodStart(now)
+	usage, err := d.ListUsage(from)
+	if err != nil {
+		return nil, err
+	}
+
+	var statuses []*BudgetStatus
+	for _, b := range budgets {
+		if spriteName != "" && !applies(b, spriteName) {
+			continue
+		}
+		s := &BudgetStatus{Budget: b}
+		for _, u := range usage {
+			if applies(b, u.SpriteName) {
+				s.Used += u.Overlap(from, now)
+			}
+		}
+		statuses = append(statuses, s)
+	}
+	return statuses, nil
+}
+
+// allTags returns every sprite's tags, keyed by sprite name.
+func (d *DB) allTags() (map[string][]string, error) {
+	rows, err := d.db.Query(`SELECT sprite_name, tag FROM tags`)
+	if err != nil {
+		return nil, fmt.Errorf("listing tags: %w", err)
+	}
+	defer rows.Close()
+
+	tags := make(map[string][]string)
+	for rows.Next() {
+		var name, tag string
+		if err := rows.Scan(&name, &tag); err != nil {
+			return nil, fmt.Errorf("scanning tag: %w", err)
+		}
+		tags[name] = append(tags[name], tag)
+	}
+	return tags, rows.Err()
+}
@@ -0,0 +1,66 @@
+package store
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// AddCheckpoint records a checkpoint, replacing the label of an existing
+// record with the same ID.
+func (d *DB) AddCheckpoint(c *Checkpoint) error {
+	createdAt := c.CreatedAt
+	if createdAt.IsZero() {
+		createdAt = time.Now()
+	}
+	_, err := d.db.Exec(`
+		INSERT INTO checkpoints (sprite_name, checkpoint_id, label, created_at)
+		VALUES (?, ?, ?, ?)
+		ON CONFLICT(sprite_name, checkpoint_id) DO UPDATE SET label = excluded.label
+	`, c.SpriteName, c.CheckpointID, c.Label, createdAt)
+	if err != nil {
+		return fmt.Errorf("adding checkpoint %q for sprite %q: %w", c.CheckpointID, c.SpriteName, err)
+	}
+	return nil
+}
+
+// ListCheckpoints returns the recorded checkpoints for a sprite, newest first.
+func (d *DB) ListCheckpoints(spriteName string) ([]*Checkpoint, error) {
+	rows, err := d.db.Query(`
+		SELECT sprite_name, checkpoint_id, label, created_at
+		FROM checkpoints WHERE sprite_name = ?
+		ORDER BY created_at DESC
+	`, spriteName)
+	if err != nil {
+		return nil, fmt.Errorf("listing checkpoints for sprite %q: %w", spriteName, err)
+	}
+	defer rows.Close()
+
+	var checkpoints []*Checkpoint
+	for rows.Next() {
+		c := &Checkpoint{}
+		if err := rows.Scan(&c.SpriteName, &c.CheckpointID, &c.Label, &c.CreatedAt); err != nil {
+			return nil, fmt.Errorf("scanning checkpoint: %w", err)
+		}
+		checkpoints = append(checkpoints, c)
+	}
+	return checkpoints, rows.Err()
+}
+
+// FindCheckpointByLabel returns the newest checkpoint of a sprite with the
+// given label, or nil if there is none.
+func (d *DB) FindCheckpointByLabel(spriteName, label string) (*Checkpoint, error) {
+	c := &Checkpoint{}
+	err := d.db.QueryRow(`
+		SELECT sprite_name, checkpoint_id, label, created_at
+		FROM checkpoints WHERE sprite_name = ? AND label = ?
+		ORDER BY created_at DESC LIMIT 1
+	`, spriteName, label).Scan(&c.SpriteName, &c.CheckpointID, &c.Label, &c.CreatedAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("finding checkpoint %q for sprite %q: %w", label, spriteName, err)
+	}
+	return c, nil
+}
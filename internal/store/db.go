@@ -60,6 +60,15 @@ type Tag struct {
 	Tag        string
 }
 
+// Checkpoint records a sprite checkpoint taken through sp, with the
+// user's label for it. The checkpoint itself lives on the Sprites platform.
+type Checkpoint struct {
+	SpriteName   string
+	CheckpointID string
+	Label        string
+	CreatedAt    time.Time
+}
+
 // defaultDBPath returns the default path for the sp database file.
 func defaultDBPath() (string, error) {
 	home, err := os.UserHomeDir()
@@ -85,7 +94,35 @@ func Open() (*DB, error) {
 
 // OpenPath opens a SQLite database at the given path and runs migrations.
 func OpenPath(path string) (*DB, error) {
-	db, err := sql.Open("sqlite", path+"?_journal_mode=WAL&_busy_timeout=5000")
+	store, err := OpenPathWithoutMigrating(path)
+	if err != nil {
+		return nil, err
+	}
+	if err := store.migrate(); err != nil {
+		store.Close()
+		return nil, fmt.Errorf("running migrations: %w", err)
+	}
+	return store, nil
+}
+
+// OpenWithoutMigrating opens the database at the default path as it is,
+// for inspecting or changing its schema version (see MigrationStatus and
+// MigrateTo). Other methods may fail until it has been migrated.
+func OpenWithoutMigrating() (*DB, error) {
+	path, err := defaultDBPath()
+	if err != nil {
+		return nil, err
+	}
+	return OpenPathWithoutMigrating(path)
+}
+
+// OpenPathWithoutMigrating is OpenWithoutMigrating for a database at the
+// given path.
+func OpenPathWithoutMigrating(path string) (*DB, error) {
+	// The busy timeout applies to every pooled connection, so concurrent sp
+	// processes wait for each other's writes instead of failing. Immediate
+	// transactions take the write lock up front, which migrations rely on.
+	db, err := sql.Open("sqlite", path+"?_pragma=busy_timeout(5000)&_txlock=immediate")
 	if err != nil {
 		return nil, fmt.Errorf("opening database: %w", err)
 	}
@@ -99,12 +136,7 @@ func OpenPath(path string) (*DB, error) {
 		db.Close()
 		return nil, fmt.Errorf("enabling foreign keys: %w", err)
 	}
-	store := &DB{db: db}
-	if err := store.migrate(); err != nil {
-		db.Close()
-		return nil, fmt.Errorf("running migrations: %w", err)
-	}
-	return store, nil
+	return &DB{db: db}, nil
 }
 
 // Close closes the database connection.
@@ -112,96 +144,6 @@ func (d *DB) Close() error {
 	return d.db.Close()
 }
 
-// migrate runs all schema migrations in order.
-func (d *DB) migrate() error {
-	migrations := []string{
-		`CREATE TABLE IF NOT EXISTS sprites (
-			name TEXT PRIMARY KEY,
-			local_path TEXT,
-			remote_path TEXT,
-			repo TEXT,
-			org TEXT,
-			sprite_id TEXT,
-			url TEXT,
-			status TEXT DEFAULT 'unknown',
-			sync_status TEXT DEFAULT 'none',
-			sync_error TEXT DEFAULT '',
-			last_seen DATETIME,
-			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
-			updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
-		)`,
-		`CREATE TABLE IF NOT EXISTS tags (
-			sprite_name TEXT REFERENCES sprites(name) ON DELETE CASCADE,
-			tag TEXT,
-			PRIMARY KEY (sprite_name, tag)
-		)`,
-		`CREATE TABLE IF NOT EXISTS sync_sessions (
-			sprite_name TEXT REFERENCES sprites(name) ON DELETE CASCADE UNIQUE,
-			mutagen_id TEXT,
-			ssh_port INTEGER,
-			proxy_pid INTEGER,
-			alpha_connected BOOLEAN DEFAULT 0,
-			beta_connected BOOLEAN DEFAULT 0,
-			conflicts INTEGER DEFAULT 0,
-			last_error TEXT DEFAULT '',
-			updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
-		)`,
-	}
-	for _, m := range migrations {
-		if _, err := d.db.Exec(m); err != nil {
-			return fmt.Errorf("migration failed: %w", err)
-		}
-	}
-
-	// Additive column migrations for the variant feature. These run after the
-	// base migrations so they work on both fresh and existing databases.
-	additions := []struct {
-		column string
-		ddl    string
-	}{
-		{"variant", `ALTER TABLE sprites ADD COLUMN variant TEXT DEFAULT ''`},
-		{"base_name", `ALTER TABLE sprites ADD COLUMN base_name TEXT DEFAULT ''`},
-		{"pinned", `ALTER TABLE sprites ADD COLUMN pinned BOOLEAN DEFAULT 0`},
-	}
-	for _, a := range additions {
-		if err := d.addColumnIfMissing("sprites", a.column, a.ddl); err != nil {
-			return fmt.Errorf("adding column %s: %w", a.column, err)
-		}
-	}
-	return nil
-}
-
-// addColumnIfMissing runs an ALTER TABLE only if the column doesn't already
-// exist. SQLite lacks ADD COLUMN IF NOT EXISTS, so we inspect PRAGMA table_info.
-func (d *DB) addColumnIfMissing(table, column, ddl string) error {
-	rows, err := d.db.Query(fmt.Sprintf("PRAGMA table_info(%s)", table))
-	if err != nil {
-		return fmt.Errorf("inspecting %s: %w", table, err)
-	}
-	defer rows.Close()
-	for rows.Next() {
-		var (
-			cid        int
-			name       string
-			colType    string
-			notNull    int
-			dfltValue  sql.NullString
-			primaryKey int
-		)
-		if err := rows.Scan(&cid, &name, &colType, &notNull, &dfltValue, &primaryKey); err != nil {
-			return err
-		}
-		if name == column {
-			return nil
-		}
-	}
-	if err := rows.Err(); err != nil {
-		return err
-	}
-	_, err = d.db.Exec(ddl)
-	return err
-}
-
 // SQL returns the underlying *sql.DB for advanced queries. Use sparingly.
 func (d *DB) SQL() *sql.DB {
 	return d.db
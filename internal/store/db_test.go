@@ -1,8 +1,10 @@
 package store
 
 import (
+	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 	"time"
 )
@@ -480,6 +482,39 @@ func TestSyncSession(t *testing.T) {
 	}
 }
 
+func TestSyncPause(t *testing.T) {
+	db := testDB(t)
+	if err := db.UpsertSprite(&Sprite{Name: "alpha"}); err != nil {
+		t.Fatalf("upsert sprite: %v", err)
+	}
+
+	check := func(want bool) {
+		t.Helper()
+		if got, err := db.IsSyncPaused("alpha"); err != nil || got != want {
+			t.Errorf("IsSyncPaused = %v, %v; want %v", got, err, want)
+		}
+	}
+	check(false)
+	for range 2 { // pausing twice is fine
+		if err := db.SetSyncPaused("alpha", true); err != nil {
+			t.Fatalf("pause: %v", err)
+		}
+	}
+	check(true)
+	if err := db.SetSyncPaused("alpha", false); err != nil {
+		t.Fatalf("resume: %v", err)
+	}
+	check(false)
+
+	// Deleting the sprite drops its pause.
+	db.SetSyncPaused("alpha", true)
+	if err := db.DeleteSprite("alpha"); err != nil {
+		t.Fatalf("delete: %v", err)
+	}
+	db.UpsertSprite(&Sprite{Name: "alpha"})
+	check(false)
+}
+
 func TestTags(t *testing.T) {
 	db := testDB(t)
 
@@ -536,3 +571,330 @@ func TestTags(t *testing.T) {
 		t.Errorf("expected 3 unique tags, got %d: %v", len(allTags), allTags)
 	}
 }
+
+func TestMeta(t *testing.T) {
+	db := testDB(t)
+
+	if err := db.UpsertSprite(&Sprite{Name: "annotated"}); err != nil {
+		t.Fatalf("upsert: %v", err)
+	}
+	for _, kv := range [][2]string{{"ticket", "ENG-1"}, {MetaNote, "keep: demo on friday"}, {"ticket", "ENG-2"}} {
+		if err := db.SetMeta("annotated", kv[0], kv[1]); err != nil {
+			t.Fatalf("SetMeta(%s): %v", kv[0], err)
+		}
+	}
+
+	meta, err := db.GetMeta("annotated")
+	if err != nil {
+		t.Fatalf("GetMeta: %v", err)
+	}
+	var got []string
+	for _, m := range meta {
+		got = append(got, m.Key+"="+m.Value)
+	}
+	if want := "note=keep: demo on friday,ticket=ENG-2"; strings.Join(got, ",") != want {
+		t.Errorf("meta = %v, want %s", got, want)
+	}
+
+	if ok, err := db.DeleteMeta("annotated", "ticket"); err != nil || !ok {
+		t.Errorf("DeleteMeta(ticket) = %v, %v; want true", ok, err)
+	}
+	if ok, err := db.DeleteMeta("annotated", "ticket"); err != nil || ok {
+		t.Errorf("second DeleteMeta(ticket) = %v, %v; want false", ok, err)
+	}
+
+	// Annotations can't outlive their sprite.
+	if err := db.SetMeta("missing", "owner", "me"); err == nil {
+		t.Error("SetMeta on an unknown sprite should fail")
+	}
+	if err := db.DeleteSprite("annotated"); err != nil {
+		t.Fatalf("delete: %v", err)
+	}
+	if meta, _ := db.GetMeta("annotated"); len(meta) != 0 {
+		t.Errorf("meta should be cascade-deleted, got %d entries", len(meta))
+	}
+}
+
+func TestCheckpoints(t *testing.T) {
+	db := testDB(t)
+
+	if err := db.UpsertSprite(&Sprite{Name: "cp-sprite"}); err != nil {
+		t.Fatalf("upsert: %v", err)
+	}
+
+	base := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i, c := range []Checkpoint{
+		{CheckpointID: "v1", Label: "before-refactor"},
+		{CheckpointID: "v2", Label: ""},
+		{CheckpointID: "v3", Label: "before-refactor"},
+	} {
+		c.SpriteName = "cp-sprite"
+		c.CreatedAt = base.Add(time.Duration(i) * time.Minute)
+		if err := db.AddCheckpoint(&c); err != nil {
+			t.Fatalf("add checkpoint %s: %v", c.CheckpointID, err)
+		}
+	}
+
+	list, err := db.ListCheckpoints("cp-sprite")
+	if err != nil {
+		t.Fatalf("list checkpoints: %v", err)
+	}
+	if len(list) != 3 || list[0].CheckpointID != "v3" {
+		t.Errorf("expected 3 checkpoints newest first, got %+v", list)
+	}
+
+	// Labels can repeat; lookup picks the newest.
+	c, err := db.FindCheckpointByLabel("cp-sprite", "before-refactor")
+	if err != nil {
+		t.Fatalf("find by label: %v", err)
+	}
+	if c == nil || c.CheckpointID != "v3" {
+		t.Errorf("find by label = %+v, want v3", c)
+	}
+
+	// Re-adding relabels.
+	if err := db.AddCheckpoint(&Checkpoint{SpriteName: "cp-sprite", CheckpointID: "v2", Label: "green"}); err != nil {
+		t.Fatalf("relabel: %v", err)
+	}
+	if c, _ := db.FindCheckpointByLabel("cp-sprite", "green"); c == nil || c.CheckpointID != "v2" {
+		t.Errorf("find relabeled = %+v, want v2", c)
+	}
+
+	if c, err := db.FindCheckpointByLabel("cp-sprite", "missing"); err != nil || c != nil {
+		t.Errorf("find missing = %+v, %v; want nil, nil", c, err)
+	}
+
+	// Checkpoints go with their sprite.
+	if err := db.DeleteSprite("cp-sprite"); err != nil {
+		t.Fatalf("delete sprite: %v", err)
+	}
+	if list, _ := db.ListCheckpoints("cp-sprite"); len(list) != 0 {
+		t.Errorf("expected checkpoints removed with sprite, got %d", len(list))
+	}
+}
+
+func TestEvents(t *testing.T) {
+	db := testDB(t)
+
+	if err := db.UpsertSprite(&Sprite{Name: "ev-sprite", Status: "cold", SyncStatus: "none"}); err != nil {
+		t.Fatalf("upsert: %v", err)
+	}
+
+	// Status and sync changes are recorded; repeating a value is not.
+	db.UpdateSpriteStatus("ev-sprite", "running")
+	db.UpdateSpriteStatus("ev-sprite", "running")
+	db.UpdateSyncStatus("ev-sprite", "watching", "")
+	db.UpdateSyncStatus("ev-sprite", "error", "ssh: connection refused")
+	db.UpdateSyncStatus("ev-sprite", "error", "ssh: timeout")
+	db.UpdateSpriteStatus("no-such-sprite", "running")
+	if err := db.AddEvent(&Event{SpriteName: "ev-sprite", Kind: EventProxy, Message: "proxy exited: signal: killed"}); err != nil {
+		t.Fatalf("add event: %v", err)
+	}
+
+	events, err := db.ListEvents(EventListOptions{SpriteName: "ev-sprite"})
+	if err != nil {
+		t.Fatalf("list events: %v", err)
+	}
+	var got []string
+	for _, e := range events {
+		got = append(got, e.Kind+" "+e.From+">"+e.To+" "+e.Message)
+	}
+	want := []string{
+		"status cold>running ",
+		"sync none>watching ",
+		"sync watching>error ssh: connection refused",
+		"proxy > proxy exited: signal: killed",
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("events:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+
+	if sync, _ := db.ListEvents(EventListOptions{Kinds: []string{EventSync}}); len(sync) != 2 {
+		t.Errorf("sync events = %d, want 2", len(sync))
+	}
+	if last, _ := db.ListEvents(EventListOptions{SpriteName: "ev-sprite", Limit: 1}); len(last) != 1 || last[0].Kind != EventProxy {
+		t.Errorf("newest event = %+v, want the proxy event", last)
+	}
+
+	// Since and pruning work on event time.
+	old := time.Now().Add(-48 * time.Hour)
+	db.AddEvent(&Event{SpriteName: "ev-sprite", Kind: EventSetup, To: "ok", CreatedAt: old})
+	if recent, _ := db.ListEvents(EventListOptions{Since: time.Now().Add(-24 * time.Hour)}); len(recent) != 4 {
+		t.Errorf("events in the last day = %d, want 4", len(recent))
+	}
+	if n, err := db.PruneEvents(time.Now().Add(-24 * time.Hour)); err != nil || n != 1 {
+		t.Errorf("prune = %d, %v; want 1", n, err)
+	}
+
+	// History outlives the sprite.
+	if err := db.DeleteSprite("ev-sprite"); err != nil {
+		t.Fatalf("delete sprite: %v", err)
+	}
+	if events, _ := db.ListEvents(EventListOptions{SpriteName: "ev-sprite"}); len(events) != 4 {
+		t.Errorf("events after delete = %d, want 4", len(events))
+	}
+}
+
+func TestUsage(t *testing.T) {
+	db := testDB(t)
+	db.UpsertSprite(&Sprite{Name: "gh-acme--api--spike", BaseName: "gh-acme--api", Variant: "spike"})
+
+	day := time.Now().Add(-24 * time.Hour).Truncate(time.Second)
+	hour := func(n int) time.Time { return day.Add(time.Duration(n) * time.Hour) }
+
+	// Two hours running, a gap, then still running.
+	if err := db.StartUsage("gh-acme--api--spike", hour(1)); err != nil {
+		t.Fatalf("start: %v", err)
+	}
+	db.StartUsage("gh-acme--api--spike", hour(2)) // already open: ignored
+	if err := db.EndUsage("gh-acme--api--spike", hour(3)); err != nil {
+		t.Fatalf("end: %v", err)
+	}
+	db.EndUsage("gh-acme--api--spike", hour(4))   // none open: ignored
+	db.StartUsage("gh-acme--api--spike", hour(2)) // before the last end: clamped
+	db.StartUsage("gone", hour(5))
+
+	usage, err := db.ListUsage(day)
+	if err != nil {
+		t.Fatalf("list: %v", err)
+	}
+	if len(usage) != 3 {
+		t.Fatalf("got %d intervals, want 3: %+v", len(usage), usage)
+	}
+	first := usage[0]
+	if first.BaseName != "gh-acme--api" || first.Variant != "spike" {
+		t.Errorf("first interval = %+v", first)
+	}
+	if d := first.Overlap(day, hour(24)); d != 2*time.Hour {
+		t.Errorf("first interval = %v, want 2h", d)
+	}
+	if d := first.Overlap(hour(2), hour(24)); d != time.Hour {
+		t.Errorf("clipped first interval = %v, want 1h", d)
+	}
+	if !usage[1].StartedAt.Equal(hour(3)) || !usage[1].EndedAt.IsZero() {
+		t.Errorf("reopened interval = %+v, want open from hour 3", usage[1])
+	}
+	if d := usage[1].Overlap(day, hour(6)); d != 3*time.Hour {
+		t.Errorf("open interval = %v, want 3h", d)
+	}
+	if usage[2].BaseName != "gone" {
+		t.Errorf("untracked sprite's base name = %q, want its own name", usage[2].BaseName)
+	}
+
+	if recent, _ := db.ListUsage(hour(4)); len(recent) != 2 {
+		t.Errorf("intervals since hour 4 = %d, want 2", len(recent))
+	}
+}
+
+func TestDeleteSpriteEndsUsage(t *testing.T) {
+	db := testDB(t)
+	db.UpsertSprite(&Sprite{Name: "api"})
+	db.UpsertSprite(&Sprite{Name: "web"})
+	start := time.Now().Add(-time.Hour)
+	db.StartUsage("api", start)
+	db.StartUsage("web", start)
+
+	if err := db.DeleteSprite("api"); err != nil {
+		t.Fatalf("DeleteSprite: %v", err)
+	}
+	open, err := db.ListOpenUsage()
+	if err != nil {
+		t.Fatalf("ListOpenUsage: %v", err)
+	}
+	if len(open) != 1 || open[0].SpriteName != "web" {
+		t.Errorf("open intervals = %+v, want only web's", open)
+	}
+	usage, _ := db.ListUsage(start.Add(-time.Minute))
+	if len(usage) != 2 || usage[0].SpriteName != "api" || usage[0].EndedAt.IsZero() {
+		t.Errorf("usage = %+v, want api's interval kept and ended", usage)
+	}
+}
+
+func TestBudgets(t *testing.T) {
+	db := testDB(t)
+	db.UpsertSprite(&Sprite{Name: "api"})
+	db.UpsertSprite(&Sprite{Name: "web"})
+	db.AddTag("api", "work")
+
+	now := time.Now()
+	start := BudgetPeriodStart(now)
+	if now.Sub(start) < 3*time.Hour {
+		t.Skip("too close to the start of the month")
+	}
+	db.StartUsage("api", now.Add(-3*time.Hour))
+	db.EndUsage("api", now.Add(-time.Hour))
+	db.StartUsage("web", now.Add(-time.Hour))
+	db.StartUsage("old", start.Add(-time.Hour)) // only counts from the period start
+	db.EndUsage("old", start.Add(time.Hour))
+
+	for _, b := range []*Budget{
+		{Scope: BudgetGlobal, Target: "ignored", Hours: 10},
+		{Scope: BudgetTag, Target: "work", Hours: 2, WarnPercent: 50},
+		{Scope: BudgetSprite, Target: "web", Hours: 4},
+	} {
+		if err := db.SetBudget(b); err != nil {
+			t.Fatalf("SetBudget(%s): %v", b.Label(), err)
+		}
+	}
+
+	all, err := db.BudgetStatuses("", now)
+	if err != nil {
+		t.Fatalf("BudgetStatuses: %v", err)
+	}
+	var got []string
+	for _, s := range all {
+		got = append(got, fmt.Sprintf("%s %.0fh exhausted=%v warning=%v", s.Budget.Label(), s.Used.Hours(), s.Exhausted(), s.Warning()))
+	}
+	want := []string{
+		"global 4h exhausted=false warning=false",
+		"tag work 2h exhausted=true warning=true",
+		"sprite web 1h exhausted=false warning=false",
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("statuses:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+	if all[0].Budget.Target != "" || all[0].Budget.WarnPercent != DefaultBudgetWarnPercent {
+		t.Errorf("global budget = %+v", all[0].Budget)
+	}
+
+	web, _ := db.BudgetStatuses("web", now)
+	if len(web) != 2 || web[1].Remaining().Round(time.Minute) != 3*time.Hour {
+		t.Errorf("web's budgets = %+v", web)
+	}
+
+	if ok, err := db.DeleteBudget(BudgetTag, "work"); !ok || err != nil {
+		t.Errorf("DeleteBudget = %v, %v", ok, err)
+	}
+	if ok, _ := db.DeleteBudget(BudgetTag, "work"); ok {
+		t.Error("deleted the same budget twice")
+	}
+	if api, _ := db.BudgetStatuses("api", now); len(api) != 1 {
+		t.Errorf("api's budgets after delete = %d, want 1", len(api))
+	}
+}
+
+func TestBudgetsStopCountingDeletedSprites(t *testing.T) {
+	db := testDB(t)
+	db.UpsertSprite(&Sprite{Name: "api"})
+	db.SetBudget(&Budget{Scope: BudgetGlobal, Hours: 10})
+
+	now := time.Now()
+	if now.Sub(BudgetPeriodStart(now)) < 2*time.Hour || !BudgetPeriodStart(now.Add(5*time.Hour)).Equal(BudgetPeriodStart(now)) {
+		t.Skip("too close to a month boundary")
+	}
+	db.StartUsage("api", now.Add(-2*time.Hour))
+	if err := db.DeleteSprite("api"); err != nil {
+		t.Fatalf("DeleteSprite: %v", err)
+	}
+
+	// The two hours it ran still count; the hours after deletion don't.
+	for _, at := range []time.Time{now, now.Add(5 * time.Hour)} {
+		statuses, err := db.BudgetStatuses("", at)
+		if err != nil {
+			t.Fatalf("BudgetStatuses: %v", err)
+		}
+		if used := statuses[0].Used.Round(time.Minute); used != 2*time.Hour {
+			t.Errorf("global usage at %s = %s, want 2h", at.Format(time.Kitchen), used)
+		}
+	}
+}
@@ -0,0 +1,166 @@
+package store
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Event kinds recorded in a sprite's history.
+const (
+	EventStatus    = "status"    // sprite status changed (From → To)
+	EventSync      = "sync"      // sync status changed (From → To), Message is the sync error
+	EventProxy     = "proxy"     // the SSH proxy exited unexpectedly
+	EventSetup     = "setup"     // setup.conf ran; To is "ok" or "failed"
+	EventKeepalive = "keepalive" // a hold was started or released; To is "held" or "released"
+	EventHook      = "hook"      // a configured hook ran; To is "ok" or "failed"
+	EventConflict  = "conflict"  // a sync conflict was resolved; To is the version kept, Message the path
+)
+
+// Event is one entry in a sprite's append-only history.
+type Event struct {
+	ID         int64
+	SpriteName string
+	Kind       string // one of the Event* constants
+	From       string // previous value, for transitions
+	To         string // new value or outcome
+	Message    string
+	CreatedAt  time.Time
+}
+
+// Change describes what the event changed, e.g. "cold → running", or just
+// the outcome for events that aren't transitions.
+func (e *Event) Change() string {
+	if e.From != "" && e.To != "" {
+		return e.From + " → " + e.To
+	}
+	return e.To
+}
+
+// EventListOptions filters ListEvents.
+type EventListOptions struct {
+	SpriteName string    // only this sprite's events ("" = all sprites)
+	Since      time.Time // only events at or after this time (zero = no limit)
+	Kinds      []string  // only these kinds (empty = all)
+	Limit      int       // at most this many of the newest matches (0 = no limit)
+}
+
+// execer is the part of *sql.DB and *sql.Tx that addEvent needs.
+type execer interface {
+	Exec(query string, args ...any) (sql.Result, error)
+}
+
+// AddEvent appends an event, stamping it with the current time if it has
+// none. Times are stored in UTC so they compare correctly as text.
+func (d *DB) AddEvent(e *Event) error {
+	return addEvent(d.db, e)
+}
+
+func addEvent(db execer, e *Event) error {
+	createdAt := e.CreatedAt
+	if createdAt.IsZero() {
+		createdAt = time.Now()
+	}
+	_, err := db.Exec(`
+		INSERT INTO events (sprite_name, kind, from_value, to_value, message, created_at)
+		VALUES (?, ?, ?, ?, ?, ?)
+	`, e.SpriteName, e.Kind, e.From, e.To, e.Message, createdAt.UTC())
+	if err != nil {
+		return fmt.Errorf("recording %s event for %q: %w", e.Kind, e.SpriteName, err)
+	}
+	return nil
+}
+
+// ListEvents returns matching events, oldest first.
+func (d *DB) ListEvents(opts EventListOptions) ([]*Event, error) {
+	var (
+		wheres []string
+		args   []any
+	)
+	if opts.SpriteName != "" {
+		wheres = append(wheres, "sprite_name = ?")
+		args = append(args, opts.SpriteName)
+	}
+	if !opts.Since.IsZero() {
+		wheres = append(wheres, "created_at >= ?")
+		args = append(args, opts.Since.UTC())
+	}
+	if len(opts.Kinds) > 0 {
+		wheres = append(wheres, "kind IN (?"+strings.Repeat(", ?", len(opts.Kinds)-1)+")")
+		for _, k := range opts.Kinds {
+			args = append(args, k)
+		}
+	}
+
+	query := `SELECT id, sprite_name, kind, from_value, to_value, message, created_at FROM events`
+	if len(wheres) > 0 {
+		query += " WHERE " + strings.Join(wheres, " AND ")
+	}
+	// Take the newest Limit rows, then put them back in time order.
+	query += " ORDER BY id DESC"
+	if opts.Limit > 0 {
+		query += fmt.Sprintf(" LIMIT %d", opts.Limit)
+	}
+
+	rows, err := d.db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("listing events: %w", err)
+	}
+	defer rows.Close()
+
+	var events []*Event
+	for rows.Next() {
+		e := &Event{}
+		if err := rows.Scan(&e.ID, &e.SpriteName, &e.Kind, &e.From, &e.To, &e.Message, &e.CreatedAt); err != nil {
+			return nil, fmt.Errorf("scanning event: %w", err)
+		}
+		events = append(events, e)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	for i, j := 0, len(events)-1; i < j; i, j = i+1, j-1 {
+		events[i], events[j] = events[j], events[i]
+	}
+	return events, nil
+}
+
+// PruneEvents deletes events older than cutoff and returns how many went.
+func (d *DB) PruneEvents(cutoff time.Time) (int64, error) {
+	res, err := d.db.Exec(`DELETE FROM events WHERE created_at < ?`, cutoff.UTC())
+	if err != nil {
+		return 0, fmt.Errorf("pruning events: %w", err)
+	}
+	return res.RowsAffected()
+}
+
+// updateRecordingTransition runs an UPDATE of one sprite column and, if the
+// column's value changed, records an event of the given kind from the old
+// value to the new one, all in one transaction.
+func (d *DB) updateRecordingTransition(name, column, kind, value, message, update string, args ...any) error {
+	tx, err := d.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var old sql.NullString
+	err = tx.QueryRow(`SELECT `+column+` FROM sprites WHERE name = ?`, name).Scan(&old)
+	if err == sql.ErrNoRows {
+		return nil // nothing to update
+	}
+	if err != nil {
+		return err
+	}
+	if _, err := tx.Exec(update, args...); err != nil {
+		return err
+	}
+	if old.String != value {
+		e := &Event{SpriteName: name, Kind: kind, From: old.String, To: value, Message: message}
+		if err := addEvent(tx, e); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
@@ -0,0 +1,65 @@
+package store
+
+import (
+	"fmt"
+	"time"
+)
+
+// MetaNote is the metadata key `sp note` writes a sprite's free-form note to.
+const MetaNote = "note"
+
+// Meta is one key/value annotation on a sprite, e.g. the ticket a variant
+// is for or who owns it. Unlike tags, annotations aren't used for filtering;
+// they record why a sprite exists.
+type Meta struct {
+	Key       string
+	Value     string
+	UpdatedAt time.Time
+}
+
+// SetMeta sets a sprite's annotation, replacing any existing value for the key.
+func (d *DB) SetMeta(spriteName, key, value string) error {
+	_, err := d.db.Exec(`
+		INSERT INTO sprite_meta (sprite_name, key, value, updated_at)
+		VALUES (?, ?, ?, ?)
+		ON CONFLICT(sprite_name, key) DO UPDATE SET
+			value = excluded.value,
+			updated_at = excluded.updated_at
+	`, spriteName, key, value, time.Now())
+	if err != nil {
+		return fmt.Errorf("setting %q on sprite %q: %w", key, spriteName, err)
+	}
+	return nil
+}
+
+// DeleteMeta removes a sprite's annotation. It reports whether there was one.
+func (d *DB) DeleteMeta(spriteName, key string) (bool, error) {
+	res, err := d.db.Exec(`DELETE FROM sprite_meta WHERE sprite_name = ? AND key = ?`, spriteName, key)
+	if err != nil {
+		return false, fmt.Errorf("removing %q from sprite %q: %w", key, spriteName, err)
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
+
+// GetMeta returns a sprite's annotations, ordered by key.
+func (d *DB) GetMeta(spriteName string) ([]*Meta, error) {
+	rows, err := d.db.Query(`
+		SELECT key, value, updated_at FROM sprite_meta
+		WHERE sprite_name = ? ORDER BY key
+	`, spriteName)
+	if err != nil {
+		return nil, fmt.Errorf("getting metadata for sprite %q: %w", spriteName, err)
+	}
+	defer rows.Close()
+
+	var meta []*Meta
+	for rows.Next() {
+		m := &Meta{}
+		if err := rows.Scan(&m.Key, &m.Value, &m.UpdatedAt); err != nil {
+			return nil, fmt.Errorf("scanning metadata: %w", err)
+		}
+		meta = append(meta, m)
+	}
+	return meta, rows.Err()
+}
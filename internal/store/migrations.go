@@ -0,0 +1,434 @@
+package store
+
+import (
+	"database/sql"
+	"fmt"
+	"sort"
+	"time"
+)
+
+// migration is one numbered schema change. up runs in a transaction along
+// with recording the version in schema_migrations, so a failure leaves the
+// database at the previous version. down undoes it for MigrateTo; nil
+// means the migration can't be undone.
+type migration struct {
+	version int
+	name    string
+	up      func(tx *sql.Tx) error
+	down    func(tx *sql.Tx) error
+}
+
+// migrations is the schema history, oldest first. Released entries must
+// never be edited or reordered — add a new one instead. Versions 1–4
+// predate version tracking: databases created before it already have some
+// or all of their changes, so they are written to be no-ops there.
+var migrations = []migration{
+	{
+		version: 1,
+		name:    "create sprites, tags and sync_sessions",
+		up: execAll(
+			`CREATE TABLE IF NOT EXISTS sprites (
+				name TEXT PRIMARY KEY,
+				local_path TEXT,
+				remote_path TEXT,
+				repo TEXT,
+				org TEXT,
+				sprite_id TEXT,
+				url TEXT,
+				status TEXT DEFAULT 'unknown',
+				sync_status TEXT DEFAULT 'none',
+				sync_error TEXT DEFAULT '',
+				last_seen DATETIME,
+				created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+				updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
+			)`,
+			`CREATE TABLE IF NOT EXISTS tags (
+				sprite_name TEXT REFERENCES sprites(name) ON DELETE CASCADE,
+				tag TEXT,
+				PRIMARY KEY (sprite_name, tag)
+			)`,
+			`CREATE TABLE IF NOT EXISTS sync_sessions (
+				sprite_name TEXT REFERENCES sprites(name) ON DELETE CASCADE UNIQUE,
+				mutagen_id TEXT,
+				ssh_port INTEGER,
+				proxy_pid INTEGER,
+				alpha_connected BOOLEAN DEFAULT 0,
+				beta_connected BOOLEAN DEFAULT 0,
+				conflicts INTEGER DEFAULT 0,
+				last_error TEXT DEFAULT '',
+				updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
+			)`,
+		),
+		down: execAll(
+			`DROP TABLE sync_sessions`,
+			`DROP TABLE tags`,
+			`DROP TABLE sprites`,
+		),
+	},
+	{
+		version: 2,
+		name:    "add variant, base_name and pinned to sprites",
+		up: func(tx *sql.Tx) error {
+			for _, a := range []struct{ column, ddl string }{
+				{"variant", `ALTER TABLE sprites ADD COLUMN variant TEXT DEFAULT ''`},
+				{"base_name", `ALTER TABLE sprites ADD COLUMN base_name TEXT DEFAULT ''`},
+				{"pinned", `ALTER TABLE sprites ADD COLUMN pinned BOOLEAN DEFAULT 0`},
+			} {
+				if err := addColumnIfMissing(tx, "sprites", a.column, a.ddl); err != nil {
+					return fmt.Errorf("adding column %s: %w", a.column, err)
+				}
+			}
+			return nil
+		},
+		down: execAll(
+			`ALTER TABLE sprites DROP COLUMN pinned`,
+			`ALTER TABLE sprites DROP COLUMN base_name`,
+			`ALTER TABLE sprites DROP COLUMN variant`,
+		),
+	},
+	{
+		// Rows from before variants were registered without a base name;
+		// BaseName is documented to equal Name for non-variant sprites.
+		version: 3,
+		name:    "backfill base_name for sprites without one",
+		up: execAll(
+			`UPDATE sprites SET variant = '' WHERE variant IS NULL`,
+			`UPDATE sprites SET pinned = 0 WHERE pinned IS NULL`,
+			`UPDATE sprites SET base_name = name WHERE (base_name IS NULL OR base_name = '') AND variant = ''`,
+		),
+		down: execAll(), // the backfilled values are still correct
+	},
+	{
+		version: 4,
+		name:    "create checkpoints",
+		up: execAll(
+			`CREATE TABLE IF NOT EXISTS checkpoints (
+				sprite_name TEXT REFERENCES sprites(name) ON DELETE CASCADE,
+				checkpoint_id TEXT,
+				label TEXT DEFAULT '',
+				created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+				PRIMARY KEY (sprite_name, checkpoint_id)
+			)`,
+		),
+		down: execAll(`DROP TABLE checkpoints`),
+	},
+	{
+		// No foreign key to sprites: history outlives the sprite.
+		version: 5,
+		name:    "create events",
+		up: execAll(
+			`CREATE TABLE events (
+				id INTEGER PRIMARY KEY AUTOINCREMENT,
+				sprite_name TEXT NOT NULL,
+				kind TEXT NOT NULL,
+				from_value TEXT DEFAULT '',
+				to_value TEXT DEFAULT '',
+				message TEXT DEFAULT '',
+				created_at DATETIME NOT NULL
+			)`,
+			`CREATE INDEX events_sprite_time ON events (sprite_name, created_at)`,
+			`CREATE INDEX events_time ON events (created_at)`,
+		),
+		down: execAll(`DROP TABLE events`),
+	},
+	{
+		// Base name and variant are copied in so usage can still be
+		// grouped after the sprite is deleted.
+		version: 6,
+		name:    "create usage intervals",
+		up: execAll(
+			`CREATE TABLE usage_intervals (
+				id INTEGER PRIMARY KEY AUTOINCREMENT,
+				sprite_name TEXT NOT NULL,
+				base_name TEXT DEFAULT '',
+				variant TEXT DEFAULT '',
+				started_at DATETIME NOT NULL,
+				ended_at DATETIME
+			)`,
+			`CREATE INDEX usage_intervals_sprite ON usage_intervals (sprite_name, started_at)`,
+		),
+		down: execAll(`DROP TABLE usage_intervals`),
+	},
+	{
+		version: 7,
+		name:    "create budgets",
+		up: execAll(
+			`CREATE TABLE budgets (
+				scope TEXT NOT NULL,
+				target TEXT NOT NULL DEFAULT '',
+				hours REAL NOT NULL,
+				warn_percent INTEGER NOT NULL DEFAULT 80,
+				PRIMARY KEY (scope, target)
+			)`,
+		),
+		down: execAll(`DROP TABLE budgets`),
+	},
+	{
+		version: 8,
+		name:    "create sprite_meta",
+		up: execAll(
+			`CREATE TABLE sprite_meta (
+				sprite_name TEXT NOT NULL REFERENCES sprites(name) ON DELETE CASCADE,
+				key TEXT NOT NULL,
+				value TEXT NOT NULL DEFAULT '',
+				updated_at DATETIME NOT NULL,
+				PRIMARY KEY (sprite_name, key)
+			)`,
+		),
+		down: execAll(`DROP TABLE sprite_meta`),
+	},
+	{
+		// Before pauses were stored, a daemon restart forgot them but left
+		// sync_status at "paused", so those rows are stale.
+		version: 9,
+		name:    "create sync_pauses",
+		up: execAll(
+			`CREATE TABLE sync_pauses (
+				sprite_name TEXT PRIMARY KEY REFERENCES sprites(name) ON DELETE CASCADE,
+				paused_at DATETIME NOT NULL
+			)`,
+			`UPDATE sprites SET sync_status = 'idle' WHERE sync_status = 'paused'`,
+		),
+		down: execAll(`DROP TABLE sync_pauses`),
+	},
+}
+
+// LatestSchemaVersion is the schema version this build of sp migrates to.
+func LatestSchemaVersion() int {
+	return migrations[len(migrations)-1].version
+}
+
+// MigrationStatus describes one schema migration and whether it has been
+// applied to a database.
+type MigrationStatus struct {
+	Version   int
+	Name      string
+	AppliedAt time.Time // zero if pending
+	// Unknown marks a version recorded in the database that this build of
+	// sp doesn't have, i.e. one applied by a newer sp.
+	Unknown bool
+}
+
+// Applied reports whether the migration has run.
+func (m MigrationStatus) Applied() bool {
+	return !m.AppliedAt.IsZero()
+}
+
+// execAll returns a migration step that runs the statements in order.
+func execAll(stmts ...string) func(tx *sql.Tx) error {
+	return func(tx *sql.Tx) error {
+		for _, s := range stmts {
+			if _, err := tx.Exec(s); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
+
+// migrate applies every pending migration.
+func (d *DB) migrate() error {
+	return d.MigrateTo(LatestSchemaVersion())
+}
+
+// MigrateTo brings the schema to the given version: pending migrations up
+// to it are applied in order, and applied ones above it are rolled back,
+// newest first. Each step commits on its own, so an error leaves the
+// database at the last version that succeeded.
+func (d *DB) MigrateTo(version int) error {
+	if version < 0 || version > LatestSchemaVersion() {
+		return fmt.Errorf("unknown schema version %d (latest is %d)", version, LatestSchemaVersion())
+	}
+	if _, err := d.db.Exec(`CREATE TABLE IF NOT EXISTS schema_migrations (
+		version INTEGER PRIMARY KEY,
+		name TEXT NOT NULL,
+		applied_at DATETIME NOT NULL
+	)`); err != nil {
+		return fmt.Errorf("creating schema_migrations: %w", err)
+	}
+
+	statuses, err := d.MigrationStatus()
+	if err != nil {
+		return err
+	}
+	applied := make(map[int]bool)
+	for _, s := range statuses {
+		applied[s.Version] = s.Applied()
+	}
+
+	for _, m := range migrations {
+		if m.version > version {
+			break
+		}
+		if applied[m.version] {
+			continue
+		}
+		if err := d.applyMigration(m); err != nil {
+			return err
+		}
+	}
+	for i := len(migrations) - 1; i >= 0 && migrations[i].version > version; i-- {
+		if !applied[migrations[i].version] {
+			continue
+		}
+		if err := d.revertMigration(migrations[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// applyMigration runs m unless it has already been applied. Transactions
+// take SQLite's write lock as they begin (see OpenPathWithoutMigrating), so
+// when another process — say, the daemon and a CLI starting together — is
+// applying the same migration, this one waits for it and then finds the
+// version already recorded.
+func (d *DB) applyMigration(m migration) error {
+	tx, err := d.db.Begin()
+	if err != nil {
+		return fmt.Errorf("migration %d: %w", m.version, err)
+	}
+	defer tx.Rollback()
+
+	res, err := tx.Exec(`INSERT OR IGNORE INTO schema_migrations (version, name, applied_at) VALUES (?, ?, ?)`,
+		m.version, m.name, time.Now())
+	if err != nil {
+		return fmt.Errorf("migration %d: recording version: %w", m.version, err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return nil // already applied
+	}
+	if err := m.up(tx); err != nil {
+		return fmt.Errorf("migration %d (%s): %w", m.version, m.name, err)
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("migration %d: committing: %w", m.version, err)
+	}
+	return nil
+}
+
+// revertMigration undoes m if it has been applied.
+func (d *DB) revertMigration(m migration) error {
+	tx, err := d.db.Begin()
+	if err != nil {
+		return fmt.Errorf("reverting migration %d: %w", m.version, err)
+	}
+	defer tx.Rollback()
+
+	res, err := tx.Exec(`DELETE FROM schema_migrations WHERE version = ?`, m.version)
+	if err != nil {
+		return fmt.Errorf("reverting migration %d: %w", m.version, err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return nil // not applied
+	}
+	if m.down == nil {
+		return fmt.Errorf("migration %d (%s) can't be rolled back", m.version, m.name)
+	}
+	if err := m.down(tx); err != nil {
+		return fmt.Errorf("reverting migration %d (%s): %w", m.version, m.name, err)
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("reverting migration %d: committing: %w", m.version, err)
+	}
+	return nil
+}
+
+// SchemaVersion returns the highest migration version applied to the
+// database, or 0 for one that has never been migrated by a version-tracking
+// sp (even if an older sp created its tables).
+func (d *DB) SchemaVersion() (int, error) {
+	statuses, err := d.MigrationStatus()
+	if err != nil {
+		return 0, err
+	}
+	version := 0
+	for _, s := range statuses {
+		if s.Applied() && s.Version > version {
+			version = s.Version
+		}
+	}
+	return version, nil
+}
+
+// MigrationStatus lists every migration this build of sp knows, plus any
+// newer ones recorded in the database, in version order.
+func (d *DB) MigrationStatus() ([]MigrationStatus, error) {
+	var exists int
+	err := d.db.QueryRow(`SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name = 'schema_migrations'`).Scan(&exists)
+	if err != nil {
+		return nil, fmt.Errorf("checking for schema_migrations: %w", err)
+	}
+
+	applied := make(map[int]MigrationStatus)
+	if exists > 0 {
+		rows, err := d.db.Query(`SELECT version, name, applied_at FROM schema_migrations ORDER BY version`)
+		if err != nil {
+			return nil, fmt.Errorf("listing applied migrations: %w", err)
+		}
+		defer rows.Close()
+		for rows.Next() {
+			var s MigrationStatus
+			if err := rows.Scan(&s.Version, &s.Name, &s.AppliedAt); err != nil {
+				return nil, fmt.Errorf("scanning migration: %w", err)
+			}
+			applied[s.Version] = s
+		}
+		if err := rows.Err(); err != nil {
+			return nil, err
+		}
+	}
+
+	var statuses []MigrationStatus
+	for _, m := range migrations {
+		s := MigrationStatus{Version: m.version, Name: m.name}
+		if a, ok := applied[m.version]; ok {
+			s.AppliedAt = a.AppliedAt
+			delete(applied, m.version)
+		}
+		statuses = append(statuses, s)
+	}
+	var unknown []int
+	for v := range applied {
+		unknown = append(unknown, v)
+	}
+	sort.Ints(unknown)
+	for _, v := range unknown {
+		a := applied[v]
+		a.Unknown = true
+		statuses = append(statuses, a)
+	}
+	return statuses, nil
+}
+
+// addColumnIfMissing runs an ALTER TABLE only if the column doesn't already
+// exist. SQLite lacks ADD COLUMN IF NOT EXISTS, so we inspect PRAGMA table_info.
+func addColumnIfMissing(tx *sql.Tx, table, column, ddl string) error {
+	rows, err := tx.Query(fmt.Sprintf("PRAGMA table_info(%s)", table))
+	if err != nil {
+		return fmt.Errorf("inspecting %s: %w", table, err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var (
+			cid        int
+			name       string
+			colType    string
+			notNull    int
+			dfltValue  sql.NullString
+			primaryKey int
+		)
+		if err := rows.Scan(&cid, &name, &colType, &notNull, &dfltValue, &primaryKey); err != nil {
+			return err
+		}
+		if name == column {
+			return nil
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	rows.Close()
+	_, err = tx.Exec(ddl)
+	return err
+}
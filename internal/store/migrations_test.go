@@ -0,0 +1,290 @@
+package store
+
+import (
+	"database/sql"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// schema describes every table except schema_migrations as
+// "table: column type default pk" lines, for comparing databases.
+func schema(t *testing.T, d *DB) string {
+	t.Helper()
+	rows, err := d.db.Query(`SELECT name FROM sqlite_master
+		WHERE type = 'table' AND name NOT LIKE 'sqlite_%' AND name != 'schema_migrations'
+		ORDER BY name`)
+	if err != nil {
+		t.Fatalf("listing tables: %v", err)
+	}
+	var tables []string
+	for rows.Next() {
+		var name string
+		rows.Scan(&name)
+		tables = append(tables, name)
+	}
+	rows.Close()
+
+	var b strings.Builder
+	for _, table := range tables {
+		cols, err := d.db.Query(fmt.Sprintf("PRAGMA table_info(%s)", table))
+		if err != nil {
+			t.Fatalf("inspecting %s: %v", table, err)
+		}
+		for cols.Next() {
+			var (
+				cid, notNull, pk int
+				name, colType    string
+				dflt             sql.NullString
+			)
+			cols.Scan(&cid, &name, &colType, &notNull, &dflt, &pk)
+			fmt.Fprintf(&b, "%s: %s %s %s %d\n", table, name, colType, dflt.String, pk)
+		}
+		cols.Close()
+	}
+	return b.String()
+}
+
+// freshSchema returns the schema of a newly created database.
+func freshSchema(t *testing.T) string {
+	t.Helper()
+	return schema(t, testDB(t))
+}
+
+// fixtureDB creates a database from a testdata SQL file, as an older sp
+// would have left it, and returns its path.
+func fixtureDB(t *testing.T, fixture string) string {
+	t.Helper()
+	script, err := os.ReadFile(filepath.Join("testdata", fixture))
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "sp.db")
+	raw, err := sql.Open("sqlite", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer raw.Close()
+	if _, err := raw.Exec(string(script)); err != nil {
+		t.Fatalf("loading %s: %v", fixture, err)
+	}
+	return path
+}
+
+func TestUpgradeLegacyDatabases(t *testing.T) {
+	want := freshSchema(t)
+
+	for _, fixture := range []string{"legacy-base.sql", "legacy-variants.sql", "legacy-checkpoints.sql"} {
+		t.Run(fixture, func(t *testing.T) {
+			path := fixtureDB(t, fixture)
+
+			db, err := OpenPathWithoutMigrating(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if v, err := db.SchemaVersion(); err != nil || v != 0 {
+				t.Errorf("SchemaVersion before upgrade = %d, %v; want 0", v, err)
+			}
+			db.Close()
+
+			db, err = OpenPath(path)
+			if err != nil {
+				t.Fatalf("upgrading: %v", err)
+			}
+			defer db.Close()
+
+			if v, err := db.SchemaVersion(); err != nil || v != LatestSchemaVersion() {
+				t.Errorf("SchemaVersion = %d, %v; want %d", v, err, LatestSchemaVersion())
+			}
+			if got := schema(t, db); got != want {
+				t.Errorf("upgraded schema differs from a fresh one:\n got:\n%s\nwant:\n%s", got, want)
+			}
+
+			// Existing data survives, and old rows get a base name.
+			s, err := db.GetSprite("gh-acme--api")
+			if err != nil || s == nil {
+				t.Fatalf("GetSprite = %v, %v", s, err)
+			}
+			if s.LocalPath != "/Users/dev/acme/api" || s.Org != "acme" || s.Status != "running" {
+				t.Errorf("sprite = %+v", s)
+			}
+			if s.BaseName != "gh-acme--api" || s.Variant != "" || s.Pinned {
+				t.Errorf("backfilled sprite: base_name=%q variant=%q pinned=%v", s.BaseName, s.Variant, s.Pinned)
+			}
+			if tags, _ := db.GetTags("gh-acme--api"); len(tags) != 1 || tags[0] != "work" {
+				t.Errorf("tags = %v, want [work]", tags)
+			}
+			if v, _ := db.GetSprite("gh-acme--api--spike"); v != nil && (v.BaseName != "gh-acme--api" || !v.Pinned) {
+				t.Errorf("variant = %+v", v)
+			}
+
+			// The store is fully usable afterwards.
+			if err := db.AddCheckpoint(&Checkpoint{SpriteName: "gh-acme--api", CheckpointID: "v9"}); err != nil {
+				t.Errorf("AddCheckpoint: %v", err)
+			}
+		})
+	}
+}
+
+func TestMigrateFromEveryVersion(t *testing.T) {
+	want := freshSchema(t)
+
+	for v := 0; v < LatestSchemaVersion(); v++ {
+		t.Run(fmt.Sprintf("v%d", v), func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "sp.db")
+			db, err := OpenPathWithoutMigrating(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := db.MigrateTo(v); err != nil {
+				t.Fatalf("MigrateTo(%d): %v", v, err)
+			}
+			if got, _ := db.SchemaVersion(); got != v {
+				t.Errorf("SchemaVersion = %d, want %d", got, v)
+			}
+			db.Close()
+
+			db, err = OpenPath(path)
+			if err != nil {
+				t.Fatalf("upgrading from %d: %v", v, err)
+			}
+			defer db.Close()
+			if got := schema(t, db); got != want {
+				t.Errorf("schema after upgrade from %d:\n%s\nwant:\n%s", v, got, want)
+			}
+		})
+	}
+}
+
+func TestMigrateClearsStalePauses(t *testing.T) {
+	db := testDB(t)
+	if err := db.MigrateTo(8); err != nil {
+		t.Fatalf("MigrateTo(8): %v", err)
+	}
+	if err := db.UpsertSprite(&Sprite{Name: "x", SyncStatus: "paused"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.MigrateTo(9); err != nil {
+		t.Fatalf("MigrateTo(9): %v", err)
+	}
+	if s, _ := db.GetSprite("x"); s.SyncStatus != "idle" {
+		t.Errorf("sync status = %q, want idle", s.SyncStatus)
+	}
+}
+
+func TestMigrateDown(t *testing.T) {
+	db := testDB(t)
+	if err := db.UpsertSprite(&Sprite{Name: "x"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.AddCheckpoint(&Checkpoint{SpriteName: "x", CheckpointID: "c1"}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := db.MigrateTo(3); err != nil {
+		t.Fatalf("MigrateTo(3): %v", err)
+	}
+	if strings.Contains(schema(t, db), "checkpoints:") {
+		t.Error("checkpoints table still exists at version 3")
+	}
+	if err := db.MigrateTo(1); err != nil {
+		t.Fatalf("MigrateTo(1): %v", err)
+	}
+	if strings.Contains(schema(t, db), "base_name") {
+		t.Error("variant columns still exist at version 1")
+	}
+	if v, _ := db.SchemaVersion(); v != 1 {
+		t.Errorf("SchemaVersion = %d, want 1", v)
+	}
+	if err := db.MigrateTo(LatestSchemaVersion() + 1); err == nil {
+		t.Error("MigrateTo past the latest version succeeded")
+	}
+}
+
+func TestFailedMigrationRollsBack(t *testing.T) {
+	db := testDB(t)
+	latest := LatestSchemaVersion()
+
+	orig := migrations
+	t.Cleanup(func() { migrations = orig })
+	migrations = append(append([]migration(nil), orig...), migration{
+		version: latest + 1,
+		name:    "half-finished",
+		up: execAll(
+			`CREATE TABLE half (x TEXT)`,
+			`INSERT INTO no_such_table VALUES (1)`,
+		),
+	})
+
+	if err := db.migrate(); err == nil {
+		t.Fatal("migrate succeeded with a broken migration")
+	}
+	if v, _ := db.SchemaVersion(); v != latest {
+		t.Errorf("SchemaVersion = %d, want %d", v, latest)
+	}
+	if strings.Contains(schema(t, db), "half:") {
+		t.Error("failed migration left its table behind")
+	}
+}
+
+func TestMigrationStatusUnknownVersion(t *testing.T) {
+	db := testDB(t)
+	latest := LatestSchemaVersion()
+	if _, err := db.db.Exec(`INSERT INTO schema_migrations (version, name, applied_at) VALUES (?, 'from the future', CURRENT_TIMESTAMP)`, latest+5); err != nil {
+		t.Fatal(err)
+	}
+
+	statuses, err := db.MigrationStatus()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(statuses) != latest+1 {
+		t.Fatalf("got %d statuses, want %d", len(statuses), latest+1)
+	}
+	for _, s := range statuses[:latest] {
+		if !s.Applied() || s.Unknown {
+			t.Errorf("migration %d: applied=%v unknown=%v", s.Version, s.Applied(), s.Unknown)
+		}
+	}
+	if last := statuses[latest]; !last.Unknown || last.Name != "from the future" {
+		t.Errorf("last status = %+v, want the unknown migration", last)
+	}
+
+	// Opening with an older sp still works.
+	if err := db.migrate(); err != nil {
+		t.Errorf("migrate with a newer version recorded: %v", err)
+	}
+}
+
+func TestConcurrentOpenMigratesOnce(t *testing.T) {
+	path := fixtureDB(t, "legacy-base.sql")
+
+	errs := make(chan error, 4)
+	for range 4 {
+		go func() {
+			db, err := OpenPath(path)
+			if err == nil {
+				db.Close()
+			}
+			errs <- err
+		}()
+	}
+	for range 4 {
+		if err := <-errs; err != nil {
+			t.Errorf("concurrent open: %v", err)
+		}
+	}
+
+	db, err := OpenPath(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	var n int
+	db.db.QueryRow(`SELECT COUNT(*) FROM schema_migrations`).Scan(&n)
+	if n != LatestSchemaVersion() {
+		t.Errorf("schema_migrations has %d rows, want %d", n, LatestSchemaVersion())
+	}
+}
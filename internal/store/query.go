@@ -0,0 +1,358 @@
+package store
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// A Query filters sprites with a small search syntax, for `sp status
+// --query`, `sp tui --query` and the TUI filter:
+//
+//	team:infra AND NOT archived
+//	tag:a,b status:running        (terms side by side are ANDed)
+//	variant:* OR name:api*        (parentheses group)
+//	age>7d
+//
+// Terms:
+//
+//	tag:a,b     has every listed tag
+//	status:v    status (running, warm, cold); sync:v is the sync status
+//	variant:v   variant name; variant:* is any variant, variant: none
+//	name:v      name contains v; path:v is a local path prefix
+//	org:v, repo:v, base:v
+//	pinned:yes  or pinned:no
+//	age>7d      created more than 7 days ago (also <, >=, <=; units m h d w)
+//	word        name or path contains word, or has it as a tag
+//
+// Any other key:value is taken as a tag, so tags like team:infra work
+// as-is. Values may be quoted ("my tag") and may use * and ? wildcards.
+// AND, OR and NOT are case-insensitive; NOT binds tightest, then AND.
+type Query struct {
+	root queryNode
+}
+
+// ParseQuery parses a query. An empty query is valid and matches everything.
+func ParseQuery(q string) (*Query, error) {
+	tokens, err := lexQuery(q)
+	if err != nil {
+		return nil, fmt.Errorf("invalid query %q: %w", q, err)
+	}
+	p := &queryParser{tokens: tokens}
+	if len(tokens) == 0 {
+		return &Query{}, nil
+	}
+	root, err := p.parseOr()
+	if err == nil && p.pos < len(p.tokens) {
+		err = fmt.Errorf("unexpected %q", p.tokens[p.pos].text)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("invalid query %q: %w", q, err)
+	}
+	return &Query{root: root}, nil
+}
+
+// where returns the query as an SQL condition on the sprites table (aliased
+// s) and its arguments, with ages relative to now.
+func (q *Query) where(now time.Time) (string, []any) {
+	if q.root == nil {
+		return "1", nil
+	}
+	var args []any
+	return q.root.sql(&args, now), args
+}
+
+// queryNode is one node of a parsed query.
+type queryNode interface {
+	sql(args *[]any, now time.Time) string
+}
+
+type (
+	andNode  struct{ left, right queryNode }
+	orNode   struct{ left, right queryNode }
+	notNode  struct{ inner queryNode }
+	termNode struct {
+		key   string // "" for a bare word
+		op    string // ":" or a comparison for age
+		value string
+	}
+)
+
+func (n andNode) sql(args *[]any, now time.Time) string {
+	return "(" + n.left.sql(args, now) + " AND " + n.right.sql(args, now) + ")"
+}
+
+func (n orNode) sql(args *[]any, now time.Time) string {
+	return "(" + n.left.sql(args, now) + " OR " + n.right.sql(args, now) + ")"
+}
+
+func (n notNode) sql(args *[]any, now time.Time) string {
+	return "NOT " + n.inner.sql(args, now)
+}
+
+// sprite columns that terms compare exactly (or by wildcard).
+var queryColumns = map[string]string{
+	"status":  "s.status",
+	"sync":    "s.sync_status",
+	"variant": "s.variant",
+	"org":     "s.org",
+	"repo":    "s.repo",
+	"base":    "s.base_name",
+}
+
+func (n termNode) sql(args *[]any, now time.Time) string {
+	arg := func(v any) string {
+		*args = append(*args, v)
+		return "?"
+	}
+	switch n.key {
+	case "":
+		return "(s.name LIKE " + arg("%"+n.value+"%") + " OR s.local_path LIKE " + arg("%"+n.value+"%") +
+			" OR " + hasTag(n.value, arg) + ")"
+	case "tag":
+		var conds []string
+		for _, tag := range strings.Split(n.value, ",") {
+			conds = append(conds, hasTag(tag, arg))
+		}
+		return "(" + strings.Join(conds, " AND ") + ")"
+	case "name":
+		if isGlob(n.value) {
+			return "s.name GLOB " + arg(n.value)
+		}
+		return "s.name LIKE " + arg("%"+n.value+"%")
+	case "path":
+		if isGlob(n.value) {
+			return "s.local_path GLOB " + arg(n.value)
+		}
+		return "s.local_path LIKE " + arg(n.value+"%")
+	case "pinned":
+		if n.value == "no" {
+			return "s.pinned = 0"
+		}
+		return "s.pinned != 0"
+	case "age":
+		// age>7d: created before now-7d. The comparison flips because an
+		// older sprite has an earlier created_at.
+		d, _ := parseQueryDuration(n.value)
+		flipped := map[string]string{">": "<", ">=": "<=", "<": ">", "<=": ">="}[n.op]
+		return "s.created_at " + flipped + " " + arg(now.Add(-d))
+	case "variant":
+		if n.value == "*" {
+			return "s.variant != ''"
+		}
+	}
+	column := queryColumns[n.key]
+	if isGlob(n.value) {
+		return column + " GLOB " + arg(n.value)
+	}
+	return column + " = " + arg(n.value)
+}
+
+// hasTag returns a condition that the sprite has a tag, adding its argument
+// with arg. A subquery rather than a join, so a sprite is never listed twice.
+func hasTag(tag string, arg func(any) string) string {
+	op := "="
+	if isGlob(tag) {
+		op = "GLOB"
+	}
+	return "EXISTS (SELECT 1 FROM tags t WHERE t.sprite_name = s.name AND t.tag " + op + " " + arg(tag) + ")"
+}
+
+func isGlob(v string) bool {
+	return strings.ContainsAny(v, "*?")
+}
+
+// queryToken is a word or parenthesis from a query.
+type queryToken struct {
+	text   string
+	quoted bool // contained quotes, so never a keyword or parenthesis
+}
+
+// lexQuery splits a query into words and parentheses. Double quotes group
+// spaces and parentheses into a word and are removed.
+func lexQuery(q string) ([]queryToken, error) {
+	var (
+		tokens  []queryToken
+		cur     strings.Builder
+		inWord  bool
+		quoted  bool
+		inQuote bool
+	)
+	flush := func() {
+		if inWord {
+			tokens = append(tokens, queryToken{text: cur.String(), quoted: quoted})
+		}
+		cur.Reset()
+		inWord, quoted = false, false
+	}
+	for _, r := range q {
+		switch {
+		case r == '"':
+			inQuote = !inQuote
+			inWord, quoted = true, true
+		case inQuote:
+			cur.WriteRune(r)
+		case r == ' ' || r == '\t' || r == '\n':
+			flush()
+		case r == '(' || r == ')':
+			flush()
+			tokens = append(tokens, queryToken{text: string(r)})
+		default:
+			cur.WriteRune(r)
+			inWord = true
+		}
+	}
+	if inQuote {
+		return nil, fmt.Errorf("unterminated quote")
+	}
+	flush()
+	return tokens, nil
+}
+
+// queryParser is a recursive-descent parser over lexed tokens.
+type queryParser struct {
+	tokens []queryToken
+	pos    int
+}
+
+// keyword reports whether the next token is the given keyword.
+func (p *queryParser) keyword(kw string) bool {
+	if p.pos >= len(p.tokens) {
+		return false
+	}
+	t := p.tokens[p.pos]
+	return !t.quoted && strings.EqualFold(t.text, kw)
+}
+
+func (p *queryParser) parseOr() (queryNode, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.keyword("OR") {
+		p.pos++
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = orNode{left, right}
+	}
+	return left, nil
+}
+
+func (p *queryParser) parseAnd() (queryNode, error) {
+	left, err := p.parseNot()
+	if err != nil {
+		return nil, err
+	}
+	for p.pos < len(p.tokens) && !p.keyword("OR") && p.tokens[p.pos].text != ")" {
+		if p.keyword("AND") {
+			p.pos++
+		}
+		right, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		left = andNode{left, right}
+	}
+	return left, nil
+}
+
+func (p *queryParser) parseNot() (queryNode, error) {
+	if p.keyword("NOT") {
+		p.pos++
+		inner, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		return notNode{inner}, nil
+	}
+	return p.parseTerm()
+}
+
+// comparisonTerm matches comparison terms like age>7d.
+var comparisonTerm = regexp.MustCompile(`^([a-z]+)(>=|<=|>|<)(.*)$`)
+
+func (p *queryParser) parseTerm() (queryNode, error) {
+	if p.pos >= len(p.tokens) {
+		return nil, fmt.Errorf("unexpected end of query")
+	}
+	t := p.tokens[p.pos]
+	p.pos++
+
+	if !t.quoted {
+		switch {
+		case t.text == "(":
+			inner, err := p.parseOr()
+			if err != nil {
+				return nil, err
+			}
+			if p.pos >= len(p.tokens) || p.tokens[p.pos].text != ")" {
+				return nil, fmt.Errorf("missing )")
+			}
+			p.pos++
+			return inner, nil
+		case t.text == ")":
+			return nil, fmt.Errorf("unexpected )")
+		}
+		for _, kw := range []string{"AND", "OR", "NOT"} {
+			if strings.EqualFold(t.text, kw) {
+				return nil, fmt.Errorf("expected a term, got %s", kw)
+			}
+		}
+	}
+
+	if m := comparisonTerm.FindStringSubmatch(t.text); m != nil {
+		if m[1] != "age" {
+			return nil, fmt.Errorf("%q can't be compared with %s (only age can)", m[1], m[2])
+		}
+		if _, err := parseQueryDuration(m[3]); err != nil {
+			return nil, err
+		}
+		return termNode{key: "age", op: m[2], value: m[3]}, nil
+	}
+
+	key, value, ok := strings.Cut(t.text, ":")
+	if !ok {
+		return termNode{value: t.text}, nil
+	}
+	key = strings.ToLower(key)
+	switch key {
+	case "tag", "name", "path":
+		if value == "" {
+			return nil, fmt.Errorf("%s: needs a value", key)
+		}
+	case "pinned":
+		switch strings.ToLower(value) {
+		case "yes", "true", "1", "":
+			value = "yes"
+		case "no", "false", "0":
+			value = "no"
+		default:
+			return nil, fmt.Errorf("pinned:%s: want yes or no", value)
+		}
+	case "age":
+		return nil, fmt.Errorf("age needs a comparison, e.g. age>7d")
+	default:
+		if _, ok := queryColumns[key]; !ok {
+			// Not a known key: a tag that contains a colon.
+			return termNode{key: "tag", value: t.text}, nil
+		}
+	}
+	return termNode{key: key, op: ":", value: value}, nil
+}
+
+// parseQueryDuration parses durations like 30m, 12h, 7d and 2w.
+func parseQueryDuration(s string) (time.Duration, error) {
+	units := map[byte]time.Duration{'m': time.Minute, 'h': time.Hour, 'd': 24 * time.Hour, 'w': 7 * 24 * time.Hour}
+	if len(s) >= 2 {
+		if unit, ok := units[s[len(s)-1]]; ok {
+			if n, err := strconv.Atoi(s[:len(s)-1]); err == nil && n >= 0 {
+				return time.Duration(n) * unit, nil
+			}
+		}
+	}
+	return 0, fmt.Errorf("invalid duration %q (want e.g. 30m, 12h, 7d, 2w)", s)
+}
@@ -0,0 +1,93 @@
+package store
+
+import (
+	"fmt"
+	"sort"
+	"testing"
+	"time"
+)
+
+func TestListSpritesQuery(t *testing.T) {
+	db := testDB(t)
+	for _, s := range []*Sprite{
+		{Name: "gh-acme--api", LocalPath: "/src/acme/api", Status: "running", Org: "acme"},
+		{Name: "gh-acme--web", LocalPath: "/src/acme/web", Status: "cold", Org: "acme"},
+		{Name: "gh-acme--api--spike", BaseName: "gh-acme--api", Variant: "spike", Status: "running"},
+		{Name: "notes", LocalPath: "/home/me/notes", Status: "warm"},
+	} {
+		if err := db.UpsertSprite(s); err != nil {
+			t.Fatal(err)
+		}
+	}
+	db.SetPinned("gh-acme--api--spike", true)
+	for name, tags := range map[string][]string{
+		"gh-acme--api":        {"team:infra", "work"},
+		"gh-acme--web":        {"team:infra", "work", "archived"},
+		"gh-acme--api--spike": {"work"},
+	} {
+		for _, tag := range tags {
+			db.AddTag(name, tag)
+		}
+	}
+	// Back-date one sprite for age queries.
+	db.db.Exec(`UPDATE sprites SET created_at = ? WHERE name = 'notes'`, time.Now().Add(-10*24*time.Hour))
+
+	for _, tc := range []struct {
+		query string
+		want  string
+	}{
+		{"team:infra AND NOT archived", "[gh-acme--api]"},
+		{"tag:team:infra,archived", "[gh-acme--web]"},
+		{"tag:work,team:infra", "[gh-acme--api gh-acme--web]"},
+		{"status:running", "[gh-acme--api gh-acme--api--spike]"},
+		{"variant:*", "[gh-acme--api--spike]"},
+		{"variant: work", "[gh-acme--api gh-acme--web]"},
+		{"age>7d", "[notes]"},
+		{"age<7d status:cold", "[gh-acme--web]"},
+		{"status:cold OR (pinned:yes AND work)", "[gh-acme--api--spike gh-acme--web]"},
+		{"not work", "[notes]"},
+		{"path:/src/acme org:acme name:api", "[gh-acme--api]"},
+		{"name:gh-*--web OR status:warm", "[gh-acme--web notes]"},
+		{`"team:infra" -x`, "[]"},
+		{"", "[gh-acme--api gh-acme--api--spike gh-acme--web notes]"},
+	} {
+		sprites, err := db.ListSprites(ListOptions{Query: tc.query})
+		if err != nil {
+			t.Errorf("%q: %v", tc.query, err)
+			continue
+		}
+		var names []string
+		for _, s := range sprites {
+			names = append(names, s.Name)
+		}
+		sort.Strings(names)
+		if got := fmt.Sprint(names); got != tc.want {
+			t.Errorf("%q = %s, want %s", tc.query, got, tc.want)
+		}
+	}
+
+	// A sprite with several matching tags is listed once.
+	if sprites, _ := db.ListSprites(ListOptions{Tags: []string{"work", "team:infra"}}); len(sprites) != 3 {
+		t.Errorf("tag filter returned %d sprites, want 3", len(sprites))
+	}
+}
+
+func TestParseQueryErrors(t *testing.T) {
+	for _, q := range []string{
+		"(status:running",
+		"status:running)",
+		"NOT",
+		"a AND",
+		"OR a",
+		"age>soon",
+		"status>3",
+		"age:7d",
+		"pinned:maybe",
+		`name:"unterminated`,
+		"tag:",
+	} {
+		if _, err := ParseQuery(q); err == nil {
+			t.Errorf("ParseQuery(%q) succeeded", q)
+		}
+	}
+}
@@ -89,7 +89,8 @@ func (d *DB) ListSprites(opts ListOptions) ([]*Sprite, error) {
 	wheres = append(wheres, "s.name != ''")
 
 	if len(opts.Tags) > 0 {
-		query += ` INNER JOIN tags t ON t.sprite_name = s.name`
+		// A subquery rather than a join, so a sprite with several of the
+		// tags is still listed once.
 		var ph strings.Builder
 		for i, tag := range opts.Tags {
 			if i > 0 {
@@ -98,7 +99,17 @@ func (d *DB) ListSprites(opts ListOptions) ([]*Sprite, error) {
 			ph.WriteString("?")
 			args = append(args, tag)
 		}
-		wheres = append(wheres, fmt.Sprintf("t.tag IN (%s)", ph.String()))
+		wheres = append(wheres, fmt.Sprintf("EXISTS (SELECT 1 FROM tags t WHERE t.sprite_name = s.name AND t.tag IN (%s))", ph.String()))
+	}
+
+	if opts.Query != "" {
+		q, err := ParseQuery(opts.Query)
+		if err != nil {
+			return nil, err
+		}
+		where, qargs := q.where(time.Now())
+		wheres = append(wheres, where)
+		args = append(args, qargs...)
 	}
 
 	if opts.PathPrefix != "" {
@@ -170,11 +181,13 @@ type ListOptions struct {
 	VariantsOf   string    // only return sprites with this base_name
 	OnlyUnpinned bool      // only return sprites where pinned = false
 	OlderThan    time.Time // only return sprites whose updated_at is before this time (zero = no filter)
+	Query        string    // only return sprites matching this query (see Query)
 }
 
-// UpdateSpriteStatus updates only the remote status fields of a sprite.
+// UpdateSpriteStatus updates only the remote status fields of a sprite,
+// recording an EventStatus when the status changes.
 func (d *DB) UpdateSpriteStatus(name, status string) error {
-	_, err := d.db.Exec(`
+	err := d.updateRecordingTransition(name, "status", EventStatus, status, "", `
 		UPDATE sprites SET status = ?, last_seen = ?, updated_at = ?
 		WHERE name = ?
 	`, status, time.Now(), time.Now(), name)
@@ -184,9 +197,10 @@ func (d *DB) UpdateSpriteStatus(name, status string) error {
 	return nil
 }
 
-// UpdateSyncStatus updates the sync-related fields of a sprite.
+// UpdateSyncStatus updates the sync-related fields of a sprite, recording
+// an EventSync when the sync status changes.
 func (d *DB) UpdateSyncStatus(name, syncStatus, syncError string) error {
-	_, err := d.db.Exec(`
+	err := d.updateRecordingTransition(name, "sync_status", EventSync, syncStatus, syncError, `
 		UPDATE sprites SET sync_status = ?, sync_error = ?, updated_at = ?
 		WHERE name = ?
 	`, syncStatus, syncError, time.Now(), name)
@@ -197,14 +211,52 @@ func (d *DB) UpdateSyncStatus(name, syncStatus, syncError string) error {
 }
 
 // DeleteSprite removes a sprite and all associated data from the database.
+// Its usage history is kept, but a running interval is ended now: nothing
+// polls an untracked sprite, so it would otherwise count as running forever.
 func (d *DB) DeleteSprite(name string) error {
-	_, err := d.db.Exec(`DELETE FROM sprites WHERE name = ?`, name)
+	tx, err := d.db.Begin()
 	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(endUsageSQL, time.Now().UTC(), name); err != nil {
+		return fmt.Errorf("ending usage for %q: %w", name, err)
+	}
+	if _, err := tx.Exec(`DELETE FROM sprites WHERE name = ?`, name); err != nil {
 		return fmt.Errorf("deleting sprite %q: %w", name, err)
 	}
+	return tx.Commit()
+}
+
+// SetSyncPaused records or clears a pause on a sprite's sync (see the
+// daemon's pause_sync). It is stored so a daemon restart doesn't lift it.
+func (d *DB) SetSyncPaused(name string, paused bool) error {
+	var err error
+	if paused {
+		_, err = d.db.Exec(`
+			INSERT INTO sync_pauses (sprite_name, paused_at) VALUES (?, ?)
+			ON CONFLICT(sprite_name) DO NOTHING
+		`, name, time.Now())
+	} else {
+		_, err = d.db.Exec(`DELETE FROM sync_pauses WHERE sprite_name = ?`, name)
+	}
+	if err != nil {
+		return fmt.Errorf("setting sync pause for %q: %w", name, err)
+	}
 	return nil
 }
 
+// IsSyncPaused reports whether a sprite's sync is paused.
+func (d *DB) IsSyncPaused(name string) (bool, error) {
+	var n int
+	err := d.db.QueryRow(`SELECT COUNT(*) FROM sync_pauses WHERE sprite_name = ?`, name).Scan(&n)
+	if err != nil {
+		return false, fmt.Errorf("checking sync pause for %q: %w", name, err)
+	}
+	return n > 0, nil
+}
+
 // UpsertSyncSession creates or updates a sync session record.
 func (d *DB) UpsertSyncSession(ss *SyncSession) error {
 	_, err := d.db.Exec(`
@@ -0,0 +1,48 @@
+-- Schema written by sp once checkpoints were added, the last release
+-- without schema_migrations.
+CREATE TABLE sprites (
+	name TEXT PRIMARY KEY,
+	local_path TEXT,
+	remote_path TEXT,
+	repo TEXT,
+	org TEXT,
+	sprite_id TEXT,
+	url TEXT,
+	status TEXT DEFAULT 'unknown',
+	sync_status TEXT DEFAULT 'none',
+	sync_error TEXT DEFAULT '',
+	last_seen DATETIME,
+	created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+	updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
+, variant TEXT DEFAULT '', base_name TEXT DEFAULT '', pinned BOOLEAN DEFAULT 0);
+CREATE TABLE tags (
+	sprite_name TEXT REFERENCES sprites(name) ON DELETE CASCADE,
+	tag TEXT,
+	PRIMARY KEY (sprite_name, tag)
+);
+CREATE TABLE sync_sessions (
+	sprite_name TEXT REFERENCES sprites(name) ON DELETE CASCADE UNIQUE,
+	mutagen_id TEXT,
+	ssh_port INTEGER,
+	proxy_pid INTEGER,
+	alpha_connected BOOLEAN DEFAULT 0,
+	beta_connected BOOLEAN DEFAULT 0,
+	conflicts INTEGER DEFAULT 0,
+	last_error TEXT DEFAULT '',
+	updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
+);
+CREATE TABLE checkpoints (
+	sprite_name TEXT REFERENCES sprites(name) ON DELETE CASCADE,
+	checkpoint_id TEXT,
+	label TEXT DEFAULT '',
+	created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+	PRIMARY KEY (sprite_name, checkpoint_id)
+);
+
+INSERT INTO sprites (name, local_path, remote_path, repo, org, sprite_id, url, status, sync_status, sync_error, last_seen, base_name)
+VALUES ('gh-acme--api', '/Users/dev/acme/api', '/home/sprite/api', 'acme/api', 'acme', 'spr_1', 'https://gh-acme--api.sprites.app', 'running', 'watching', '', '2025-06-01 10:00:00', '');
+INSERT INTO sprites (name, local_path, remote_path, repo, org, sprite_id, url, status, sync_status, sync_error, last_seen, variant, base_name, pinned)
+VALUES ('gh-acme--api--spike', '', '/home/sprite/api', '', 'acme', 'spr_2', '', 'cold', 'none', '', '2025-06-02 10:00:00', 'spike', 'gh-acme--api', 1);
+INSERT INTO tags (sprite_name, tag) VALUES ('gh-acme--api', 'work');
+INSERT INTO checkpoints (sprite_name, checkpoint_id, label)
+VALUES ('gh-acme--api', 'v3', 'before-upgrade');
@@ -0,0 +1,44 @@
+-- Schema written by sp once variants were added with ad hoc ALTER TABLEs,
+-- before checkpoints. Rows registered before the upgrade have an empty
+-- base_name.
+CREATE TABLE sprites (
+	name TEXT PRIMARY KEY,
+	local_path TEXT,
+	remote_path TEXT,
+	repo TEXT,
+	org TEXT,
+	sprite_id TEXT,
+	url TEXT,
+	status TEXT DEFAULT 'unknown',
+	sync_status TEXT DEFAULT 'none',
+	sync_error TEXT DEFAULT '',
+	last_seen DATETIME,
+	created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+	updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
+);
+CREATE TABLE tags (
+	sprite_name TEXT REFERENCES sprites(name) ON DELETE CASCADE,
+	tag TEXT,
+	PRIMARY KEY (sprite_name, tag)
+);
+CREATE TABLE sync_sessions (
+	sprite_name TEXT REFERENCES sprites(name) ON DELETE CASCADE UNIQUE,
+	mutagen_id TEXT,
+	ssh_port INTEGER,
+	proxy_pid INTEGER,
+	alpha_connected BOOLEAN DEFAULT 0,
+	beta_connected BOOLEAN DEFAULT 0,
+	conflicts INTEGER DEFAULT 0,
+	last_error TEXT DEFAULT '',
+	updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
+);
+INSERT INTO sprites (name, local_path, remote_path, repo, org, sprite_id, url, status, sync_status, sync_error, last_seen)
+VALUES ('gh-acme--api', '/Users/dev/acme/api', '/home/sprite/api', 'acme/api', 'acme', 'spr_1', 'https://gh-acme--api.sprites.app', 'running', 'watching', '', '2025-06-01 10:00:00');
+INSERT INTO tags (sprite_name, tag) VALUES ('gh-acme--api', 'work');
+
+ALTER TABLE sprites ADD COLUMN variant TEXT DEFAULT '';
+ALTER TABLE sprites ADD COLUMN base_name TEXT DEFAULT '';
+ALTER TABLE sprites ADD COLUMN pinned BOOLEAN DEFAULT 0;
+
+INSERT INTO sprites (name, local_path, remote_path, repo, org, sprite_id, url, status, sync_status, sync_error, last_seen, variant, base_name, pinned)
+VALUES ('gh-acme--api--spike', '', '/home/sprite/api', '', 'acme', 'spr_2', '', 'cold', 'none', '', '2025-06-02 10:00:00', 'spike', 'gh-acme--api', 1);
@@ -0,0 +1,150 @@
+package store
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// UsageInterval is a span of time a sprite spent running.
+type UsageInterval struct {
+	ID         int64
+	SpriteName string
+	BaseName   string
+	Variant    string
+	StartedAt  time.Time
+	EndedAt    time.Time // zero while the sprite is still running
+}
+
+// Overlap returns how much of the interval falls between from and to. An
+// interval that hasn't ended counts as running until to.
+func (u *UsageInterval) Overlap(from, to time.Time) time.Duration {
+	start, end := u.StartedAt, u.EndedAt
+	if end.IsZero() || end.After(to) {
+		end = to
+	}
+	if start.Before(from) {
+		start = from
+	}
+	if !end.After(start) {
+		return 0
+	}
+	return end.Sub(start)
+}
+
+// StartUsage opens a running interval for a sprite at the given time, unless
+// one is already open. The start is never earlier than the end of the
+// sprite's previous interval, so the same time isn't counted twice.
+func (d *DB) StartUsage(name string, at time.Time) error {
+	tx, err := d.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var open int
+	if err := tx.QueryRow(`SELECT COUNT(*) FROM usage_intervals WHERE sprite_name = ? AND ended_at IS NULL`, name).Scan(&open); err != nil {
+		return fmt.Errorf("checking usage for %q: %w", name, err)
+	}
+	if open > 0 {
+		return nil
+	}
+
+	var lastEnd time.Time
+	err = tx.QueryRow(`
+		SELECT ended_at FROM usage_intervals WHERE sprite_name = ?
+		ORDER BY ended_at DESC LIMIT 1
+	`, name).Scan(&lastEnd)
+	if err != nil && err != sql.ErrNoRows {
+		return fmt.Errorf("checking usage for %q: %w", name, err)
+	}
+	if at.Before(lastEnd) {
+		at = lastEnd
+	}
+
+	var baseName, variant string
+	err = tx.QueryRow(`SELECT COALESCE(base_name, ''), COALESCE(variant, '') FROM sprites WHERE name = ?`, name).Scan(&baseName, &variant)
+	if err != nil && err != sql.ErrNoRows {
+		return fmt.Errorf("looking up sprite %q: %w", name, err)
+	}
+	if baseName == "" {
+		baseName = name
+	}
+
+	_, err = tx.Exec(`
+		INSERT INTO usage_intervals (sprite_name, base_name, variant, started_at)
+		VALUES (?, ?, ?, ?)
+	`, name, baseName, variant, at.UTC())
+	if err != nil {
+		return fmt.Errorf("starting usage for %q: %w", name, err)
+	}
+	return tx.Commit()
+}
+
+// endUsageSQL closes a sprite's open interval; its arguments are the end
+// time (UTC) and the sprite name.
+const endUsageSQL = `
+	UPDATE usage_intervals SET ended_at = MAX(started_at, ?)
+	WHERE sprite_name = ? AND ended_at IS NULL
+`
+
+// EndUsage closes a sprite's open running interval at the given time (or at
+// its start, if that is later). It does nothing if none is open.
+func (d *DB) EndUsage(name string, at time.Time) error {
+	_, err := d.db.Exec(endUsageSQL, at.UTC(), name)
+	if err != nil {
+		return fmt.Errorf("ending usage for %q: %w", name, err)
+	}
+	return nil
+}
+
+// ListOpenUsage returns the intervals that haven't ended, one per sprite
+// at most.
+func (d *DB) ListOpenUsage() ([]*UsageInterval, error) {
+	rows, err := d.db.Query(`
+		SELECT id, sprite_name, base_name, variant, started_at
+		FROM usage_intervals WHERE ended_at IS NULL
+		ORDER BY started_at, id
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("listing open usage: %w", err)
+	}
+	defer rows.Close()
+
+	var intervals []*UsageInterval
+	for rows.Next() {
+		u := &UsageInterval{}
+		if err := rows.Scan(&u.ID, &u.SpriteName, &u.BaseName, &u.Variant, &u.StartedAt); err != nil {
+			return nil, fmt.Errorf("scanning usage interval: %w", err)
+		}
+		intervals = append(intervals, u)
+	}
+	return intervals, rows.Err()
+}
+
+// ListUsage returns the intervals that were running at any point since the
+// given time, oldest first.
+func (d *DB) ListUsage(since time.Time) ([]*UsageInterval, error) {
+	rows, err := d.db.Query(`
+		SELECT id, sprite_name, base_name, variant, started_at, ended_at
+		FROM usage_intervals
+		WHERE ended_at IS NULL OR ended_at > ?
+		ORDER BY started_at, id
+	`, since.UTC())
+	if err != nil {
+		return nil, fmt.Errorf("listing usage: %w", err)
+	}
+	defer rows.Close()
+
+	var intervals []*UsageInterval
+	for rows.Next() {
+		u := &UsageInterval{}
+		var endedAt sql.NullTime
+		if err := rows.Scan(&u.ID, &u.SpriteName, &u.BaseName, &u.Variant, &u.StartedAt, &endedAt); err != nil {
+			return nil, fmt.Errorf("scanning usage interval: %w", err)
+		}
+		u.EndedAt = endedAt.Time
+		intervals = append(intervals, u)
+	}
+	return intervals, rows.Err()
+}
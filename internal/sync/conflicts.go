@@ -0,0 +1,250 @@
+package sync
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// MaxConflictSize is the largest file ConflictResolver.Read fetches for
+// comparing. Bigger conflicts can still be resolved by keeping one side.
+const MaxConflictSize = 4 << 20
+
+// ConflictFile describes what one side has at a conflicting path.
+type ConflictFile struct {
+	Kind    string // "file", "directory", "symlink" or "other"; "" if nothing is there
+	Size    int64
+	Mode    fs.FileMode // permission bits
+	ModTime time.Time
+}
+
+// Exists reports whether the side has anything at the path.
+func (f ConflictFile) Exists() bool {
+	return f.Kind != ""
+}
+
+// Summary describes the side for a listing, given the change the engine
+// reports for it, e.g. "modified: 1.2 KB, Mar 3 15:04" or "deleted".
+func (f ConflictFile) Summary(change string) string {
+	var s string
+	switch {
+	case !f.Exists():
+		if strings.HasPrefix(change, "deleted") {
+			return change
+		}
+		s = "missing"
+	case f.Kind == "file":
+		s = formatSize(f.Size) + ", " + f.ModTime.Local().Format("Jan 2 15:04")
+	default:
+		s = f.Kind
+	}
+	if change == "" {
+		return s
+	}
+	return change + ": " + s
+}
+
+// formatSize formats a byte count for people, e.g. "512 B" or "1.2 MB".
+func formatSize(n int64) string {
+	if n < 1024 {
+		return fmt.Sprintf("%d B", n)
+	}
+	size, unit := float64(n)/1024, "KB"
+	for _, u := range []string{"MB", "GB"} {
+		if size < 1024 {
+			break
+		}
+		size, unit = size/1024, u
+	}
+	return fmt.Sprintf("%.1f %s", size, unit)
+}
+
+// ConflictDetail is a conflict with what each side now has at its path.
+type ConflictDetail struct {
+	Conflict
+	Local, Remote ConflictFile
+}
+
+// ConflictContents is a conflicting file as each side has it. LocalData
+// and RemoteData are only filled in for a side whose Kind is "file".
+type ConflictContents struct {
+	ConflictDetail
+	LocalData, RemoteData []byte
+}
+
+// Resolution is the version of a conflicting file to keep.
+type Resolution string
+
+const (
+	KeepLocal  Resolution = "local"
+	KeepRemote Resolution = "remote"
+	KeepMerged Resolution = "merged" // contents the user merged by hand
+)
+
+// ConflictResolver inspects and settles a sprite's sync conflicts. It reads
+// and writes the sprite's side over the SSH host alias, as the CopyEngine
+// does, so it works the same whichever engine runs the session. Only files
+// can be resolved; a path that's a directory or symlink on either side
+// needs a one-way resync.
+type ConflictResolver struct {
+	Engine     SyncEngine
+	SpriteName string
+	LocalDir   string
+	RemoteDir  string
+}
+
+func (r *ConflictResolver) remote() *remoteTree {
+	return &remoteTree{alias: SSHHostAlias(r.SpriteName), dir: r.RemoteDir}
+}
+
+// List returns the session's conflicts and what each side has at them.
+func (r *ConflictResolver) List(ctx context.Context) ([]ConflictDetail, error) {
+	conflicts, err := r.Engine.Conflicts(ctx, r.SpriteName)
+	if err != nil {
+		return nil, err
+	}
+	if len(conflicts) == 0 {
+		return nil, nil
+	}
+	paths := make([]string, len(conflicts))
+	for i, c := range conflicts {
+		paths[i] = c.Path
+	}
+	remote, err := r.remote().stat(ctx, paths)
+	if err != nil {
+		return nil, fmt.Errorf("reading remote files: %w", err)
+	}
+	details := make([]ConflictDetail, len(conflicts))
+	for i, c := range conflicts {
+		local, err := statLocal(r.LocalDir, c.Path)
+		if err != nil {
+			return nil, err
+		}
+		details[i] = ConflictDetail{Conflict: c, Local: local, Remote: remote[c.Path]}
+	}
+	return details, nil
+}
+
+// Read returns both sides of a conflicting path, for comparing.
+func (r *ConflictResolver) Read(ctx context.Context, path string) (*ConflictContents, error) {
+	local, remote, err := r.stat(ctx, path)
+	if err != nil {
+		return nil, err
+	}
+	c := &ConflictContents{ConflictDetail: ConflictDetail{Conflict: Conflict{Path: path}, Local: local, Remote: remote}}
+	for _, f := range []ConflictFile{local, remote} {
+		if f.Kind == "file" && f.Size > MaxConflictSize {
+			return nil, fmt.Errorf("%s is too large to compare (%d bytes); keep one side instead", path, f.Size)
+		}
+	}
+	if local.Kind == "file" {
+		if c.LocalData, err = os.ReadFile(filepath.Join(r.LocalDir, filepath.FromSlash(path))); err != nil {
+			return nil, err
+		}
+	}
+	if remote.Kind == "file" {
+		if c.RemoteData, err = r.remote().read(ctx, path); err != nil {
+			return nil, fmt.Errorf("reading remote %s: %w", path, err)
+		}
+	}
+	return c, nil
+}
+
+// Resolve makes both sides of a conflicting path match the version keep
+// names, then flushes the session so the engine sees they agree. merged is
+// the file's new contents for KeepMerged, and ignored otherwise. Keeping a
+// side that has no file at the path deletes it from the other.
+func (r *ConflictResolver) Resolve(ctx context.Context, path string, keep Resolution, merged []byte) error {
+	local, remote, err := r.stat(ctx, path)
+	if err != nil {
+		return err
+	}
+	for i, f := range []ConflictFile{local, remote} {
+		if f.Exists() && f.Kind != "file" {
+			side := [...]string{"local", "remote"}[i]
+			return fmt.Errorf("%s is a %s on the %s side; only files can be resolved, use sp resync --mode instead", path, f.Kind, side)
+		}
+	}
+
+	localPath := filepath.Join(r.LocalDir, filepath.FromSlash(path))
+	switch keep {
+	case KeepLocal:
+		err = r.copyToRemote(ctx, path, local.Exists())
+	case KeepRemote:
+		if remote.Exists() {
+			err = r.remote().pull(ctx, r.LocalDir, []string{path})
+		} else if err = os.Remove(localPath); errors.Is(err, fs.ErrNotExist) {
+			err = nil
+		}
+	case KeepMerged:
+		mode := fs.FileMode(0o644)
+		if local.Exists() {
+			mode = local.Mode
+		} else if remote.Exists() {
+			mode = remote.Mode
+		}
+		if err = os.MkdirAll(filepath.Dir(localPath), 0o755); err == nil {
+			err = os.WriteFile(localPath, merged, mode)
+		}
+		if err == nil {
+			err = r.copyToRemote(ctx, path, true)
+		}
+	default:
+		return fmt.Errorf("unknown resolution %q (want local, remote or merged)", keep)
+	}
+	if err != nil {
+		return fmt.Errorf("keeping the %s %s: %w", keep, path, err)
+	}
+	return r.Engine.Flush(ctx, r.SpriteName)
+}
+
+// copyToRemote makes the remote path match the local one: a copy of the
+// local file, or gone if there is none.
+func (r *ConflictResolver) copyToRemote(ctx context.Context, path string, exists bool) error {
+	if exists {
+		return r.remote().push(ctx, r.LocalDir, []string{path})
+	}
+	return r.remote().remove(ctx, []string{path})
+}
+
+// stat describes both sides of a conflicting path, after checking that it
+// names something inside the synced directories.
+func (r *ConflictResolver) stat(ctx context.Context, path string) (local, remote ConflictFile, err error) {
+	if !filepath.IsLocal(filepath.FromSlash(path)) {
+		return local, remote, fmt.Errorf("%q is not a path inside the synced directory", path)
+	}
+	if local, err = statLocal(r.LocalDir, path); err != nil {
+		return local, remote, err
+	}
+	files, err := r.remote().stat(ctx, []string{path})
+	if err != nil {
+		return local, remote, fmt.Errorf("reading remote %s: %w", path, err)
+	}
+	return local, files[path], nil
+}
+
+// statLocal describes a local path, without following symlinks.
+func statLocal(dir, path string) (ConflictFile, error) {
+	info, err := os.Lstat(filepath.Join(dir, filepath.FromSlash(path)))
+	if errors.Is(err, fs.ErrNotExist) {
+		return ConflictFile{}, nil
+	}
+	if err != nil {
+		return ConflictFile{}, err
+	}
+	kind := "other"
+	switch {
+	case info.Mode().IsRegular():
+		kind = "file"
+	case info.IsDir():
+		kind = "directory"
+	case info.Mode()&fs.ModeSymlink != 0:
+		kind = "symlink"
+	}
+	return ConflictFile{Kind: kind, Size: info.Size(), Mode: info.Mode().Perm(), ModTime: info.ModTime()}, nil
+}
@@ -0,0 +1,115 @@
+package sync
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/jphenow/sp/internal/fakebin"
+	"github.com/jphenow/sp/internal/sprite"
+)
+
+func TestConflictResolverEndToEnd(t *testing.T) {
+	if testing.Short() {
+		t.Skip("end-to-end conflict test")
+	}
+	env := fakebin.New(t)
+	const name = "conflicted"
+	env.AddSprite(name, "running")
+	mgr := NewManager(sprite.NewClient(""), NewCopyEngine())
+	if err := mgr.SetupSSHServer(name); err != nil {
+		t.Fatalf("SetupSSHServer: %v", err)
+	}
+	proxy, port, err := mgr.StartProxy(name)
+	if err != nil {
+		t.Fatalf("StartProxy: %v", err)
+	}
+	t.Cleanup(func() { proxy.Process.Kill(); proxy.Wait() })
+	if err := AddSSHConfig(name, port); err != nil {
+		t.Fatalf("AddSSHConfig: %v", err)
+	}
+
+	local, remote := t.TempDir(), env.Path(name, "/home/sprite/proj")
+	write := func(dir, rel, content string) {
+		t.Helper()
+		p := filepath.Join(dir, rel)
+		os.MkdirAll(filepath.Dir(p), 0o755)
+		if err := os.WriteFile(p, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	read := func(dir, rel string) string {
+		data, err := os.ReadFile(filepath.Join(dir, rel))
+		if err != nil {
+			return "<" + err.Error() + ">"
+		}
+		return string(data)
+	}
+	for _, rel := range []string{"keep-local.go", "keep-remote.go", "merge.go"} {
+		write(local, rel, "local "+rel+"\n")
+		write(remote, rel, "remote "+rel+"\n")
+	}
+
+	// A safe push leaves every file that differs alone, as conflicts.
+	ctx := context.Background()
+	if _, err := mgr.StartSession(name, local, "/home/sprite/proj", "one-way-safe-to-remote"); err != nil {
+		t.Fatalf("StartSession: %v", err)
+	}
+	r := &ConflictResolver{Engine: mgr.engine, SpriteName: name, LocalDir: local, RemoteDir: "/home/sprite/proj"}
+	details, err := r.List(ctx)
+	if err != nil {
+		t.Fatalf("List: %v", err)
+	}
+	if len(details) != 3 {
+		t.Fatalf("List = %+v, want 3 conflicts", details)
+	}
+	if d := details[0]; d.Path != "keep-local.go" || d.Local.Kind != "file" || d.Remote.Kind != "file" ||
+		d.Remote.Size != int64(len("remote keep-local.go\n")) || d.Remote.ModTime.IsZero() {
+		t.Errorf("List[0] = %+v", d)
+	}
+
+	c, err := r.Read(ctx, "merge.go")
+	if err != nil {
+		t.Fatalf("Read: %v", err)
+	}
+	if string(c.LocalData) != "local merge.go\n" || string(c.RemoteData) != "remote merge.go\n" {
+		t.Errorf("Read = %q, %q", c.LocalData, c.RemoteData)
+	}
+
+	if err := r.Resolve(ctx, "keep-local.go", KeepLocal, nil); err != nil {
+		t.Fatalf("Resolve(local): %v", err)
+	}
+	if got := read(remote, "keep-local.go"); got != "local keep-local.go\n" {
+		t.Errorf("remote keep-local.go = %q", got)
+	}
+	if err := r.Resolve(ctx, "keep-remote.go", KeepRemote, nil); err != nil {
+		t.Fatalf("Resolve(remote): %v", err)
+	}
+	if got := read(local, "keep-remote.go"); got != "remote keep-remote.go\n" {
+		t.Errorf("local keep-remote.go = %q", got)
+	}
+	if err := r.Resolve(ctx, "merge.go", KeepMerged, []byte("merged\n")); err != nil {
+		t.Fatalf("Resolve(merged): %v", err)
+	}
+	if got, got2 := read(local, "merge.go"), read(remote, "merge.go"); got != "merged\n" || got2 != "merged\n" {
+		t.Errorf("merge.go = %q locally, %q remotely", got, got2)
+	}
+
+	// The flush after each resolution re-ran the pass, which no longer
+	// finds them in conflict.
+	details, err = r.List(ctx)
+	if err != nil || len(details) != 0 {
+		t.Errorf("List after resolving = %+v, %v; want none", details, err)
+	}
+
+	// A path that's a directory on one side needs a resync.
+	write(local, "dir/x", "local dir\n")
+	write(remote, "dir", "remote file\n")
+	if err := r.Resolve(ctx, "dir", KeepRemote, nil); err == nil {
+		t.Error("Resolve of a local directory succeeded")
+	}
+	if err := r.Resolve(ctx, "../escape", KeepLocal, nil); err == nil {
+		t.Error("Resolve outside the synced directory succeeded")
+	}
+}
@@ -0,0 +1,680 @@
+package sync
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log/slog"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	gosync "sync"
+	"time"
+)
+
+// ErrOneWayOnly is returned by CopyEngine.Create for sync modes that need a
+// continuously running session, which only Mutagen provides.
+var ErrOneWayOnly = errors.New("continuous two-way sync needs Mutagen; the built-in engine only does one-way copies")
+
+// CopyEngine is the built-in SyncEngine for machines without Mutagen. It
+// doesn't watch anything: Create and Flush each make one pass over the
+// tree in the session's direction, comparing SHA-256 checksums and copying
+// changed files as a tar stream over SSH, through the host alias
+// AddSSHConfig writes. Only regular files are synced; symlinks and empty
+// directories are left alone. Sessions live in memory, so they end with
+// the process.
+type CopyEngine struct {
+	mu       gosync.Mutex
+	sessions map[string]*copySession // keyed by sprite name
+}
+
+// copySession is one sprite's sync settings and the outcome of its last pass.
+type copySession struct {
+	opts      CreateOptions
+	id        string
+	paused    bool
+	state     SessionState
+	conflicts []string // destination files the last pass left alone
+}
+
+// NewCopyEngine returns an engine with no sessions.
+func NewCopyEngine() *CopyEngine {
+	return &CopyEngine{sessions: make(map[string]*copySession)}
+}
+
+// DefaultEngine returns the Mutagen engine when mutagen is installed, and
+// the built-in CopyEngine otherwise.
+func DefaultEngine() SyncEngine {
+	if !MutagenInstalled() {
+		return NewCopyEngine()
+	}
+	return NewMutagenEngine()
+}
+
+// IsOneWayMode reports whether mode is one of the one-way sync modes, the
+// only ones the CopyEngine supports.
+func IsOneWayMode(mode string) bool {
+	_, _, err := copyDirection(mode)
+	return err == nil
+}
+
+// copyDirection decodes a one-way sync mode: whether it copies local to
+// remote, and whether the destination is made an exact replica (deleting
+// what the source lacks) rather than only gaining missing files.
+func copyDirection(mode string) (toRemote, replica bool, err error) {
+	switch mode {
+	case "one-way-replica-to-remote":
+		return true, true, nil
+	case "one-way-replica-to-local":
+		return false, true, nil
+	case "one-way-safe-to-remote":
+		return true, false, nil
+	case "one-way-safe-to-local":
+		return false, false, nil
+	}
+	return false, false, ErrOneWayOnly
+}
+
+// Create runs the first pass and keeps the session for Flush. It replaces
+// any session the sprite already had.
+func (e *CopyEngine) Create(ctx context.Context, opts CreateOptions) (string, error) {
+	if _, _, err := copyDirection(opts.SyncMode); err != nil {
+		return "", err
+	}
+	s := &copySession{
+		opts: opts,
+		id:   fmt.Sprintf("copy_%d", time.Now().UnixNano()),
+	}
+	if err := e.pass(ctx, s); err != nil {
+		return "", err
+	}
+	e.mu.Lock()
+	e.sessions[opts.SpriteName] = s
+	e.mu.Unlock()
+	return s.id, nil
+}
+
+// Flush runs another pass, unless the session is paused.
+func (e *CopyEngine) Flush(ctx context.Context, spriteName string) error {
+	s, err := e.session(spriteName)
+	if err != nil {
+		return err
+	}
+	e.mu.Lock()
+	paused := s.paused
+	e.mu.Unlock()
+	if paused {
+		return fmt.Errorf("flushing %q: session is paused", spriteName)
+	}
+	return e.pass(ctx, s)
+}
+
+// Reset is a no-op: every pass already compares the full tree.
+func (e *CopyEngine) Reset(ctx context.Context, spriteName string) error {
+	_, err := e.session(spriteName)
+	return err
+}
+
+// Pause stops Flush from copying until Resume.
+func (e *CopyEngine) Pause(ctx context.Context, spriteName string) error {
+	return e.setPaused(spriteName, true)
+}
+
+// Resume lifts a Pause.
+func (e *CopyEngine) Resume(ctx context.Context, spriteName string) error {
+	return e.setPaused(spriteName, false)
+}
+
+func (e *CopyEngine) setPaused(spriteName string, paused bool) error {
+	s, err := e.session(spriteName)
+	if err != nil {
+		return err
+	}
+	e.mu.Lock()
+	s.paused = paused
+	e.mu.Unlock()
+	return nil
+}
+
+// Terminate forgets the session.
+func (e *CopyEngine) Terminate(ctx context.Context, spriteName string) error {
+	if _, err := e.session(spriteName); err != nil {
+		return err
+	}
+	e.mu.Lock()
+	delete(e.sessions, spriteName)
+	e.mu.Unlock()
+	return nil
+}
+
+// Status reports the outcome of the session's last pass.
+func (e *CopyEngine) Status(ctx context.Context, spriteName string) (*SessionState, error) {
+	s, err := e.session(spriteName)
+	if err != nil {
+		return nil, err
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return s.status(), nil
+}
+
+// List reports every session, sorted by name.
+func (e *CopyEngine) List(ctx context.Context) ([]SessionState, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	states := make([]SessionState, 0, len(e.sessions))
+	for _, s := range e.sessions {
+		states = append(states, *s.status())
+	}
+	sort.Slice(states, func(i, j int) bool { return states[i].Name < states[j].Name })
+	return states, nil
+}
+
+// Conflicts reports the destination files the last pass left alone because
+// they differ from the source, a safe mode's conflicts. The engine keeps no
+// history, so it can't tell what changed them.
+func (e *CopyEngine) Conflicts(ctx context.Context, spriteName string) ([]Conflict, error) {
+	s, err := e.session(spriteName)
+	if err != nil {
+		return nil, err
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	conflicts := make([]Conflict, len(s.conflicts))
+	for i, p := range s.conflicts {
+		conflicts[i] = Conflict{Path: p}
+	}
+	return conflicts, nil
+}
+
+// session looks up a sprite's session.
+func (e *CopyEngine) session(spriteName string) (*copySession, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	s, ok := e.sessions[spriteName]
+	if !ok {
+		return nil, fmt.Errorf("sprite %q: %w", spriteName, ErrNoSession)
+	}
+	return s, nil
+}
+
+// status fills in the fixed parts of a session's state. Callers hold e.mu.
+func (s *copySession) status() *SessionState {
+	state := s.state
+	state.Name = SessionName(s.opts.SpriteName)
+	state.MutagenID = s.id
+	state.Alpha = s.opts.LocalDir
+	state.Beta = SSHHostAlias(s.opts.SpriteName) + ":" + s.opts.RemoteDir
+	if s.paused {
+		state.Status = "paused"
+	}
+	return &state
+}
+
+// pass makes one copy in the session's direction and records the outcome.
+func (e *CopyEngine) pass(ctx context.Context, s *copySession) error {
+	stats, err := copyOnce(ctx, s.opts)
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	s.state = SessionState{Status: "watching", AlphaConnected: true, BetaConnected: err == nil}
+	s.conflicts = nil
+	if err != nil {
+		s.state.Status = "error"
+		s.state.LastError = err.Error()
+		return err
+	}
+	s.state.Conflicts = len(stats.conflicts)
+	s.conflicts = stats.conflicts
+	if len(stats.conflicts) > 0 {
+		s.state.LastError = fmt.Sprintf("%d file(s) differ on the destination and were left alone", len(stats.conflicts))
+	}
+	slog.Info("copy_sync: pass complete", "sprite", s.opts.SpriteName, "mode", s.opts.SyncMode,
+		"copied", stats.copied, "removed", stats.removed, "conflicts", len(stats.conflicts))
+	return nil
+}
+
+// copyStats summarizes one pass.
+type copyStats struct {
+	copied, removed int
+	conflicts       []string
+}
+
+// copyOnce compares both trees and copies what differs from source to
+// destination.
+func copyOnce(ctx context.Context, opts CreateOptions) (*copyStats, error) {
+	toRemote, replica, err := copyDirection(opts.SyncMode)
+	if err != nil {
+		return nil, err
+	}
+	m := newIgnoreMatcher(opts.Ignores)
+	remote := &remoteTree{alias: SSHHostAlias(opts.SpriteName), dir: opts.RemoteDir}
+
+	localFiles, err := localManifest(opts.LocalDir, m)
+	if err != nil {
+		return nil, fmt.Errorf("scanning %s: %w", opts.LocalDir, err)
+	}
+	remoteFiles, err := remote.manifest(ctx, m)
+	if err != nil {
+		return nil, fmt.Errorf("scanning remote %s: %w", opts.RemoteDir, err)
+	}
+
+	// Only same-size files need their contents compared.
+	var candidates []string
+	for p, l := range localFiles {
+		if r, ok := remoteFiles[p]; ok && r.size == l.size {
+			candidates = append(candidates, p)
+		}
+	}
+	sort.Strings(candidates)
+	if err := hashLocal(opts.LocalDir, candidates, localFiles); err != nil {
+		return nil, err
+	}
+	if err := remote.hash(ctx, candidates, remoteFiles); err != nil {
+		return nil, fmt.Errorf("hashing remote files: %w", err)
+	}
+
+	src, dst := localFiles, remoteFiles
+	if !toRemote {
+		src, dst = remoteFiles, localFiles
+	}
+	plan := planCopy(src, dst, replica)
+	stats := &copyStats{copied: len(plan.copy), removed: len(plan.remove), conflicts: plan.conflicts}
+
+	if toRemote {
+		if err := remote.push(ctx, opts.LocalDir, plan.copy); err != nil {
+			return nil, fmt.Errorf("copying to remote: %w", err)
+		}
+		if err := remote.remove(ctx, plan.remove); err != nil {
+			return nil, fmt.Errorf("removing remote files: %w", err)
+		}
+		return stats, nil
+	}
+	if err := remote.pull(ctx, opts.LocalDir, plan.copy); err != nil {
+		return nil, fmt.Errorf("copying from remote: %w", err)
+	}
+	for _, p := range plan.remove {
+		if err := os.Remove(filepath.Join(opts.LocalDir, filepath.FromSlash(p))); err != nil && !os.IsNotExist(err) {
+			return nil, fmt.Errorf("removing %s: %w", p, err)
+		}
+	}
+	return stats, nil
+}
+
+// fileEntry is a regular file in a tree manifest. hash is only filled in
+// for files whose contents need comparing.
+type fileEntry struct {
+	size int64
+	exec bool
+	hash string
+}
+
+// manifest maps slash-separated paths, relative to the sync root, to files.
+type manifest map[string]*fileEntry
+
+// same reports whether two entries hold the same file, comparing contents
+// by checksum when both have been hashed.
+func (f *fileEntry) same(o *fileEntry) bool {
+	return f.size == o.size && f.exec == o.exec && f.hash == o.hash
+}
+
+// copyPlan is what a pass will change on the destination.
+type copyPlan struct {
+	copy      []string // missing or different on the destination
+	remove    []string // on the destination only (replica mode)
+	conflicts []string // different on the destination, left alone (safe mode)
+}
+
+// planCopy works out how to bring dst in line with src. A replica copies
+// every difference and removes extras; safe mode only adds missing files
+// and reports the differing ones as conflicts.
+func planCopy(src, dst manifest, replica bool) copyPlan {
+	var plan copyPlan
+	for p, s := range src {
+		d, ok := dst[p]
+		switch {
+		case !ok:
+			plan.copy = append(plan.copy, p)
+		case s.same(d):
+		case replica:
+			plan.copy = append(plan.copy, p)
+		default:
+			plan.conflicts = append(plan.conflicts, p)
+		}
+	}
+	if replica {
+		for p := range dst {
+			if _, ok := src[p]; !ok {
+				plan.remove = append(plan.remove, p)
+			}
+		}
+	}
+	sort.Strings(plan.copy)
+	sort.Strings(plan.remove)
+	sort.Strings(plan.conflicts)
+	return plan
+}
+
+// localManifest lists the regular files under dir that m doesn't exclude.
+// A missing dir is an empty tree.
+func localManifest(dir string, m *ignoreMatcher) (manifest, error) {
+	files := make(manifest)
+	err := filepath.WalkDir(dir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if p == dir && errors.Is(err, fs.ErrNotExist) {
+				return fs.SkipAll
+			}
+			return err
+		}
+		rel, _ := filepath.Rel(dir, p)
+		if rel == "." {
+			return nil
+		}
+		rel = filepath.ToSlash(rel)
+		if m.match(rel) {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		files[rel] = &fileEntry{size: info.Size(), exec: info.Mode()&0o100 != 0}
+		return nil
+	})
+	return files, err
+}
+
+// hashLocal fills in the checksums of the named files.
+func hashLocal(dir string, paths []string, files manifest) error {
+	for _, p := range paths {
+		f, err := os.Open(filepath.Join(dir, filepath.FromSlash(p)))
+		if err != nil {
+			return fmt.Errorf("hashing %s: %w", p, err)
+		}
+		h := sha256.New()
+		_, err = io.Copy(h, f)
+		f.Close()
+		if err != nil {
+			return fmt.Errorf("hashing %s: %w", p, err)
+		}
+		files[p].hash = hex.EncodeToString(h.Sum(nil))
+	}
+	return nil
+}
+
+// remoteTree is a directory on a sprite, reached over SSH.
+type remoteTree struct {
+	alias string // SSH host alias
+	dir   string
+}
+
+// run runs a shell script in the remote directory with stdin and stdout
+// attached. The directory is passed as $1 rather than spliced into the
+// script, so it needs no escaping beyond the ssh command line's.
+func (r *remoteTree) run(ctx context.Context, script string, stdin io.Reader, stdout io.Writer) error {
+	cmd := exec.CommandContext(ctx, "ssh", "-o", "ConnectTimeout=10", r.alias,
+		"sh", "-c", shellQuote(script), "sp-sync", shellQuote(r.dir))
+	var stderr bytes.Buffer
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = stdin, stdout, &stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("%w\n%s", err, strings.TrimSpace(stderr.String()))
+	}
+	return nil
+}
+
+// manifest lists the remote regular files m doesn't exclude, pruning
+// ignored directories on the remote side where it safely can. A missing
+// directory is an empty tree.
+func (r *remoteTree) manifest(ctx context.Context, m *ignoreMatcher) (manifest, error) {
+	find := "find ."
+	if names := m.prunableNames(); len(names) > 0 {
+		var prunes []string
+		for _, n := range names {
+			prunes = append(prunes, "-name "+shellQuote(n))
+		}
+		find += ` -path . -o \( ` + strings.Join(prunes, " -o ") + ` \) -prune -o`
+	}
+	script := `cd "$1" 2>/dev/null || exit 0; ` + find + ` -type f -printf '%s %m %P\0'`
+
+	var out bytes.Buffer
+	if err := r.run(ctx, script, nil, &out); err != nil {
+		return nil, err
+	}
+	files := make(manifest)
+	for _, rec := range strings.Split(out.String(), "\x00") {
+		sizeStr, rest, ok := strings.Cut(rec, " ")
+		if !ok {
+			continue
+		}
+		modeStr, rel, ok := strings.Cut(rest, " ")
+		if !ok || m.ignored(rel) {
+			continue
+		}
+		size, err1 := strconv.ParseInt(sizeStr, 10, 64)
+		mode, err2 := strconv.ParseUint(modeStr, 8, 32)
+		if err1 != nil || err2 != nil {
+			return nil, fmt.Errorf("unexpected find output %q", rec)
+		}
+		files[rel] = &fileEntry{size: size, exec: mode&0o100 != 0}
+	}
+	return files, nil
+}
+
+// hash fills in the checksums of the named remote files.
+func (r *remoteTree) hash(ctx context.Context, paths []string, files manifest) error {
+	if len(paths) == 0 {
+		return nil
+	}
+	var out bytes.Buffer
+	if err := r.run(ctx, `cd "$1" && xargs -0 -r sha256sum -z`, nulList(paths), &out); err != nil {
+		return err
+	}
+	for _, rec := range strings.Split(out.String(), "\x00") {
+		sum, rel, ok := strings.Cut(rec, "  ")
+		if !ok {
+			continue
+		}
+		if f, ok := files[rel]; ok {
+			f.hash = sum
+		}
+	}
+	return nil
+}
+
+// push streams the named local files into the remote directory.
+func (r *remoteTree) push(ctx context.Context, localDir string, paths []string) error {
+	if len(paths) == 0 {
+		return nil
+	}
+	pr, pw := io.Pipe()
+	go func() {
+		pw.CloseWithError(writeTar(pw, localDir, paths))
+	}()
+	err := r.run(ctx, `mkdir -p "$1" && cd "$1" && tar xf -`, pr, io.Discard)
+	pr.Close()
+	return err
+}
+
+// pull streams the named remote files into the local directory.
+func (r *remoteTree) pull(ctx context.Context, localDir string, paths []string) error {
+	if len(paths) == 0 {
+		return nil
+	}
+	pr, pw := io.Pipe()
+	extracted := make(chan error, 1)
+	go func() {
+		err := extractTar(pr, localDir)
+		// Drain tar's end-of-archive padding so ssh doesn't fail writing it.
+		io.Copy(io.Discard, pr)
+		extracted <- err
+	}()
+	err := r.run(ctx, `cd "$1" && tar cf - --null -T -`, nulList(paths), pw)
+	pw.CloseWithError(err)
+	if extractErr := <-extracted; err == nil {
+		err = extractErr
+	}
+	return err
+}
+
+// remove deletes the named remote files.
+func (r *remoteTree) remove(ctx context.Context, paths []string) error {
+	if len(paths) == 0 {
+		return nil
+	}
+	return r.run(ctx, `cd "$1" && xargs -0 -r rm -f --`, nulList(paths), io.Discard)
+}
+
+// stat describes the named remote paths, without following symlinks. Paths
+// that don't exist are left out.
+func (r *remoteTree) stat(ctx context.Context, paths []string) (map[string]ConflictFile, error) {
+	files := make(map[string]ConflictFile)
+	if len(paths) == 0 {
+		return files, nil
+	}
+	// stat fails for missing paths, and xargs with it; only an unreadable
+	// directory is an error.
+	script := `cd "$1" 2>/dev/null || exit 0; xargs -0 -r stat --printf '%F\t%s\t%a\t%Y\t%n\0' -- 2>/dev/null; exit 0`
+	var out bytes.Buffer
+	if err := r.run(ctx, script, nulList(paths), &out); err != nil {
+		return nil, err
+	}
+	for _, rec := range strings.Split(out.String(), "\x00") {
+		fields := strings.SplitN(rec, "\t", 5)
+		if len(fields) != 5 {
+			continue
+		}
+		size, err1 := strconv.ParseInt(fields[1], 10, 64)
+		mode, err2 := strconv.ParseUint(fields[2], 8, 32)
+		mtime, err3 := strconv.ParseInt(fields[3], 10, 64)
+		if err1 != nil || err2 != nil || err3 != nil {
+			return nil, fmt.Errorf("unexpected stat output %q", rec)
+		}
+		kind := "other"
+		switch {
+		case strings.HasPrefix(fields[0], "regular"):
+			kind = "file"
+		case fields[0] == "directory":
+			kind = "directory"
+		case fields[0] == "symbolic link":
+			kind = "symlink"
+		}
+		files[fields[4]] = ConflictFile{Kind: kind, Size: size, Mode: fs.FileMode(mode).Perm(), ModTime: time.Unix(mtime, 0)}
+	}
+	return files, nil
+}
+
+// read returns the contents of a remote file.
+func (r *remoteTree) read(ctx context.Context, path string) ([]byte, error) {
+	var out bytes.Buffer
+	if err := r.run(ctx, `cd "$1" && xargs -0 cat --`, nulList([]string{path}), &out); err != nil {
+		return nil, err
+	}
+	return out.Bytes(), nil
+}
+
+// writeTar writes the named files under dir as a tar stream.
+func writeTar(w io.Writer, dir string, paths []string) error {
+	tw := tar.NewWriter(w)
+	for _, p := range paths {
+		if err := addTarFile(tw, dir, p); err != nil {
+			return err
+		}
+	}
+	return tw.Close()
+}
+
+func addTarFile(tw *tar.Writer, dir, rel string) error {
+	f, err := os.Open(filepath.Join(dir, filepath.FromSlash(rel)))
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	header, err := tar.FileInfoHeader(info, "")
+	if err != nil {
+		return err
+	}
+	header.Name = rel
+	if err := tw.WriteHeader(header); err != nil {
+		return err
+	}
+	_, err = io.Copy(tw, f)
+	return err
+}
+
+// extractTar writes the regular files in a tar stream under dir, replacing
+// each file atomically. Entries that would land outside dir are rejected.
+func extractTar(r io.Reader, dir string) error {
+	tr := tar.NewReader(r)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if header.Typeflag != tar.TypeReg {
+			continue
+		}
+		if !filepath.IsLocal(header.Name) {
+			return fmt.Errorf("refusing to extract %q outside %s", header.Name, dir)
+		}
+		dest := filepath.Join(dir, filepath.FromSlash(header.Name))
+		if err := os.MkdirAll(filepath.Dir(dest), 0o755); err != nil {
+			return err
+		}
+		tmp, err := os.CreateTemp(filepath.Dir(dest), ".sp-sync-*")
+		if err != nil {
+			return err
+		}
+		_, err = io.Copy(tmp, tr)
+		if closeErr := tmp.Close(); err == nil {
+			err = closeErr
+		}
+		if err == nil {
+			err = os.Chmod(tmp.Name(), fs.FileMode(header.Mode).Perm())
+		}
+		if err == nil {
+			err = os.Rename(tmp.Name(), dest)
+		}
+		if err != nil {
+			os.Remove(tmp.Name())
+			return fmt.Errorf("writing %s: %w", header.Name, err)
+		}
+	}
+}
+
+// nulList joins paths into NUL-terminated records, for xargs -0 and tar --null.
+func nulList(paths []string) io.Reader {
+	var b bytes.Buffer
+	for _, p := range paths {
+		b.WriteString(p)
+		b.WriteByte(0)
+	}
+	return &b
+}
+
+// shellQuote wraps a string in single quotes for safe interpolation into a
+// remote shell command line, escaping embedded single quotes.
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
@@ -0,0 +1,146 @@
+package sync
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/jphenow/sp/internal/fakebin"
+	"github.com/jphenow/sp/internal/sprite"
+)
+
+func TestMain(m *testing.M) { fakebin.Main(m) }
+
+func TestPlanCopy(t *testing.T) {
+	src := manifest{
+		"same.go":    {size: 3, hash: "aa"},
+		"changed.go": {size: 3, hash: "bb"},
+		"longer.go":  {size: 9},
+		"new.go":     {size: 1},
+		"run.sh":     {size: 4, hash: "cc", exec: true},
+	}
+	dst := manifest{
+		"same.go":    {size: 3, hash: "aa"},
+		"changed.go": {size: 3, hash: "b0"},
+		"longer.go":  {size: 2},
+		"run.sh":     {size: 4, hash: "cc"},
+		"stale.go":   {size: 5},
+	}
+
+	replica := planCopy(src, dst, true)
+	if got := fmt.Sprint(replica.copy); got != "[changed.go longer.go new.go run.sh]" {
+		t.Errorf("replica copy = %s", got)
+	}
+	if got := fmt.Sprint(replica.remove); got != "[stale.go]" {
+		t.Errorf("replica remove = %s", got)
+	}
+	if len(replica.conflicts) != 0 {
+		t.Errorf("replica conflicts = %v, want none", replica.conflicts)
+	}
+
+	safe := planCopy(src, dst, false)
+	if got := fmt.Sprint(safe.copy); got != "[new.go]" {
+		t.Errorf("safe copy = %s", got)
+	}
+	if len(safe.remove) != 0 {
+		t.Errorf("safe remove = %v, want none", safe.remove)
+	}
+	if got := fmt.Sprint(safe.conflicts); got != "[changed.go longer.go run.sh]" {
+		t.Errorf("safe conflicts = %s", got)
+	}
+}
+
+func TestCopyEngineRejectsTwoWay(t *testing.T) {
+	_, err := NewCopyEngine().Create(context.Background(), CreateOptions{SpriteName: "x", SyncMode: ""})
+	if !errors.Is(err, ErrOneWayOnly) {
+		t.Errorf("Create(two-way) = %v, want ErrOneWayOnly", err)
+	}
+}
+
+func TestCopyEngineEndToEnd(t *testing.T) {
+	if testing.Short() {
+		t.Skip("end-to-end copy test")
+	}
+	env := fakebin.New(t)
+	const name = "copy-engine"
+	env.AddSprite(name, "running")
+	mgr := NewManager(sprite.NewClient(""), NewCopyEngine())
+	if err := mgr.SetupSSHServer(name); err != nil {
+		t.Fatalf("SetupSSHServer: %v", err)
+	}
+	proxy, port, err := mgr.StartProxy(name)
+	if err != nil {
+		t.Fatalf("StartProxy: %v", err)
+	}
+	t.Cleanup(func() { proxy.Process.Kill(); proxy.Wait() })
+	if err := AddSSHConfig(name, port); err != nil {
+		t.Fatalf("AddSSHConfig: %v", err)
+	}
+
+	local := t.TempDir()
+	write := func(dir, rel, content string, mode os.FileMode) {
+		t.Helper()
+		p := filepath.Join(dir, rel)
+		os.MkdirAll(filepath.Dir(p), 0o755)
+		if err := os.WriteFile(p, []byte(content), mode); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write(local, "main.go", "package main\n", 0o644)
+	write(local, "bin/run.sh", "#!/bin/sh\n", 0o755)
+	write(local, "it's here.txt", "quoted\n", 0o644)
+	write(local, "node_modules/dep/index.js", "ignored\n", 0o644)
+	write(local, ".gitignore", "*.log\n", 0o644)
+	write(local, "debug.log", "ignored\n", 0o644)
+	remote := env.Path(name, "/home/sprite/proj")
+	write(remote, "stale.go", "old\n", 0o644)
+	write(remote, "node_modules/keep.js", "remote only\n", 0o644)
+
+	ctx := context.Background()
+	engine := mgr.engine.(*CopyEngine)
+	if _, err := mgr.StartSession(name, local, "/home/sprite/proj", "one-way-replica-to-remote"); err != nil {
+		t.Fatalf("replica push: %v", err)
+	}
+	for _, rel := range []string{"main.go", "bin/run.sh", "it's here.txt", ".gitignore", "node_modules/keep.js"} {
+		if _, err := os.Stat(filepath.Join(remote, rel)); err != nil {
+			t.Errorf("%s missing on remote: %v", rel, err)
+		}
+	}
+	for _, rel := range []string{"stale.go", "debug.log", "node_modules/dep/index.js"} {
+		if _, err := os.Stat(filepath.Join(remote, rel)); err == nil {
+			t.Errorf("%s should not be on remote", rel)
+		}
+	}
+	if info, err := os.Stat(filepath.Join(remote, "bin/run.sh")); err == nil && info.Mode()&0o100 == 0 {
+		t.Error("executable bit lost")
+	}
+
+	// Safe pull: new remote files come down, a locally edited one is left
+	// alone and reported.
+	if err := engine.Terminate(ctx, name); err != nil {
+		t.Fatalf("Terminate: %v", err)
+	}
+	write(remote, "from-remote.go", "package main // remote\n", 0o644)
+	write(remote, "main.go", "package main // edited remotely\n", 0o644)
+	if _, err := mgr.StartSession(name, local, "/home/sprite/proj", "one-way-safe-to-local"); err != nil {
+		t.Fatalf("safe pull: %v", err)
+	}
+	if data, _ := os.ReadFile(filepath.Join(local, "from-remote.go")); string(data) != "package main // remote\n" {
+		t.Errorf("from-remote.go = %q", data)
+	}
+	if data, _ := os.ReadFile(filepath.Join(local, "main.go")); string(data) != "package main\n" {
+		t.Errorf("safe pull overwrote main.go: %q", data)
+	}
+	state, err := engine.Status(ctx, name)
+	if err != nil || state.Conflicts != 1 {
+		t.Errorf("Status = %+v, %v; want one conflict", state, err)
+	}
+
+	// Flush re-runs the pass; with nothing new, it changes nothing.
+	if err := engine.Flush(ctx, name); err != nil {
+		t.Errorf("Flush: %v", err)
+	}
+}
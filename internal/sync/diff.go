@@ -0,0 +1,224 @@
+package sync
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+)
+
+// diffContext is the number of unchanged lines around each hunk of a
+// unified diff.
+const diffContext = 3
+
+// maxDiffCells bounds the line-matching table diffLines builds (4 bytes a
+// cell). Changes too big for it are shown as every old line removed and
+// every new line added, which is still a correct diff, just not a minimal one.
+const maxDiffCells = 4 << 20
+
+// IsBinary reports whether data looks like a binary file: whether it has a
+// NUL byte in its first 8000 bytes, the test git uses.
+func IsBinary(data []byte) bool {
+	return bytes.IndexByte(data[:min(len(data), 8000)], 0) >= 0
+}
+
+// UnifiedDiff returns the changes from a to b as a unified diff, or "" if
+// they're the same.
+func UnifiedDiff(aName, bName string, a, b []byte) string {
+	ops := diffLines(splitLines(a), splitLines(b))
+
+	// aLines[i] and bLines[i] count the lines of a and b before ops[i].
+	aLines, bLines := make([]int, len(ops)+1), make([]int, len(ops)+1)
+	for i, op := range ops {
+		aLines[i+1], bLines[i+1] = aLines[i], bLines[i]
+		if op.kind != '+' {
+			aLines[i+1]++
+		}
+		if op.kind != '-' {
+			bLines[i+1]++
+		}
+	}
+
+	var out strings.Builder
+	for i := 0; i < len(ops); {
+		if ops[i].kind == ' ' {
+			i++
+			continue
+		}
+		// A hunk runs from diffContext lines before this change to
+		// diffContext lines after the last change that isn't separated from
+		// it by more than twice that many unchanged lines.
+		start, end := max(0, i-diffContext), i
+		for end < len(ops) {
+			if ops[end].kind != ' ' {
+				end++
+				continue
+			}
+			run := end
+			for run < len(ops) && ops[run].kind == ' ' {
+				run++
+			}
+			if run == len(ops) || run-end > 2*diffContext {
+				end = min(run, end+diffContext)
+				break
+			}
+			end = run
+		}
+
+		if out.Len() == 0 {
+			fmt.Fprintf(&out, "--- %s\n+++ %s\n", aName, bName)
+		}
+		fmt.Fprintf(&out, "@@ -%s +%s @@\n", hunkRange(aLines[start], aLines[end]), hunkRange(bLines[start], bLines[end]))
+		for _, op := range ops[start:end] {
+			out.WriteByte(op.kind)
+			out.WriteString(op.line)
+			if !strings.HasSuffix(op.line, "\n") {
+				out.WriteString("\n\\ No newline at end of file\n")
+			}
+		}
+		i = end
+	}
+	return out.String()
+}
+
+// hunkRange formats the lines from..to of one side for a hunk header.
+// An empty range names the line before it.
+func hunkRange(from, to int) string {
+	if to == from {
+		return fmt.Sprintf("%d,0", from)
+	}
+	return fmt.Sprintf("%d,%d", from+1, to-from)
+}
+
+// MergeMarkers returns the lines a and b share, with each run of lines that
+// differs between them set out between git-style conflict markers, for
+// editing into a merged file:
+//
+//	<<<<<<< local
+//	a's lines
+//	=======
+//	b's lines
+//	>>>>>>> remote
+func MergeMarkers(a, b []byte, aLabel, bLabel string) []byte {
+	var out bytes.Buffer
+	var aRun, bRun []string
+	writeLine := func(line string) {
+		out.WriteString(line)
+		if !strings.HasSuffix(line, "\n") {
+			out.WriteByte('\n')
+		}
+	}
+	flush := func() {
+		if len(aRun) == 0 && len(bRun) == 0 {
+			return
+		}
+		out.WriteString("<<<<<<< " + aLabel + "\n")
+		for _, line := range aRun {
+			writeLine(line)
+		}
+		out.WriteString("=======\n")
+		for _, line := range bRun {
+			writeLine(line)
+		}
+		out.WriteString(">>>>>>> " + bLabel + "\n")
+		aRun, bRun = nil, nil
+	}
+
+	for _, op := range diffLines(splitLines(a), splitLines(b)) {
+		switch op.kind {
+		case '-':
+			aRun = append(aRun, op.line)
+		case '+':
+			bRun = append(bRun, op.line)
+		default:
+			flush()
+			out.WriteString(op.line)
+		}
+	}
+	flush()
+	return out.Bytes()
+}
+
+// diffOp is one line of a diff: kept (' '), removed ('-') or added ('+').
+type diffOp struct {
+	kind byte
+	line string
+}
+
+// splitLines splits data after each newline. The last line has none if
+// data doesn't end with one.
+func splitLines(data []byte) []string {
+	if len(data) == 0 {
+		return nil
+	}
+	lines := strings.SplitAfter(string(data), "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
+
+// diffLines returns the edits that turn a into b, keeping as many lines as
+// it can.
+func diffLines(a, b []string) []diffOp {
+	prefix := 0
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(a)-prefix && suffix < len(b)-prefix && a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		suffix++
+	}
+
+	ops := make([]diffOp, 0, len(a)+len(b))
+	for _, line := range a[:prefix] {
+		ops = append(ops, diffOp{' ', line})
+	}
+	ops = append(ops, diffMiddle(a[prefix:len(a)-suffix], b[prefix:len(b)-suffix])...)
+	for _, line := range a[len(a)-suffix:] {
+		ops = append(ops, diffOp{' ', line})
+	}
+	return ops
+}
+
+// diffMiddle diffs the lines between a and b's common prefix and suffix
+// with a longest-common-subsequence table.
+func diffMiddle(a, b []string) []diffOp {
+	var ops []diffOp
+	n, m := len(a), len(b)
+	i, j := 0, 0
+	if n*m <= maxDiffCells {
+		// lcs[i*w+j] is the length of the longest common subsequence of
+		// a[i:] and b[j:].
+		w := m + 1
+		lcs := make([]int32, (n+1)*w)
+		for i := n - 1; i >= 0; i-- {
+			for j := m - 1; j >= 0; j-- {
+				if a[i] == b[j] {
+					lcs[i*w+j] = lcs[(i+1)*w+j+1] + 1
+				} else {
+					lcs[i*w+j] = max(lcs[(i+1)*w+j], lcs[i*w+j+1])
+				}
+			}
+		}
+		for i < n && j < m {
+			switch {
+			case a[i] == b[j]:
+				ops = append(ops, diffOp{' ', a[i]})
+				i, j = i+1, j+1
+			case lcs[(i+1)*w+j] >= lcs[i*w+j+1]:
+				ops = append(ops, diffOp{'-', a[i]})
+				i++
+			default:
+				ops = append(ops, diffOp{'+', b[j]})
+				j++
+			}
+		}
+	}
+	for ; i < n; i++ {
+		ops = append(ops, diffOp{'-', a[i]})
+	}
+	for ; j < m; j++ {
+		ops = append(ops, diffOp{'+', b[j]})
+	}
+	return ops
+}
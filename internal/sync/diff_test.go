@@ -0,0 +1,70 @@
+package sync
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestUnifiedDiff(t *testing.T) {
+	a := "one\ntwo\nthree\nfour\nfive\nsix\nseven\neight\nnine\nten\neleven\ntwelve\n"
+	b := "one\n2\nthree\nfour\nfive\nsix\nseven\neight\nnine\nten\neleven\ntwelve\nthirteen"
+	want := `--- local/f.txt
++++ remote/f.txt
+@@ -1,5 +1,5 @@
+ one
+-two
++2
+ three
+ four
+ five
+@@ -10,3 +10,4 @@
+ ten
+ eleven
+ twelve
++thirteen
+\ No newline at end of file
+`
+	if got := UnifiedDiff("local/f.txt", "remote/f.txt", []byte(a), []byte(b)); got != want {
+		t.Errorf("UnifiedDiff =\n%s\nwant\n%s", got, want)
+	}
+	if got := UnifiedDiff("a", "b", []byte(a), []byte(a)); got != "" {
+		t.Errorf("UnifiedDiff of equal files = %q, want none", got)
+	}
+	if got := UnifiedDiff("a", "b", nil, []byte("new\n")); got != "--- a\n+++ b\n@@ -0,0 +1,1 @@\n+new\n" {
+		t.Errorf("UnifiedDiff from empty = %q", got)
+	}
+}
+
+func TestMergeMarkers(t *testing.T) {
+	local := "package main\n\nconst a = 1\n\nfunc main() {}\n"
+	remote := "package main\n\nconst a = 2\nconst b = 3\n\nfunc main() {}"
+	want := strings.Join([]string{
+		"package main",
+		"",
+		"<<<<<<< local",
+		"const a = 1",
+		"=======",
+		"const a = 2",
+		"const b = 3",
+		">>>>>>> remote",
+		"",
+		"<<<<<<< local",
+		"func main() {}",
+		"=======",
+		"func main() {}",
+		">>>>>>> remote",
+		"",
+	}, "\n")
+	if got := string(MergeMarkers([]byte(local), []byte(remote), "local", "remote")); got != want {
+		t.Errorf("MergeMarkers =\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestIsBinary(t *testing.T) {
+	if IsBinary([]byte("plain text\n")) {
+		t.Error("text reported as binary")
+	}
+	if !IsBinary([]byte("\x89PNG\r\n\x1a\n\x00\x00")) {
+		t.Error("PNG header not reported as binary")
+	}
+}
@@ -0,0 +1,66 @@
+package sync
+
+import (
+	"context"
+	"errors"
+	"strings"
+)
+
+// SyncEngine runs the file sync sessions between a local directory and a
+// sprite, reached through the SSH host alias the Manager sets up. Sessions
+// are addressed by sprite name; each engine derives its own session name
+// from it (see SessionName).
+type SyncEngine interface {
+	// Create starts a session and returns its identifier.
+	Create(ctx context.Context, opts CreateOptions) (string, error)
+	// Flush blocks until pending changes have been synced.
+	Flush(ctx context.Context, spriteName string) error
+	// Reset discards the session's cached state, forcing a full rescan.
+	Reset(ctx context.Context, spriteName string) error
+	// Pause suspends syncing without tearing the session down.
+	Pause(ctx context.Context, spriteName string) error
+	// Resume restarts a paused session.
+	Resume(ctx context.Context, spriteName string) error
+	// Terminate stops and removes the session.
+	Terminate(ctx context.Context, spriteName string) error
+	// Status reports a sprite's session, or ErrNoSession if it has none.
+	Status(ctx context.Context, spriteName string) (*SessionState, error)
+	// List reports every session the engine knows about, including ones sp
+	// did not create.
+	List(ctx context.Context) ([]SessionState, error)
+	// Conflicts reports the paths the session left alone because they
+	// changed on both sides, or ErrNoSession if the sprite has none.
+	Conflicts(ctx context.Context, spriteName string) ([]Conflict, error)
+}
+
+// ErrNoSession is returned by SyncEngine.Status when the sprite has no
+// sync session.
+var ErrNoSession = errors.New("no sync session")
+
+// Conflict is a path a sync session couldn't reconcile. The changes are
+// "created", "modified" or "deleted", followed by the entry's kind when it
+// isn't a file ("created directory"), or "" when the engine can't tell.
+type Conflict struct {
+	Path         string // slash-separated, relative to the synced directories
+	LocalChange  string
+	RemoteChange string
+}
+
+// CreateOptions describes a sync session to create.
+type CreateOptions struct {
+	SpriteName string
+	LocalDir   string
+	RemoteDir  string
+	SyncMode   string   // one of the sp sync modes; "" means two-way-safe
+	Ignores    []string // gitignore-style patterns to leave out
+}
+
+// SpriteNameForSession returns the sprite a session name belongs to, and
+// false for sessions sp did not create.
+func SpriteNameForSession(sessionName string) (string, bool) {
+	name, ok := strings.CutPrefix(sessionName, "sprite-")
+	if !ok || name == "" {
+		return "", false
+	}
+	return name, true
+}
@@ -3,6 +3,7 @@ package sync
 import (
 	"bufio"
 	"os"
+	"path"
 	"path/filepath"
 	"strings"
 )
@@ -175,3 +176,128 @@ func deduplicatePatterns(patterns []string) []string {
 	}
 	return result
 }
+
+// ignoreMatcher applies ignore patterns the way Mutagen does, for syncs sp
+// runs itself: a pattern without a slash matches a file or directory name
+// at any depth, one with a slash matches the whole path from the sync root
+// ("**" spanning any number of directories), a leading "!" re-includes, and
+// the last matching pattern wins.
+type ignoreMatcher struct {
+	rules []ignoreRule
+}
+
+// ignoreRule is one parsed ignore pattern.
+type ignoreRule struct {
+	pattern  string
+	negate   bool
+	anchored bool // contains a slash, so matched against the full path
+}
+
+// newIgnoreMatcher parses patterns as returned by CollectIgnorePatterns.
+func newIgnoreMatcher(patterns []string) *ignoreMatcher {
+	m := &ignoreMatcher{}
+	for _, p := range patterns {
+		r := ignoreRule{pattern: p}
+		if strings.HasPrefix(p, "!") {
+			r.negate = true
+			r.pattern = p[1:]
+		}
+		r.pattern = strings.Trim(r.pattern, "/")
+		if r.pattern == "" {
+			continue
+		}
+		r.anchored = strings.Contains(r.pattern, "/")
+		m.rules = append(m.rules, r)
+	}
+	return m
+}
+
+// match reports whether the patterns exclude rel itself (slash-separated,
+// relative to the sync root), without looking at its parent directories.
+func (m *ignoreMatcher) match(rel string) bool {
+	ignored := false
+	for _, r := range m.rules {
+		if r.matches(rel) {
+			ignored = !r.negate
+		}
+	}
+	return ignored
+}
+
+// ignored reports whether rel is excluded, either itself or because one of
+// its parent directories is.
+func (m *ignoreMatcher) ignored(rel string) bool {
+	for i, c := range rel {
+		if c == '/' && m.match(rel[:i]) {
+			return true
+		}
+	}
+	return m.match(rel)
+}
+
+func (r ignoreRule) matches(rel string) bool {
+	if !r.anchored {
+		ok, _ := path.Match(r.pattern, path.Base(rel))
+		return ok
+	}
+	return matchSegments(strings.Split(r.pattern, "/"), strings.Split(rel, "/"))
+}
+
+// matchSegments matches a path against a pattern one directory level at a
+// time, letting a "**" segment stand for zero or more levels.
+func matchSegments(pattern, name []string) bool {
+	for len(pattern) > 0 {
+		if pattern[0] == "**" {
+			for i := 0; i <= len(name); i++ {
+				if matchSegments(pattern[1:], name[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+		if len(name) == 0 {
+			return false
+		}
+		if ok, _ := path.Match(pattern[0], name[0]); !ok {
+			return false
+		}
+		pattern, name = pattern[1:], name[1:]
+	}
+	return len(name) == 0
+}
+
+// prunableNames returns the name-only patterns a remote `find` can prune
+// while walking: ones no later "!" pattern could re-include a directory
+// they match. (Files under an excluded directory stay excluded, as in
+// ignored.) Pruning is only a shortcut; results are still filtered.
+func (m *ignoreMatcher) prunableNames() []string {
+	var names []string
+	for i, r := range m.rules {
+		if r.negate || r.anchored {
+			continue
+		}
+		prunable := true
+		for _, later := range m.rules[i+1:] {
+			if later.negate && mayMatchSameName(r.pattern, later.pattern) {
+				prunable = false
+				break
+			}
+		}
+		if prunable {
+			names = append(names, r.pattern)
+		}
+	}
+	return names
+}
+
+// mayMatchSameName reports whether some file name could match both a
+// name-only pattern and the last level of another pattern. Globs in the
+// other pattern are assumed to overlap.
+func mayMatchSameName(name, other string) bool {
+	last := path.Base(other)
+	if strings.ContainsAny(last, "*?[") {
+		return true
+	}
+	ok, _ := path.Match(name, last)
+	return ok
+}
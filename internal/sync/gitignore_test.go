@@ -1,6 +1,7 @@
 package sync
 
 import (
+	"fmt"
 	"os"
 	"path/filepath"
 	"testing"
@@ -138,3 +139,54 @@ func assertNotContains(t *testing.T, patterns []string, unwanted string) {
 		}
 	}
 }
+
+func TestIgnoreMatcher(t *testing.T) {
+	m := newIgnoreMatcher([]string{
+		"node_modules", "._*", "*.log", "!keep.log", "src/build",
+		"!.git", ".git/index.lock", ".git/refs/**/*.lock",
+	})
+	tests := []struct {
+		path string
+		want bool
+	}{
+		{"main.go", false},
+		{"node_modules", true},
+		{"web/node_modules/react/index.js", true},
+		{"._main.go", true},
+		{"logs/debug.log", true},
+		{"logs/keep.log", false},
+		{"src/build/out.o", true},
+		{"build/out.o", false},
+		{".git/HEAD", false},
+		{".git/index.lock", true},
+		{".git/refs/heads/main.lock", true},
+		{".git/refs/heads/feature/x.lock", true},
+		{".git/refs/heads/main", false},
+	}
+	for _, tt := range tests {
+		if got := m.ignored(tt.path); got != tt.want {
+			t.Errorf("ignored(%q) = %v, want %v", tt.path, got, tt.want)
+		}
+	}
+}
+
+func TestPrunableNames(t *testing.T) {
+	tests := []struct {
+		patterns []string
+		want     []string
+	}{
+		{[]string{"node_modules", "dist", "!.git", ".git/index.lock"}, []string{"node_modules", "dist"}},
+		// A later negation could re-include a directory with that name.
+		{[]string{"keep", "!src/keep"}, nil},
+		{[]string{"*.tmp", "!important.tmp"}, nil},
+		// Files under an excluded directory stay excluded.
+		{[]string{"vendor", "!vendor/keep"}, []string{"vendor"}},
+		{[]string{"src/gen", "tmp"}, []string{"tmp"}},
+	}
+	for _, tt := range tests {
+		got := newIgnoreMatcher(tt.patterns).prunableNames()
+		if fmt.Sprint(got) != fmt.Sprint(tt.want) {
+			t.Errorf("prunableNames(%q) = %q, want %q", tt.patterns, got, tt.want)
+		}
+	}
+}
@@ -14,20 +14,24 @@ import (
 	"syscall"
 	"time"
 
+	"github.com/jphenow/sp/internal/retry"
 	"github.com/jphenow/sp/internal/sprite"
 )
 
-// Manager handles Mutagen sync session lifecycle for sprite environments.
-// It manages SSH server setup, proxy processes, and Mutagen sessions.
+// Manager handles sync session lifecycle for sprite environments.
+// It manages SSH server setup, proxy processes, and sync engine sessions.
 type Manager struct {
 	client *sprite.Client
+	engine SyncEngine
 }
 
 // SessionState describes the current state of a sync session.
 type SessionState struct {
 	Name           string
 	MutagenID      string
-	Status         string // "watching", "syncing", "connecting", "error", "none"
+	Status         string // "watching", "syncing", "connecting", "paused", "error", "none"
+	Alpha          string // alpha endpoint URL, normally the local path
+	Beta           string // beta endpoint URL, normally HOST:PATH on the sprite
 	AlphaConnected bool
 	BetaConnected  bool
 	Conflicts      int
@@ -36,9 +40,10 @@ type SessionState struct {
 	ProxyPID       int
 }
 
-// NewManager creates a new sync manager wrapping the given sprite client.
-func NewManager(client *sprite.Client) *Manager {
-	return &Manager{client: client}
+// NewManager creates a new sync manager wrapping the given sprite client,
+// running sync sessions with engine.
+func NewManager(client *sprite.Client, engine SyncEngine) *Manager {
+	return &Manager{client: client, engine: engine}
 }
 
 // ComputeSSHPort derives a deterministic SSH port from a sprite name.
@@ -60,24 +65,29 @@ func SSHHostAlias(spriteName string) string {
 
 // WakeSprite ensures a sprite is running by executing a trivial command.
 // This wakes warm/cold sprites before we try to set up SSH and proxies.
-// Retries a few times because waking can take several seconds.
+// Retries under retry.SpriteReady because waking can take several seconds;
+// a missing sprite or rejected credentials fail immediately.
 func (m *Manager) WakeSprite(spriteName string) error {
 	slog.Info("wake: ensuring sprite is running", "sprite", spriteName)
-	var lastErr error
-	for attempt := 0; attempt < 5; attempt++ {
+	p := retry.SpriteReady
+	p.Retryable = func(err error) bool { return !sprite.IsPermanent(err) }
+	p.OnRetry = func(attempt int, err error, delay time.Duration) {
+		slog.Debug("wake: attempt failed, retrying", "sprite", spriteName, "attempt", attempt, "delay", delay, "error", err)
+	}
+	err := retry.Do(context.Background(), p, func(context.Context) error {
 		out, err := m.client.Exec(sprite.ExecOptions{
 			Sprite:  spriteName,
 			Command: []string{"echo", "ready"},
 		})
 		if err == nil {
 			slog.Info("wake: sprite is running", "sprite", spriteName, "output", strings.TrimSpace(string(out)))
-			return nil
 		}
-		lastErr = err
-		slog.Debug("wake: attempt failed, retrying", "sprite", spriteName, "attempt", attempt+1, "error", err)
-		time.Sleep(time.Duration(attempt+1) * 2 * time.Second)
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("waking sprite %q: %w", spriteName, err)
 	}
-	return fmt.Errorf("waking sprite %q: %w", spriteName, lastErr)
+	return nil
 }
 
 // FetchAuthLog retrieves the sshd authentication log from the sprite.
@@ -373,8 +383,8 @@ func RemoveSSHConfig(spriteName string) error {
 }
 
 // CleanupStaleSSHConfigs removes SSH config entries for sprites that no longer
-// have active Mutagen sessions. Called during daemon startup or periodic cleanup.
-func CleanupStaleSSHConfigs() ([]string, error) {
+// have active sync sessions in engine. Called during daemon startup or periodic cleanup.
+func CleanupStaleSSHConfigs(engine SyncEngine) ([]string, error) {
 	home, err := os.UserHomeDir()
 	if err != nil {
 		return nil, fmt.Errorf("getting home directory: %w", err)
@@ -396,13 +406,13 @@ func CleanupStaleSSHConfigs() ([]string, error) {
 		}
 	}
 
-	// Check each alias — if the corresponding Mutagen session doesn't exist,
+	// Check each alias — if the corresponding sync session doesn't exist,
 	// the SSH config entry is stale and should be removed
 	var removed []string
 	for _, alias := range managedAliases {
 		// Alias format is "sprite-mutagen-<name>", sprite name is after that prefix
 		spriteName := strings.TrimPrefix(alias, "sprite-mutagen-")
-		if !MutagenSessionExists(spriteName) {
+		if _, err := engine.Status(context.Background(), spriteName); err != nil {
 			if err := removeSSHConfigEntry(configPath, alias); err == nil {
 				removed = append(removed, alias)
 				slog.Info("ssh_cleanup: removed stale config entry", "alias", alias)
@@ -465,211 +475,17 @@ func removeSSHConfigEntry(configPath, alias string) error {
 	return os.WriteFile(configPath, []byte(output), 0o600)
 }
 
-// MutagenSyncMode translates our sync mode constants into Mutagen CLI flags.
-// For directional modes it also returns whether to swap alpha/beta ordering.
-// Alpha is the first positional arg (normally local), beta is the second (normally remote).
-func MutagenSyncMode(mode string) (mutagenMode string, swapAlphaBeta bool) {
-	switch mode {
-	case "one-way-replica-to-remote":
-		return "one-way-replica", false // local (alpha) -> remote (beta)
-	case "one-way-replica-to-local":
-		return "one-way-replica", true // remote (alpha) -> local (beta)
-	case "one-way-safe-to-remote":
-		return "one-way-safe", false // local (alpha) -> remote (beta)
-	case "one-way-safe-to-local":
-		return "one-way-safe", true // remote (alpha) -> local (beta)
-	default:
-		return "two-way-safe", false
-	}
-}
-
-// StartMutagenSession creates a new Mutagen sync session between localDir and the sprite.
-// The syncMode parameter controls the Mutagen sync mode; pass "" for the default two-way-safe.
-func (m *Manager) StartMutagenSession(spriteName, localDir, remoteDir, syncMode string) (string, error) {
-	sessionName := SessionName(spriteName)
-	alias := SSHHostAlias(spriteName)
-
-	// Build ignore arguments from .gitignore
-	ignorePatterns := CollectIgnorePatterns(localDir)
-	var ignoreArgs []string
-	for _, p := range ignorePatterns {
-		ignoreArgs = append(ignoreArgs, "--ignore", p)
-	}
-
-	// Resolve Mutagen mode and alpha/beta ordering
-	mutagenMode, swapAlphaBeta := MutagenSyncMode(syncMode)
-	alpha := localDir
-	beta := fmt.Sprintf("%s:%s", alias, remoteDir)
-	if swapAlphaBeta {
-		alpha, beta = beta, alpha
-	}
-
-	// Build mutagen sync create command
-	args := []string{"sync", "create",
-		"--name", sessionName,
-		"--sync-mode", mutagenMode,
-	}
-	args = append(args, ignoreArgs...)
-	args = append(args, alpha, beta)
-
-	cmd := exec.Command("mutagen", args...)
-	if out, err := cmd.CombinedOutput(); err != nil {
-		return "", fmt.Errorf("creating mutagen session: %w\n%s", err, string(out))
-	}
-
-	// Get the session identifier
-	id, err := getMutagenSessionID(sessionName)
-	if err != nil {
-		return sessionName, nil // Return name even if we can't get ID
-	}
-
-	return id, nil
-}
-
-// FlushMutagenSession flushes pending changes for a Mutagen sync session with
-// a 15-second timeout. This ensures both sides agree before tearing down the session.
-func FlushMutagenSession(spriteName string) error {
-	sessionName := SessionName(spriteName)
-	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
-	defer cancel()
-
-	cmd := exec.CommandContext(ctx, "mutagen", "sync", "flush", sessionName)
-	if out, err := cmd.CombinedOutput(); err != nil {
-		return fmt.Errorf("flushing mutagen session %q: %w\n%s", sessionName, err, string(out))
-	}
-	return nil
-}
-
-// ResetMutagenSession clears the internal snapshot for a Mutagen sync session,
-// forcing a full rescan of both alpha and beta on the next sync cycle. Use this
-// when Mutagen reports "watching" but files appear out of date — the reset
-// discards cached state so Mutagen re-evaluates every file.
-func ResetMutagenSession(spriteName string) error {
-	sessionName := SessionName(spriteName)
-	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
-	defer cancel()
-
-	cmd := exec.CommandContext(ctx, "mutagen", "sync", "reset", sessionName)
-	if out, err := cmd.CombinedOutput(); err != nil {
-		return fmt.Errorf("resetting mutagen session %q: %w\n%s", sessionName, err, string(out))
-	}
-	return nil
-}
-
-// TerminateMutagenSession stops and removes a Mutagen sync session.
-func TerminateMutagenSession(spriteName string) error {
-	sessionName := SessionName(spriteName)
-	cmd := exec.Command("mutagen", "sync", "terminate", sessionName)
-	if out, err := cmd.CombinedOutput(); err != nil {
-		return fmt.Errorf("terminating mutagen session %q: %w\n%s", sessionName, err, string(out))
-	}
-	return nil
-}
-
-// GetMutagenStatus queries the current status of a Mutagen sync session.
-func GetMutagenStatus(spriteName string) (*SessionState, error) {
-	sessionName := SessionName(spriteName)
-
-	out, err := exec.Command("mutagen", "sync", "list", sessionName).Output()
-	if err != nil {
-		return nil, fmt.Errorf("listing mutagen session %q: %w", sessionName, err)
-	}
-
-	return parseMutagenStatus(sessionName, string(out)), nil
-}
-
-// parseMutagenStatus extracts session state from mutagen sync list output.
-func parseMutagenStatus(name, output string) *SessionState {
-	state := &SessionState{
-		Name:   name,
-		Status: "none",
-	}
-
-	lines := strings.Split(output, "\n")
-	for _, line := range lines {
-		line = strings.TrimSpace(line)
-
-		if strings.HasPrefix(line, "Identifier:") {
-			state.MutagenID = strings.TrimSpace(strings.TrimPrefix(line, "Identifier:"))
-		}
-
-		if strings.HasPrefix(line, "Status:") {
-			statusText := strings.TrimSpace(strings.TrimPrefix(line, "Status:"))
-			state.Status = normalizeMutagenStatus(statusText)
-		}
-
-		if strings.HasPrefix(line, "Connected:") {
-			connected := strings.TrimSpace(strings.TrimPrefix(line, "Connected:"))
-			// This appears under Alpha and Beta sections
-			if !state.AlphaConnected {
-				state.AlphaConnected = connected == "Yes"
-			} else {
-				state.BetaConnected = connected == "Yes"
-			}
-		}
-
-		if strings.Contains(line, "conflict") || strings.Contains(line, "Conflict") {
-			// Try to extract conflict count
-			parts := strings.Fields(line)
-			for _, p := range parts {
-				if n, err := strconv.Atoi(p); err == nil && n > 0 {
-					state.Conflicts = n
-					break
-				}
-			}
-		}
-
-		if strings.HasPrefix(line, "Last error:") {
-			state.LastError = strings.TrimSpace(strings.TrimPrefix(line, "Last error:"))
-		}
-	}
-
-	return state
-}
-
-// normalizeMutagenStatus converts Mutagen's verbose status to our short form.
-func normalizeMutagenStatus(status string) string {
-	status = strings.ToLower(status)
-	switch {
-	case strings.Contains(status, "watching"):
-		return "watching"
-	case strings.Contains(status, "scanning"):
-		return "syncing"
-	case strings.Contains(status, "staging"):
-		return "syncing"
-	case strings.Contains(status, "transitioning"):
-		return "syncing"
-	case strings.Contains(status, "saving"):
-		return "syncing"
-	case strings.Contains(status, "connecting"):
-		return "connecting"
-	case strings.Contains(status, "halted"):
-		return "error"
-	default:
-		return "unknown"
-	}
-}
-
-// getMutagenSessionID gets the session identifier from mutagen sync list.
-func getMutagenSessionID(sessionName string) (string, error) {
-	out, err := exec.Command("mutagen", "sync", "list", sessionName).Output()
-	if err != nil {
-		return "", err
-	}
-	for _, line := range strings.Split(string(out), "\n") {
-		line = strings.TrimSpace(line)
-		if strings.HasPrefix(line, "Identifier:") {
-			return strings.TrimSpace(strings.TrimPrefix(line, "Identifier:")), nil
-		}
-	}
-	return "", fmt.Errorf("identifier not found in output")
-}
-
-// MutagenSessionExists checks if a named Mutagen session exists.
-func MutagenSessionExists(spriteName string) bool {
-	sessionName := SessionName(spriteName)
-	err := exec.Command("mutagen", "sync", "list", sessionName).Run()
-	return err == nil
+// StartSession creates a sync session between localDir and the sprite,
+// leaving out whatever localDir's .gitignore files exclude. The syncMode
+// parameter is one of the sp sync modes; pass "" for the default two-way-safe.
+func (m *Manager) StartSession(spriteName, localDir, remoteDir, syncMode string) (string, error) {
+	return m.engine.Create(context.Background(), CreateOptions{
+		SpriteName: spriteName,
+		LocalDir:   localDir,
+		RemoteDir:  remoteDir,
+		SyncMode:   syncMode,
+		Ignores:    CollectIgnorePatterns(localDir),
+	})
 }
 
 // TestSSHConnection verifies SSH connectivity through the proxy.
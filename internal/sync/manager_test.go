@@ -52,112 +52,20 @@ func TestSSHHostAlias(t *testing.T) {
 	}
 }
 
-func TestParseMutagenStatus(t *testing.T) {
-	output := `--------------------------------------------------------------------------------
-Name: sprite-gh-superfly--flyctl
-Identifier: sync_abc123def456
-Alpha:
-	URL: /Users/jon/workspace/superfly/flyctl
-	Connected: Yes
-Beta:
-	URL: sprite-mutagen-gh-superfly--flyctl:/home/sprite/flyctl
-	Connected: Yes
-Status: Watching for changes
---------------------------------------------------------------------------------`
-
-	state := parseMutagenStatus("sprite-gh-superfly--flyctl", output)
-
-	if state.MutagenID != "sync_abc123def456" {
-		t.Errorf("MutagenID = %q, want %q", state.MutagenID, "sync_abc123def456")
-	}
-	if state.Status != "watching" {
-		t.Errorf("Status = %q, want %q", state.Status, "watching")
-	}
-	if !state.AlphaConnected {
-		t.Error("Alpha should be connected")
-	}
-	if !state.BetaConnected {
-		t.Error("Beta should be connected")
-	}
-	if state.Conflicts != 0 {
-		t.Errorf("Conflicts = %d, want 0", state.Conflicts)
-	}
-}
-
-func TestParseMutagenStatusConnecting(t *testing.T) {
-	output := `--------------------------------------------------------------------------------
-Name: sprite-gh-jphenow--market-moves
-Identifier: sync_qUfwEyNk6L4dH0zhnn0EJbHFGNXpfu7xrFzxMiF1wav
-Alpha:
-	URL: /Users/jon/Code/market-moves
-	Connected: Yes
-Beta:
-	URL: sprite-mutagen-gh-jphenow--market-moves:/home/sprite/market-moves
-	Connected: No
-Status: Connecting to beta
---------------------------------------------------------------------------------`
-
-	state := parseMutagenStatus("sprite-gh-jphenow--market-moves", output)
-
-	if state.Status != "connecting" {
-		t.Errorf("Status = %q, want %q", state.Status, "connecting")
-	}
-	if !state.AlphaConnected {
-		t.Error("Alpha should be connected")
-	}
-	if state.BetaConnected {
-		t.Error("Beta should NOT be connected")
-	}
-}
-
-func TestParseMutagenStatusWithError(t *testing.T) {
-	output := `--------------------------------------------------------------------------------
-Name: sprite-gh-superfly--mpg-ui
-Identifier: sync_ErLuVjov2h8BTeGnDCGd0rusNjnxphXevwqK8WwL9Zf
-Alpha:
-	URL: /Users/jon/workspace/superfly/mpg-ui
-	Connected: Yes
-Beta:
-	URL: sprite-mutagen-gh-superfly--mpg-ui:/home/sprite/mpg-ui
-	Connected: No
-Last error: beta polling error: unable to receive poll response: unable to read message length: unexpected EOF
-Status: Connecting to beta
---------------------------------------------------------------------------------`
-
-	state := parseMutagenStatus("sprite-gh-superfly--mpg-ui", output)
-
-	if state.Status != "connecting" {
-		t.Errorf("Status = %q, want %q", state.Status, "connecting")
-	}
-	if state.LastError == "" {
-		t.Error("LastError should be populated")
-	}
-	if state.LastError != "beta polling error: unable to receive poll response: unable to read message length: unexpected EOF" {
-		t.Errorf("LastError = %q", state.LastError)
-	}
-}
-
-func TestNormalizeMutagenStatus(t *testing.T) {
+func TestSpriteNameForSession(t *testing.T) {
 	tests := []struct {
-		input string
-		want  string
+		session string
+		want    string
+		ok      bool
 	}{
-		{"Watching for changes", "watching"},
-		{"Scanning files", "syncing"},
-		{"Staging files on alpha", "syncing"},
-		{"Transitioning", "syncing"},
-		{"Saving archive", "syncing"},
-		{"Connecting to beta", "connecting"},
-		{"Halted on root emptied", "error"},
-		{"Something unknown", "unknown"},
+		{"sprite-gh-superfly--flyctl", "gh-superfly--flyctl", true},
+		{"sprite-", "", false},
+		{"my-other-sync", "", false},
 	}
-
 	for _, tt := range tests {
-		t.Run(tt.input, func(t *testing.T) {
-			got := normalizeMutagenStatus(tt.input)
-			if got != tt.want {
-				t.Errorf("normalizeMutagenStatus(%q) = %q, want %q", tt.input, got, tt.want)
-			}
-		})
+		got, ok := SpriteNameForSession(tt.session)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("SpriteNameForSession(%q) = %q, %v; want %q, %v", tt.session, got, ok, tt.want, tt.ok)
+		}
 	}
 }
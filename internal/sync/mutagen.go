@@ -0,0 +1,338 @@
+package sync
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// mutagenCommandTimeout bounds flush and reset, which wait on the remote
+// side and would otherwise hang for as long as a dead proxy goes unnoticed.
+const mutagenCommandTimeout = 15 * time.Second
+
+// mutagenJSONTemplate makes `mutagen sync list` print its sessions as JSON
+// instead of the human-readable listing.
+const mutagenJSONTemplate = "{{ json . }}"
+
+// MutagenEngine is the SyncEngine backed by the mutagen CLI.
+type MutagenEngine struct{}
+
+// NewMutagenEngine returns a SyncEngine that drives the mutagen CLI.
+func NewMutagenEngine() *MutagenEngine {
+	return &MutagenEngine{}
+}
+
+// MutagenInstalled reports whether the mutagen CLI is on PATH.
+func MutagenInstalled() bool {
+	_, err := exec.LookPath("mutagen")
+	return err == nil
+}
+
+// MutagenSyncMode translates our sync mode constants into Mutagen CLI flags.
+// For directional modes it also returns whether to swap alpha/beta ordering.
+// Alpha is the first positional arg (normally local), beta is the second (normally remote).
+func MutagenSyncMode(mode string) (mutagenMode string, swapAlphaBeta bool) {
+	switch mode {
+	case "one-way-replica-to-remote":
+		return "one-way-replica", false // local (alpha) -> remote (beta)
+	case "one-way-replica-to-local":
+		return "one-way-replica", true // remote (alpha) -> local (beta)
+	case "one-way-safe-to-remote":
+		return "one-way-safe", false // local (alpha) -> remote (beta)
+	case "one-way-safe-to-local":
+		return "one-way-safe", true // remote (alpha) -> local (beta)
+	default:
+		return "two-way-safe", false
+	}
+}
+
+// Create creates a Mutagen session between the local directory and the
+// sprite's SSH host alias, and returns its identifier.
+func (e *MutagenEngine) Create(ctx context.Context, opts CreateOptions) (string, error) {
+	sessionName := SessionName(opts.SpriteName)
+
+	// Resolve Mutagen mode and alpha/beta ordering
+	mutagenMode, swapAlphaBeta := MutagenSyncMode(opts.SyncMode)
+	alpha := opts.LocalDir
+	beta := fmt.Sprintf("%s:%s", SSHHostAlias(opts.SpriteName), opts.RemoteDir)
+	if swapAlphaBeta {
+		alpha, beta = beta, alpha
+	}
+
+	args := []string{"sync", "create",
+		"--name", sessionName,
+		"--sync-mode", mutagenMode,
+	}
+	for _, p := range opts.Ignores {
+		args = append(args, "--ignore", p)
+	}
+	args = append(args, alpha, beta)
+
+	if out, err := exec.CommandContext(ctx, "mutagen", args...).CombinedOutput(); err != nil {
+		return "", fmt.Errorf("creating mutagen session: %w\n%s", err, string(out))
+	}
+
+	state, err := e.Status(ctx, opts.SpriteName)
+	if err != nil || state.MutagenID == "" {
+		return sessionName, nil // Return name even if we can't get ID
+	}
+	return state.MutagenID, nil
+}
+
+// Flush flushes pending changes so both sides agree, for example before
+// tearing the session down.
+func (e *MutagenEngine) Flush(ctx context.Context, spriteName string) error {
+	ctx, cancel := context.WithTimeout(ctx, mutagenCommandTimeout)
+	defer cancel()
+	return e.session(ctx, "flush", spriteName)
+}
+
+// Reset clears the session's snapshot, forcing a full rescan of both alpha
+// and beta on the next sync cycle. Use this when Mutagen reports "watching"
+// but files appear out of date.
+func (e *MutagenEngine) Reset(ctx context.Context, spriteName string) error {
+	ctx, cancel := context.WithTimeout(ctx, mutagenCommandTimeout)
+	defer cancel()
+	return e.session(ctx, "reset", spriteName)
+}
+
+// Pause suspends the session; Mutagen keeps it and its snapshot.
+func (e *MutagenEngine) Pause(ctx context.Context, spriteName string) error {
+	return e.session(ctx, "pause", spriteName)
+}
+
+// Resume restarts a paused session.
+func (e *MutagenEngine) Resume(ctx context.Context, spriteName string) error {
+	return e.session(ctx, "resume", spriteName)
+}
+
+// Terminate stops and removes the session.
+func (e *MutagenEngine) Terminate(ctx context.Context, spriteName string) error {
+	return e.session(ctx, "terminate", spriteName)
+}
+
+// session runs a `mutagen sync <command>` that takes a single session name.
+func (e *MutagenEngine) session(ctx context.Context, command, spriteName string) error {
+	sessionName := SessionName(spriteName)
+	out, err := exec.CommandContext(ctx, "mutagen", "sync", command, sessionName).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("running mutagen sync %s on %q: %w\n%s", command, sessionName, err, string(out))
+	}
+	return nil
+}
+
+// Status reports the sprite's Mutagen session.
+func (e *MutagenEngine) Status(ctx context.Context, spriteName string) (*SessionState, error) {
+	sessionName := SessionName(spriteName)
+	sessions, err := e.list(ctx, sessionName)
+	if err != nil {
+		return nil, fmt.Errorf("listing mutagen session %q: %w", sessionName, err)
+	}
+	for _, s := range sessions {
+		if s.Name == sessionName {
+			return s.state(), nil
+		}
+	}
+	return nil, fmt.Errorf("listing mutagen session %q: %w", sessionName, ErrNoSession)
+}
+
+// List reports every Mutagen sync session.
+func (e *MutagenEngine) List(ctx context.Context) ([]SessionState, error) {
+	sessions, err := e.list(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("listing mutagen sessions: %w", err)
+	}
+	states := make([]SessionState, len(sessions))
+	for i, s := range sessions {
+		states[i] = *s.state()
+	}
+	return states, nil
+}
+
+// Conflicts reports the paths Mutagen is holding back in the sprite's session.
+func (e *MutagenEngine) Conflicts(ctx context.Context, spriteName string) ([]Conflict, error) {
+	sessionName := SessionName(spriteName)
+	sessions, err := e.list(ctx, sessionName)
+	if err != nil {
+		return nil, fmt.Errorf("listing mutagen session %q: %w", sessionName, err)
+	}
+	for _, s := range sessions {
+		if s.Name == sessionName {
+			return s.conflicts(), nil
+		}
+	}
+	return nil, fmt.Errorf("listing mutagen session %q: %w", sessionName, ErrNoSession)
+}
+
+// list runs `mutagen sync list` as JSON, for all sessions or just the
+// named ones. Naming a session that doesn't exist is ErrNoSession.
+func (e *MutagenEngine) list(ctx context.Context, names ...string) ([]mutagenSession, error) {
+	args := append([]string{"sync", "list", "--template", mutagenJSONTemplate}, names...)
+	out, err := exec.CommandContext(ctx, "mutagen", args...).Output()
+	if err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) {
+			stderr := strings.TrimSpace(string(exitErr.Stderr))
+			if strings.Contains(stderr, "unable to locate requested sessions") {
+				return nil, ErrNoSession
+			}
+			return nil, fmt.Errorf("%w\n%s", err, stderr)
+		}
+		return nil, err
+	}
+	return parseMutagenSessions(out)
+}
+
+// mutagenSession is the subset of Mutagen's JSON session model sp uses.
+type mutagenSession struct {
+	Identifier string            `json:"identifier"`
+	Name       string            `json:"name"`
+	Alpha      mutagenEndpoint   `json:"alpha"`
+	Beta       mutagenEndpoint   `json:"beta"`
+	Paused     bool              `json:"paused"`
+	Status     string            `json:"status"`
+	LastError  string            `json:"lastError"`
+	Conflicts  []mutagenConflict `json:"conflicts"`
+}
+
+// mutagenConflict is a path Mutagen's two-way-safe mode won't reconcile:
+// the changes each side made at or below Root since they last agreed.
+type mutagenConflict struct {
+	Root         string          `json:"root"`
+	AlphaChanges []mutagenChange `json:"alphaChanges"`
+	BetaChanges  []mutagenChange `json:"betaChanges"`
+}
+
+// mutagenChange is one side's change to a path; a nil Old or New means
+// the path didn't or doesn't exist.
+type mutagenChange struct {
+	Path string `json:"path"`
+	Old  *struct {
+		Kind string `json:"kind"`
+	} `json:"old"`
+	New *struct {
+		Kind string `json:"kind"`
+	} `json:"new"`
+}
+
+// mutagenEndpoint is one side of a Mutagen session.
+type mutagenEndpoint struct {
+	Protocol  string `json:"protocol"`
+	User      string `json:"user"`
+	Host      string `json:"host"`
+	Path      string `json:"path"`
+	Connected bool   `json:"connected"`
+}
+
+// url formats the endpoint the way it was given to `mutagen sync create`.
+func (ep mutagenEndpoint) url() string {
+	if ep.Protocol != "ssh" {
+		return ep.Path
+	}
+	host := ep.Host
+	if ep.User != "" {
+		host = ep.User + "@" + host
+	}
+	return host + ":" + ep.Path
+}
+
+// parseMutagenSessions decodes `mutagen sync list` JSON output. Mutagen
+// prints null rather than [] when there are no sessions.
+func parseMutagenSessions(data []byte) ([]mutagenSession, error) {
+	var sessions []mutagenSession
+	if err := json.Unmarshal(data, &sessions); err != nil {
+		return nil, fmt.Errorf("parsing mutagen output: %w", err)
+	}
+	return sessions, nil
+}
+
+// state converts a Mutagen session to our SessionState.
+func (s mutagenSession) state() *SessionState {
+	status := normalizeMutagenStatus(s.Status)
+	if s.Paused {
+		status = "paused"
+	}
+	return &SessionState{
+		Name:           s.Name,
+		MutagenID:      s.Identifier,
+		Status:         status,
+		Alpha:          s.Alpha.url(),
+		Beta:           s.Beta.url(),
+		AlphaConnected: s.Alpha.Connected,
+		BetaConnected:  s.Beta.Connected,
+		Conflicts:      len(s.Conflicts),
+		LastError:      s.LastError,
+	}
+}
+
+// conflicts converts the session's conflicts to ours, putting the local
+// endpoint's changes first whichever side of the session it is.
+func (s mutagenSession) conflicts() []Conflict {
+	conflicts := make([]Conflict, len(s.Conflicts))
+	for i, c := range s.Conflicts {
+		local, remote := describeChanges(c.Root, c.AlphaChanges), describeChanges(c.Root, c.BetaChanges)
+		if s.Alpha.Protocol != "local" {
+			local, remote = remote, local
+		}
+		conflicts[i] = Conflict{Path: c.Root, LocalChange: local, RemoteChange: remote}
+	}
+	return conflicts
+}
+
+// describeChanges summarizes one side's changes to a conflict root, e.g.
+// "deleted" or "created directory". Changes only below the root make it
+// "modified".
+func describeChanges(root string, changes []mutagenChange) string {
+	if len(changes) == 0 {
+		return ""
+	}
+	c := changes[0]
+	for _, change := range changes {
+		if change.Path == root {
+			c = change
+		}
+	}
+	if c.Path != root {
+		return "modified"
+	}
+	switch {
+	case c.Old == nil && c.New != nil:
+		return withKind("created", c.New.Kind)
+	case c.Old != nil && c.New == nil:
+		return withKind("deleted", c.Old.Kind)
+	case c.Old != nil && c.New != nil && c.Old.Kind != c.New.Kind:
+		return "replaced with " + c.New.Kind
+	case c.New != nil:
+		return withKind("modified", c.New.Kind)
+	}
+	return ""
+}
+
+// withKind appends an entry's kind to a change, unless it's a file.
+func withKind(change, kind string) string {
+	if kind == "" || kind == "file" {
+		return change
+	}
+	return change + " " + kind
+}
+
+// normalizeMutagenStatus converts Mutagen's session status to our short form.
+func normalizeMutagenStatus(status string) string {
+	switch {
+	case status == "watching":
+		return "watching"
+	case status == "scanning", status == "waiting-for-rescan", status == "reconciling",
+		strings.HasPrefix(status, "staging-"), status == "transitioning", status == "saving":
+		return "syncing"
+	case strings.HasPrefix(status, "connecting-"), status == "disconnected":
+		return "connecting"
+	case strings.HasPrefix(status, "halted-"):
+		return "error"
+	default:
+		return "unknown"
+	}
+}
@@ -0,0 +1,146 @@
+package sync
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestParseMutagenSessions(t *testing.T) {
+	output := `[{"identifier":"sync_abc123def456","version":1,"creationTime":"2026-01-05T17:02:11.123456Z","creatingVersion":"0.18.0",` +
+		`"alpha":{"protocol":"local","path":"/Users/jon/workspace/superfly/flyctl","connected":true,"scanned":true,"directories":12,"files":140},` +
+		`"beta":{"protocol":"ssh","user":"sprite","host":"sprite-mutagen-gh-superfly--flyctl","path":"/home/sprite/flyctl","connected":true,"scanned":true},` +
+		`"mode":"two-way-safe","name":"sprite-gh-superfly--flyctl","paused":false,"status":"watching","successfulCycles":3,` +
+		`"conflicts":[{"root":"go.sum","alphaChanges":[{"path":"go.sum","new":{"kind":"file","digest":"aa"}}],"betaChanges":[{"path":"go.sum","new":{"kind":"file","digest":"bb"}}]}]},` +
+		`{"identifier":"sync_ErLuVjov2h8BTeGnDCGd0rusNjnxphXevwqK8WwL9Zf","alpha":{"protocol":"local","path":"/Users/jon/workspace/superfly/mpg-ui","connected":true},` +
+		`"beta":{"protocol":"ssh","host":"sprite-mutagen-gh-superfly--mpg-ui","path":"/home/sprite/mpg-ui","connected":false},` +
+		`"name":"sprite-gh-superfly--mpg-ui","paused":false,"status":"connecting-beta",` +
+		`"lastError":"beta polling error: unable to receive poll response: unable to read message length: unexpected EOF"}]`
+
+	sessions, err := parseMutagenSessions([]byte(output))
+	if err != nil {
+		t.Fatalf("parseMutagenSessions: %v", err)
+	}
+	if len(sessions) != 2 {
+		t.Fatalf("got %d sessions, want 2", len(sessions))
+	}
+
+	watching := sessions[0].state()
+	if watching.Name != "sprite-gh-superfly--flyctl" {
+		t.Errorf("Name = %q", watching.Name)
+	}
+	if watching.MutagenID != "sync_abc123def456" {
+		t.Errorf("MutagenID = %q, want %q", watching.MutagenID, "sync_abc123def456")
+	}
+	if watching.Status != "watching" {
+		t.Errorf("Status = %q, want %q", watching.Status, "watching")
+	}
+	if watching.Alpha != "/Users/jon/workspace/superfly/flyctl" {
+		t.Errorf("Alpha = %q", watching.Alpha)
+	}
+	if want := "sprite@sprite-mutagen-gh-superfly--flyctl:/home/sprite/flyctl"; watching.Beta != want {
+		t.Errorf("Beta = %q, want %q", watching.Beta, want)
+	}
+	if !watching.AlphaConnected || !watching.BetaConnected {
+		t.Error("both endpoints should be connected")
+	}
+	if watching.Conflicts != 1 {
+		t.Errorf("Conflicts = %d, want 1", watching.Conflicts)
+	}
+
+	connecting := sessions[1].state()
+	if connecting.Status != "connecting" {
+		t.Errorf("Status = %q, want %q", connecting.Status, "connecting")
+	}
+	if want := "sprite-mutagen-gh-superfly--mpg-ui:/home/sprite/mpg-ui"; connecting.Beta != want {
+		t.Errorf("Beta = %q, want %q", connecting.Beta, want)
+	}
+	if !connecting.AlphaConnected {
+		t.Error("Alpha should be connected")
+	}
+	if connecting.BetaConnected {
+		t.Error("Beta should NOT be connected")
+	}
+	if connecting.LastError != "beta polling error: unable to receive poll response: unable to read message length: unexpected EOF" {
+		t.Errorf("LastError = %q", connecting.LastError)
+	}
+}
+
+func TestParseMutagenSessionsEmpty(t *testing.T) {
+	for _, output := range []string{"null\n", "[]\n"} {
+		sessions, err := parseMutagenSessions([]byte(output))
+		if err != nil || len(sessions) != 0 {
+			t.Errorf("parseMutagenSessions(%q) = %v, %v; want none", output, sessions, err)
+		}
+	}
+	if _, err := parseMutagenSessions([]byte("Name: sprite-x\n")); err == nil {
+		t.Error("parseMutagenSessions accepted text output")
+	}
+}
+
+func TestMutagenSessionPaused(t *testing.T) {
+	s := mutagenSession{Name: "sprite-x", Status: "disconnected", Paused: true}
+	if got := s.state().Status; got != "paused" {
+		t.Errorf("Status = %q, want %q", got, "paused")
+	}
+}
+
+func TestMutagenSessionConflicts(t *testing.T) {
+	output := `[{"name":"sprite-web","alpha":{"protocol":"local","path":"/src/web"},"beta":{"protocol":"ssh","host":"sprite-mutagen-web","path":"/home/sprite/web"},` +
+		`"conflicts":[` +
+		`{"root":"go.sum","alphaChanges":[{"path":"go.sum","old":{"kind":"file"},"new":{"kind":"file"}}],"betaChanges":[{"path":"go.sum","old":{"kind":"file"}}]},` +
+		`{"root":"docs","alphaChanges":[{"path":"docs","new":{"kind":"directory"}}],"betaChanges":[{"path":"docs/a.md","new":{"kind":"file"}}]},` +
+		`{"root":"bin","alphaChanges":[{"path":"bin","old":{"kind":"file"},"new":{"kind":"symlink"}}],"betaChanges":[]}]}]`
+	sessions, err := parseMutagenSessions([]byte(output))
+	if err != nil {
+		t.Fatalf("parseMutagenSessions: %v", err)
+	}
+	want := []Conflict{
+		{Path: "go.sum", LocalChange: "modified", RemoteChange: "deleted"},
+		{Path: "docs", LocalChange: "created directory", RemoteChange: "modified"},
+		{Path: "bin", LocalChange: "replaced with symlink", RemoteChange: ""},
+	}
+	if got := sessions[0].conflicts(); !slices.Equal(got, want) {
+		t.Errorf("conflicts() = %+v, want %+v", got, want)
+	}
+	if got := sessions[0].state().Conflicts; got != 3 {
+		t.Errorf("Conflicts = %d, want 3", got)
+	}
+
+	// A "-to-local" mode puts the sprite on the alpha side.
+	s := sessions[0]
+	s.Alpha, s.Beta = s.Beta, s.Alpha
+	if got := s.conflicts()[0]; got.LocalChange != "deleted" || got.RemoteChange != "modified" {
+		t.Errorf("swapped conflicts()[0] = %+v, want local deleted, remote modified", got)
+	}
+}
+
+func TestNormalizeMutagenStatus(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{"watching", "watching"},
+		{"scanning", "syncing"},
+		{"waiting-for-rescan", "syncing"},
+		{"reconciling", "syncing"},
+		{"staging-alpha", "syncing"},
+		{"staging-beta", "syncing"},
+		{"transitioning", "syncing"},
+		{"saving", "syncing"},
+		{"connecting-alpha", "connecting"},
+		{"connecting-beta", "connecting"},
+		{"disconnected", "connecting"},
+		{"halted-on-root-emptied", "error"},
+		{"halted-on-root-type-change", "error"},
+		{"something-new", "unknown"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			got := normalizeMutagenStatus(tt.input)
+			if got != tt.want {
+				t.Errorf("normalizeMutagenStatus(%q) = %q, want %q", tt.input, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,183 @@
+// Package synctest provides an in-memory sync.SyncEngine for tests of code
+// that drives sync sessions, such as the daemon's health monitor and
+// `sp discover`.
+package synctest
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	gosync "sync"
+
+	spSync "github.com/jphenow/sp/internal/sync"
+)
+
+// Engine is an in-memory SyncEngine. Sessions it creates start out
+// "watching"; tests change what it reports with Set. Every method call is
+// recorded as "Method sprite" for Calls.
+type Engine struct {
+	mu        gosync.Mutex
+	sessions  map[string]*spSync.SessionState // keyed by session name
+	conflicts map[string][]spSync.Conflict    // keyed by session name
+	calls     []string
+	seq       int
+
+	// Err, when set, is returned by every method.
+	Err error
+}
+
+// New returns an engine with no sessions.
+func New() *Engine {
+	return &Engine{
+		sessions:  make(map[string]*spSync.SessionState),
+		conflicts: make(map[string][]spSync.Conflict),
+	}
+}
+
+// Set adds or replaces a session, keyed by its Name (a session name such
+// as spSync.SessionName(sprite), not a sprite name).
+func (e *Engine) Set(state spSync.SessionState) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.sessions[state.Name] = &state
+}
+
+// SetConflicts replaces a session's conflicts, keyed like Set, and its
+// conflict count to match. Flush leaves them be; tests resolve them by
+// calling SetConflicts again.
+func (e *Engine) SetConflicts(sessionName string, conflicts ...spSync.Conflict) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.conflicts[sessionName] = conflicts
+	if s := e.sessions[sessionName]; s != nil {
+		s.Conflicts = len(conflicts)
+	}
+}
+
+// Calls returns the calls made so far, e.g. "Reset web".
+func (e *Engine) Calls() []string {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return append([]string(nil), e.calls...)
+}
+
+// record notes a call and returns the session for spriteName, if any.
+func (e *Engine) record(method, spriteName string) *spSync.SessionState {
+	e.calls = append(e.calls, method+" "+spriteName)
+	return e.sessions[spSync.SessionName(spriteName)]
+}
+
+// Create adds a watching session.
+func (e *Engine) Create(ctx context.Context, opts spSync.CreateOptions) (string, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.record("Create", opts.SpriteName) != nil {
+		return "", fmt.Errorf("session for %q already exists", opts.SpriteName)
+	}
+	if e.Err != nil {
+		return "", e.Err
+	}
+	e.seq++
+	id := fmt.Sprintf("sync_test%d", e.seq)
+	e.sessions[spSync.SessionName(opts.SpriteName)] = &spSync.SessionState{
+		Name:           spSync.SessionName(opts.SpriteName),
+		MutagenID:      id,
+		Status:         "watching",
+		Alpha:          opts.LocalDir,
+		Beta:           spSync.SSHHostAlias(opts.SpriteName) + ":" + opts.RemoteDir,
+		AlphaConnected: true,
+		BetaConnected:  true,
+	}
+	return id, nil
+}
+
+// Flush succeeds for any existing session.
+func (e *Engine) Flush(ctx context.Context, spriteName string) error {
+	return e.update("Flush", spriteName, nil)
+}
+
+// Reset succeeds for any existing session.
+func (e *Engine) Reset(ctx context.Context, spriteName string) error {
+	return e.update("Reset", spriteName, nil)
+}
+
+// Pause marks the session paused.
+func (e *Engine) Pause(ctx context.Context, spriteName string) error {
+	return e.update("Pause", spriteName, func(s *spSync.SessionState) { s.Status = "paused" })
+}
+
+// Resume marks the session watching again.
+func (e *Engine) Resume(ctx context.Context, spriteName string) error {
+	return e.update("Resume", spriteName, func(s *spSync.SessionState) { s.Status = "watching" })
+}
+
+// Terminate removes the session.
+func (e *Engine) Terminate(ctx context.Context, spriteName string) error {
+	return e.update("Terminate", spriteName, func(*spSync.SessionState) {
+		delete(e.sessions, spSync.SessionName(spriteName))
+		delete(e.conflicts, spSync.SessionName(spriteName))
+	})
+}
+
+// update records a call and applies fn to the sprite's session, failing
+// when there is none.
+func (e *Engine) update(method, spriteName string, fn func(*spSync.SessionState)) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	s := e.record(method, spriteName)
+	if e.Err != nil {
+		return e.Err
+	}
+	if s == nil {
+		return fmt.Errorf("%s %q: %w", method, spriteName, spSync.ErrNoSession)
+	}
+	if fn != nil {
+		fn(s)
+	}
+	return nil
+}
+
+// Status returns a copy of the sprite's session.
+func (e *Engine) Status(ctx context.Context, spriteName string) (*spSync.SessionState, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	s := e.record("Status", spriteName)
+	if e.Err != nil {
+		return nil, e.Err
+	}
+	if s == nil {
+		return nil, fmt.Errorf("status of %q: %w", spriteName, spSync.ErrNoSession)
+	}
+	state := *s
+	return &state, nil
+}
+
+// List returns every session, sorted by name.
+func (e *Engine) List(ctx context.Context) ([]spSync.SessionState, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.calls = append(e.calls, "List")
+	if e.Err != nil {
+		return nil, e.Err
+	}
+	var states []spSync.SessionState
+	for _, s := range e.sessions {
+		states = append(states, *s)
+	}
+	sort.Slice(states, func(i, j int) bool { return states[i].Name < states[j].Name })
+	return states, nil
+}
+
+// Conflicts returns the conflicts set with SetConflicts.
+func (e *Engine) Conflicts(ctx context.Context, spriteName string) ([]spSync.Conflict, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	s := e.record("Conflicts", spriteName)
+	if e.Err != nil {
+		return nil, e.Err
+	}
+	if s == nil {
+		return nil, fmt.Errorf("conflicts of %q: %w", spriteName, spSync.ErrNoSession)
+	}
+	return append([]spSync.Conflict(nil), e.conflicts[s.Name]...), nil
+}
@@ -13,11 +13,15 @@ import (
 
 	"github.com/jphenow/sp/internal/daemon"
 	"github.com/jphenow/sp/internal/store"
+	spSync "github.com/jphenow/sp/internal/sync"
 )
 
 // binaryCheckInterval is how often the TUI checks if the sp binary has changed.
 const binaryCheckInterval = 10 * time.Second
 
+// timelineLength is how many recent events the detail view shows.
+const timelineLength = 10
+
 // pollInterval is how often the TUI re-fetches the sprite list from the daemon
 // to pick up newly added/imported sprites and status changes.
 const pollInterval = 3 * time.Second
@@ -29,6 +33,7 @@ const (
 	viewDashboard view = iota
 	viewDetail
 	viewTagInput
+	viewConflicts
 )
 
 // FilterOptions holds the TUI's initial filter configuration.
@@ -36,6 +41,7 @@ type FilterOptions struct {
 	Tags       []string
 	PathPrefix string
 	NameFilter string
+	Query      string // a store query; the [f] filter edits this
 }
 
 // Model is the top-level Bubbletea model for the sp TUI.
@@ -58,6 +64,8 @@ type Model struct {
 	// Detail state
 	selectedSprite *store.Sprite
 	selectedTags   []string
+	timeline       []*store.Event // recent history of selectedSprite, oldest first
+	selectedMeta   []*store.Meta  // notes and key/value metadata on selectedSprite
 
 	// Tag input state
 	tagInput    textinput.Model
@@ -69,6 +77,15 @@ type Model struct {
 	syncMenu       bool          // when true, the sync action submenu is visible
 	syncMenuTarget *store.Sprite // sprite the sync menu applies to
 
+	// Conflict view state
+	conflictSprite   *store.Sprite
+	conflictReturn   view // the view esc goes back to
+	conflicts        []spSync.ConflictDetail
+	conflictCursor   int
+	conflictContents *spSync.ConflictContents // both sides of the selected file
+	conflictErr      error                    // why the selected file couldn't be read
+	conflictsLoading bool
+
 	// Delete confirmation state
 	confirmDelete bool   // when true, waiting for y/n to confirm delete
 	deleteName    string // name of sprite pending deletion
@@ -88,6 +105,18 @@ type spriteListMsg struct {
 	tags    map[string][]string
 }
 
+// timelineMsg is sent when a sprite's recent events have been fetched.
+type timelineMsg struct {
+	name   string
+	events []*store.Event
+}
+
+// metaMsg is sent when a sprite's metadata has been fetched.
+type metaMsg struct {
+	name string
+	meta []*store.Meta
+}
+
 // stateUpdateMsg is sent when the daemon broadcasts a state change.
 type stateUpdateMsg struct {
 	update daemon.StateUpdate
@@ -146,12 +175,14 @@ type setupResultMsg struct {
 // NewModel creates a new TUI model connected to the daemon.
 func NewModel(client *daemon.Client, opts FilterOptions) Model {
 	ti := textinput.New()
-	ti.Placeholder = "filter by name, tag, or path..."
-	ti.CharLimit = 100
+	ti.Placeholder = "filter by name, tag, or path, or a query like status:running AND NOT archived..."
+	ti.CharLimit = 200
 	ti.Width = 40
 
 	// Pre-populate filter if provided
-	if opts.NameFilter != "" {
+	if opts.Query != "" {
+		ti.SetValue(opts.Query)
+	} else if opts.NameFilter != "" {
 		ti.SetValue(opts.NameFilter)
 	}
 
@@ -227,8 +258,25 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		// Auto re-exec: the TUI replaces itself with the new binary
 		return m, m.reExec
 
+	case timelineMsg:
+		if m.selectedSprite != nil && m.selectedSprite.Name == msg.name {
+			m.timeline = msg.events
+		}
+		return m, nil
+
+	case metaMsg:
+		if m.selectedSprite != nil && m.selectedSprite.Name == msg.name {
+			m.selectedMeta = msg.meta
+		}
+		return m, nil
+
 	case stateUpdateMsg:
-		// Refresh sprite list on any state change
+		// Refresh sprite list on any state change, and the timeline and
+		// metadata if the change is to the sprite being viewed.
+		if m.currentView == viewDetail && m.selectedSprite != nil && msg.update.SpriteName == m.selectedSprite.Name {
+			name := m.selectedSprite.Name
+			return m, tea.Batch(m.fetchSprites, m.fetchTimeline(name), m.fetchMeta(name))
+		}
 		return m, m.fetchSprites
 
 	case consoleFinishedMsg:
@@ -288,6 +336,9 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		m.message = "reconnected to daemon"
 		return m, m.fetchSprites
 
+	case conflictsMsg, conflictContentsMsg, conflictResolvedMsg, mergeEditedMsg:
+		return m.updateConflicts(msg)
+
 	case errMsg:
 		m.err = msg.err
 		return m, nil
@@ -343,6 +394,11 @@ func (m Model) handleKey(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 			m.currentView = viewDashboard
 			return m, nil
 		}
+		if m.currentView == viewConflicts && msg.String() == "q" {
+			m.currentView = m.conflictReturn
+			m.conflictSprite = nil
+			return m, nil
+		}
 		return m, tea.Quit
 	}
 
@@ -355,6 +411,8 @@ func (m Model) handleKey(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 		return m.handleDashboardKey(msg)
 	case viewDetail:
 		return m.handleDetailKey(msg)
+	case viewConflicts:
+		return m.handleConflictsKey(msg)
 	}
 
 	return m, nil
@@ -375,7 +433,10 @@ func (m Model) handleDashboardKey(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 		if len(m.sprites) > 0 && m.cursor < len(m.sprites) {
 			m.selectedSprite = m.sprites[m.cursor]
 			m.selectedTags = m.tags[m.selectedSprite.Name]
+			m.timeline = nil
+			m.selectedMeta = nil
 			m.currentView = viewDetail
+			return m, tea.Batch(m.fetchTimeline(m.selectedSprite.Name), m.fetchMeta(m.selectedSprite.Name))
 		}
 	case "f":
 		m.filtering = true
@@ -443,6 +504,9 @@ func (m Model) handleDetailKey(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 	case "esc", "backspace":
 		m.currentView = viewDashboard
 	case "r":
+		if m.selectedSprite != nil {
+			return m, tea.Batch(m.fetchSprites, m.fetchTimeline(m.selectedSprite.Name), m.fetchMeta(m.selectedSprite.Name))
+		}
 		return m, m.fetchSprites
 	case "o":
 		// Open sprite URL in browser
@@ -482,15 +546,25 @@ func (m Model) handleDetailKey(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 func (m Model) handleFilterKey(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 	switch msg.String() {
 	case "enter":
+		// A bare word matches names, paths and tags, so a plain filter
+		// works as before; anything else is the query syntax.
+		query := m.filterInput.Value()
+		if _, err := store.ParseQuery(query); err != nil {
+			m.err = err
+			return m, nil
+		}
+		m.err = nil
 		m.filtering = false
 		m.filterInput.Blur()
-		m.filterOpts.NameFilter = m.filterInput.Value()
+		m.filterOpts.NameFilter = ""
+		m.filterOpts.Query = query
 		return m, m.fetchSprites
 	case "esc":
 		m.filtering = false
 		m.filterInput.Blur()
 		m.filterInput.SetValue("")
 		m.filterOpts.NameFilter = ""
+		m.filterOpts.Query = ""
 		return m, m.fetchSprites
 	}
 
@@ -504,6 +578,8 @@ func (m Model) View() string {
 	switch m.currentView {
 	case viewDetail:
 		return m.viewDetail()
+	case viewConflicts:
+		return m.viewConflicts()
 	default:
 		return m.viewDashboard()
 	}
@@ -534,6 +610,9 @@ func (m Model) viewDashboard() string {
 	if m.filterOpts.PathPrefix != "" {
 		header += fmt.Sprintf("  [prefix: %s]", m.filterOpts.PathPrefix)
 	}
+	if m.filterOpts.Query != "" {
+		header += fmt.Sprintf("  [query: %s]", m.filterOpts.Query)
+	}
 	b.WriteString(HeaderStyle.Render(header))
 	b.WriteString("\n")
 
@@ -687,6 +766,32 @@ func (m Model) viewDetail() string {
 		b.WriteString("\n")
 	}
 
+	// Notes and metadata
+	if len(m.selectedMeta) > 0 {
+		b.WriteString("\n")
+		b.WriteString(DetailLabelStyle.Render("Metadata:"))
+		b.WriteString("\n")
+		for _, meta := range m.selectedMeta {
+			b.WriteString("  " + DetailLabelStyle.Render(meta.Key+":") + "  " + DetailValueStyle.Render(meta.Value))
+			b.WriteString("\n")
+		}
+	}
+
+	// Timeline
+	if len(m.timeline) > 0 {
+		b.WriteString("\n")
+		b.WriteString(DetailLabelStyle.Render("Timeline:"))
+		b.WriteString("\n")
+		for _, e := range m.timeline {
+			line := fmt.Sprintf("  %s  %-10s %s", e.CreatedAt.Local().Format("01-02 15:04:05"), e.Kind, e.Change())
+			b.WriteString(DetailValueStyle.Render(line))
+			if e.Message != "" {
+				b.WriteString("  " + HelpStyle.Render(e.Message))
+			}
+			b.WriteString("\n")
+		}
+	}
+
 	// Sync menu
 	if m.syncMenu && m.syncMenuTarget != nil {
 		b.WriteString("\n")
@@ -718,6 +823,7 @@ func (m Model) fetchSprites() tea.Msg {
 		Tags:       m.filterOpts.Tags,
 		PathPrefix: m.filterOpts.PathPrefix,
 		NameFilter: m.filterOpts.NameFilter,
+		Query:      m.filterOpts.Query,
 	}
 
 	sprites, err := m.client.ListSprites(opts)
@@ -743,6 +849,32 @@ func (m Model) fetchSprites() tea.Msg {
 	return spriteListMsg{sprites: sprites, tags: tags}
 }
 
+// fetchTimeline returns a tea.Cmd that fetches a sprite's most recent events
+// for the detail view. Errors just leave the timeline as it was.
+func (m Model) fetchTimeline(name string) tea.Cmd {
+	client := m.client
+	return func() tea.Msg {
+		events, err := client.ListEvents(store.EventListOptions{SpriteName: name, Limit: timelineLength})
+		if err != nil {
+			return nil
+		}
+		return timelineMsg{name: name, events: events}
+	}
+}
+
+// fetchMeta returns a tea.Cmd that fetches a sprite's notes and metadata for
+// the detail view. Errors just leave them as they were.
+func (m Model) fetchMeta(name string) tea.Cmd {
+	client := m.client
+	return func() tea.Msg {
+		meta, err := client.GetMeta(name)
+		if err != nil {
+			return nil
+		}
+		return metaMsg{name: name, meta: meta}
+	}
+}
+
 // checkBinaryChanged is a tea.Cmd that compares the current binary hash to
 // the one at startup. Sends binaryChangedMsg if they differ.
 func (m Model) checkBinaryChanged() tea.Msg {
@@ -919,6 +1051,7 @@ func (m Model) renderSyncMenu() string {
 	b.WriteString("  [3] Force pull  sprite -> local  (overwrites local)\n")
 	b.WriteString("  [4] Safe push   local -> sprite  (skip conflicts)\n")
 	b.WriteString("  [5] Safe pull   sprite -> local  (skip conflicts)\n")
+	b.WriteString("  [6] Resolve conflicts file by file\n")
 	b.WriteString(HelpStyle.Render("  [esc] cancel"))
 
 	return b.String()
@@ -973,6 +1106,11 @@ func (m Model) handleSyncMenuKey(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 		m.syncMenu = false
 		m.syncMenuTarget = nil
 		return m, m.resyncWithMode(s, daemon.SyncModeOneWaySafeToLocal, "safe sprite -> local")
+	case "6":
+		// Conflict view: pick a side or merge per file
+		m.syncMenu = false
+		m.syncMenuTarget = nil
+		return m.openConflicts(s)
 	}
 	return m, nil
 }
@@ -0,0 +1,338 @@
+package tui
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+
+	"github.com/jphenow/sp/internal/store"
+	spSync "github.com/jphenow/sp/internal/sync"
+)
+
+// The conflict view lists a sprite's sync conflicts with a diff of the
+// selected file, and resolves them one at a time like `sp conflicts`.
+
+// conflictsMsg is sent when a sprite's conflicts have been fetched.
+type conflictsMsg struct {
+	name      string
+	conflicts []spSync.ConflictDetail
+	err       error
+}
+
+// conflictContentsMsg is sent when both sides of a conflicting file have
+// been fetched.
+type conflictContentsMsg struct {
+	name, path string
+	contents   *spSync.ConflictContents
+	err        error
+}
+
+// conflictResolvedMsg is sent after a conflict resolution completes.
+type conflictResolvedMsg struct {
+	name, path string
+	keep       spSync.Resolution
+	err        error
+}
+
+// mergeEditedMsg is sent when the editor opened on a merge exits.
+type mergeEditedMsg struct {
+	name, path string
+	file       string // the merge file, removed once read
+	err        error
+}
+
+// openConflicts switches to the conflict view for a sprite.
+func (m Model) openConflicts(s *store.Sprite) (tea.Model, tea.Cmd) {
+	m.conflictReturn = m.currentView
+	m.currentView = viewConflicts
+	m.conflictSprite = s
+	m.conflicts = nil
+	m.conflictCursor = 0
+	m.conflictContents = nil
+	m.conflictErr = nil
+	m.conflictsLoading = true
+	return m, m.fetchConflicts(s.Name)
+}
+
+// fetchConflicts lists a sprite's conflicts through the daemon.
+func (m Model) fetchConflicts(name string) tea.Cmd {
+	return func() tea.Msg {
+		conflicts, err := m.client.ListConflicts(name)
+		return conflictsMsg{name: name, conflicts: conflicts, err: err}
+	}
+}
+
+// fetchConflictContents reads both sides of a conflicting file.
+func (m Model) fetchConflictContents(name, path string) tea.Cmd {
+	return func() tea.Msg {
+		contents, err := m.client.ReadConflict(name, path)
+		return conflictContentsMsg{name: name, path: path, contents: contents, err: err}
+	}
+}
+
+// resolveConflict keeps one version of a conflicting file.
+func (m Model) resolveConflict(name, path string, keep spSync.Resolution, merged []byte) tea.Cmd {
+	return func() tea.Msg {
+		err := m.client.ResolveConflict(name, path, keep, merged)
+		return conflictResolvedMsg{name: name, path: path, keep: keep, err: err}
+	}
+}
+
+// selectedConflict returns the conflict under the cursor, or nil.
+func (m Model) selectedConflict() *spSync.ConflictDetail {
+	if m.conflictCursor < 0 || m.conflictCursor >= len(m.conflicts) {
+		return nil
+	}
+	return &m.conflicts[m.conflictCursor]
+}
+
+// selectConflict moves the cursor and fetches the newly selected file.
+func (m Model) selectConflict(i int) (tea.Model, tea.Cmd) {
+	m.conflictCursor = i
+	m.conflictContents = nil
+	m.conflictErr = nil
+	if c := m.selectedConflict(); c != nil {
+		return m, m.fetchConflictContents(m.conflictSprite.Name, c.Path)
+	}
+	return m, nil
+}
+
+// updateConflicts handles the conflict view's messages. Replies for a
+// sprite or file no longer shown are dropped.
+func (m Model) updateConflicts(msg tea.Msg) (tea.Model, tea.Cmd) {
+	if m.conflictSprite == nil {
+		return m, nil
+	}
+	name := m.conflictSprite.Name
+
+	switch msg := msg.(type) {
+	case conflictsMsg:
+		if msg.name != name {
+			return m, nil
+		}
+		m.conflictsLoading = false
+		if msg.err != nil {
+			m.err = msg.err
+			return m, nil
+		}
+		m.conflicts = msg.conflicts
+		return m.selectConflict(min(m.conflictCursor, max(0, len(m.conflicts)-1)))
+
+	case conflictContentsMsg:
+		if c := m.selectedConflict(); msg.name == name && c != nil && c.Path == msg.path {
+			m.conflictContents, m.conflictErr = msg.contents, msg.err
+		}
+		return m, nil
+
+	case conflictResolvedMsg:
+		if msg.err != nil {
+			m.err = msg.err
+			return m, nil
+		}
+		m.err = nil
+		m.message = fmt.Sprintf("Kept the %s %s", msg.keep, msg.path)
+		return m, tea.Batch(m.fetchConflicts(msg.name), m.fetchSprites)
+
+	case mergeEditedMsg:
+		defer os.Remove(msg.file)
+		if msg.err != nil {
+			m.err = fmt.Errorf("editing merge: %w", msg.err)
+			return m, nil
+		}
+		merged, err := os.ReadFile(msg.file)
+		if err != nil {
+			m.err = err
+			return m, nil
+		}
+		if bytes.Contains(merged, []byte("<<<<<<< ")) {
+			m.err = fmt.Errorf("%s still has conflict markers; not saved", msg.path)
+			return m, nil
+		}
+		return m, m.resolveConflict(msg.name, msg.path, spSync.KeepMerged, merged)
+	}
+	return m, nil
+}
+
+// canMergeConflict reports whether the selected file can be merged: both
+// sides have it, as text.
+func (m Model) canMergeConflict() bool {
+	c := m.conflictContents
+	return c != nil && c.Local.Kind == "file" && c.Remote.Kind == "file" &&
+		!spSync.IsBinary(c.LocalData) && !spSync.IsBinary(c.RemoteData)
+}
+
+// editMerge suspends the TUI and opens both versions of the selected file,
+// between conflict markers, in $EDITOR.
+func (m Model) editMerge() tea.Cmd {
+	name, c := m.conflictSprite.Name, m.conflictContents
+	f, err := os.CreateTemp("", "sp-merge-*-"+filepath.Base(c.Path))
+	if err != nil {
+		return func() tea.Msg { return errMsg{err: err} }
+	}
+	_, err = f.Write(spSync.MergeMarkers(c.LocalData, c.RemoteData, "local", "remote"))
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(f.Name())
+		return func() tea.Msg { return errMsg{err: err} }
+	}
+
+	editor := os.Getenv("EDITOR")
+	if editor == "" {
+		editor = "vi"
+	}
+	file, path := f.Name(), c.Path
+	return tea.ExecProcess(exec.Command(editor, file), func(err error) tea.Msg {
+		return mergeEditedMsg{name: name, path: path, file: file, err: err}
+	})
+}
+
+// handleConflictsKey processes keys in the conflict view.
+func (m Model) handleConflictsKey(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	c := m.selectedConflict()
+	switch msg.String() {
+	case "esc", "backspace":
+		m.currentView = m.conflictReturn
+		m.conflictSprite = nil
+	case "up", "k":
+		if m.conflictCursor > 0 {
+			return m.selectConflict(m.conflictCursor - 1)
+		}
+	case "down", "j":
+		if m.conflictCursor < len(m.conflicts)-1 {
+			return m.selectConflict(m.conflictCursor + 1)
+		}
+	case "l":
+		if c != nil {
+			return m, m.resolveConflict(m.conflictSprite.Name, c.Path, spSync.KeepLocal, nil)
+		}
+	case "r":
+		if c != nil {
+			return m, m.resolveConflict(m.conflictSprite.Name, c.Path, spSync.KeepRemote, nil)
+		}
+	case "m":
+		if c != nil && m.canMergeConflict() {
+			return m, m.editMerge()
+		}
+	case "g":
+		m.conflictsLoading = true
+		return m, m.fetchConflicts(m.conflictSprite.Name)
+	}
+	return m, nil
+}
+
+// viewConflicts renders the conflict list and the selected file's diff.
+func (m Model) viewConflicts() string {
+	s := m.conflictSprite
+	if s == nil {
+		return "No sprite selected"
+	}
+	var b strings.Builder
+
+	b.WriteString(HeaderStyle.Render(fmt.Sprintf("Conflicts: %s", s.Name)))
+	b.WriteString("\n")
+
+	switch {
+	case m.conflictsLoading && m.conflicts == nil:
+		b.WriteString(NormalRowStyle.Render("  Loading..."))
+		b.WriteString("\n")
+	case len(m.conflicts) == 0:
+		b.WriteString(NormalRowStyle.Render("  No sync conflicts."))
+		b.WriteString("\n")
+	default:
+		b.WriteString(NormalRowStyle.Render(fmt.Sprintf("  %-40s %-32s %s", "PATH", "LOCAL", "REMOTE")))
+		b.WriteString("\n")
+		for i, c := range m.conflicts {
+			cursor := "  "
+			if i == m.conflictCursor {
+				cursor = "> "
+			}
+			row := fmt.Sprintf("%s%-40s %-32s %s", cursor, c.Path,
+				c.Local.Summary(c.LocalChange), c.Remote.Summary(c.RemoteChange))
+			if i == m.conflictCursor {
+				b.WriteString(SelectedRowStyle.Render(row))
+			} else {
+				b.WriteString(NormalRowStyle.Render(row))
+			}
+			b.WriteString("\n")
+		}
+		b.WriteString("\n")
+		b.WriteString(m.renderConflictDiff(len(m.conflicts)))
+	}
+
+	if m.err != nil {
+		b.WriteString("\n")
+		b.WriteString(ErrorStyle.Render(fmt.Sprintf("Error: %v", m.err)))
+	}
+	if m.message != "" {
+		b.WriteString("\n")
+		b.WriteString(m.message)
+	}
+
+	b.WriteString("\n")
+	help := "[↑↓/jk] navigate  [l] keep local  [r] keep remote  [m] merge in $EDITOR  [g] refresh  [esc] back  [q] back"
+	b.WriteString(HelpStyle.Render(help))
+	return b.String()
+}
+
+// renderConflictDiff renders the selected file's diff, cut to fit under
+// the list when the terminal size is known.
+func (m Model) renderConflictDiff(listRows int) string {
+	c := m.conflictContents
+	switch {
+	case m.conflictErr != nil:
+		return ErrorStyle.Render("  Can't compare: "+m.conflictErr.Error()) + "\n"
+	case c == nil:
+		return NormalRowStyle.Render("  Loading diff...") + "\n"
+	case spSync.IsBinary(c.LocalData) || spSync.IsBinary(c.RemoteData):
+		return NormalRowStyle.Render("  Binary files differ") + "\n"
+	case c.Local.Exists() && c.Local.Kind != "file", c.Remote.Exists() && c.Remote.Kind != "file":
+		return NormalRowStyle.Render("  Only files can be resolved here; use the sync menu to force a side") + "\n"
+	}
+
+	localName, remoteName := "local/"+c.Path, "remote/"+c.Path
+	if !c.Local.Exists() {
+		localName = "/dev/null"
+	}
+	if !c.Remote.Exists() {
+		remoteName = "/dev/null"
+	}
+	diff := spSync.UnifiedDiff(localName, remoteName, c.LocalData, c.RemoteData)
+	if diff == "" {
+		return NormalRowStyle.Render("  The two copies are the same") + "\n"
+	}
+
+	lines := strings.Split(strings.TrimSuffix(diff, "\n"), "\n")
+	// Leave room for the header, list, help bar and messages.
+	if room := m.height - listRows - 10; m.height > 0 && len(lines) > room {
+		hidden := len(lines) - max(room, 1)
+		lines = append(lines[:max(room, 1)], fmt.Sprintf("… %d more lines", hidden))
+	}
+	add := lipgloss.NewStyle().Foreground(colorSuccess)
+	del := lipgloss.NewStyle().Foreground(colorDanger)
+	hunk := lipgloss.NewStyle().Foreground(colorSecondary)
+	var b strings.Builder
+	for _, line := range lines {
+		switch {
+		case strings.HasPrefix(line, "+++"), strings.HasPrefix(line, "---"):
+			b.WriteString(DetailValueStyle.Render(line))
+		case strings.HasPrefix(line, "+"):
+			b.WriteString(add.Render(line))
+		case strings.HasPrefix(line, "-"):
+			b.WriteString(del.Render(line))
+		case strings.HasPrefix(line, "@@"):
+			b.WriteString(hunk.Render(line))
+		default:
+			b.WriteString(NormalRowStyle.Render(line))
+		}
+		b.WriteString("\n")
+	}
+	return b.String()
+}